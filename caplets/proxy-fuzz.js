@@ -0,0 +1,9 @@
+// called after a request is proxied and there's a response
+function onResponse(req, res) {
+    if( res.ContentType.indexOf("application/json") == 0 ){
+        res.ReadBody();
+        // mutate up to 4 random bytes of the body
+        res.Body = fuzz(res.Body, 4);
+        res.Updated();
+    }
+}
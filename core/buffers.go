@@ -0,0 +1,42 @@
+package core
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+)
+
+// Buffers bigger than this are left to the GC instead of being pooled,
+// so that a single huge body doesn't stay allocated forever.
+const maxPooledBuffer = 1024 * 1024
+
+var (
+	bufferGets   = uint64(0)
+	bufferAllocs = uint64(0)
+	bufferPool   = sync.Pool{
+		New: func() interface{} {
+			atomic.AddUint64(&bufferAllocs, 1)
+			return new(bytes.Buffer)
+		},
+	}
+)
+
+// Returns an empty buffer from the pool, it must be released with
+// PutBuffer once its contents are not referenced anymore.
+func GetBuffer() *bytes.Buffer {
+	atomic.AddUint64(&bufferGets, 1)
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func PutBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufferPool.Put(buf)
+	}
+}
+
+// Number of buffers requested and of the ones which had to be allocated.
+func BufferPoolStats() (gets uint64, allocs uint64) {
+	return atomic.LoadUint64(&bufferGets), atomic.LoadUint64(&bufferAllocs)
+}
@@ -7,15 +7,27 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func Exec(executable string, args []string) (string, error) {
+	return ExecWithEnv(executable, args, nil)
+}
+
+// Same as Exec, the command inherits the environment of
+// the process plus the given KEY=VALUE variables.
+func ExecWithEnv(executable string, args []string, env []string) (string, error) {
 	path, err := exec.LookPath(executable)
 	if err != nil {
 		return "", err
 	}
 
-	raw, err := exec.Command(path, args...).CombinedOutput()
+	cmd := exec.Command(path, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	raw, err := cmd.CombinedOutput()
 	if err != nil {
 		fmt.Printf("ERROR: path=%s args=%s err=%s out='%s'\n", path, args, err, raw)
 		return "", err
@@ -46,3 +58,27 @@ func ExpandPath(path string) (string, error) {
 	}
 	return "", nil
 }
+
+// Renames the file at path to a timestamped name once fp gets bigger
+// than maxSize and reopens path, returns the file to keep writing to
+// and the name of the rotated file if the rotation happened.
+func RotateFile(fp *os.File, path string, maxSize int64) (*os.File, string, error) {
+	info, err := fp.Stat()
+	if err != nil || info.Size() < maxSize {
+		return fp, "", nil
+	}
+
+	fp.Close()
+
+	rotated := fmt.Sprintf("%s.%s", path, time.Now().Format("20060102150405"))
+	if err = os.Rename(path, rotated); err != nil {
+		rotated = ""
+		err = fmt.Errorf("Error while rotating %s: %s", path, err)
+	}
+
+	if fp, openErr := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); openErr != nil {
+		return nil, rotated, fmt.Errorf("Error while opening %s: %s", path, openErr)
+	} else {
+		return fp, rotated, err
+	}
+}
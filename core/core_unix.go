@@ -1,5 +1,26 @@
+//go:build !windows
+// +build !windows
+
 package core
 
 func Shell(cmd string) (string, error) {
 	return Exec("/bin/sh", []string{"-c", cmd})
 }
+
+// Same as Shell, with additional environment variables.
+func ShellWithEnv(cmd string, env []string) (string, error) {
+	return ExecWithEnv("/bin/sh", []string{"-c", cmd}, env)
+}
+
+// Returns a reference to the environment variable which the shell
+// expands to its value as a single argument, without parsing it,
+// quote is the quote character the reference is enclosed in, if any.
+func ShellVariable(name string, quote rune) string {
+	switch quote {
+	case '"':
+		return "${" + name + "}"
+	case '\'':
+		return "'\"${" + name + "}\"'"
+	}
+	return "\"${" + name + "}\""
+}
@@ -3,3 +3,15 @@ package core
 func Shell(cmd string) (string, error) {
 	return Exec("cmd.exe", []string{"/c", cmd})
 }
+
+// Same as Shell, with additional environment variables, delayed
+// expansion is enabled for ShellVariable references.
+func ShellWithEnv(cmd string, env []string) (string, error) {
+	return ExecWithEnv("cmd.exe", []string{"/V:ON", "/c", cmd}, env)
+}
+
+// Returns a reference to the environment variable which is expanded
+// after the command has been parsed, so its value is never executed.
+func ShellVariable(name string, quote rune) string {
+	return "!" + name + "!"
+}
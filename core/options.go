@@ -5,20 +5,54 @@ import "flag"
 type Options struct {
 	InterfaceName *string
 	Caplet        *string
+	Script        *string
 	Debug         *bool
 	Silent        *bool
 	NoHistory     *bool
+	HistoryFile   *string
+	HistoryLimit  *int
 	Commands      *string
+	NoPrompt      *bool
+	NoColors      *bool
+	JSONOutput    *bool
+	DryRun        *bool
+	Firewall      *string
+	FirewallExact *bool
+	Container     *bool
+	LogFile       *string
+	LogLevel      *string
+	LogFormat     *string
+	LogMaxSize    *int
+	EventsBuffer  *int
+	Pprof         *string
+	Plugins       *string
 }
 
 func ParseOptions() (Options, error) {
 	o := Options{
 		InterfaceName: flag.String("iface", "", "Network interface to bind to."),
 		Caplet:        flag.String("caplet", "", "Read commands from this file and execute them in the interactive session."),
+		Script:        flag.String("script", "", "Load and execute this javascript session script."),
 		Debug:         flag.Bool("debug", false, "Print debug messages."),
 		Silent:        flag.Bool("silent", false, "Suppress all logs which are not errors."),
 		NoHistory:     flag.Bool("no-history", false, "Disable history file."),
+		HistoryFile:   flag.String("history", "~/.bettercap_history", "File to save the interactive commands history to."),
+		HistoryLimit:  flag.Int("history-limit", 1000, "Maximum number of commands to keep in the history file."),
 		Commands:      flag.String("eval", "", "Run a command, used to set variables via command line."),
+		NoColors:      flag.Bool("no-colors", false, "Disable output color effects."),
+		JSONOutput:    flag.Bool("json-output", false, "Print tables and events as JSON, implies -no-colors."),
+		DryRun:        flag.Bool("dry-run", false, "Print the firewall, forwarding and packet injection changes modules would make to the network without applying them."),
+		Firewall:      flag.String("firewall", "auto", "Firewall backend to use on Linux: auto, iptables or nftables."),
+		FirewallExact: flag.Bool("firewall-exact-restore", false, "On exit, load back the firewall rulesets exactly as they were before the first change, also dropping rules other programs added meanwhile, instead of only removing the session ones."),
+		Container:     flag.Bool("container", false, "Running inside a container, skip the kernel parameters changes which are already applied and disable the modules which would only affect the container network namespace."),
+		LogFile:       flag.String("log", "", "If set, write logs to this file."),
+		LogLevel:      flag.String("log-level", "info", "Minimum level of the messages written to the -log file: debug, info, important, warning or error."),
+		LogFormat:     flag.String("log-format", "plain", "Format of the -log file: plain or json."),
+		LogMaxSize:    flag.Int("log-max-size", 10, "Rotate the -log file when it gets bigger than this size in MB, 0 to disable rotation."),
+		EventsBuffer:  flag.Int("events-buffer", 10000, "Maximum number of events kept in memory, the oldest ones are discarded."),
+		Pprof:         flag.String("pprof", "", "If set, serve the Go profiler on this address, e.g. 127.0.0.1:6060."),
+		Plugins:       flag.String("plugins", "", "Load every executable in this folder as an external module."),
+		NoPrompt:      flag.Bool("no-prompt", false, "Do not start the interactive prompt, run the -caplet and -eval commands and wait for a quit command or a signal."),
 	}
 
 	flag.Parse()
@@ -1,7 +1,7 @@
 package core
 
 // https://misc.flogisoft.com/bash/tip_colors_and_formatting
-const (
+var (
 	BOLD = "\033[1m"
 	DIM  = "\033[2m"
 
@@ -22,8 +22,8 @@ const (
 	RESET = "\033[0m"
 )
 
-const ON = GREEN + "✔" + RESET
-const OFF = RED + "✘" + RESET
+var ON = GREEN + "✔" + RESET
+var OFF = RED + "✘" + RESET
 
 const (
 	DEBUG = iota
@@ -77,3 +77,29 @@ func Green(s string) string {
 func Yellow(s string) string {
 	return W(YELLOW, s)
 }
+
+// Disable all the ANSI sequences, must be called before any
+// colored output is generated.
+func DisableColors() {
+	BOLD = ""
+	DIM = ""
+	RED = ""
+	GREEN = ""
+	BLUE = ""
+	YELLOW = ""
+	FG_BLACK = ""
+	FG_WHITE = ""
+	BG_DGRAY = ""
+	BG_RED = ""
+	BG_GREEN = ""
+	BG_YELLOW = ""
+	BG_LBLUE = ""
+	RESET = ""
+
+	ON = "✔"
+	OFF = "✘"
+
+	for level := range LogColors {
+		LogColors[level] = ""
+	}
+}
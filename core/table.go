@@ -0,0 +1,62 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// If true, tables are printed as JSON instead of ASCII art.
+var JSONOutput = false
+
+var ansiParser = regexp.MustCompile("\033\\[[0-9;]*m")
+
+func StripColors(s string) string {
+	return ansiParser.ReplaceAllString(s, "")
+}
+
+func tableAsJSON(columns []string, rows [][]string) ([]byte, error) {
+	if len(columns) == 0 {
+		plain := make([][]string, len(rows))
+		for i, row := range rows {
+			plain[i] = make([]string, len(row))
+			for j, cell := range row {
+				plain[i][j] = StripColors(cell)
+			}
+		}
+		return json.Marshal(plain)
+	}
+
+	objects := make([]map[string]string, len(rows))
+	for i, row := range rows {
+		objects[i] = make(map[string]string)
+		for j, cell := range row {
+			if j < len(columns) {
+				objects[i][columns[j]] = StripColors(cell)
+			}
+		}
+	}
+	return json.Marshal(objects)
+}
+
+// Renders rows as a table, or as a single line of JSON ( a list of
+// objects keyed by column name ) if JSONOutput is true.
+func AsTable(w io.Writer, columns []string, rows [][]string) {
+	if JSONOutput == true {
+		if raw, err := tableAsJSON(columns, rows); err == nil {
+			fmt.Fprintf(w, "%s\n", raw)
+		}
+		return
+	}
+
+	table := tablewriter.NewWriter(w)
+	if len(columns) > 0 {
+		table.SetHeader(columns)
+	}
+	table.SetColWidth(80)
+	table.AppendBulk(rows)
+	table.Render()
+}
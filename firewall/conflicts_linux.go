@@ -0,0 +1,152 @@
+package firewall
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+var (
+	iptDportParser = regexp.MustCompile(`--dport (\d+)\b`)
+	nftDportParser = regexp.MustCompile(`dport (\d+)\b`)
+)
+
+// Looks for rules of the host, typically installed by Docker or by
+// a NAT setup, which would take precedence over the redirection or
+// change how the forwarded traffic looks like.
+func iptablesConflicts(dump string, r *Redirection) []string {
+	warnings := make([]string, 0)
+	table := ""
+	port := fmt.Sprintf("%d", r.SrcPort)
+
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			table = line[1:]
+			continue
+		} else if strings.Contains(line, RuleTag) || strings.HasPrefix(line, "-A ") == false {
+			continue
+		}
+
+		chain := strings.Fields(line)[1]
+		if table == "nat" && (chain == "PREROUTING" || chain == "DOCKER") {
+			if m := iptDportParser.FindStringSubmatch(line); len(m) == 2 && m[1] == port &&
+				(strings.Contains(line, "-j DNAT") || strings.Contains(line, "-j REDIRECT")) {
+				if chain == "DOCKER" {
+					warnings = append(warnings, fmt.Sprintf("Port %s is published by a Docker container, connections addressed to this host on that port will reach the container instead of the proxy.", port))
+				} else {
+					warnings = append(warnings, fmt.Sprintf("Rule '%s' redirects port %s before ours, traffic it matches will not reach the proxy.", line, port))
+				}
+			}
+		} else if table == "nat" && chain == "POSTROUTING" && strings.Contains(line, "-j MASQUERADE") {
+			if strings.Contains(line, "-o "+r.Interface) || (strings.Contains(line, " -o ") == false && strings.Contains(line, " -s ") == false) {
+				warnings = append(warnings, fmt.Sprintf("Rule '%s' masquerades traffic leaving %s, forwarded packets of the targets will have this host address upstream.", line, r.Interface))
+			}
+		}
+	}
+
+	return warnings
+}
+
+func nftConflicts(dump string, r *Redirection) []string {
+	warnings := make([]string, 0)
+	table := ""
+	port := fmt.Sprintf("%d", r.SrcPort)
+
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "table ") {
+			table = line
+			continue
+		} else if strings.HasSuffix(table, " "+nftTable+" {") || strings.Contains(line, RuleTag) {
+			continue
+		}
+
+		if m := nftDportParser.FindStringSubmatch(line); len(m) == 2 && m[1] == port &&
+			(strings.Contains(line, "dnat") || strings.Contains(line, "redirect")) {
+			warnings = append(warnings, fmt.Sprintf("Rule '%s' redirects port %s as well, traffic it matches might not reach the proxy.", line, port))
+		} else if strings.Contains(line, "masquerade") &&
+			(strings.Contains(line, fmt.Sprintf("oifname \"%s\"", r.Interface)) || strings.Contains(line, "oifname") == false && strings.Contains(line, "saddr") == false) {
+			warnings = append(warnings, fmt.Sprintf("Rule '%s' masquerades traffic leaving %s, forwarded packets of the targets will have this host address upstream.", line, r.Interface))
+		}
+	}
+
+	return warnings
+}
+
+// Docker sets the FORWARD policy to DROP and provides the DOCKER-USER
+// chain for user rules, so forwarded traffic is accepted there instead
+// of changing the policy, which would expose the containers.
+// The same goes for IPv6 with binary set to ip6tables.
+func (f *LinuxFirewall) allowForwarding(r *Redirection, binary string) error {
+	dump, err := core.Exec(binary+"-save", []string{})
+	if err != nil {
+		// can't inspect the host rules, do what we always did
+		_, err = core.Exec(binary, []string{"-P", "FORWARD", "ACCEPT"})
+		return err
+	}
+
+	for _, warning := range iptablesConflicts(dump, r) {
+		Warn("%s", warning)
+	}
+
+	if strings.Contains(dump, ":DOCKER-USER ") == false {
+		_, err = core.Exec(binary, []string{"-P", "FORWARD", "ACCEPT"})
+		return err
+	}
+
+	rule := fmt.Sprintf("-I DOCKER-USER -i %s -j ACCEPT", r.Interface)
+	if binary == "iptables" {
+		for _, existing := range f.rules {
+			if existing == rule {
+				return nil
+			}
+		}
+
+		Warn("Docker detected, accepting traffic forwarded from %s in the DOCKER-USER chain.", r.Interface)
+		return f.AddRule(rule)
+	} else if f.rules6[rule] == true {
+		return nil
+	}
+
+	args, err := f.ruleArgs(rule, false)
+	if err != nil {
+		return err
+	} else if _, err = core.Exec(binary, args); err != nil {
+		return err
+	}
+
+	Warn("Docker detected, accepting IPv6 traffic forwarded from %s in the DOCKER-USER chain.", r.Interface)
+	f.rules6[rule] = true
+	return nil
+}
+
+func (f *NftFirewall) allowForwarding(r *Redirection) {
+	dump, err := core.Exec("nft", []string{"list", "ruleset"})
+	if err != nil {
+		return
+	}
+
+	for _, warning := range nftConflicts(dump, r) {
+		Warn("%s", warning)
+	}
+
+	// an accept in our own table would not override a drop in Docker's
+	if _, found := f.handles["forward"][r.Interface]; found == true || strings.Contains(dump, "chain DOCKER-USER {") == false {
+		return
+	}
+
+	out, err := core.Exec("nft", []string{"--echo", "--handle", "insert", "rule", "ip", "filter", "DOCKER-USER",
+		"iifname", r.Interface, "accept", "comment", fmt.Sprintf("\"%s\"", RuleTag)})
+	if err != nil {
+		Warn("Could not accept forwarded traffic in the DOCKER-USER chain: %s", err)
+		return
+	}
+
+	if m := nftHandleParser.FindStringSubmatch(out); len(m) == 2 {
+		Warn("Docker detected, accepting traffic forwarded from %s in the DOCKER-USER chain.", r.Interface)
+		f.handles["forward"][r.Interface] = m[1]
+	}
+}
@@ -0,0 +1,132 @@
+package firewall
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIptablesConflicts(t *testing.T) {
+	r := NewRedirection("eth0", "TCP", 80, "192.168.1.2", 8080)
+
+	tests := []struct {
+		name     string
+		dump     string
+		expected []string
+	}{
+		{
+			"no conflicts",
+			"*nat\n:PREROUTING ACCEPT [0:0]\n-A PREROUTING -p tcp --dport 443 -j DNAT --to-destination 10.0.0.1\nCOMMIT\n",
+			[]string{},
+		},
+		{
+			"redirection",
+			"*nat\n-A PREROUTING -p tcp -m tcp --dport 80 -j REDIRECT --to-ports 3128\nCOMMIT\n",
+			[]string{"redirects port 80 before ours"},
+		},
+		{
+			"docker",
+			"*nat\n-A DOCKER ! -i docker0 -p tcp -m tcp --dport 80 -j DNAT --to-destination 172.17.0.2:80\nCOMMIT\n",
+			[]string{"published by a Docker container"},
+		},
+		{
+			"other port",
+			"*nat\n-A PREROUTING -p tcp -m tcp --dport 8080 -j DNAT --to-destination 10.0.0.1\nCOMMIT\n",
+			[]string{},
+		},
+		{
+			"filter table",
+			"*filter\n-A PREROUTING -p tcp -m tcp --dport 80 -j DNAT --to-destination 10.0.0.1\nCOMMIT\n",
+			[]string{},
+		},
+		{
+			"our rules",
+			"*nat\n-A PREROUTING -i eth0 -p tcp -m tcp --dport 80 -m comment --comment bettercap-ng -j DNAT --to-destination 192.168.1.2:8080\nCOMMIT\n",
+			[]string{},
+		},
+		{
+			"masquerade",
+			"*nat\n-A POSTROUTING -o eth0 -j MASQUERADE\n-A POSTROUTING -j MASQUERADE\nCOMMIT\n",
+			[]string{"masquerades traffic leaving eth0", "masquerades traffic leaving eth0"},
+		},
+		{
+			"other masquerade",
+			"*nat\n-A POSTROUTING -o wlan0 -j MASQUERADE\n-A POSTROUTING -s 172.17.0.0/16 ! -o docker0 -j MASQUERADE\nCOMMIT\n",
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		warnings := iptablesConflicts(test.dump, r)
+		if len(warnings) != len(test.expected) {
+			t.Fatalf("%s: expected %d warnings, got %q", test.name, len(test.expected), warnings)
+		}
+		for i, warning := range warnings {
+			if strings.Contains(warning, test.expected[i]) == false {
+				t.Fatalf("%s: expected '%s' in '%s'", test.name, test.expected[i], warning)
+			}
+		}
+	}
+}
+
+func TestNftConflicts(t *testing.T) {
+	r := NewRedirection("eth0", "TCP", 80, "192.168.1.2", 8080)
+
+	tests := []struct {
+		name     string
+		dump     string
+		expected []string
+	}{
+		{
+			"no conflicts",
+			"table ip nat {\n\tchain prerouting {\n\t\ttcp dport 443 dnat to 10.0.0.1\n\t}\n}\n",
+			[]string{},
+		},
+		{
+			"dnat",
+			"table ip nat {\n\tchain prerouting {\n\t\ttcp dport 80 dnat to 10.0.0.1\n\t}\n}\n",
+			[]string{"redirects port 80 as well"},
+		},
+		{
+			"redirect",
+			"table inet proxy {\n\tchain prerouting {\n\t\ttcp dport 80 redirect to :3128\n\t}\n}\n",
+			[]string{"redirects port 80 as well"},
+		},
+		{
+			"other port",
+			"table ip nat {\n\tchain prerouting {\n\t\ttcp dport 8080 dnat to 10.0.0.1\n\t}\n}\n",
+			[]string{},
+		},
+		{
+			"our table",
+			"table ip bettercap {\n\tchain prerouting {\n\t\tiifname \"eth0\" tcp dport 80 dnat to 192.168.1.2:8080\n\t}\n}\n",
+			[]string{},
+		},
+		{
+			"our rules",
+			"table ip nat {\n\tchain prerouting {\n\t\ttcp dport 80 redirect to :10080 comment \"bettercap-ng\"\n\t}\n}\n",
+			[]string{},
+		},
+		{
+			"masquerade",
+			"table ip nat {\n\tchain postrouting {\n\t\toifname \"eth0\" masquerade\n\t\tmasquerade\n\t}\n}\n",
+			[]string{"masquerades traffic leaving eth0", "masquerades traffic leaving eth0"},
+		},
+		{
+			"other masquerade",
+			"table ip nat {\n\tchain postrouting {\n\t\toifname \"wlan0\" masquerade\n\t\tip saddr 172.17.0.0/16 masquerade\n\t}\n}\n",
+			[]string{},
+		},
+	}
+
+	for _, test := range tests {
+		warnings := nftConflicts(test.dump, r)
+		if len(warnings) != len(test.expected) {
+			t.Fatalf("%s: expected %d warnings, got %q", test.name, len(test.expected), warnings)
+		}
+		for i, warning := range warnings {
+			if strings.Contains(warning, test.expected[i]) == false {
+				t.Fatalf("%s: expected '%s' in '%s'", test.name, test.expected[i], warning)
+			}
+		}
+	}
+}
@@ -1,5 +1,42 @@
 package firewall
 
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Backend used by Make on platforms supporting more than
+// one, "auto" selects the best one available.
+var Backend = "auto"
+
+var Backends = []string{"auto", "iptables", "nftables"}
+
+// Selects the backend Make will use, or returns an error if unknown.
+func SetBackend(name string) error {
+	for _, backend := range Backends {
+		if backend == name {
+			Backend = name
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown firewall backend '%s', expected one of %s.", name, strings.Join(Backends, ", "))
+}
+
+// If true the rulesets are loaded back on Restore exactly as they were
+// before the first change, otherwise only the session rules are removed.
+var ExactRestore = false
+
+// If true we're inside a container, where kernel parameters
+// might not be writable even if already set by the host.
+var Container = false
+
+// Called by the backends to report rules interactions
+// which might break the MITM, without failing.
+var Warn = func(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
 type FirewallManager interface {
 	IsForwardingEnabled() bool
 	EnableForwarding(enabled bool) error
@@ -1,10 +1,8 @@
 package firewall
 
 import (
-	"bufio"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 	"regexp"
 	"strings"
@@ -13,19 +11,30 @@ import (
 )
 
 var (
-	sysCtlParser = regexp.MustCompile("([^:]+):\\s*(.+)")
-	pfFilePath   = fmt.Sprintf("/tmp/bcap_pf_%d.conf", os.Getpid())
+	sysCtlParser  = regexp.MustCompile("([^:]+):\\s*(.+)")
+	pfTokenParser = regexp.MustCompile("Token\\s*:\\s*(\\d+)")
+	pfFilePath    = fmt.Sprintf("/tmp/bcap_pf_%d.conf", os.Getpid())
 )
 
+// The default /etc/pf.conf evaluates rdr-anchor "com.apple/*", so rules
+// loaded in a sub anchor of it are active without touching the main
+// ruleset, and flushing the anchor leaves the user rules untouched.
+const pfAnchor = "com.apple/bettercap"
+
 type PfFirewall struct {
-	filename   string
-	forwarding bool
+	filename     string
+	forwarding   bool
+	token        string
+	redirections map[string]*Redirection
+	order        []string
 }
 
 func Make() FirewallManager {
 	firewall := &PfFirewall{
-		filename:   pfFilePath,
-		forwarding: false,
+		filename:     pfFilePath,
+		forwarding:   false,
+		redirections: make(map[string]*Redirection, 0),
+		order:        make([]string, 0),
 	}
 
 	firewall.forwarding = firewall.IsForwardingEnabled()
@@ -63,11 +72,11 @@ func (f PfFirewall) sysCtlWrite(param string, value string) (string, error) {
 func (f PfFirewall) IsForwardingEnabled() bool {
 	out, err := f.sysCtlRead("net.inet.ip.forwarding")
 	if err != nil {
-		log.Printf("ERROR: %s", err)
+		fmt.Fprintf(os.Stderr, "ERROR: %s\n", err)
 		return false
 	}
 
-	return strings.HasSuffix(out, ": 1")
+	return strings.Trim(out, "\r\n\t ") == "1"
 }
 
 func (f PfFirewall) enableParam(param string, enabled bool) error {
@@ -98,83 +107,145 @@ func (f PfFirewall) EnableSendRedirects(enabled bool) error {
 }
 
 func (f PfFirewall) generateRule(r *Redirection) string {
+	proto := ""
+	from := "any"
 	src_a := "any"
+	src_p := ""
 	dst_a := "any"
+	dst_p := ""
+
+	if r.Protocol != "" {
+		proto = fmt.Sprintf(" proto %s", strings.ToLower(r.Protocol))
+	}
+
+	if r.From != "" {
+		from = r.From
+	}
 
 	if r.SrcAddress != "" {
 		src_a = r.SrcAddress
 	}
 
+	if r.SrcPort > 0 {
+		src_p = fmt.Sprintf(" port %d", r.SrcPort)
+	}
+
 	if r.DstAddress != "" {
 		dst_a = r.DstAddress
 	}
 
-	return fmt.Sprintf("rdr pass on %s proto %s from any to %s port %d -> %s port %d",
-		r.Interface, r.Protocol, src_a, r.SrcPort, dst_a, r.DstPort)
-}
+	if r.DstPort > 0 {
+		dst_p = fmt.Sprintf(" port %d", r.DstPort)
+	}
 
-func (f PfFirewall) enable(enabled bool) {
-	if enabled {
-		core.Exec("pfctl", []string{"-e"})
-	} else {
-		core.Exec("pfctl", []string{"-d"})
+	rule := fmt.Sprintf("rdr pass on %s inet%s from %s to %s%s -> %s%s",
+		r.Interface, proto, from, src_a, src_p, dst_a, dst_p)
+
+	// SrcAddress and From are IPv4 addresses, v6 rules match any destination
+	if r.DstAddress6 != "" {
+		rule += fmt.Sprintf("\nrdr pass on %s inet6%s from any to any%s -> %s%s",
+			r.Interface, proto, src_p, r.DstAddress6, dst_p)
 	}
-}
 
-func (f PfFirewall) EnableRedirection(r *Redirection, enabled bool) error {
-	rule := f.generateRule(r)
+	return rule
+}
 
+// pf is reference counted with -E / -X, so it is only
+// disabled if nobody else asked for it to be enabled.
+func (f *PfFirewall) enable(enabled bool) error {
 	if enabled == true {
-		fd, err := os.OpenFile(f.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if f.token != "" {
+			return nil
+		}
+
+		out, err := core.Exec("pfctl", []string{"-E"})
 		if err != nil {
 			return err
+		} else if m := pfTokenParser.FindStringSubmatch(out); len(m) == 2 {
+			f.token = m[1]
 		}
-		defer fd.Close()
-
-		if _, err = fd.WriteString(rule + "\n"); err != nil {
+	} else if f.token != "" {
+		if _, err := core.Exec("pfctl", []string{"-X", f.token}); err != nil {
 			return err
 		}
+		f.token = ""
+	}
+
+	return nil
+}
+
+// Loads the rules of all the active redirections in the anchor.
+func (f *PfFirewall) load() error {
+	if len(f.order) == 0 {
+		_, err := core.Exec("pfctl", []string{"-a", pfAnchor, "-F", "all"})
+		os.Remove(f.filename)
+		return err
+	}
 
-		// load the rule
-		if _, err := core.Exec("pfctl", []string{"-f", f.filename}); err != nil {
+	rules := ""
+	for _, key := range f.order {
+		rules += f.generateRule(f.redirections[key]) + "\n"
+	}
+
+	if err := ioutil.WriteFile(f.filename, []byte(rules), 0600); err != nil {
+		return err
+	}
+
+	_, err := core.Exec("pfctl", []string{"-a", pfAnchor, "-f", f.filename})
+	return err
+}
+
+func (f *PfFirewall) EnableRedirection(r *Redirection, enabled bool) error {
+	rkey := r.String()
+	_, found := f.redirections[rkey]
+
+	if enabled == true {
+		if found == true {
+			return fmt.Errorf("Redirection '%s' already enabled.", rkey)
+		}
+
+		f.redirections[rkey] = r
+		f.order = append(f.order, rkey)
+
+		if err := f.load(); err != nil {
 			return err
 		}
 
-		// enable pf
-		f.enable(true)
+		return f.enable(true)
+	} else if found == false {
+		return nil
+	}
 
-	} else {
-		fd, err := os.Open(f.filename)
-		if err == nil {
-			defer fd.Close()
-
-			lines := ""
-			scanner := bufio.NewScanner(fd)
-			for scanner.Scan() {
-				line := strings.Trim(scanner.Text(), "\r\n\t ")
-				if line != rule {
-					lines += line + "\n"
-				}
-			}
-
-			if strings.Trim(lines, "\r\n\t ") == "" {
-				os.Remove(f.filename)
-				f.enable(false)
-			} else {
-				ioutil.WriteFile(f.filename, []byte(lines), 0600)
-			}
+	delete(f.redirections, rkey)
+	for i, key := range f.order {
+		if key == rkey {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			break
 		}
 	}
 
+	if err := f.load(); err != nil {
+		return err
+	} else if len(f.order) == 0 {
+		return f.enable(false)
+	}
+
 	return nil
 }
 
-func (f PfFirewall) Restore() {
-	err := f.EnableForwarding(f.forwarding)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s", err)
+func (f *PfFirewall) Restore() {
+	f.redirections = make(map[string]*Redirection, 0)
+	f.order = make([]string, 0)
+
+	if err := f.load(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
 	}
 
-	f.enable(false)
-	os.Remove(f.filename)
+	if err := f.enable(false); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+
+	if err := f.EnableForwarding(f.forwarding); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
 }
@@ -0,0 +1,98 @@
+package firewall
+
+import "fmt"
+
+// A FirewallManager which doesn't apply any change to the system, it just
+// reports what would have been done by the real one.
+type DryRunFirewall struct {
+	forwarding   bool
+	initial      bool
+	redirections map[string]*Redirection
+	rules        []string
+	report       func(format string, args ...interface{})
+}
+
+func MakeDryRun(forwarding bool, report func(format string, args ...interface{})) FirewallManager {
+	return &DryRunFirewall{
+		forwarding:   forwarding,
+		initial:      forwarding,
+		redirections: make(map[string]*Redirection, 0),
+		rules:        make([]string, 0),
+		report:       report,
+	}
+}
+
+func (f *DryRunFirewall) IsForwardingEnabled() bool {
+	return f.forwarding
+}
+
+func (f *DryRunFirewall) EnableForwarding(enabled bool) error {
+	f.report("[dry-run] Would set IPv4 packet forwarding to %v.", enabled)
+	f.forwarding = enabled
+	return nil
+}
+
+func (f *DryRunFirewall) EnableIcmpBcast(enabled bool) error {
+	f.report("[dry-run] Would set ICMP broadcast replies to %v.", enabled)
+	return nil
+}
+
+func (f *DryRunFirewall) EnableSendRedirects(enabled bool) error {
+	f.report("[dry-run] Would set ICMP send redirects to %v.", enabled)
+	return nil
+}
+
+func (f *DryRunFirewall) EnableRedirection(r *Redirection, enabled bool) error {
+	rkey := r.String()
+	_, found := f.redirections[rkey]
+
+	if enabled == true {
+		if found == true {
+			return fmt.Errorf("Redirection '%s' already enabled.", rkey)
+		}
+		f.redirections[rkey] = r
+		f.report("[dry-run] Would add firewall redirection %s.", rkey)
+	} else if found == true {
+		delete(f.redirections, rkey)
+		f.report("[dry-run] Would remove firewall redirection %s.", rkey)
+	}
+
+	return nil
+}
+
+func (f *DryRunFirewall) AddRule(rule string) error {
+	f.rules = append(f.rules, rule)
+	f.report("[dry-run] Would add firewall rule '%s'.", rule)
+	return nil
+}
+
+func (f *DryRunFirewall) DelRule(rule string) error {
+	for i, r := range f.rules {
+		if r == rule {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			f.report("[dry-run] Would remove firewall rule '%s'.", rule)
+			return nil
+		}
+	}
+	return fmt.Errorf("Rule '%s' not found.", rule)
+}
+
+func (f *DryRunFirewall) Rules() []string {
+	rules := make([]string, len(f.rules))
+	copy(rules, f.rules)
+	return rules
+}
+
+func (f *DryRunFirewall) Restore() {
+	for len(f.rules) > 0 {
+		f.DelRule(f.rules[0])
+	}
+
+	for _, r := range f.redirections {
+		f.EnableRedirection(r, false)
+	}
+
+	if f.forwarding != f.initial {
+		f.EnableForwarding(f.initial)
+	}
+}
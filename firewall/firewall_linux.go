@@ -4,14 +4,19 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"strings"
 
 	"github.com/evilsocket/bettercap-ng/core"
 )
 
 type LinuxFirewall struct {
-	forwarding   bool
-	redirections map[string]*Redirection
+	forwarding    bool
+	redirections  map[string]*Redirection
+	redirections6 map[string]bool
+	rules         []string
+	rules6        map[string]bool
+	snap          *snapshot
 }
 
 const (
@@ -21,9 +26,40 @@ const (
 )
 
 func Make() FirewallManager {
+	switch Backend {
+	case "nftables":
+		return makeNftables()
+	case "iptables":
+		return makeIptables()
+	default:
+		if hasNftables() == true {
+			return makeNftables()
+		}
+		return makeIptables()
+	}
+}
+
+// Prefers nftables if iptables is missing or it's just
+// the nf_tables compatibility layer.
+func hasNftables() bool {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return false
+	} else if _, err := exec.LookPath("iptables"); err != nil {
+		return true
+	} else if out, err := core.Exec("iptables", []string{"--version"}); err == nil && strings.Contains(out, "nf_tables") {
+		return true
+	}
+	return false
+}
+
+func makeIptables() FirewallManager {
 	firewall := &LinuxFirewall{
-		forwarding:   false,
-		redirections: make(map[string]*Redirection, 0),
+		forwarding:    false,
+		redirections:  make(map[string]*Redirection, 0),
+		redirections6: make(map[string]bool, 0),
+		rules:         make([]string, 0),
+		rules6:        make(map[string]bool, 0),
+		snap:          newSnapshot(iptablesRuleset("iptables"), iptablesRuleset("ip6tables")),
 	}
 
 	firewall.forwarding = firewall.IsForwardingEnabled()
@@ -39,8 +75,19 @@ func (f LinuxFirewall) enableFeature(filename string, enable bool) error {
 		value = "0"
 	}
 
+	if Container == true {
+		if raw, err := ioutil.ReadFile(filename); err == nil && strings.TrimSpace(string(raw)) == value {
+			return nil
+		}
+	}
+
+	f.snap.take()
+
 	fd, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
 	if err != nil {
+		if Container == true {
+			return fmt.Errorf("%s is not writable from the container, set it to %s on the host or run the container with --privileged.", filename, value)
+		}
 		return err
 	}
 	defer fd.Close()
@@ -73,9 +120,46 @@ func (f LinuxFirewall) EnableSendRedirects(enabled bool) error {
 	return f.enableFeature(IPV4SendRedirectsFile, enabled)
 }
 
-func (f *LinuxFirewall) EnableRedirection(r *Redirection, enabled bool) error {
-	var opts []string
+// Builds the iptables or ip6tables arguments to append (-A) or delete (-D) the rule.
+func (f LinuxFirewall) redirectionOpts(action string, r *Redirection, v6 bool) []string {
+	opts := []string{
+		"-t", "nat",
+		action, "PREROUTING",
+		"-i", r.Interface,
+	}
+
+	if r.Protocol != "" {
+		opts = append(opts, "-p", r.Protocol)
+	}
+
+	// SrcAddress and From are IPv4 addresses, v6 rules match any destination
+	if r.From != "" && v6 == false {
+		opts = append(opts, "-s", r.From)
+	}
+	if r.SrcAddress != "" && v6 == false {
+		opts = append(opts, "-d", r.SrcAddress)
+	}
+
+	if r.SrcPort > 0 {
+		opts = append(opts, "--dport", fmt.Sprintf("%d", r.SrcPort))
+	}
 
+	to := r.DstAddress
+	if v6 == true {
+		to = fmt.Sprintf("[%s]", r.DstAddress6)
+	}
+	if r.DstPort > 0 {
+		to = fmt.Sprintf("%s:%d", to, r.DstPort)
+	}
+
+	// tagged so that the snapshot can find them if they're left behind
+	return append(opts,
+		"-m", "comment", "--comment", RuleTag,
+		"-j", "DNAT",
+		"--to", to)
+}
+
+func (f *LinuxFirewall) EnableRedirection(r *Redirection, enabled bool) error {
 	rkey := r.String()
 	_, found := f.redirections[rkey]
 
@@ -84,68 +168,42 @@ func (f *LinuxFirewall) EnableRedirection(r *Redirection, enabled bool) error {
 			return fmt.Errorf("Redirection '%s' already enabled.", rkey)
 		}
 
+		f.snap.take()
 		f.redirections[rkey] = r
 
-		// accept all
-		if _, err := core.Exec("iptables", []string{"-P", "FORWARD", "ACCEPT"}); err != nil {
+		if err := f.allowForwarding(r, "iptables"); err != nil {
 			return err
 		}
 
-		if r.SrcAddress == "" {
-			opts = []string{
-				"-t", "nat",
-				"-A", "PREROUTING",
-				"-i", r.Interface,
-				"-p", r.Protocol,
-				"--dport", fmt.Sprintf("%d", r.SrcPort),
-				"-j", "DNAT",
-				"--to", fmt.Sprintf("%s:%d", r.DstAddress, r.DstPort),
-			}
-		} else {
-			opts = []string{
-				"-t", "nat",
-				"-A", "PREROUTING",
-				"-i", r.Interface,
-				"-p", r.Protocol,
-				"-d", r.SrcAddress,
-				"--dport", fmt.Sprintf("%d", r.SrcPort),
-				"-j", "DNAT",
-				"--to", fmt.Sprintf("%s:%d", r.DstAddress, r.DstPort),
-			}
-		}
-		if _, err := core.Exec("iptables", opts); err != nil {
+		if _, err := core.Exec("iptables", f.redirectionOpts("-A", r, false)); err != nil {
 			return err
 		}
+
+		// IPv6 is best effort, the host might not have ip6tables or its nat table
+		if r.DstAddress6 != "" {
+			if err := f.allowForwarding(r, "ip6tables"); err != nil {
+				Warn("Could not accept IPv6 forwarded traffic for '%s': %s", rkey, err)
+			} else if _, err := core.Exec("ip6tables", f.redirectionOpts("-A", r, true)); err != nil {
+				Warn("Could not redirect IPv6 traffic for '%s': %s", rkey, err)
+			} else {
+				f.redirections6[rkey] = true
+			}
+		}
 	} else {
 		if found == false {
 			return nil
 		}
 
-		delete(f.redirections, r.String())
-
-		if r.SrcAddress == "" {
-			opts = []string{
-				"-t", "nat",
-				"-D", "PREROUTING",
-				"-i", r.Interface,
-				"-p", r.Protocol,
-				"--dport", fmt.Sprintf("%d", r.SrcPort),
-				"-j", "DNAT",
-				"--to", fmt.Sprintf("%s:%d", r.DstAddress, r.DstPort),
-			}
-		} else {
-			opts = []string{
-				"-t", "nat",
-				"-D", "PREROUTING",
-				"-i", r.Interface,
-				"-p", r.Protocol,
-				"-d", r.SrcAddress,
-				"--dport", fmt.Sprintf("%d", r.SrcPort),
-				"-j", "DNAT",
-				"--to", fmt.Sprintf("%s:%d", r.DstAddress, r.DstPort),
+		delete(f.redirections, rkey)
+
+		if f.redirections6[rkey] == true {
+			delete(f.redirections6, rkey)
+			if _, err := core.Exec("ip6tables", f.redirectionOpts("-D", r, true)); err != nil {
+				Warn("Could not remove the IPv6 redirection '%s': %s", rkey, err)
 			}
 		}
-		if _, err := core.Exec("iptables", opts); err != nil {
+
+		if _, err := core.Exec("iptables", f.redirectionOpts("-D", r, false)); err != nil {
 			return err
 		}
 	}
@@ -153,14 +211,30 @@ func (f *LinuxFirewall) EnableRedirection(r *Redirection, enabled bool) error {
 	return nil
 }
 
-func (f LinuxFirewall) Restore() {
+func (f *LinuxFirewall) Restore() {
+	for len(f.rules) > 0 {
+		if err := f.DelRule(f.rules[0]); err != nil {
+			fmt.Printf("%s", err)
+			f.rules = f.rules[1:]
+		}
+	}
+
 	for _, r := range f.redirections {
 		if err := f.EnableRedirection(r, false); err != nil {
 			fmt.Printf("%s", err)
 		}
 	}
 
-	if err := f.EnableForwarding(f.forwarding); err != nil {
-		fmt.Printf("%s", err)
+	for rule := range f.rules6 {
+		if args, err := f.ruleArgs(rule, true); err == nil {
+			if _, err = core.Exec("ip6tables", args); err != nil {
+				fmt.Printf("%s", err)
+			}
+		}
 	}
+	f.rules6 = make(map[string]bool, 0)
+
+	// this also restores forwarding and undoes anything
+	// that failed to be removed in the steps above
+	f.snap.restore()
 }
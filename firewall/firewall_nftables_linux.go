@@ -0,0 +1,205 @@
+package firewall
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+const (
+	nftTable = "bettercap"
+	nftChain = "prerouting"
+)
+
+var nftHandleParser = regexp.MustCompile("# handle (\\d+)")
+
+// Native nftables backend, redirections are added to dedicated
+// ip and ip6 tables which are deleted as a whole on Restore, while
+// forwarding is handled via /proc exactly like with iptables.
+type NftFirewall struct {
+	LinuxFirewall
+	// indexed by family, ip or ip6, custom rules handles are under "rule"
+	// and the DOCKER-USER accept rules, indexed by interface, under "forward"
+	tables  map[string]bool
+	handles map[string]map[string]string
+}
+
+func makeNftables() FirewallManager {
+	firewall := &NftFirewall{
+		LinuxFirewall: LinuxFirewall{
+			forwarding:   false,
+			redirections: make(map[string]*Redirection, 0),
+			rules:        make([]string, 0),
+			snap:         newSnapshot(nftRuleset()),
+		},
+		tables: make(map[string]bool, 0),
+		handles: map[string]map[string]string{
+			"ip":      make(map[string]string, 0),
+			"ip6":     make(map[string]string, 0),
+			"rule":    make(map[string]string, 0),
+			"forward": make(map[string]string, 0),
+		},
+	}
+
+	firewall.forwarding = firewall.IsForwardingEnabled()
+
+	return firewall
+}
+
+func (f NftFirewall) nft(args ...string) (string, error) {
+	// -- prevents negative priorities from being parsed as options
+	return core.Exec("nft", append([]string{"--"}, args...))
+}
+
+func (f *NftFirewall) createTable(family string) error {
+	if f.tables[family] == true {
+		return nil
+	}
+
+	if _, err := f.nft("add", "table", family, nftTable); err != nil {
+		return err
+	}
+
+	if _, err := f.nft("add", "chain", family, nftTable, nftChain,
+		"{", "type", "nat", "hook", "prerouting", "priority", "-100", ";", "}"); err != nil {
+		return err
+	}
+
+	// rules left behind by a session which did not exit cleanly
+	if _, err := f.nft("flush", "chain", family, nftTable, nftChain); err != nil {
+		return err
+	}
+
+	f.tables[family] = true
+	return nil
+}
+
+func (f NftFirewall) ruleFor(r *Redirection, family string) []string {
+	rule := []string{"iifname", r.Interface}
+	to := r.DstAddress
+
+	if family == "ip6" {
+		// SrcAddress and From are IPv4 addresses, v6 rules match any destination
+		to = fmt.Sprintf("[%s]", r.DstAddress6)
+	} else {
+		if r.From != "" {
+			rule = append(rule, "ip", "saddr", r.From)
+		}
+		if r.SrcAddress != "" {
+			rule = append(rule, "ip", "daddr", r.SrcAddress)
+		}
+	}
+
+	if r.DstPort > 0 {
+		to = fmt.Sprintf("%s:%d", to, r.DstPort)
+	}
+
+	if r.Protocol != "" && r.SrcPort > 0 {
+		rule = append(rule, strings.ToLower(r.Protocol), "dport", fmt.Sprintf("%d", r.SrcPort))
+	} else if r.Protocol != "" {
+		rule = append(rule, "meta", "l4proto", strings.ToLower(r.Protocol))
+	}
+
+	return append(rule, "dnat", "to", to)
+}
+
+func (f *NftFirewall) addRule(family string, rkey string, r *Redirection) error {
+	if err := f.createTable(family); err != nil {
+		return err
+	}
+
+	// --echo --handle prints the rule back with its handle, needed to delete it
+	args := append([]string{"--echo", "--handle", "add", "rule", family, nftTable, nftChain}, f.ruleFor(r, family)...)
+	out, err := core.Exec("nft", args)
+	if err != nil {
+		return err
+	}
+
+	m := nftHandleParser.FindStringSubmatch(out)
+	if len(m) != 2 {
+		return fmt.Errorf("Could not find the handle of the nftables rule in '%s'.", out)
+	}
+
+	f.handles[family][rkey] = m[1]
+	return nil
+}
+
+func (f *NftFirewall) deleteRule(family string, rkey string) error {
+	handle, found := f.handles[family][rkey]
+	if found == false {
+		return nil
+	}
+
+	delete(f.handles[family], rkey)
+	_, err := f.nft("delete", "rule", family, nftTable, nftChain, "handle", handle)
+	return err
+}
+
+func (f *NftFirewall) EnableRedirection(r *Redirection, enabled bool) error {
+	rkey := r.String()
+	_, found := f.redirections[rkey]
+
+	if enabled == true {
+		if found == true {
+			return fmt.Errorf("Redirection '%s' already enabled.", rkey)
+		}
+
+		f.snap.take()
+		if err := f.addRule("ip", rkey, r); err != nil {
+			return err
+		}
+
+		f.redirections[rkey] = r
+		f.allowForwarding(r)
+
+		// IPv6 is best effort, like with ip6tables
+		if r.DstAddress6 != "" {
+			if err := f.addRule("ip6", rkey, r); err != nil {
+				Warn("Could not redirect IPv6 traffic for '%s': %s", rkey, err)
+			}
+		}
+	} else {
+		if found == false {
+			return nil
+		}
+
+		delete(f.redirections, rkey)
+
+		f.deleteRule("ip6", rkey)
+		if err := f.deleteRule("ip", rkey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *NftFirewall) Restore() {
+	for _, handle := range f.handles["forward"] {
+		if _, err := f.nft("delete", "rule", "ip", "filter", "DOCKER-USER", "handle", handle); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+	}
+	f.handles["forward"] = make(map[string]string, 0)
+
+	for family, created := range f.tables {
+		if created == true {
+			if _, err := f.nft("delete", "table", family, nftTable); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+		}
+	}
+
+	f.tables = make(map[string]bool, 0)
+	f.redirections = make(map[string]*Redirection, 0)
+	f.handles["ip"] = make(map[string]string, 0)
+	f.handles["ip6"] = make(map[string]string, 0)
+	f.handles["rule"] = make(map[string]string, 0)
+	f.rules = make([]string, 0)
+
+	// this also restores forwarding
+	f.snap.restore()
+}
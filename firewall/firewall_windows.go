@@ -0,0 +1,147 @@
+package firewall
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// Uses netsh portproxy for redirections, which works for connections
+// addressed to this host but, unlike iptables DNAT, it can not
+// intercept traffic which is only being routed through it.
+type WindowsFirewall struct {
+	forwarding   map[string]bool
+	redirections map[string]*Redirection
+}
+
+func Make() FirewallManager {
+	firewall := &WindowsFirewall{
+		forwarding:   make(map[string]bool, 0),
+		redirections: make(map[string]*Redirection, 0),
+	}
+
+	firewall.forwarding = firewall.forwardingStates()
+
+	return firewall
+}
+
+func (f WindowsFirewall) powershell(cmd string) (string, error) {
+	return core.Exec("powershell", []string{"-NoProfile", "-NonInteractive", "-Command", cmd})
+}
+
+// Returns the forwarding state of each IPv4 interface by index.
+func (f WindowsFirewall) forwardingStates() map[string]bool {
+	states := make(map[string]bool, 0)
+
+	out, err := f.powershell("Get-NetIPInterface -AddressFamily IPv4 | ForEach-Object { \"$($_.InterfaceIndex) $($_.Forwarding)\" }")
+	if err != nil {
+		return states
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			states[fields[0]] = fields[1] == "Enabled"
+		}
+	}
+
+	return states
+}
+
+func (f WindowsFirewall) setForwarding(index string, enabled bool) error {
+	value := "Disabled"
+	if enabled {
+		value = "Enabled"
+	}
+
+	_, err := f.powershell(fmt.Sprintf("Set-NetIPInterface -InterfaceIndex %s -AddressFamily IPv4 -Forwarding %s", index, value))
+	return err
+}
+
+func (f WindowsFirewall) IsForwardingEnabled() bool {
+	out, err := f.powershell("@(Get-NetIPInterface -AddressFamily IPv4 | Where-Object { $_.Forwarding -eq 'Enabled' }).Count")
+	if err != nil {
+		return false
+	}
+	return out != "" && out != "0"
+}
+
+func (f WindowsFirewall) EnableForwarding(enabled bool) error {
+	value := "Disabled"
+	if enabled {
+		value = "Enabled"
+	}
+
+	_, err := f.powershell(fmt.Sprintf("Set-NetIPInterface -AddressFamily IPv4 -Forwarding %s", value))
+	return err
+}
+
+func (f WindowsFirewall) EnableIcmpBcast(enabled bool) error {
+	return nil
+}
+
+func (f WindowsFirewall) EnableSendRedirects(enabled bool) error {
+	return nil
+}
+
+func (f WindowsFirewall) listenAddress(r *Redirection) string {
+	if r.SrcAddress == "" {
+		return "0.0.0.0"
+	}
+	return r.SrcAddress
+}
+
+func (f *WindowsFirewall) EnableRedirection(r *Redirection, enabled bool) error {
+	rkey := r.String()
+	_, found := f.redirections[rkey]
+
+	if strings.ToLower(r.Protocol) != "tcp" {
+		return fmt.Errorf("Only TCP redirections are supported on Windows.")
+	}
+
+	if enabled == true {
+		if found == true {
+			return fmt.Errorf("Redirection '%s' already enabled.", rkey)
+		}
+
+		f.redirections[rkey] = r
+
+		_, err := core.Exec("netsh", []string{
+			"interface", "portproxy", "add", "v4tov4",
+			fmt.Sprintf("listenport=%d", r.SrcPort),
+			fmt.Sprintf("listenaddress=%s", f.listenAddress(r)),
+			fmt.Sprintf("connectport=%d", r.DstPort),
+			fmt.Sprintf("connectaddress=%s", r.DstAddress),
+		})
+		return err
+	} else if found == false {
+		return nil
+	}
+
+	delete(f.redirections, rkey)
+
+	_, err := core.Exec("netsh", []string{
+		"interface", "portproxy", "delete", "v4tov4",
+		fmt.Sprintf("listenport=%d", r.SrcPort),
+		fmt.Sprintf("listenaddress=%s", f.listenAddress(r)),
+	})
+	return err
+}
+
+func (f WindowsFirewall) Restore() {
+	for _, r := range f.redirections {
+		if err := f.EnableRedirection(r, false); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+	}
+
+	// only put back the interfaces whose state changed, each one as it was
+	for index, enabled := range f.forwardingStates() {
+		if was, found := f.forwarding[index]; found == true && was != enabled {
+			if err := f.setForwarding(index, was); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+			}
+		}
+	}
+}
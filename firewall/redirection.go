@@ -2,6 +2,8 @@ package firewall
 
 import "fmt"
 
+// An empty Protocol matches every protocol, a zero SrcPort every
+// port and a zero DstPort keeps the original one.
 type Redirection struct {
 	Interface  string
 	Protocol   string
@@ -9,6 +11,10 @@ type Redirection struct {
 	SrcPort    int
 	DstAddress string
 	DstPort    int
+	// if set, IPv6 traffic is redirected to this address as well
+	DstAddress6 string
+	// if set, only traffic coming from this IPv4 address is redirected
+	From string
 }
 
 func NewRedirection(iface string, proto string, port_from int, addr_to string, port_to int) *Redirection {
@@ -19,9 +25,19 @@ func NewRedirection(iface string, proto string, port_from int, addr_to string, p
 		SrcPort:    port_from,
 		DstAddress: addr_to,
 		DstPort:    port_to,
+
+		DstAddress6: "",
+		From:        "",
 	}
 }
 
 func (r Redirection) String() string {
-	return fmt.Sprintf("[%s] (%s) %s:%d -> %s:%d", r.Interface, r.Protocol, r.SrcAddress, r.SrcPort, r.DstAddress, r.DstPort)
+	s := fmt.Sprintf("[%s] (%s) %s:%d -> %s:%d", r.Interface, r.Protocol, r.SrcAddress, r.SrcPort, r.DstAddress, r.DstPort)
+	if r.DstAddress6 != "" {
+		s += fmt.Sprintf(" / [%s]:%d", r.DstAddress6, r.DstPort)
+	}
+	if r.From != "" {
+		s += fmt.Sprintf(" from %s", r.From)
+	}
+	return s
 }
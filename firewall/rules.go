@@ -0,0 +1,47 @@
+package firewall
+
+import (
+	"errors"
+	"strings"
+)
+
+// Comment attached to the raw rules installed by the session.
+const RuleTag = "bettercap-ng"
+
+var ErrRulesUnsupported = errors.New("This firewall backend does not support custom rules.")
+
+// Implemented by backends which can install raw, backend specific rules,
+// every rule is removed when the firewall is restored.
+type RuleManager interface {
+	AddRule(rule string) error
+	DelRule(rule string) error
+	Rules() []string
+}
+
+func normalizeRule(rule string) string {
+	return strings.Join(strings.Fields(rule), " ")
+}
+
+// Installs a raw rule if the backend supports it.
+func AddRule(fw FirewallManager, rule string) error {
+	if rm, ok := fw.(RuleManager); ok == true {
+		return rm.AddRule(normalizeRule(rule))
+	}
+	return ErrRulesUnsupported
+}
+
+// Removes a raw rule previously installed with AddRule.
+func DelRule(fw FirewallManager, rule string) error {
+	if rm, ok := fw.(RuleManager); ok == true {
+		return rm.DelRule(normalizeRule(rule))
+	}
+	return ErrRulesUnsupported
+}
+
+// Returns the raw rules installed by the session.
+func Rules(fw FirewallManager) []string {
+	if rm, ok := fw.(RuleManager); ok == true {
+		return rm.Rules()
+	}
+	return []string{}
+}
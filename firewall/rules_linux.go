@@ -0,0 +1,141 @@
+package firewall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// Rules are iptables arguments such as "-t nat -A PREROUTING -p tcp
+// --dport 25 -j REDIRECT --to-ports 10025", they're tagged with a
+// comment and deleted by turning their -A or -I into a -D.
+func (f LinuxFirewall) ruleArgs(rule string, del bool) ([]string, error) {
+	args := strings.Fields(rule)
+	found := false
+
+	for i := 0; i < len(args); i++ {
+		if args[i] != "-A" && args[i] != "-I" {
+			continue
+		} else if i+1 >= len(args) {
+			return nil, fmt.Errorf("Missing chain name in rule '%s'.", rule)
+		}
+
+		found = true
+		if del == true {
+			args[i] = "-D"
+			// -I CHAIN NUM, the position doesn't matter for deletion
+			if i+2 < len(args) && isRulePosition(args[i+2]) {
+				args = append(args[:i+2], args[i+3:]...)
+			}
+		}
+		break
+	}
+
+	if found == false {
+		return nil, fmt.Errorf("Rule '%s' must append (-A) or insert (-I) in a chain.", rule)
+	}
+
+	return append(args, "-m", "comment", "--comment", RuleTag), nil
+}
+
+func isRulePosition(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (f *LinuxFirewall) AddRule(rule string) error {
+	for _, r := range f.rules {
+		if r == rule {
+			return fmt.Errorf("Rule '%s' already installed.", rule)
+		}
+	}
+
+	args, err := f.ruleArgs(rule, false)
+	if err != nil {
+		return err
+	}
+
+	f.snap.take()
+	if _, err = core.Exec("iptables", args); err != nil {
+		return err
+	}
+
+	f.rules = append(f.rules, rule)
+	return nil
+}
+
+func (f *LinuxFirewall) DelRule(rule string) error {
+	for i, r := range f.rules {
+		if r == rule {
+			args, err := f.ruleArgs(rule, true)
+			if err != nil {
+				return err
+			} else if _, err = core.Exec("iptables", args); err != nil {
+				return err
+			}
+
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("Rule '%s' not found.", rule)
+}
+
+func (f *LinuxFirewall) Rules() []string {
+	rules := make([]string, len(f.rules))
+	copy(rules, f.rules)
+	return rules
+}
+
+// Rules are nft statements added to the nat prerouting chain of the
+// bettercap ip table, such as "tcp dport 25 redirect to :10025".
+func (f *NftFirewall) AddRule(rule string) error {
+	if _, found := f.handles["rule"][rule]; found == true {
+		return fmt.Errorf("Rule '%s' already installed.", rule)
+	}
+
+	f.snap.take()
+	if err := f.createTable("ip"); err != nil {
+		return err
+	}
+
+	args := append([]string{"--echo", "--handle", "add", "rule", "ip", nftTable, nftChain}, strings.Fields(rule)...)
+	args = append(args, "comment", fmt.Sprintf("\"%s\"", RuleTag))
+
+	out, err := core.Exec("nft", args)
+	if err != nil {
+		return err
+	}
+
+	m := nftHandleParser.FindStringSubmatch(out)
+	if len(m) != 2 {
+		return fmt.Errorf("Could not find the handle of the nftables rule in '%s'.", out)
+	}
+
+	f.handles["rule"][rule] = m[1]
+	f.rules = append(f.rules, rule)
+	return nil
+}
+
+func (f *NftFirewall) DelRule(rule string) error {
+	handle, found := f.handles["rule"][rule]
+	if found == false {
+		return fmt.Errorf("Rule '%s' not found.", rule)
+	} else if _, err := f.nft("delete", "rule", "ip", nftTable, nftChain, "handle", handle); err != nil {
+		return err
+	}
+
+	delete(f.handles["rule"], rule)
+	for i, r := range f.rules {
+		if r == rule {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package firewall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+var (
+	countersParser = regexp.MustCompile(`\[\d+:\d+\]`)
+	iptTagParser   = regexp.MustCompile(`--comment "?` + RuleTag + `"?(\s|$)`)
+	nftTagParser   = regexp.MustCompile(`comment "` + RuleTag + `"`)
+)
+
+// A ruleset which can be dumped and cleaned from what the session left
+// behind, given the dump taken before the first change and the current one,
+// or loaded back exactly as it was with ExactRestore.
+type ruleset struct {
+	name    string
+	save    func() (string, error)
+	restore func(dump string, current string) error
+	exact   func(dump string, current string) error
+}
+
+// State of the host firewall and of the kernel parameters before the
+// first change, so that whatever the session left behind can be removed
+// even if something went wrong while removing its rules one by one.
+type snapshot struct {
+	taken    bool
+	rulesets []ruleset
+	dumps    map[string]string
+	sysctl   map[string]string
+}
+
+func newSnapshot(rulesets ...ruleset) *snapshot {
+	return &snapshot{
+		taken:    false,
+		rulesets: rulesets,
+		dumps:    make(map[string]string),
+		sysctl:   make(map[string]string),
+	}
+}
+
+// Loads a dump through a temporary file, as core.Exec has no stdin.
+func loadDump(dump string, executable string, args ...string) error {
+	tmp, err := ioutil.TempFile("", "bcap-fw-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(dump)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	_, err = core.Exec(executable, append(args, tmp.Name()))
+	return err
+}
+
+// Builds the iptables-restore --noflush input deleting the rules tagged
+// by the session and restoring the policies of the built-in chains which
+// changed, rules added by anyone else in the meantime are left alone.
+func iptablesCleanup(dump string, current string) string {
+	policies := make(map[string]string)
+	table := ""
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			table = line[1:]
+		} else if fields := strings.Fields(line); len(fields) >= 2 && line[0] == ':' && fields[1] != "-" {
+			policies[table+" "+fields[0][1:]] = fields[1]
+		}
+	}
+
+	cleanup := ""
+	table = ""
+	changes := make([]string, 0)
+	flush := func() {
+		if len(changes) > 0 {
+			cleanup += "*" + table + "\n" + strings.Join(changes, "\n") + "\nCOMMIT\n"
+		}
+		changes = make([]string, 0)
+	}
+
+	for _, line := range strings.Split(current, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			flush()
+			table = line[1:]
+		} else if strings.HasPrefix(line, "-A ") && iptTagParser.MatchString(line) {
+			changes = append(changes, "-D "+line[3:])
+		} else if fields := strings.Fields(line); len(fields) >= 2 && line[0] == ':' {
+			chain := fields[0][1:]
+			if policy, found := policies[table+" "+chain]; found == true && policy != fields[1] {
+				changes = append(changes, fmt.Sprintf(":%s %s [0:0]", chain, policy))
+			}
+		}
+	}
+	flush()
+
+	return cleanup
+}
+
+// Builds the iptables-restore input bringing the tables back exactly as
+// they were, the tables which were not loaded in the dump are flushed.
+func iptablesExact(dump string, current string) string {
+	tables := make(map[string]bool)
+	for _, line := range strings.Split(dump, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "*") {
+			tables[line[1:]] = true
+		}
+	}
+
+	exact := dump
+	if strings.HasSuffix(exact, "\n") == false {
+		exact += "\n"
+	}
+
+	for _, line := range strings.Split(current, "\n") {
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, "*") && tables[line[1:]] == false {
+			exact += line + "\nCOMMIT\n"
+		}
+	}
+
+	return exact
+}
+
+func iptablesRuleset(name string) ruleset {
+	return ruleset{
+		name: name,
+		save: func() (string, error) {
+			return core.Exec(name+"-save", []string{})
+		},
+		restore: func(dump string, current string) error {
+			if cleanup := iptablesCleanup(dump, current); cleanup != "" {
+				return loadDump(cleanup, name+"-restore", "--noflush")
+			}
+			return nil
+		},
+		exact: func(dump string, current string) error {
+			return loadDump(iptablesExact(dump, current), name+"-restore")
+		},
+	}
+}
+
+// Builds the nft commands deleting the session tables and the rules it
+// tagged in the tables of the host, the dump must include the handles.
+func nftCleanup(current string) string {
+	cleanup := ""
+	table := ""
+	chain := ""
+	for _, line := range strings.Split(current, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "table" {
+			table = fields[1] + " " + fields[2]
+			chain = ""
+			if fields[2] == nftTable {
+				cleanup += "delete table " + table + "\n"
+			}
+		} else if len(fields) >= 2 && fields[0] == "chain" {
+			chain = fields[1]
+		} else if m := nftHandleParser.FindStringSubmatch(line); len(m) == 2 && chain != "" && nftTagParser.MatchString(line) &&
+			strings.HasSuffix(table, " "+nftTable) == false {
+			cleanup += fmt.Sprintf("delete rule %s %s handle %s\n", table, chain, m[1])
+		}
+	}
+	return cleanup
+}
+
+func nftRuleset() ruleset {
+	return ruleset{
+		name: "nftables",
+		save: func() (string, error) {
+			return core.Exec("nft", []string{"--handle", "list", "ruleset"})
+		},
+		restore: func(dump string, current string) error {
+			if cleanup := nftCleanup(current); cleanup != "" {
+				return loadDump(cleanup, "nft", "-f")
+			}
+			return nil
+		},
+		exact: func(dump string, current string) error {
+			return loadDump("flush ruleset\n"+dump, "nft", "-f")
+		},
+	}
+}
+
+// Dumps are compared without comments and packet counters, which
+// change on their own without the ruleset being modified.
+func normalizeDump(dump string) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(dump, "\n") {
+		if line = strings.TrimSpace(line); line != "" && line[0] != '#' {
+			lines = append(lines, countersParser.ReplaceAllString(line, "[0:0]"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *snapshot) take() {
+	if s.taken == true {
+		return
+	}
+	s.taken = true
+
+	for _, filename := range []string{IPV4ForwardingFile, IPV4ICMPBcastFile, IPV4SendRedirectsFile} {
+		if raw, err := ioutil.ReadFile(filename); err == nil {
+			s.sysctl[filename] = strings.TrimSpace(string(raw))
+		}
+	}
+
+	// rulesets which can't be dumped, like ip6tables on hosts
+	// without IPv6, are simply not restored
+	for _, rs := range s.rulesets {
+		if dump, err := rs.save(); err == nil {
+			s.dumps[rs.name] = dump
+		}
+	}
+}
+
+// Removes the rules the session left behind and restores the kernel
+// parameters, changes made by other programs since the snapshot are kept
+// unless ExactRestore is set.
+func (s *snapshot) restore() {
+	if s.taken == false {
+		return
+	}
+
+	for _, rs := range s.rulesets {
+		dump, found := s.dumps[rs.name]
+		if found == false {
+			continue
+		}
+
+		current, err := rs.save()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error while reading %s ruleset: %s\n", rs.name, err)
+			continue
+		} else if normalizeDump(current) == normalizeDump(dump) {
+			continue
+		}
+
+		restore := rs.restore
+		if ExactRestore == true {
+			fmt.Fprintf(os.Stderr, "Loading back the %s ruleset as it was before the session.\n", rs.name)
+			restore = rs.exact
+		} else {
+			fmt.Fprintf(os.Stderr, "Removing what is left of the session from the %s ruleset.\n", rs.name)
+		}
+
+		if err := restore(dump, current); err != nil {
+			fmt.Fprintf(os.Stderr, "Error while restoring %s ruleset: %s\n", rs.name, err)
+		}
+	}
+
+	for filename, value := range s.sysctl {
+		if raw, err := ioutil.ReadFile(filename); err == nil && strings.TrimSpace(string(raw)) == value {
+			continue
+		} else if err := ioutil.WriteFile(filename, []byte(value), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error while restoring %s: %s\n", filename, err)
+		}
+	}
+
+	s.taken = false
+	s.dumps = make(map[string]string)
+	s.sysctl = make(map[string]string)
+}
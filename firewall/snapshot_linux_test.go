@@ -0,0 +1,167 @@
+package firewall
+
+import (
+	"testing"
+)
+
+const iptablesDump = `# Generated by iptables-save v1.8.7 on Thu Oct 15 06:00:00 2026
+*filter
+:INPUT ACCEPT [120:9000]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [80:7000]
+:DOCKER-USER - [0:0]
+-A FORWARD -j DOCKER-USER
+-A DOCKER-USER -j RETURN
+COMMIT
+# Completed on Thu Oct 15 06:00:00 2026
+*nat
+:PREROUTING ACCEPT [10:600]
+:POSTROUTING ACCEPT [3:180]
+-A POSTROUTING -s 172.17.0.0/16 ! -o docker0 -j MASQUERADE
+COMMIT
+`
+
+func TestNormalizeDump(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{iptablesDump, iptablesDump, true},
+		// counters and comments change on their own
+		{":INPUT ACCEPT [120:9000]", ":INPUT ACCEPT [0:0]", true},
+		{"# Generated on Thu\n-A FORWARD -j DOCKER-USER", "# Generated on Fri\n-A FORWARD -j DOCKER-USER", true},
+		{"  -A FORWARD -j DOCKER-USER\n\n", "-A FORWARD -j DOCKER-USER", true},
+		{":FORWARD DROP [0:0]", ":FORWARD ACCEPT [0:0]", false},
+		{"-A FORWARD -j DOCKER-USER", "-A FORWARD -j DOCKER-USER\n-A FORWARD -j ACCEPT", false},
+		{"ip daddr 10.0.0.1 counter packets 3 bytes 180 # handle 4", "ip daddr 10.0.0.1 counter packets 3 bytes 180 # handle 4", true},
+	}
+
+	for _, test := range tests {
+		if equal := normalizeDump(test.a) == normalizeDump(test.b); equal != test.equal {
+			t.Fatalf("Expected equal=%v for %q and %q", test.equal, test.a, test.b)
+		}
+	}
+}
+
+func TestIptablesCleanup(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		expected string
+	}{
+		{"unchanged", iptablesDump, ""},
+		{
+			"tagged rules",
+			`*filter
+:INPUT ACCEPT [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+:DOCKER-USER - [0:0]
+-A FORWARD -j DOCKER-USER
+-A DOCKER-USER -i eth0 -m comment --comment bettercap-ng -j ACCEPT
+-A DOCKER-USER -j RETURN
+COMMIT
+*nat
+:PREROUTING ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+-A PREROUTING -i eth0 -p tcp -m tcp --dport 80 -m comment --comment "bettercap-ng" -j DNAT --to-destination 192.168.1.2:8080
+-A POSTROUTING -s 172.17.0.0/16 ! -o docker0 -j MASQUERADE
+COMMIT
+`,
+			"*filter\n-D DOCKER-USER -i eth0 -m comment --comment bettercap-ng -j ACCEPT\nCOMMIT\n" +
+				"*nat\n-D PREROUTING -i eth0 -p tcp -m tcp --dport 80 -m comment --comment \"bettercap-ng\" -j DNAT --to-destination 192.168.1.2:8080\nCOMMIT\n",
+		},
+		{
+			"changed policy",
+			`*filter
+:INPUT ACCEPT [0:0]
+:FORWARD ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:DOCKER-USER - [0:0]
+COMMIT
+`,
+			"*filter\n:FORWARD DROP [0:0]\nCOMMIT\n",
+		},
+		{
+			"rules of other programs",
+			`*filter
+:INPUT ACCEPT [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+:f2b-sshd - [0:0]
+-A INPUT -p tcp -m multiport --dports 22 -j f2b-sshd
+-A f2b-sshd -s 10.0.0.1/32 -j REJECT --reject-with icmp-port-unreachable
+-A INPUT -m comment --comment "bettercap-ng-like" -j ACCEPT
+COMMIT
+*mangle
+:PREROUTING ACCEPT [0:0]
+COMMIT
+`,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		if cleanup := iptablesCleanup(iptablesDump, test.current); cleanup != test.expected {
+			t.Fatalf("%s: expected:\n%s\ngot:\n%s", test.name, test.expected, cleanup)
+		}
+	}
+}
+
+func TestNftCleanup(t *testing.T) {
+	current := `table ip filter { # handle 1
+	chain DOCKER-USER { # handle 5
+		iifname "eth0" accept comment "bettercap-ng" # handle 12
+		iifname "docker0" accept # handle 13
+	}
+}
+table ip bettercap { # handle 8
+	chain prerouting { # handle 1
+		type nat hook prerouting priority dstnat; policy accept;
+		iifname "eth0" tcp dport 80 dnat to 192.168.1.2:8080 # handle 2
+		tcp dport 25 redirect to :10025 comment "bettercap-ng" # handle 3
+	}
+}
+table ip6 bettercap { # handle 9
+	chain prerouting { # handle 1
+		type nat hook prerouting priority dstnat; policy accept;
+	}
+}
+table inet f2b-table { # handle 10
+	chain f2b-chain { # handle 1
+		ip saddr 10.0.0.1 reject comment "bettercap-ng-like" # handle 4
+	}
+}
+`
+	expected := "delete rule ip filter DOCKER-USER handle 12\n" +
+		"delete table ip bettercap\n" +
+		"delete table ip6 bettercap\n"
+
+	if cleanup := nftCleanup(current); cleanup != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, cleanup)
+	} else if cleanup = nftCleanup("table inet filter { # handle 1\n}\n"); cleanup != "" {
+		t.Fatalf("Expected no cleanup, got:\n%s", cleanup)
+	}
+}
+
+func TestIptablesExact(t *testing.T) {
+	current := `*filter
+:INPUT ACCEPT [0:0]
+-A INPUT -j ACCEPT
+COMMIT
+*nat
+:PREROUTING ACCEPT [0:0]
+COMMIT
+*mangle
+:PREROUTING ACCEPT [0:0]
+-A PREROUTING -j MARK --set-mark 1
+COMMIT
+`
+	// the dump is loaded as it is, the tables it doesn't have are flushed
+	expected := iptablesDump + "*mangle\nCOMMIT\n"
+	if exact := iptablesExact(iptablesDump, current); exact != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, exact)
+	} else if exact = iptablesExact("*filter\nCOMMIT", "*filter\nCOMMIT\n"); exact != "*filter\nCOMMIT\n" {
+		t.Fatalf("Expected the dump only, got:\n%s", exact)
+	}
+}
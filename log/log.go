@@ -22,5 +22,7 @@ func Error(format string, args ...interface{}) {
 }
 
 func Fatal(format string, args ...interface{}) {
+	// never leave the network in a broken state
+	session.I.Close()
 	session.I.Events.Log(core.FATAL, format, args...)
 }
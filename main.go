@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/evilsocket/bettercap-ng/core"
 	"github.com/evilsocket/bettercap-ng/log"
@@ -22,16 +23,53 @@ func main() {
 	fmt.Printf(core.Bold("%s v%s\n\n"), core.Name, core.Version)
 
 	sess.Register(modules.NewEventsStream(sess))
+	sess.Register(modules.NewSyslogSink(sess))
+	sess.Register(modules.NewMQTTSink(sess))
+	sess.Register(modules.NewElasticShipper(sess))
+	sess.Register(modules.NewWebhook(sess))
+	sess.Register(modules.NewAgent(sess))
+	sess.Register(modules.NewController(sess))
+	sess.Register(modules.NewUploader(sess))
+	sess.Register(modules.NewCredsModule(sess))
+	sess.Register(modules.NewReportModule(sess))
+	sess.Register(modules.NewFirewallModule(sess))
+	sess.Register(modules.NewTicker(sess))
+	sess.Register(modules.NewWatchdog(sess))
+	sess.Register(modules.NewMacChanger(sess))
 	sess.Register(modules.NewProber(sess))
 	sess.Register(modules.NewDiscovery(sess))
+	sess.Register(modules.NewNetWatcher(sess))
+	sess.Register(modules.NewGPS(sess))
+	sess.Register(modules.NewBLERecon(sess))
+	sess.Register(modules.NewBLEAdvertiser(sess))
+	sess.Register(modules.NewHIDRecon(sess))
 	sess.Register(modules.NewArpSpoofer(sess))
+	sess.Register(modules.NewQuarantine(sess))
+	sess.Register(modules.NewArpWatcher(sess))
+	sess.Register(modules.NewRAWatcher(sess))
 	sess.Register(modules.NewDHCP6Spoofer(sess))
 	sess.Register(modules.NewDNSSpoofer(sess))
+	sess.Register(modules.NewDNSServer(sess))
+	sess.Register(modules.NewEncryptedDNS(sess))
 	sess.Register(modules.NewSniffer(sess))
+	sess.Register(modules.NewPacketsReplay(sess))
 	sess.Register(modules.NewHttpServer(sess))
+	sess.Register(modules.NewMailHoneypot(sess))
+	sess.Register(modules.NewFTPHoneypot(sess))
+	sess.Register(modules.NewSMBRelay(sess))
 	sess.Register(modules.NewHttpProxy(sess))
 	sess.Register(modules.NewHttpsProxy(sess))
 	sess.Register(modules.NewRestAPI(sess))
+	sess.Register(modules.NewGRPCAPI(sess))
+	sess.Register(modules.NewRPCAPI(sess))
+	sess.Register(modules.NewWebUI(sess))
+
+	if *sess.Options.Plugins != "" {
+		if err = modules.LoadPlugins(sess, *sess.Options.Plugins); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
 
 	if err = sess.Start(); err != nil {
 		log.Fatal("%", err)
@@ -42,6 +80,7 @@ func main() {
 	}
 
 	defer sess.Close()
+	defer sess.Recover()
 
 	if *sess.Options.Caplet != "" {
 		if err = sess.RunCaplet(*sess.Options.Caplet); err != nil {
@@ -49,12 +88,25 @@ func main() {
 		}
 	}
 
+	if *sess.Options.Script != "" {
+		if err = sess.Run("script " + *sess.Options.Script); err != nil {
+			log.Fatal("%s", err)
+		}
+	}
+
 	for _, cmd := range session.ParseCommands(*sess.Options.Commands) {
 		if err = sess.Run(cmd); err != nil {
 			log.Fatal("%s", err)
 		}
 	}
 
+	if *sess.Options.NoPrompt == true {
+		for sess.Active {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return
+	}
+
 	for sess.Active {
 		line, err := sess.ReadLine()
 		if err != nil {
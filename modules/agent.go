@@ -0,0 +1,338 @@
+package modules
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+const agentMaxBackoff = 2 * time.Minute
+
+type Agent struct {
+	session.SessionModule
+	controller  string
+	name        string
+	token       string
+	fingerprint string
+	commands    bool
+	include     []string
+	exclude     []string
+	conn        *agentConn
+	quit        chan bool
+	lock        *sync.Mutex
+}
+
+func NewAgent(s *session.Session) *Agent {
+	a := &Agent{
+		SessionModule: session.NewSessionModule("agent", s),
+		include:       make([]string, 0),
+		exclude:       make([]string, 0),
+		lock:          &sync.Mutex{},
+	}
+
+	a.AddParam(session.NewStringParameter("agent.controller",
+		"",
+		"",
+		"Address of the controller to register with as HOST:PORT."))
+
+	a.AddParam(session.NewStringParameter("agent.name",
+		"",
+		"",
+		"Name of this agent, if empty the hostname will be used."))
+
+	a.AddParam(session.NewSecretParameter("agent.token",
+		"",
+		"",
+		"Token to register with the controller."))
+
+	a.AddParam(session.NewStringParameter("agent.fingerprint",
+		"",
+		"^([a-fA-F0-9]{64})?$",
+		"SHA256 fingerprint of the controller certificate, if empty the certificate must be signed by a trusted CA."))
+
+	a.AddParam(session.NewBoolParameter("agent.commands",
+		"false",
+		"If true, commands sent by the controller will be executed."))
+
+	a.AddParam(session.NewStringParameter("agent.filter",
+		"",
+		"",
+		"If filled, only send events whose type starts with one of these comma separated prefixes."))
+
+	a.AddParam(session.NewStringParameter("agent.exclude",
+		"",
+		"",
+		"If filled, do not send events whose type starts with one of these comma separated prefixes."))
+
+	a.AddHandler(session.NewModuleHandler("agent on", "",
+		"Register with the controller and keep the connection up.",
+		func(args []string) error {
+			return a.Start()
+		}))
+
+	a.AddHandler(session.NewModuleHandler("agent off", "",
+		"Disconnect from the controller.",
+		func(args []string) error {
+			return a.Stop()
+		}))
+
+	return a
+}
+
+func (a Agent) Name() string {
+	return "agent"
+}
+
+func (a Agent) Description() string {
+	return "Stream events to a remote controller and run the commands it sends."
+}
+
+func (a Agent) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (a *Agent) Configure() (err error) {
+	if err, a.controller = a.StringParam("agent.controller"); err != nil {
+		return err
+	} else if err, a.name = a.StringParam("agent.name"); err != nil {
+		return err
+	} else if err, a.token = a.StringParam("agent.token"); err != nil {
+		return err
+	} else if err, a.fingerprint = a.StringParam("agent.fingerprint"); err != nil {
+		return err
+	} else if err, a.commands = a.BoolParam("agent.commands"); err != nil {
+		return err
+	} else if err, a.include = a.ListParam("agent.filter"); err != nil {
+		return err
+	} else if err, a.exclude = a.ListParam("agent.exclude"); err != nil {
+		return err
+	}
+
+	if a.controller == "" {
+		return fmt.Errorf("No agent.controller specified.")
+	} else if a.name == "" {
+		if a.name, err = os.Hostname(); err != nil {
+			return err
+		}
+	}
+
+	// certFingerprint is lowercase hex
+	a.fingerprint = strings.ToLower(a.fingerprint)
+	if a.fingerprint == "" {
+		log.Info("agent.fingerprint is empty, the controller certificate must be signed by a trusted CA.")
+	}
+
+	return nil
+}
+
+// Checks the controller certificate against agent.fingerprint.
+func (a *Agent) verify(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+	if a.fingerprint == "" {
+		return fmt.Errorf("No agent.fingerprint to verify the controller certificate with.")
+	} else if len(rawCerts) == 0 {
+		return fmt.Errorf("No certificate presented by the controller.")
+	}
+
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return err
+	} else if fp := certFingerprint(cert); fp != a.fingerprint {
+		return fmt.Errorf("Controller certificate fingerprint %s does not match.", fp)
+	}
+	return nil
+}
+
+func (a *Agent) connect() (*agentConn, error) {
+	// the certificate generated by the controller module is self signed,
+	// so it is pinned with agent.fingerprint instead of verified by a CA
+	config := &tls.Config{}
+	if a.fingerprint != "" {
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = a.verify
+	}
+
+	raw, err := tls.Dial("tcp", a.controller, config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := newAgentConn(raw)
+	if err = conn.Send(agentMessage{Type: agentMsgHello, Name: a.name, Token: a.token}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	raw.SetReadDeadline(time.Now().Add(10 * time.Second))
+	welcome, err := conn.Receive()
+	raw.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	} else if welcome.Type != agentMsgWelcome {
+		conn.Close()
+		return nil, fmt.Errorf("Unexpected %s message.", welcome.Type)
+	} else if welcome.Error != "" {
+		conn.Close()
+		return nil, fmt.Errorf("%s", welcome.Error)
+	}
+
+	return conn, nil
+}
+
+func (a *Agent) accept(e session.Event) bool {
+	if len(a.include) > 0 && hasPrefix(e.Tag, a.include) == false {
+		return false
+	}
+	return hasPrefix(e.Tag, a.exclude) == false
+}
+
+func (a *Agent) execute(conn *agentConn, msg agentMessage) {
+	defer a.Session.Recover()
+	result := agentMessage{Type: agentMsgResult, ID: msg.ID}
+
+	if a.commands == false {
+		result.Error = "Remote commands are disabled on this agent."
+	} else {
+		log.Info("Running command from controller: %s", msg.Command)
+		for _, cmd := range session.ParseCommands(msg.Command) {
+			if err := a.Session.Run(cmd); err != nil {
+				result.Error = err.Error()
+				break
+			}
+		}
+	}
+
+	if err := conn.Send(result); err != nil {
+		log.Debug("Error while sending command result: %s", err)
+	}
+}
+
+// Serves a single connection until it drops or the module is stopped.
+func (a *Agent) serve(conn *agentConn, quit chan bool) {
+	listener := a.Session.Events.Listen()
+	defer a.Session.Events.Unlisten(listener)
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for {
+			msg, err := conn.Receive()
+			if err != nil {
+				return
+			} else if msg.Type == agentMsgCommand {
+				go a.execute(conn, msg)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(agentPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		var err error
+
+		select {
+		case e := <-listener:
+			if a.accept(e) == false {
+				continue
+			}
+
+			data, merr := json.Marshal(e.Data)
+			if merr != nil {
+				log.Debug("Error while encoding %s event: %s", e.Tag, merr)
+				continue
+			}
+			err = conn.Send(agentMessage{Type: agentMsgEvent, Tag: e.Tag, Time: e.Time, Data: data})
+
+		case <-ticker.C:
+			err = conn.Send(agentMessage{Type: agentMsgPing})
+
+		case <-done:
+			return
+
+		case <-quit:
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (a *Agent) Start() error {
+	if a.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := a.Configure(); err != nil {
+		return err
+	}
+
+	a.SetRunning(true)
+	a.quit = make(chan bool)
+
+	go func(quit chan bool) {
+		backoff := time.Second
+
+		for a.Running() == true {
+			conn, err := a.connect()
+			if err != nil {
+				log.Warning("Error while connecting to controller %s: %s", a.controller, err)
+			} else {
+				log.Info("Registered with controller %s as %s.", a.controller, a.name)
+				backoff = time.Second
+
+				a.lock.Lock()
+				a.conn = conn
+				a.lock.Unlock()
+
+				a.serve(conn, quit)
+
+				a.lock.Lock()
+				a.conn = nil
+				a.lock.Unlock()
+
+				conn.Close()
+				if a.Running() == false {
+					return
+				}
+				log.Warning("Connection to controller %s lost.", a.controller)
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-quit:
+				return
+			}
+
+			if backoff *= 2; backoff > agentMaxBackoff {
+				backoff = agentMaxBackoff
+			}
+		}
+	}(a.quit)
+
+	return nil
+}
+
+func (a *Agent) Stop() error {
+	if a.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	a.SetRunning(false)
+	close(a.quit)
+
+	a.lock.Lock()
+	if a.conn != nil {
+		a.conn.Close()
+	}
+	a.lock.Unlock()
+
+	return nil
+}
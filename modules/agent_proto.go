@@ -0,0 +1,90 @@
+package modules
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Agents and controller exchange newline delimited JSON
+// messages over a TLS connection.
+const (
+	agentMsgHello   = "hello"
+	agentMsgWelcome = "welcome"
+	agentMsgEvent   = "event"
+	agentMsgCommand = "command"
+	agentMsgResult  = "result"
+	agentMsgPing    = "ping"
+
+	agentMaxMessage = 4 * 1024 * 1024
+	agentPingPeriod = 30 * time.Second
+)
+
+type agentMessage struct {
+	Type    string          `json:"type"`
+	ID      uint64          `json:"id,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Token   string          `json:"token,omitempty"`
+	Command string          `json:"command,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Tag     string          `json:"tag,omitempty"`
+	Time    time.Time       `json:"time,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type agentConn struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	lock    *sync.Mutex
+}
+
+func newAgentConn(conn net.Conn) *agentConn {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), agentMaxMessage)
+
+	return &agentConn{
+		conn:    conn,
+		scanner: scanner,
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (c *agentConn) Send(msg agentMessage) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(agentPingPeriod))
+	_, err = c.conn.Write(append(raw, '\n'))
+	return err
+}
+
+func (c *agentConn) Receive() (msg agentMessage, err error) {
+	if c.scanner.Scan() == false {
+		if err = c.scanner.Err(); err == nil {
+			err = io.EOF
+		}
+		return
+	}
+	err = json.Unmarshal(c.scanner.Bytes(), &msg)
+	return
+}
+
+func (c *agentConn) Close() error {
+	return c.conn.Close()
+}
+
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,394 @@
+package modules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+	"github.com/evilsocket/bettercap-ng/tls"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type GRPCAPI struct {
+	session.SessionModule
+	server   *grpc.Server
+	address  string
+	token    string
+	certFile string
+	keyFile  string
+}
+
+func NewGRPCAPI(s *session.Session) *GRPCAPI {
+	api := &GRPCAPI{
+		SessionModule: session.NewSessionModule("api.grpc", s),
+	}
+
+	api.AddParam(session.NewStringParameter("api.grpc.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the gRPC API server to."))
+
+	api.AddParam(session.NewIntParameter("api.grpc.port",
+		"8085",
+		"Port to bind the gRPC API server to."))
+
+	api.AddParam(session.NewSecretParameter("api.grpc.token",
+		"",
+		".+",
+		"API authentication token, to be sent as 'authorization: Bearer TOKEN' metadata."))
+
+	api.AddParam(session.NewStringParameter("api.grpc.certificate",
+		"~/.bcap-api.grpc.certificate.pem",
+		"",
+		"API TLS certificate."))
+
+	api.AddParam(session.NewStringParameter("api.grpc.key",
+		"~/.bcap-api.grpc.key.pem",
+		"",
+		"API TLS key"))
+
+	api.AddHandler(session.NewModuleHandler("api.grpc on", "",
+		"Start gRPC API server.",
+		func(args []string) error {
+			return api.Start()
+		}))
+
+	api.AddHandler(session.NewModuleHandler("api.grpc off", "",
+		"Stop gRPC API server.",
+		func(args []string) error {
+			return api.Stop()
+		}))
+
+	return api
+}
+
+func (api *GRPCAPI) Name() string {
+	return "api.grpc"
+}
+
+func (api *GRPCAPI) Description() string {
+	return "Expose a gRPC API, see modules/api_grpc.proto for the service definition."
+}
+
+func (api *GRPCAPI) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (api *GRPCAPI) Configure() error {
+	var err error
+	var ip string
+	var port int
+
+	if err, ip = api.StringParam("api.grpc.address"); err != nil {
+		return err
+	} else if err, port = api.IntParam("api.grpc.port"); err != nil {
+		return err
+	} else if err, api.token = api.StringParam("api.grpc.token"); err != nil {
+		return err
+	}
+	api.address = fmt.Sprintf("%s:%d", ip, port)
+
+	if err, api.certFile = api.StringParam("api.grpc.certificate"); err != nil {
+		return err
+	} else if api.certFile, err = core.ExpandPath(api.certFile); err != nil {
+		return err
+	}
+
+	if err, api.keyFile = api.StringParam("api.grpc.key"); err != nil {
+		return err
+	} else if api.keyFile, err = core.ExpandPath(api.keyFile); err != nil {
+		return err
+	}
+
+	if core.Exists(api.certFile) == false || core.Exists(api.keyFile) == false {
+		log.Info("Generating TLS key to %s", api.keyFile)
+		log.Info("Generating TLS certificate to %s", api.certFile)
+		if err := tls.Generate(api.certFile, api.keyFile); err != nil {
+			return err
+		}
+	} else {
+		log.Info("Loading TLS key from %s", api.keyFile)
+		log.Info("Loading TLS certificate from %s", api.certFile)
+	}
+
+	creds, err := credentials.NewServerTLSFromFile(api.certFile, api.keyFile)
+	if err != nil {
+		return err
+	}
+
+	api.server = grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := api.authenticate(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := api.authenticate(ss.Context()); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}))
+
+	api.server.RegisterService(&grpcServiceDesc, api)
+
+	return nil
+}
+
+func (api *GRPCAPI) authenticate(ctx context.Context) error {
+	if md, ok := metadata.FromIncomingContext(ctx); ok == true {
+		for _, auth := range md.Get("authorization") {
+			if strings.HasPrefix(auth, "Bearer ") && secureEquals(auth[7:], api.token) {
+				return nil
+			}
+		}
+	}
+	return status.Error(codes.Unauthenticated, "Invalid or missing token.")
+}
+
+func (api *GRPCAPI) Start() error {
+	if api.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := api.Configure(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", api.address)
+	if err != nil {
+		return err
+	}
+
+	api.SetRunning(true)
+	go func() {
+		log.Info("gRPC API server starting on %s", api.address)
+		if err := api.server.Serve(listener); err != nil {
+			log.Error("%s", err)
+		}
+	}()
+
+	return nil
+}
+
+func (api *GRPCAPI) Stop() error {
+	if api.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	api.SetRunning(false)
+	api.server.Stop()
+	return nil
+}
+
+// converts anything that can be encoded as a JSON object to a Struct.
+func toStruct(obj interface{}) (*structpb.Struct, error) {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+	if err = json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	return structpb.NewStruct(fields)
+}
+
+func (api *GRPCAPI) run(line string) error {
+	for _, cmd := range session.ParseCommands(line) {
+		if err := api.Session.Run(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (api *GRPCAPI) Run(ctx context.Context, cmd *wrapperspb.StringValue) (*emptypb.Empty, error) {
+	if err := api.run(cmd.GetValue()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (api *GRPCAPI) Get(ctx context.Context, name *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	if found, value := api.Session.Env.Get(name.GetValue()); found == true {
+		return wrapperspb.String(value), nil
+	}
+	return nil, status.Errorf(codes.NotFound, "%s not found", name.GetValue())
+}
+
+func (api *GRPCAPI) Set(ctx context.Context, req *structpb.Struct) (*emptypb.Empty, error) {
+	fields := req.GetFields()
+	name, value := fields["name"].GetStringValue(), fields["value"].GetStringValue()
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "Missing variable name.")
+	}
+	api.Session.Env.Set(name, value)
+	return &emptypb.Empty{}, nil
+}
+
+func (api *GRPCAPI) State(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error) {
+	state, err := toStruct(api.Session)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return state, nil
+}
+
+func (api *GRPCAPI) Events(filter *wrapperspb.StringValue, stream grpc.ServerStream) error {
+	prefixes := make([]string, 0)
+	for _, prefix := range strings.Split(filter.GetValue(), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	listener := api.Session.Events.Listen()
+	defer api.Session.Events.Unlisten(listener)
+
+	for {
+		select {
+		case e := <-listener:
+			if len(prefixes) > 0 && hasPrefix(e.Tag, prefixes) == false {
+				continue
+			}
+
+			obj, err := toStruct(e)
+			if err != nil {
+				continue
+			} else if err = stream.SendMsg(obj); err != nil {
+				return err
+			}
+
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (api *GRPCAPI) Shell(stream grpc.ServerStream) error {
+	for {
+		cmd := &wrapperspb.StringValue{}
+		if err := stream.RecvMsg(cmd); err != nil {
+			// io.EOF when the client is done
+			return nil
+		}
+
+		result := map[string]interface{}{
+			"command": cmd.GetValue(),
+			"error":   "",
+		}
+		if err := api.run(cmd.GetValue()); err != nil {
+			result["error"] = err.Error()
+		}
+
+		obj, err := structpb.NewStruct(result)
+		if err != nil {
+			return err
+		} else if err = stream.SendMsg(obj); err != nil {
+			return err
+		}
+	}
+}
+
+// hand written equivalent of what protoc would generate from api_grpc.proto
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bettercap.Session",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Run",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &wrapperspb.StringValue{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return grpcUnary(srv, ctx, in, "Run", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(*GRPCAPI).Run(ctx, req.(*wrapperspb.StringValue))
+				})
+			},
+		},
+		{
+			MethodName: "Get",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &wrapperspb.StringValue{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return grpcUnary(srv, ctx, in, "Get", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(*GRPCAPI).Get(ctx, req.(*wrapperspb.StringValue))
+				})
+			},
+		},
+		{
+			MethodName: "Set",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &structpb.Struct{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return grpcUnary(srv, ctx, in, "Set", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(*GRPCAPI).Set(ctx, req.(*structpb.Struct))
+				})
+			},
+		},
+		{
+			MethodName: "State",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &emptypb.Empty{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return grpcUnary(srv, ctx, in, "State", interceptor, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(*GRPCAPI).State(ctx, req.(*emptypb.Empty))
+				})
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Events",
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				in := &wrapperspb.StringValue{}
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				return srv.(*GRPCAPI).Events(in, stream)
+			},
+		},
+		{
+			StreamName:    "Shell",
+			ServerStreams: true,
+			ClientStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*GRPCAPI).Shell(stream)
+			},
+		},
+	},
+	Metadata: "modules/api_grpc.proto",
+}
+
+func grpcUnary(srv interface{}, ctx context.Context, in interface{}, method string, interceptor grpc.UnaryServerInterceptor, handler grpc.UnaryHandler) (interface{}, error) {
+	if interceptor == nil {
+		return handler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bettercap.Session/" + method,
+	}
+	return interceptor(ctx, in, info, handler)
+}
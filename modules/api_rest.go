@@ -42,11 +42,31 @@ func NewRestAPI(s *session.Session) *RestAPI {
 		".+",
 		"API authentication username."))
 
-	api.AddParam(session.NewStringParameter("api.rest.password",
+	api.AddParam(session.NewSecretParameter("api.rest.password",
 		"",
 		".+",
 		"API authentication password."))
 
+	api.AddParam(session.NewSecretParameter("api.rest.token",
+		"",
+		"",
+		"If set, requests with an 'Authorization: Bearer TOKEN' header will be accepted too."))
+
+	api.AddParam(session.NewStringParameter("api.rest.readonly.username",
+		"",
+		"",
+		"If set, username of a read only account which can only perform GET requests."))
+
+	api.AddParam(session.NewSecretParameter("api.rest.readonly.password",
+		"",
+		"",
+		"Password of the read only account."))
+
+	api.AddParam(session.NewSecretParameter("api.rest.readonly.token",
+		"",
+		"",
+		"If set, bearer token of the read only account."))
+
 	api.AddParam(session.NewStringParameter("api.rest.certificate",
 		"~/.bcap-api.rest.certificate.pem",
 		"",
@@ -126,6 +146,21 @@ func (api *RestAPI) Configure() error {
 		return err
 	}
 
+	accounts := []APIAccount{{Username: username, Password: password}}
+	readonly := APIAccount{ReadOnly: true}
+
+	if err, accounts[0].Token = api.StringParam("api.rest.token"); err != nil {
+		return err
+	} else if err, readonly.Username = api.StringParam("api.rest.readonly.username"); err != nil {
+		return err
+	} else if err, readonly.Password = api.StringParam("api.rest.readonly.password"); err != nil {
+		return err
+	} else if err, readonly.Token = api.StringParam("api.rest.readonly.token"); err != nil {
+		return err
+	} else if (readonly.Username != "" && readonly.Password != "") || readonly.Token != "" {
+		accounts = append(accounts, readonly)
+	}
+
 	if core.Exists(api.certFile) == false || core.Exists(api.keyFile) == false {
 		log.Info("Generating TLS key to %s", api.keyFile)
 		log.Info("Generating TLS certificate to %s", api.certFile)
@@ -141,17 +176,26 @@ func (api *RestAPI) Configure() error {
 
 	api.router = gin.New()
 	api.router.Use(SecurityMiddleware())
-	api.router.Use(gin.BasicAuth(gin.Accounts{username: password}))
+	api.router.Use(AuthMiddleware(accounts))
+
+	setupRestRoutes(api.router)
+
+	api.server.Handler = api.router
+
+	return nil
+}
 
-	group := api.router.Group("/api")
+// Registers the API endpoints, shared by the api.rest and ui modules.
+func setupRestRoutes(router *gin.Engine) {
+	group := router.Group("/api")
 	group.GET("/session", ShowRestSession)
 	group.POST("/session", RunRestCommand)
+	group.GET("/session/modules", ShowRestModules)
+	group.GET("/session/timeline/:ip", ShowRestTimeline)
 	group.GET("/events", ShowRestEvents)
 	group.DELETE("/events", ClearRestEvents)
 
-	api.server.Handler = api.router
-
-	return nil
+	router.GET("/metrics", ShowRestMetrics)
 }
 
 func (api *RestAPI) Start() error {
@@ -1,15 +1,53 @@
 package modules
 
 import (
+	"net"
+	"sort"
 	"strconv"
 
 	"github.com/evilsocket/bettercap-ng/session"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
+// The session with the secret variables redacted.
+type restSession struct {
+	*session.Session
+	Env restEnvironment `json:"env"`
+}
+
+type restEnvironment struct {
+	Storage map[string]string `json:"storage"`
+}
+
+// Returns the value of the variable, redacted if it's
+// secret and the account is read only.
+func restValue(c *gin.Context, name string, value string) string {
+	if isReadOnly(c) == true && session.I.IsSecret(name) == true {
+		return session.RedactedValue
+	}
+	return value
+}
+
 func ShowRestSession(c *gin.Context) {
-	c.JSON(200, session.I)
+	if isReadOnly(c) == false {
+		c.JSON(200, session.I)
+		return
+	}
+
+	env := restEnvironment{Storage: make(map[string]string)}
+	session.I.Env.Lock()
+	for name, value := range session.I.Env.Storage {
+		env.Storage[name] = value
+	}
+	session.I.Env.Unlock()
+
+	for name, value := range env.Storage {
+		env.Storage[name] = restValue(c, name, value)
+	}
+
+	c.JSON(200, restSession{session.I, env})
 }
 
 func RunRestCommand(c *gin.Context) {
@@ -18,6 +56,7 @@ func RunRestCommand(c *gin.Context) {
 
 	if err = SafeBind(c, &cmd); err != nil {
 		BadRequest(c)
+		return
 	}
 
 	err = session.I.Run(cmd.Command)
@@ -28,27 +67,101 @@ func RunRestCommand(c *gin.Context) {
 	}
 }
 
+type RestModuleParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Value       string `json:"value"`
+	Default     string `json:"default"`
+}
+
+type RestModule struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Author      string            `json:"author"`
+	Running     bool              `json:"running"`
+	Parameters  []RestModuleParam `json:"parameters"`
+}
+
+func ShowRestModules(c *gin.Context) {
+	modules := make([]RestModule, 0)
+	for _, m := range session.I.Modules {
+		mod := RestModule{
+			Name:        m.Name(),
+			Description: m.Description(),
+			Author:      m.Author(),
+			Running:     m.Running(),
+			Parameters:  make([]RestModuleParam, 0),
+		}
+
+		for name, p := range m.Parameters() {
+			_, value := session.I.Env.Get(name)
+			mod.Parameters = append(mod.Parameters, RestModuleParam{
+				Name:        name,
+				Description: p.Description,
+				Value:       restValue(c, name, value),
+				Default:     restValue(c, name, p.Value),
+			})
+		}
+		sort.Slice(mod.Parameters, func(i, j int) bool {
+			return mod.Parameters[i].Name < mod.Parameters[j].Name
+		})
+
+		modules = append(modules, mod)
+	}
+
+	c.JSON(200, modules)
+}
+
+func queryInt(c *gin.Context, name string, def int) int {
+	if v := c.Query(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// Events are sorted from the most recent, use ?n=N to get N
+// events and ?offset=M to skip the M most recent ones, websocket
+// clients will get events in real time instead.
 func ShowRestEvents(c *gin.Context) {
-	var err error
+	if websocket.IsWebSocketUpgrade(c.Request) == true {
+		streamRestEvents(c)
+		return
+	}
 
 	events := session.I.Events.Events()
-	nmax := len(events)
-	n := nmax
-
-	q := c.Request.URL.Query()
-	vals := q["n"]
-	if len(vals) > 0 {
-		n, err = strconv.Atoi(q["n"][0])
-		if err == nil {
-			if n > nmax {
-				n = nmax
-			}
-		} else {
-			n = nmax
-		}
+	total := len(events)
+
+	offset := queryInt(c, "offset", 0)
+	if offset > total {
+		offset = total
+	}
+
+	n := queryInt(c, "n", total)
+	if offset+n > total {
+		n = total - offset
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(200, events[offset:offset+n])
+}
+
+// Events involving a single device, from the oldest to the most recent.
+func ShowRestTimeline(c *gin.Context) {
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		BadRequest(c, "Invalid IP address.")
+		return
 	}
 
-	c.JSON(200, events[0:n])
+	c.JSON(200, targetTimeline(session.I, ip))
+}
+
+func ShowRestMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4")
+	c.Status(200)
+	session.WriteMetrics(c.Writer, session.I.Metrics())
 }
 
 func ClearRestEvents(c *gin.Context) {
@@ -1,7 +1,9 @@
 package modules
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/evilsocket/bettercap-ng/log"
@@ -33,3 +35,63 @@ func SecurityMiddleware() gin.HandlerFunc {
 		}
 	}
 }
+
+// Credentials accepted by the API, read only accounts can
+// only use GET requests.
+type APIAccount struct {
+	Username string
+	Password string
+	Token    string
+	ReadOnly bool
+}
+
+// Read only accounts can't see secret parameters, or they
+// could log in with the credentials of the other accounts.
+func isReadOnly(c *gin.Context) bool {
+	return c.GetBool("readonly")
+}
+
+func secureEquals(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func (a APIAccount) matches(c *gin.Context) bool {
+	if a.Token != "" {
+		auth := c.GetHeader("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") && secureEquals(auth[7:], a.Token) {
+			return true
+		}
+	}
+
+	if a.Username != "" && a.Password != "" {
+		if user, pass, ok := c.Request.BasicAuth(); ok == true {
+			return secureEquals(user, a.Username) && secureEquals(pass, a.Password)
+		}
+	}
+
+	return false
+}
+
+// Authenticates requests with basic auth or a bearer token.
+func AuthMiddleware(accounts []APIAccount) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, account := range accounts {
+			if account.matches(c) == false {
+				continue
+			} else if account.ReadOnly == true && c.Request.Method != "GET" {
+				c.AbortWithStatusJSON(http.StatusForbidden, APIResponse{
+					Success: false,
+					Message: "This account is read only.",
+				})
+				return
+			}
+
+			c.Set("readonly", account.ReadOnly)
+			c.Next()
+			return
+		}
+
+		c.Header("WWW-Authenticate", "Basic realm=\"Authorization Required\"")
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
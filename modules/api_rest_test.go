@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRestReadOnlySecrets(t *testing.T) {
+	sess := &session.Session{}
+	sess.Env = session.NewEnvironment(sess)
+	sess.Events = session.NewEventPool(false, true, 64)
+	sess.Register(NewRestAPI(sess))
+	session.I = sess
+
+	secrets := map[string]string{
+		"api.rest.password":          "admin-password-secret",
+		"api.rest.token":             "admin-token-secret",
+		"api.rest.readonly.password": "readonly-password-secret",
+		"api.rest.readonly.token":    "readonly-token-secret",
+	}
+	for name, value := range secrets {
+		sess.Env.Set(name, value)
+	}
+	sess.Env.Set("api.rest.readonly.username", "viewer")
+
+	accounts := []APIAccount{
+		{Username: "admin", Password: secrets["api.rest.password"], Token: secrets["api.rest.token"]},
+		{Username: "viewer", Password: secrets["api.rest.readonly.password"], Token: secrets["api.rest.readonly.token"], ReadOnly: true},
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(AuthMiddleware(accounts))
+	setupRestRoutes(router)
+
+	get := func(path string, username string, password string) string {
+		req := httptest.NewRequest("GET", path, nil)
+		req.SetBasicAuth(username, password)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		if res.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s as %s, got %d", path, username, res.Code)
+		}
+		return res.Body.String()
+	}
+
+	for _, path := range []string{"/api/session", "/api/session/modules", "/api/events"} {
+		body := get(path, "viewer", secrets["api.rest.readonly.password"])
+		for name, value := range secrets {
+			if strings.Contains(body, value) == true {
+				t.Fatalf("%s: the read only account got the value of %s", path, name)
+			}
+		}
+		if path != "/api/events" && strings.Contains(body, "viewer") == false {
+			t.Fatalf("%s: expected the other variables to be returned", path)
+		}
+	}
+
+	// the full control account still sees them
+	if body := get("/api/session", "admin", secrets["api.rest.password"]); strings.Contains(body, secrets["api.rest.token"]) == false {
+		t.Fatalf("Expected the full control account to get the secrets.")
+	}
+}
@@ -0,0 +1,102 @@
+package modules
+
+import (
+	"strings"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// time allowed to write an event to the client
+	wsWriteWait = 10 * time.Second
+	// time allowed to read the next pong from the client
+	wsPongWait = 60 * time.Second
+	// must be less than wsPongWait
+	wsPingPeriod = (wsPongWait * 9) / 10
+)
+
+// Used by both api.rest and the ui dashboard, the default origin check
+// refuses cross origin requests so that other pages opened in a browser
+// logged into the API can't read the events with its credentials.
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+func queryList(c *gin.Context, name string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(c.Query(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// Pushes events to the client as they're fired, ?filter=a,b only sends
+// events whose type starts with a or b and ?exclude=c,d skips the ones
+// starting with c or d.
+func streamRestEvents(c *gin.Context) {
+	include := queryList(c, "filter")
+	exclude := queryList(c, "exclude")
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Error("Error while upgrading events websocket: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	listener := session.I.Events.Listen()
+	defer session.I.Events.Unlisten(listener)
+
+	// we don't expect anything from the client, but we need to
+	// read in order to process pongs and close messages.
+	closed := make(chan bool)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		return nil
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-listener:
+			if len(include) > 0 && hasPrefix(e.Tag, include) == false {
+				continue
+			} else if hasPrefix(e.Tag, exclude) == true {
+				continue
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-closed:
+			return
+		}
+	}
+}
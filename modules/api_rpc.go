@@ -0,0 +1,412 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+// Longest pause between two accepts after temporary errors.
+const acceptMaxDelay = 1 * time.Second
+
+// Returns how long to wait before accepting again after err, doubling the
+// previous delay like net/http.Server does, or false if the error is not
+// temporary and the listener should be abandoned.
+func acceptBackoff(err error, delay time.Duration) (time.Duration, bool) {
+	if ne, ok := err.(net.Error); ok == false || ne.Temporary() == false {
+		return 0, false
+	} else if delay == 0 {
+		delay = 5 * time.Millisecond
+	} else if delay *= 2; delay > acceptMaxDelay {
+		delay = acceptMaxDelay
+	}
+	return delay, true
+}
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcNotification struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcClient struct {
+	conn    net.Conn
+	encoder *json.Encoder
+	lock    *sync.Mutex
+	quit    chan bool
+	filter  []string
+}
+
+func (c *rpcClient) send(obj interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.encoder.Encode(obj)
+}
+
+type RPCAPI struct {
+	session.SessionModule
+	path     string
+	listener net.Listener
+	clients  map[*rpcClient]bool
+	lock     *sync.Mutex
+}
+
+func NewRPCAPI(s *session.Session) *RPCAPI {
+	api := &RPCAPI{
+		SessionModule: session.NewSessionModule("api.rpc", s),
+		clients:       make(map[*rpcClient]bool),
+		lock:          &sync.Mutex{},
+	}
+
+	api.AddParam(session.NewStringParameter("api.rpc.socket",
+		"/var/run/bettercap-ng.sock",
+		"",
+		"Path of the unix socket to listen on."))
+
+	api.AddParam(session.NewStringParameter("api.rpc.mode",
+		"0600",
+		"^0?[0-7]{3}$",
+		"Permissions of the unix socket."))
+
+	api.AddHandler(session.NewModuleHandler("api.rpc on", "",
+		"Start the JSON-RPC server.",
+		func(args []string) error {
+			return api.Start()
+		}))
+
+	api.AddHandler(session.NewModuleHandler("api.rpc off", "",
+		"Stop the JSON-RPC server.",
+		func(args []string) error {
+			return api.Stop()
+		}))
+
+	return api
+}
+
+func (api RPCAPI) Name() string {
+	return "api.rpc"
+}
+
+func (api RPCAPI) Description() string {
+	return "Expose a JSON-RPC 2.0 API on a local unix socket."
+}
+
+func (api RPCAPI) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (api *RPCAPI) Configure() (err error) {
+	var mode string
+	var perm uint64
+
+	if err, api.path = api.StringParam("api.rpc.socket"); err != nil {
+		return err
+	} else if api.path, err = core.ExpandPath(api.path); err != nil {
+		return err
+	} else if err, mode = api.StringParam("api.rpc.mode"); err != nil {
+		return err
+	} else if _, err = fmt.Sscanf(mode, "%o", &perm); err != nil {
+		return fmt.Errorf("Invalid api.rpc.mode %s: %s", mode, err)
+	}
+
+	// a leftover from a previous session would make Listen fail
+	if core.Exists(api.path) == true {
+		if conn, err := net.Dial("unix", api.path); err == nil {
+			conn.Close()
+			return fmt.Errorf("%s is already in use.", api.path)
+		} else if err = os.Remove(api.path); err != nil {
+			return err
+		}
+	}
+
+	if api.listener, err = net.Listen("unix", api.path); err != nil {
+		return err
+	}
+
+	// don't leave the socket reachable with the default permissions
+	if err = os.Chmod(api.path, os.FileMode(perm)); err != nil {
+		api.listener.Close()
+		api.listener = nil
+		return err
+	}
+
+	return nil
+}
+
+func (api *RPCAPI) Start() error {
+	if api.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := api.Configure(); err != nil {
+		return err
+	}
+
+	api.SetRunning(true)
+
+	go func() {
+		log.Info("JSON-RPC server listening on %s", api.path)
+
+		var delay time.Duration
+		for {
+			conn, err := api.listener.Accept()
+			if err != nil {
+				if api.Running() == false {
+					return
+				}
+
+				retry := false
+				if delay, retry = acceptBackoff(err, delay); retry == false {
+					log.Error("JSON-RPC server stopped accepting connections: %s", err)
+					return
+				}
+				log.Warning("Error while accepting JSON-RPC connection: %s, retrying in %s.", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			delay = 0
+			go api.serve(conn)
+		}
+	}()
+
+	return nil
+}
+
+func (api *RPCAPI) serve(conn net.Conn) {
+	defer api.Session.Recover()
+	client := &rpcClient{
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+		lock:    &sync.Mutex{},
+	}
+
+	api.lock.Lock()
+	api.clients[client] = true
+	api.lock.Unlock()
+
+	defer func() {
+		api.lock.Lock()
+		delete(api.clients, client)
+		api.lock.Unlock()
+
+		if client.quit != nil {
+			close(client.quit)
+		}
+		conn.Close()
+	}()
+
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok == true {
+				client.send(rpcResponse{Version: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, err.Error()}})
+			}
+			return
+		}
+
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '[' {
+			api.batch(client, raw)
+		} else if res := api.dispatch(client, raw); res != nil {
+			client.send(res)
+		}
+	}
+}
+
+func (api *RPCAPI) batch(client *rpcClient, raw json.RawMessage) {
+	var requests []json.RawMessage
+	if err := json.Unmarshal(raw, &requests); err != nil || len(requests) == 0 {
+		client.send(rpcResponse{Version: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcInvalidRequest, "Invalid batch."}})
+		return
+	}
+
+	responses := make([]*rpcResponse, 0)
+	for _, req := range requests {
+		if res := api.dispatch(client, req); res != nil {
+			responses = append(responses, res)
+		}
+	}
+
+	if len(responses) > 0 {
+		client.send(responses)
+	}
+}
+
+// Returns nil for notifications, which must not be answered.
+func (api *RPCAPI) dispatch(client *rpcClient, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+
+	res := &rpcResponse{Version: "2.0", ID: json.RawMessage("null")}
+	if err := json.Unmarshal(raw, &req); err != nil || req.Version != "2.0" || req.Method == "" {
+		res.Error = &rpcError{rpcInvalidRequest, "Invalid request."}
+		return res
+	}
+
+	result, rerr := api.call(client, req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+
+	res.ID = req.ID
+	if rerr != nil {
+		res.Error = rerr
+	} else if result == nil {
+		res.Result = true
+	} else {
+		res.Result = result
+	}
+	return res
+}
+
+func (api *RPCAPI) call(client *rpcClient, method string, raw json.RawMessage) (interface{}, *rpcError) {
+	params := struct {
+		Command string `json:"command"`
+		Name    string `json:"name"`
+		Value   string `json:"value"`
+		N       int    `json:"n"`
+		Filter  string `json:"filter"`
+	}{}
+
+	if raw != nil {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, "Params must be an object."}
+		}
+	}
+
+	switch method {
+	case "run":
+		if params.Command == "" {
+			return nil, &rpcError{rpcInvalidParams, "Missing command."}
+		}
+		for _, cmd := range session.ParseCommands(params.Command) {
+			if err := api.Session.Run(cmd); err != nil {
+				return nil, &rpcError{rpcServerError, err.Error()}
+			}
+		}
+		return nil, nil
+
+	case "get":
+		if found, value := api.Session.Env.Get(params.Name); found == true {
+			return value, nil
+		}
+		return nil, &rpcError{rpcServerError, fmt.Sprintf("%s not found", params.Name)}
+
+	case "set":
+		if params.Name == "" {
+			return nil, &rpcError{rpcInvalidParams, "Missing variable name."}
+		}
+		api.Session.Env.Set(params.Name, params.Value)
+		return nil, nil
+
+	case "session":
+		return api.Session, nil
+
+	case "events":
+		events := api.Session.Events.Events()
+		// from the newest to the oldest
+		if params.N > 0 && params.N < len(events) {
+			events = events[:params.N]
+		}
+		return events, nil
+
+	case "subscribe":
+		return nil, api.subscribe(client, params.Filter)
+
+	case "unsubscribe":
+		if client.quit != nil {
+			close(client.quit)
+			client.quit = nil
+		}
+		return nil, nil
+	}
+
+	return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("Method %s not found.", method)}
+}
+
+// Events are pushed to subscribed clients as "event" notifications.
+func (api *RPCAPI) subscribe(client *rpcClient, filter string) *rpcError {
+	if client.quit != nil {
+		return &rpcError{rpcServerError, "Already subscribed."}
+	}
+
+	client.filter = make([]string, 0)
+	for _, prefix := range strings.Split(filter, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			client.filter = append(client.filter, prefix)
+		}
+	}
+	client.quit = make(chan bool)
+
+	go func(quit chan bool, filter []string) {
+		listener := api.Session.Events.Listen()
+		defer api.Session.Events.Unlisten(listener)
+
+		for {
+			select {
+			case e := <-listener:
+				if len(filter) > 0 && hasPrefix(e.Tag, filter) == false {
+					continue
+				} else if err := client.send(rpcNotification{Version: "2.0", Method: "event", Params: e}); err != nil {
+					return
+				}
+
+			case <-quit:
+				return
+			}
+		}
+	}(client.quit, client.filter)
+
+	return nil
+}
+
+func (api *RPCAPI) Stop() error {
+	if api.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	api.SetRunning(false)
+	api.listener.Close()
+
+	api.lock.Lock()
+	for client := range api.clients {
+		client.conn.Close()
+	}
+	api.lock.Unlock()
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package modules
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type testNetError struct {
+	temporary bool
+}
+
+func (e testNetError) Error() string   { return "accept failed" }
+func (e testNetError) Timeout() bool   { return false }
+func (e testNetError) Temporary() bool { return e.temporary }
+
+var _ net.Error = testNetError{}
+
+func TestAcceptBackoff(t *testing.T) {
+	tests := []struct {
+		err      error
+		delay    time.Duration
+		expected time.Duration
+		retry    bool
+	}{
+		{testNetError{true}, 0, 5 * time.Millisecond, true},
+		{testNetError{true}, 5 * time.Millisecond, 10 * time.Millisecond, true},
+		{testNetError{true}, 640 * time.Millisecond, time.Second, true},
+		{testNetError{true}, time.Second, time.Second, true},
+		{testNetError{false}, 5 * time.Millisecond, 0, false},
+		{errors.New("use of closed network connection"), 0, 0, false},
+	}
+
+	for _, test := range tests {
+		delay, retry := acceptBackoff(test.err, test.delay)
+		if delay != test.expected || retry != test.retry {
+			t.Fatalf("Expected %s/%v after %s, got %s/%v", test.expected, test.retry, test.delay, delay, retry)
+		}
+	}
+}
@@ -81,6 +81,7 @@ func (p *ArpSpoofer) getMAC(ip net.IP, probe bool) (net.HardwareAddr, error) {
 		if err, probe := packets.NewUDPProbe(from, from_hw, ip, 139); err != nil {
 			log.Error("Error while creating UDP probe packet for %s: %s", ip.String(), err)
 		} else {
+			p.Session.RateLimit.Wait("arp.spoof")
 			p.Session.Queue.Send(probe)
 		}
 
@@ -120,6 +121,10 @@ func (p *ArpSpoofer) sendArp(saddr net.IP, smac net.HardwareAddr, check_running
 		if err, pkt := packets.NewARPReply(saddr, smac, ip, hw); err != nil {
 			log.Error("Error while creating ARP spoof packet for %s: %s", ip.String(), err)
 		} else {
+			// restoring the caches on exit is not delayed
+			if check_running == true {
+				p.Session.RateLimit.Wait("arp.spoof")
+			}
 			log.Debug("Sending %d bytes of ARP packet to %s:%s.", len(pkt), ip.String(), hw.String())
 			p.Session.Queue.Send(pkt)
 		}
@@ -132,7 +137,14 @@ func (p *ArpSpoofer) unSpoof() error {
 
 	log.Info("Restoring ARP cache of %d targets.", len(p.addresses))
 
-	p.sendArp(from, from_hw, false, false)
+	// a single packet might get lost, which would leave
+	// the target unable to reach the gateway
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			time.Sleep(500 * time.Millisecond)
+		}
+		p.sendArp(from, from_hw, false, false)
+	}
 
 	return nil
 }
@@ -161,14 +173,17 @@ func (p *ArpSpoofer) Start() error {
 	}
 
 	p.SetRunning(true)
+	p.Session.Journal.Push("arp.spoof", fmt.Sprintf("ARP poisoning of %d targets", len(p.addresses)), p.unSpoof)
+
 	go func() {
+		defer p.Session.Recover()
 		from := p.Session.Gateway.IP
-		from_hw := p.Session.Interface.HW
 
 		log.Info("ARP spoofer started, probing %d targets.", len(p.addresses))
 
 		for p.Running() {
-			p.sendArp(from, from_hw, true, false)
+			// read at each round as mac.changer might have changed it
+			p.sendArp(from, p.Session.Interface.HW, true, false)
 			time.Sleep(1 * time.Second)
 		}
 
@@ -190,6 +205,17 @@ func (p *ArpSpoofer) Stop() error {
 	<-p.done
 
 	p.unSpoof()
+	p.Session.Journal.Remove("arp.spoof")
 
 	return nil
 }
+
+func (p *ArpSpoofer) Metrics() []session.Metric {
+	poisoned := 0
+	if p.Running() == true {
+		poisoned = len(p.addresses)
+	}
+	return []session.Metric{
+		session.NewMetric("arp_spoof_targets", "Number of addresses being ARP poisoned.", session.MetricGauge, float64(poisoned)),
+	}
+}
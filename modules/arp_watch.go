@@ -0,0 +1,370 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	network "github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/packets"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"github.com/malfunkt/iprange"
+)
+
+const (
+	// the same poisoning attempt is reported once in this interval
+	arpWatchAlertInterval = 60 * time.Second
+	// corrections for the same address are sent at most once in this interval
+	arpWatchCorrectInterval = 1 * time.Second
+)
+
+// Emitted when an ARP packet contradicts the known binding of an address.
+type ArpSpoofAlert struct {
+	IP       string `json:"ip"`
+	Expected string `json:"expected"`
+	Seen     string `json:"seen"`
+	Gateway  bool   `json:"gateway"`
+	// other addresses the seen hardware address is bound to, usually
+	// the actual address of the attacker
+	Claims    []string `json:"claims"`
+	Corrected bool     `json:"corrected"`
+}
+
+type ArpWatcher struct {
+	session.SessionModule
+	handle    *pcap.Handle
+	watched   map[string]bool
+	correct   bool
+	bindings  map[string]net.HardwareAddr
+	alerted   map[string]time.Time
+	corrected map[string]time.Time
+	alerts    uint64
+	lock      sync.Mutex
+}
+
+func NewArpWatcher(s *session.Session) *ArpWatcher {
+	w := &ArpWatcher{
+		SessionModule: session.NewSessionModule("arp.watch", s),
+		watched:       make(map[string]bool),
+		bindings:      make(map[string]net.HardwareAddr),
+		alerted:       make(map[string]time.Time),
+		corrected:     make(map[string]time.Time),
+	}
+
+	w.AddParam(session.NewStringParameter("arp.watch.targets",
+		session.ParamSubnet,
+		"",
+		"IP addresses to watch for ARP poisoning."))
+
+	w.AddParam(session.NewBoolParameter("arp.watch.correct",
+		"false",
+		"If true, a gratuitous ARP reply with the expected binding is broadcast whenever poisoning is detected."))
+
+	w.AddHandler(session.NewModuleHandler("arp.watch on", "",
+		"Start watching for ARP poisoning.",
+		func(args []string) error {
+			return w.Start()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("arp.watch off", "",
+		"Stop watching for ARP poisoning.",
+		func(args []string) error {
+			return w.Stop()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("arp.watch.show", "",
+		"Show the known IP to MAC bindings.",
+		func(args []string) error {
+			return w.Show()
+		}))
+
+	return w
+}
+
+func (w *ArpWatcher) Name() string {
+	return "arp.watch"
+}
+
+func (w *ArpWatcher) Description() string {
+	return "Detects ARP poisoning by comparing ARP traffic with a baseline of the IP to MAC bindings, optionally correcting them."
+}
+
+func (w *ArpWatcher) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (w *ArpWatcher) Configure() error {
+	var err error
+	var targets string
+
+	if err, targets = w.StringParam("arp.watch.targets"); err != nil {
+		return err
+	} else if err, w.correct = w.BoolParam("arp.watch.correct"); err != nil {
+		return err
+	}
+
+	list, err := iprange.Parse(targets)
+	if err != nil {
+		return fmt.Errorf("Error while parsing arp.watch.targets variable '%s': %s.", targets, err)
+	}
+
+	w.watched = make(map[string]bool)
+	for _, ip := range list.Expand() {
+		w.watched[ip.String()] = true
+	}
+
+	if w.handle, err = pcap.OpenLive(w.Session.Interface.Name(), 1024, true, pcap.BlockForever); err != nil {
+		return err
+	} else if err = w.handle.SetBPFFilter("arp"); err != nil {
+		w.handle.Close()
+		return err
+	}
+
+	w.baseline()
+
+	return nil
+}
+
+// Seeds the bindings with the interface, the gateway and the system
+// ARP cache, addresses seen later on are trusted the first time.
+func (w *ArpWatcher) baseline() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.bindings = make(map[string]net.HardwareAddr)
+	w.alerted = make(map[string]time.Time)
+	w.corrected = make(map[string]time.Time)
+
+	if table, err := network.ArpUpdate(w.Session.Interface.Name()); err != nil {
+		log.Warning("Could not read the ARP cache: %s", err)
+	} else {
+		for ip, mac := range table {
+			if hw, err := net.ParseMAC(mac); err == nil {
+				w.bindings[ip] = hw
+			}
+		}
+	}
+
+	w.bindings[w.Session.Interface.IpAddress] = w.Session.Interface.HW
+
+	gw := w.Session.Gateway
+	if gw == nil || gw == w.Session.Interface {
+		return
+	}
+	w.bindings[gw.IpAddress] = gw.HW
+
+	// if the gateway shares its MAC with another host the cache
+	// might be poisoned already and the baseline can't be trusted
+	if claims := w.claims(gw.HW, gw.IpAddress); len(claims) > 0 {
+		w.alert(ArpSpoofAlert{
+			IP:      gw.IpAddress,
+			Seen:    gw.HwAddress,
+			Gateway: true,
+			Claims:  claims,
+		})
+	}
+}
+
+// Returns the other addresses bound to the hardware address.
+func (w *ArpWatcher) claims(mac net.HardwareAddr, except string) []string {
+	claims := make([]string, 0)
+	for ip, hw := range w.bindings {
+		if ip != except && bytes.Equal(hw, mac) {
+			claims = append(claims, ip)
+		}
+	}
+	sort.Strings(claims)
+	return claims
+}
+
+func (w *ArpWatcher) alert(alert ArpSpoofAlert) {
+	key := alert.IP + "|" + alert.Seen
+	if last, found := w.alerted[key]; found == true && time.Since(last) < arpWatchAlertInterval {
+		return
+	}
+	w.alerted[key] = time.Now()
+	w.alerts++
+
+	who := alert.IP
+	if alert.Gateway == true {
+		who = fmt.Sprintf("gateway %s", alert.IP)
+	}
+
+	if alert.Expected == "" {
+		log.Warning("[%s] The %s MAC %s is also bound to %s, the ARP cache might already be poisoned.",
+			core.Red("arp.watch"), who, alert.Seen, strings.Join(alert.Claims, ", "))
+	} else {
+		log.Warning("[%s] %s claimed by %s instead of %s.", core.Red("arp.watch"), core.Bold(who), core.Red(alert.Seen), alert.Expected)
+	}
+
+	w.Session.Events.Add("arp.watch.spoof", alert)
+}
+
+// Broadcasts the expected binding, the ethernet source is the interface
+// so that switches don't learn the address on the wrong port.
+func (w *ArpWatcher) sendCorrection(ip net.IP, mac net.HardwareAddr) bool {
+	addr := ip.String()
+	if last, found := w.corrected[addr]; found == true && time.Since(last) < arpWatchCorrectInterval {
+		return true
+	}
+	w.corrected[addr] = time.Now()
+
+	broadcast := net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	eth, arp := packets.NewARPTo(ip, mac, ip, broadcast, layers.ARPReply)
+	eth.SrcMAC = w.Session.Interface.HW
+
+	if err, raw := packets.Serialize(&eth, &arp); err != nil {
+		log.Error("Error while creating ARP correction packet for %s: %s", addr, err)
+		return false
+	} else if err := w.Session.Queue.Send(raw); err != nil {
+		log.Error("Error while sending ARP correction packet for %s: %s", addr, err)
+		return false
+	}
+
+	log.Debug("Sent ARP correction %s is at %s.", addr, mac)
+	return true
+}
+
+func (w *ArpWatcher) onPacket(pkt gopacket.Packet) {
+	leth := pkt.Layer(layers.LayerTypeEthernet)
+	larp := pkt.Layer(layers.LayerTypeARP)
+	if leth == nil || larp == nil {
+		return
+	}
+
+	eth := leth.(*layers.Ethernet)
+	arp := larp.(*layers.ARP)
+
+	// our own packets, corrections included
+	if bytes.Equal(eth.SrcMAC, w.Session.Interface.HW) {
+		return
+	}
+
+	ip := net.IP(arp.SourceProtAddress)
+	mac := net.HardwareAddr(arp.SourceHwAddress)
+	addr := ip.String()
+
+	// ARP probes have no sender address
+	if ip.IsUnspecified() || w.Session.Interface.Net.Contains(ip) == false || w.watched[addr] == false {
+		return
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	expected, found := w.bindings[addr]
+	if found == false {
+		log.Debug("[%s] Learned %s is at %s.", core.Green("arp.watch"), addr, mac)
+		w.bindings[addr] = mac
+		return
+	} else if bytes.Equal(expected, mac) {
+		return
+	}
+
+	alert := ArpSpoofAlert{
+		IP:       addr,
+		Expected: expected.String(),
+		Seen:     mac.String(),
+		Gateway:  w.Session.Gateway != nil && addr == w.Session.Gateway.IpAddress,
+		Claims:   w.claims(mac, addr),
+	}
+
+	if w.correct == true {
+		alert.Corrected = w.sendCorrection(ip, expected)
+	}
+
+	w.alert(alert)
+}
+
+func (w *ArpWatcher) Show() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.bindings) == 0 {
+		fmt.Println(core.Dim("No bindings, arp.watch is not running."))
+		return nil
+	}
+
+	addrs := make([]string, 0, len(w.bindings))
+	for ip := range w.bindings {
+		addrs = append(addrs, ip)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(addrs[i]).To16(), net.ParseIP(addrs[j]).To16()) < 0
+	})
+
+	data := make([][]string, 0, len(addrs))
+	for _, ip := range addrs {
+		mac := w.bindings[ip].String()
+		name := ""
+		if ip == w.Session.Interface.IpAddress {
+			name = "interface"
+		} else if w.Session.Gateway != nil && ip == w.Session.Gateway.IpAddress {
+			name = "gateway"
+		}
+		data = append(data, []string{ip, mac, network.OuiLookup(mac), name})
+	}
+
+	core.AsTable(os.Stdout, []string{"IP", "MAC", "Vendor", ""}, data)
+
+	fmt.Printf("\n%d alerts so far.\n\n", w.alerts)
+
+	return nil
+}
+
+func (w *ArpWatcher) Start() error {
+	if w.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := w.Configure(); err != nil {
+		return err
+	}
+
+	w.SetRunning(true)
+
+	log.Info("ARP watcher started with %d known bindings.", len(w.bindings))
+
+	go func(handle *pcap.Handle) {
+		defer w.Session.Recover()
+		defer handle.Close()
+
+		src := gopacket.NewPacketSource(handle, handle.LinkType())
+		for packet := range src.Packets() {
+			if w.Running() == false {
+				break
+			}
+
+			w.onPacket(packet)
+		}
+	}(w.handle)
+
+	return nil
+}
+
+func (w *ArpWatcher) Stop() error {
+	if w.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	w.SetRunning(false)
+	return nil
+}
+
+func (w *ArpWatcher) Metrics() []session.Metric {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return []session.Metric{
+		session.NewMetric("arp_watch_alerts_total", "Number of ARP poisoning attempts detected.", session.MetricCounter, float64(w.alerts)),
+		session.NewMetric("arp_watch_bindings", "Number of known IP to MAC bindings.", session.MetricGauge, float64(len(w.bindings))),
+	}
+}
@@ -0,0 +1,235 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/packets"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/paypal/gatt"
+)
+
+type BLEAdvertiser struct {
+	session.SessionModule
+	gattDevice gatt.Device
+	poweredOn  bool
+	packet     *gatt.AdvPacket
+	beacon     *iBeacon
+}
+
+type iBeacon struct {
+	uuid  gatt.UUID
+	major uint16
+	minor uint16
+	power int8
+}
+
+func NewBLEAdvertiser(s *session.Session) *BLEAdvertiser {
+	d := &BLEAdvertiser{
+		SessionModule: session.NewSessionModule("ble.advertise", s),
+		gattDevice:    nil,
+		poweredOn:     false,
+		packet:        nil,
+		beacon:        nil,
+	}
+
+	d.AddParam(session.NewStringParameter("ble.advertise.type",
+		"ibeacon",
+		"^(ibeacon|eddystone)$",
+		"Type of beacon to advertise, ibeacon or eddystone."))
+
+	d.AddParam(session.NewStringParameter("ble.advertise.uuid",
+		"e2c56db5-dffb-48d2-b060-d0f5a71096e0",
+		"^[a-fA-F0-9]{8}-?[a-fA-F0-9]{4}-?[a-fA-F0-9]{4}-?[a-fA-F0-9]{4}-?[a-fA-F0-9]{12}$",
+		"iBeacon proximity UUID."))
+
+	d.AddParam(session.NewIntParameter("ble.advertise.major",
+		"1",
+		"iBeacon major value ( 0-65535 )."))
+
+	d.AddParam(session.NewIntParameter("ble.advertise.minor",
+		"1",
+		"iBeacon minor value ( 0-65535 )."))
+
+	d.AddParam(session.NewModuleParameter("ble.advertise.power",
+		"-59",
+		session.INT,
+		"^-?[\\d]+$",
+		"Calibrated TX power in dBm, measured at 1m for iBeacon and at 0m for Eddystone."))
+
+	d.AddParam(session.NewStringParameter("ble.advertise.url",
+		"https://www.bettercap.org/",
+		"^https?://.+$",
+		"URL to advertise as an Eddystone-URL frame."))
+
+	d.AddHandler(session.NewModuleHandler("ble.advertise on", "",
+		"Start advertising the configured beacon.",
+		func(args []string) error {
+			return d.Start()
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.advertise off", "",
+		"Stop advertising the configured beacon.",
+		func(args []string) error {
+			return d.Stop()
+		}))
+
+	return d
+}
+
+func (d BLEAdvertiser) Name() string {
+	return "ble.advertise"
+}
+
+func (d BLEAdvertiser) Description() string {
+	return "Advertise spoofed iBeacon or Eddystone-URL Bluetooth Low Energy beacons."
+}
+
+func (d BLEAdvertiser) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (d *BLEAdvertiser) onStateChanged(dev gatt.Device, s gatt.State) {
+	log.Debug("BLE device state changed to %s.", s)
+
+	switch s {
+	case gatt.StatePoweredOn:
+		d.poweredOn = true
+		if d.Running() == true {
+			d.advertise()
+		}
+	case gatt.StatePoweredOff:
+		d.poweredOn = false
+	default:
+		log.Warning("Unexpected BLE state: %s", s)
+	}
+}
+
+func (d *BLEAdvertiser) advertise() {
+	var err error
+
+	if d.beacon != nil {
+		log.Info("Advertising iBeacon %s ( major:%d minor:%d power:%d ) ...", d.beacon.uuid, d.beacon.major, d.beacon.minor, d.beacon.power)
+		err = d.gattDevice.AdvertiseIBeacon(d.beacon.uuid, d.beacon.major, d.beacon.minor, d.beacon.power)
+	} else {
+		log.Info("Advertising Eddystone-URL beacon ...")
+		err = d.gattDevice.Advertise(d.packet)
+	}
+
+	if err != nil {
+		log.Error("Error while advertising beacon: %s", err)
+	}
+}
+
+func (d *BLEAdvertiser) configureBeacon() error {
+	var err error
+	var btype, url, uuid string
+	var major, minor, power int
+
+	if err, btype = d.StringParam("ble.advertise.type"); err != nil {
+		return err
+	} else if err, power = d.IntParam("ble.advertise.power"); err != nil {
+		return err
+	} else if power < -128 || power > 127 {
+		return fmt.Errorf("Invalid TX power %d.", power)
+	}
+
+	d.beacon = nil
+	d.packet = nil
+
+	if btype == "ibeacon" {
+		if err, uuid = d.StringParam("ble.advertise.uuid"); err != nil {
+			return err
+		} else if err, major = d.IntParam("ble.advertise.major"); err != nil {
+			return err
+		} else if err, minor = d.IntParam("ble.advertise.minor"); err != nil {
+			return err
+		} else if major > 0xffff || minor > 0xffff {
+			return fmt.Errorf("iBeacon major and minor values must be in the 0-65535 range.")
+		}
+
+		u, err := gatt.ParseUUID(uuid)
+		if err != nil {
+			return err
+		}
+
+		d.beacon = &iBeacon{
+			uuid:  u,
+			major: uint16(major),
+			minor: uint16(minor),
+			power: int8(power),
+		}
+	} else {
+		if err, url = d.StringParam("ble.advertise.url"); err != nil {
+			return err
+		}
+
+		err, frame := packets.EddystoneURLFrame(url, int8(power))
+		if err != nil {
+			return err
+		}
+
+		d.packet = &gatt.AdvPacket{}
+		// LE general discoverable, BR/EDR not supported
+		d.packet.AppendFlags(0x06)
+		d.packet.AppendField(0x03, []byte{packets.EddystoneUUID & 0xff, packets.EddystoneUUID >> 8})
+		d.packet.AppendField(0x16, frame)
+	}
+
+	return nil
+}
+
+func (d *BLEAdvertiser) Configure() (err error) {
+	if err = d.configureBeacon(); err != nil {
+		return err
+	}
+
+	if d.gattDevice == nil {
+		log.Debug("Initializing BLE device ...")
+
+		if d.gattDevice, err = gatt.NewDevice(defaultBLEServerOptions...); err != nil {
+			return err
+		}
+
+		d.gattDevice.Init(d.onStateChanged)
+	} else if d.poweredOn == true {
+		d.advertise()
+	}
+
+	return nil
+}
+
+func (d *BLEAdvertiser) Start() error {
+	if d.Running() == true {
+		return session.ErrAlreadyStarted
+	}
+
+	// set running before the device is powered on, so
+	// onStateChanged knows it has to start advertising.
+	d.SetRunning(true)
+	if err := d.Configure(); err != nil {
+		d.SetRunning(false)
+		return err
+	}
+
+	return nil
+}
+
+func (d *BLEAdvertiser) Stop() error {
+	if d.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+
+	if d.poweredOn == true {
+		if err := d.gattDevice.StopAdvertising(); err != nil {
+			log.Warning("Error while stopping BLE advertising: %s", err)
+		}
+	}
+
+	d.SetRunning(false)
+	return nil
+}
@@ -0,0 +1,23 @@
+package modules
+
+import (
+	"github.com/paypal/gatt"
+	"github.com/paypal/gatt/linux/cmd"
+)
+
+var defaultBLEClientOptions = []gatt.Option{
+	gatt.LnxMaxConnections(1),
+	gatt.LnxDeviceID(-1, true),
+}
+
+var defaultBLEServerOptions = []gatt.Option{
+	gatt.LnxMaxConnections(1),
+	gatt.LnxDeviceID(-1, true),
+	// beacons are non connectable and advertise every 100ms
+	gatt.LnxSetAdvertisingParameters(&cmd.LESetAdvertisingParameters{
+		AdvertisingIntervalMin: 0x00a0,
+		AdvertisingIntervalMax: 0x00a0,
+		AdvertisingType:        0x03,
+		AdvertisingChannelMap:  0x7,
+	}),
+}
@@ -0,0 +1,323 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/paypal/gatt"
+)
+
+const bleDeviceTTL = 30 * time.Second
+
+type BLERecon struct {
+	session.SessionModule
+	gattDevice gatt.Device
+	poweredOn  bool
+	currDevice *net.BLEDevice
+	writeUUID  *gatt.UUID
+	writeData  []byte
+	quit       chan bool
+}
+
+func NewBLERecon(s *session.Session) *BLERecon {
+	d := &BLERecon{
+		SessionModule: session.NewSessionModule("ble.recon", s),
+		gattDevice:    nil,
+		poweredOn:     false,
+		currDevice:    nil,
+		writeUUID:     nil,
+		writeData:     nil,
+		quit:          make(chan bool),
+	}
+
+	d.AddParam(session.NewStringParameter("ble.enum.output",
+		"",
+		"",
+		"If set, enumerated devices will be saved as JSON to this file."))
+
+	d.AddHandler(session.NewModuleHandler("ble.recon on", "",
+		"Start Bluetooth Low Energy devices discovery.",
+		func(args []string) error {
+			return d.Start()
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.recon off", "",
+		"Stop Bluetooth Low Energy devices discovery.",
+		func(args []string) error {
+			return d.Stop()
+		}))
+
+	d.AddParam(session.NewStringParameter("ble.show.filter",
+		"",
+		"",
+		"If set, only show devices whose MAC, name, vendor or services match this regular expression."))
+
+	d.AddParam(session.NewBoolParameter("ble.show.connectable",
+		"false",
+		"If true, only show connectable devices."))
+
+	d.AddHandler(session.NewModuleHandler("ble.show", "",
+		"Show discovered Bluetooth Low Energy devices (default sorting by signal strength).",
+		func(args []string) error {
+			return d.Show("rssi")
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.show by mac", "",
+		"Show discovered Bluetooth Low Energy devices (sort by MAC address).",
+		func(args []string) error {
+			return d.Show("mac")
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.show by name", "",
+		"Show discovered Bluetooth Low Energy devices (sort by name).",
+		func(args []string) error {
+			return d.Show("name")
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.show by seen", "",
+		"Show discovered Bluetooth Low Energy devices (sort by last seen).",
+		func(args []string) error {
+			return d.Show("seen")
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.enum MAC", "^ble\\.enum\\s+([a-fA-F0-9:]{11,17})$",
+		"Connect to the BLE device with the given MAC address and enumerate its services and characteristics.",
+		func(args []string) error {
+			return d.enumerate(args[0])
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.write MAC UUID HEX_DATA", "^ble\\.write\\s+([a-fA-F0-9:]{11,17})\\s+([a-fA-F0-9\\-]+)\\s+([a-fA-F0-9]+)$",
+		"Connect to the BLE device with the given MAC address and write the HEX_DATA buffer to the characteristic with the given UUID.",
+		func(args []string) error {
+			return d.writeBuffer(args[0], args[1], args[2])
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.sniff FILENAME", "^ble\\.sniff\\s+(.+)$",
+		"Read the link layer capture of a BLE sniffer ( i.e. Ubertooth ) from a pcap file or a named pipe, emitting events for the connection requests and the pairing procedures it contains.",
+		func(args []string) error {
+			return d.sniff(args[0])
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.export kml FILENAME", "^ble\\.export\\s+(kml)\\s+(.+)$",
+		"Export the BLE devices tagged with a position by the gps module to a KML file.",
+		func(args []string) error {
+			return d.export(args[0], args[1])
+		}))
+
+	return d
+}
+
+func (d BLERecon) Name() string {
+	return "ble.recon"
+}
+
+func (d BLERecon) Description() string {
+	return "Bluetooth Low Energy devices discovery."
+}
+
+func (d BLERecon) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (d *BLERecon) onStateChanged(dev gatt.Device, s gatt.State) {
+	log.Debug("BLE device state changed to %s.", s)
+
+	switch s {
+	case gatt.StatePoweredOn:
+		d.poweredOn = true
+		if d.Running() == true {
+			log.Info("Starting BLE discovery ...")
+			dev.Scan([]gatt.UUID{}, true)
+		}
+	case gatt.StatePoweredOff:
+		d.poweredOn = false
+	default:
+		log.Warning("Unexpected BLE state: %s", s)
+	}
+}
+
+func (d *BLERecon) onPeriphDiscovered(p gatt.Peripheral, a *gatt.Advertisement, rssi int) {
+	// new devices are tagged before their event is emitted
+	if dev := d.Session.BLE.AddIfNew(p, a, rssi); dev != nil {
+		dev.Locate(d.Session.GPS.Position())
+	}
+}
+
+func (d *BLERecon) pruner() {
+	defer d.Session.Recover()
+	for {
+		select {
+		case <-time.After(bleDeviceTTL / 2):
+			for _, dev := range d.Session.BLE.Devices() {
+				if time.Since(dev.LastSeen) > bleDeviceTTL {
+					d.Session.BLE.Remove(dev.MAC)
+				}
+			}
+
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+func (d *BLERecon) Configure() (err error) {
+	if d.gattDevice == nil {
+		if d.gattDevice, err = gatt.NewDevice(defaultBLEClientOptions...); err != nil {
+			d.gattDevice = nil
+			return err
+		}
+
+		d.gattDevice.Handle(
+			gatt.PeripheralDiscovered(d.onPeriphDiscovered),
+			gatt.PeripheralConnected(d.onPeriphConnected),
+			gatt.PeripheralDisconnected(d.onPeriphDisconnected),
+		)
+
+		// scanning will start as soon as the device is powered on
+		if err = d.gattDevice.Init(d.onStateChanged); err != nil {
+			return err
+		}
+	} else if d.poweredOn == true {
+		log.Info("Starting BLE discovery ...")
+		d.gattDevice.Scan([]gatt.UUID{}, true)
+	}
+
+	return nil
+}
+
+func (d *BLERecon) Start() error {
+	if d.Running() == true {
+		return session.ErrAlreadyStarted
+	}
+
+	// onStateChanged checks the running state before scanning
+	d.SetRunning(true)
+	if err := d.Configure(); err != nil {
+		d.SetRunning(false)
+		return err
+	}
+
+	go d.pruner()
+
+	return nil
+}
+
+func bleRSSI(dev *net.BLEDevice) string {
+	trend := ""
+	if delta := dev.RSSITrend(); delta > 0 {
+		trend = core.Green(" ▲")
+	} else if delta < 0 {
+		trend = core.Red(" ▼")
+	}
+	return fmt.Sprintf("%d dBm%s", dev.RSSI, trend)
+}
+
+func (d *BLERecon) showFilter() (err error, filter *regexp.Regexp, connectable bool) {
+	var expr string
+
+	if err, expr = d.StringParam("ble.show.filter"); err != nil {
+		return
+	} else if err, connectable = d.BoolParam("ble.show.connectable"); err != nil {
+		return
+	} else if expr != "" {
+		if filter, err = regexp.Compile(expr); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (d *BLERecon) Show(by string) error {
+	err, filter, connectable := d.showFilter()
+	if err != nil {
+		return err
+	}
+
+	all := d.Session.BLE.Devices()
+	if len(all) == 0 {
+		fmt.Println(core.Dim("No BLE devices discovered so far."))
+		return nil
+	}
+
+	devices := make([]*net.BLEDevice, 0)
+	for _, dev := range all {
+		if connectable == true && dev.Connectable == false {
+			continue
+		}
+
+		services := strings.Join(dev.Services(), ", ")
+		if filter != nil &&
+			filter.MatchString(dev.MAC) == false &&
+			filter.MatchString(dev.Name) == false &&
+			filter.MatchString(dev.Vendor) == false &&
+			filter.MatchString(services) == false {
+			continue
+		}
+
+		devices = append(devices, dev)
+	}
+
+	if len(devices) == 0 {
+		fmt.Println(core.Dim("No BLE devices matching the current filters."))
+		return nil
+	}
+
+	if by == "mac" {
+		sort.Sort(BLEByMACSorter(devices))
+	} else if by == "name" {
+		sort.Sort(BLEByNameSorter(devices))
+	} else if by == "seen" {
+		sort.Sort(BLEBySeenSorter(devices))
+	} else {
+		sort.Sort(BLEBySignalSorter(devices))
+	}
+
+	data := make([][]string, len(devices))
+	for i, dev := range devices {
+		isConnectable := core.OFF
+		if dev.Connectable == true {
+			isConnectable = core.ON
+		}
+
+		data[i] = []string{
+			bleRSSI(dev),
+			dev.MAC,
+			core.Yellow(dev.Name),
+			dev.Vendor,
+			isConnectable,
+			strings.Join(dev.Services(), ", "),
+			dev.LastSeen.Format("15:04:05"),
+		}
+	}
+
+	core.AsTable(os.Stdout, []string{"RSSI", "MAC", "Name", "Vendor", "Connectable", "Services", "Last Seen"}, data)
+
+	fmt.Println()
+
+	return nil
+}
+
+func (d *BLERecon) Stop() error {
+	if d.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+
+	d.gattDevice.StopScanning()
+	d.quit <- true
+	d.SetRunning(false)
+
+	return nil
+}
@@ -0,0 +1,286 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/net"
+
+	"github.com/paypal/gatt"
+)
+
+func bleName(name string, uuid gatt.UUID) string {
+	if name == "" {
+		return uuid.String()
+	}
+	return fmt.Sprintf("%s (%s)", name, uuid.String())
+}
+
+func bleData(raw []byte) string {
+	for _, b := range raw {
+		if b < 0x20 || b > 0x7e {
+			return hex.EncodeToString(raw)
+		}
+	}
+	return string(raw)
+}
+
+func (d *BLERecon) enumerate(mac string) error {
+	if d.Running() == false {
+		return fmt.Errorf("ble.recon must be running in order to enumerate devices.")
+	} else if d.currDevice != nil {
+		return fmt.Errorf("Already enumerating %s.", d.currDevice.MAC)
+	}
+
+	dev, found := d.Session.BLE.Get(mac)
+	if found == false {
+		return fmt.Errorf("BLE device with address %s not found.", mac)
+	} else if dev.Advertisement != nil && dev.Advertisement.Connectable == false {
+		log.Warning("Device %s is not connectable, trying anyway ...", dev.MAC)
+	}
+
+	d.currDevice = dev
+
+	// scanning and connecting at the same time confuses most adapters
+	d.gattDevice.StopScanning()
+
+	log.Info("Connecting to %s ...", dev.MAC)
+	d.gattDevice.Connect(dev.Device)
+
+	return nil
+}
+
+func (d *BLERecon) writeBuffer(mac string, uuid string, data string) error {
+	u, err := gatt.ParseUUID(uuid)
+	if err != nil {
+		return fmt.Errorf("Error while parsing UUID %s: %s", uuid, err)
+	}
+
+	raw, err := hex.DecodeString(data)
+	if err != nil {
+		return fmt.Errorf("Error while parsing data %s: %s", data, err)
+	}
+
+	d.writeUUID = &u
+	d.writeData = raw
+
+	if err = d.enumerate(mac); err != nil {
+		d.writeUUID = nil
+		d.writeData = nil
+		return err
+	}
+
+	return nil
+}
+
+func (d *BLERecon) writeCharacteristic(p gatt.Peripheral, ch *gatt.Characteristic) {
+	props := ch.Properties()
+	noRsp := (props&gatt.CharWriteNR) != 0 && (props&gatt.CharWrite) == 0
+
+	if (props & (gatt.CharWrite | gatt.CharWriteNR)) == 0 {
+		log.Warning("Characteristic %s is not writable, trying anyway ...", ch.UUID())
+	}
+
+	log.Info("Writing %d bytes to %s ...", len(d.writeData), ch.UUID())
+
+	if err := p.WriteCharacteristic(ch, d.writeData, noRsp); err != nil {
+		log.Error("Error while writing to %s: %s", ch.UUID(), err)
+	}
+}
+
+func (d *BLERecon) resumeScanning() {
+	d.currDevice = nil
+	d.writeUUID = nil
+	d.writeData = nil
+	if d.Running() == true && d.poweredOn == true {
+		d.gattDevice.Scan([]gatt.UUID{}, true)
+	}
+}
+
+// Only connections established by our own adapter can be observed,
+// third party CONNECT_IND and pairing PDUs are never reported by the
+// controller over HCI and would require a dedicated sniffer.
+type BLEConnectionEvent struct {
+	MAC   string `json:"mac"`
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+func (e BLEConnectionEvent) String() string {
+	if e.Error != "" {
+		return fmt.Sprintf("%s (%s): %s", e.MAC, e.Name, e.Error)
+	}
+	return fmt.Sprintf("%s (%s)", e.MAC, e.Name)
+}
+
+func (d *BLERecon) connectionEvent(tag string, p gatt.Peripheral, err error) {
+	e := BLEConnectionEvent{
+		MAC:  strings.ToLower(p.ID()),
+		Name: p.Name(),
+	}
+
+	if dev, found := d.Session.BLE.Get(p.ID()); found == true {
+		e.MAC = dev.MAC
+		e.Name = dev.Name
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	d.Session.Events.Add(tag, e)
+}
+
+func (d *BLERecon) onPeriphConnected(p gatt.Peripheral, err error) {
+	if err != nil {
+		log.Warning("Connection to %s failed: %s", p.ID(), err)
+		d.connectionEvent("ble.connection.failed", p, err)
+		d.resumeScanning()
+		return
+	}
+
+	d.connectionEvent("ble.device.connected", p, nil)
+
+	defer d.gattDevice.CancelConnection(p)
+
+	dev := d.currDevice
+	if dev == nil {
+		log.Debug("Connected to %s without an enumeration request.", p.ID())
+		return
+	}
+
+	log.Info("Connected to %s, enumerating services ...", dev.MAC)
+
+	services, err := p.DiscoverServices(nil)
+	if err != nil {
+		log.Error("Error while discovering services of %s: %s", dev.MAC, err)
+		return
+	}
+
+	dev.GATT = d.enumServices(p, services)
+
+	d.showServices(dev)
+
+	if err, output := d.StringParam("ble.enum.output"); err != nil {
+		log.Error("%s", err)
+	} else if output != "" {
+		if err = d.saveDevice(dev, output); err != nil {
+			log.Error("Error while saving %s to %s: %s", dev.MAC, output, err)
+		} else {
+			log.Info("Device %s saved to %s.", dev.MAC, output)
+		}
+	}
+}
+
+func (d *BLERecon) onPeriphDisconnected(p gatt.Peripheral, err error) {
+	log.Debug("Disconnected from %s.", p.ID())
+	d.connectionEvent("ble.device.disconnected", p, err)
+	d.resumeScanning()
+}
+
+func (d *BLERecon) enumServices(p gatt.Peripheral, services []*gatt.Service) []net.BLEService {
+	enumerated := make([]net.BLEService, 0)
+	wrote := false
+
+	for _, svc := range services {
+		service := net.BLEService{
+			UUID:            svc.UUID().String(),
+			Name:            svc.Name(),
+			Handle:          svc.Handle(),
+			Characteristics: make([]net.BLECharacteristic, 0),
+		}
+
+		chars, err := p.DiscoverCharacteristics(nil, svc)
+		if err != nil {
+			log.Error("Error while discovering characteristics of %s: %s", service.UUID, err)
+			enumerated = append(enumerated, service)
+			continue
+		}
+
+		for _, ch := range chars {
+			char := net.BLECharacteristic{
+				UUID:       ch.UUID().String(),
+				Name:       ch.Name(),
+				Handle:     ch.VHandle(),
+				Properties: strings.Fields(ch.Properties().String()),
+			}
+
+			// write before reading, so the new value is shown
+			if d.writeUUID != nil && ch.UUID().Equal(*d.writeUUID) {
+				d.writeCharacteristic(p, ch)
+				wrote = true
+			}
+
+			if (ch.Properties() & gatt.CharRead) != 0 {
+				if raw, err := p.ReadCharacteristic(ch); err != nil {
+					log.Debug("Error while reading %s: %s", char.UUID, err)
+				} else {
+					char.Data = bleData(raw)
+				}
+			}
+
+			service.Characteristics = append(service.Characteristics, char)
+		}
+
+		enumerated = append(enumerated, service)
+	}
+
+	if d.writeUUID != nil && wrote == false {
+		log.Warning("Characteristic %s not found.", d.writeUUID)
+	}
+
+	return enumerated
+}
+
+func (d *BLERecon) showServices(dev *net.BLEDevice) {
+	data := make([][]string, 0)
+
+	for _, svc := range dev.GATT {
+		data = append(data, []string{
+			fmt.Sprintf("%04x", svc.Handle),
+			core.Green(bleName(svc.Name, gatt.MustParseUUID(svc.UUID))),
+			"",
+			"",
+		})
+
+		for _, ch := range svc.Characteristics {
+			data = append(data, []string{
+				fmt.Sprintf("%04x", ch.Handle),
+				"  " + core.Yellow(bleName(ch.Name, gatt.MustParseUUID(ch.UUID))),
+				strings.Join(ch.Properties, ", "),
+				ch.Data,
+			})
+		}
+	}
+
+	fmt.Println()
+
+	core.AsTable(os.Stdout, []string{"Handles", "Service > Characteristics", "Properties", "Data"}, data)
+
+	fmt.Println()
+
+	d.Session.Refresh()
+}
+
+func (d *BLERecon) saveDevice(dev *net.BLEDevice, filename string) error {
+	filename, err := core.ExpandPath(filename)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(dev, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, raw, 0644)
+}
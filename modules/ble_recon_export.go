@@ -0,0 +1,88 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/net"
+)
+
+func kmlEscape(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+// Renders the devices tagged by the gps module as KML placemarks,
+// each one at the position where its signal was the strongest.
+func bleKML(devices []*net.BLEDevice) (string, int) {
+	kml := &bytes.Buffer{}
+	n := 0
+
+	kml.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	kml.WriteString("<kml xmlns=\"http://www.opengis.net/kml/2.2\">\n<Document>\n<name>bettercap BLE survey</name>\n")
+
+	for _, dev := range devices {
+		if dev.Location == nil {
+			continue
+		}
+
+		name := dev.MAC
+		if dev.Name != "" {
+			name = fmt.Sprintf("%s (%s)", dev.Name, dev.MAC)
+		}
+
+		desc := []string{
+			fmt.Sprintf("MAC: %s", dev.MAC),
+			fmt.Sprintf("Vendor: %s", dev.Vendor),
+			fmt.Sprintf("RSSI: %d dBm", dev.RSSI),
+			fmt.Sprintf("Connectable: %v", dev.Connectable),
+			fmt.Sprintf("First seen: %s", dev.FirstSeen.Format("2006-01-02 15:04:05")),
+			fmt.Sprintf("Last seen: %s", dev.LastSeen.Format("2006-01-02 15:04:05")),
+		}
+		if services := dev.Services(); len(services) > 0 {
+			desc = append(desc, fmt.Sprintf("Services: %s", strings.Join(services, ", ")))
+		}
+
+		fmt.Fprintf(kml, "<Placemark>\n<name>%s</name>\n<description>%s</description>\n", kmlEscape(name), kmlEscape(strings.Join(desc, "\n")))
+		fmt.Fprintf(kml, "<TimeStamp><when>%s</when></TimeStamp>\n", dev.Location.Updated.UTC().Format("2006-01-02T15:04:05Z"))
+		// KML wants longitude first
+		fmt.Fprintf(kml, "<Point><coordinates>%.7f,%.7f,%.1f</coordinates></Point>\n</Placemark>\n", dev.Location.Longitude, dev.Location.Latitude, dev.Location.Altitude)
+		n++
+	}
+
+	kml.WriteString("</Document>\n</kml>\n")
+
+	return kml.String(), n
+}
+
+func (d *BLERecon) export(format string, filename string) error {
+	if format != "kml" {
+		return fmt.Errorf("Export format '%s' is not supported.", format)
+	}
+
+	filename, err := core.ExpandPath(filename)
+	if err != nil {
+		return err
+	}
+
+	data, n := bleKML(d.Session.BLE.Devices())
+	if n == 0 {
+		return fmt.Errorf("No BLE devices have been tagged with a position, is the gps module running?")
+	} else if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		return err
+	}
+
+	log.Info("Exported %d BLE devices to %s.", n, filename)
+	artifactReady("kml", filename)
+
+	return nil
+}
@@ -0,0 +1,143 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/packets"
+)
+
+// Connection requested by a device to another one, as seen by a sniffer.
+type BLEConnectionRequest struct {
+	Initiator     string `json:"initiator"`
+	Advertiser    string `json:"advertiser"`
+	AccessAddress string `json:"access_address"`
+}
+
+func (r BLEConnectionRequest) String() string {
+	return fmt.Sprintf("%s > %s (%s)", r.Initiator, r.Advertiser, r.AccessAddress)
+}
+
+// Pairing procedure between two devices, addresses are empty if
+// the sniffer didn't see the connection being established.
+type BLEPairingEvent struct {
+	Initiator         string `json:"initiator"`
+	Advertiser        string `json:"advertiser"`
+	AccessAddress     string `json:"access_address"`
+	Method            string `json:"method"`
+	SecureConnections bool   `json:"secure_connections"`
+	InitiatorIO       string `json:"initiator_io"`
+	ResponderIO       string `json:"responder_io"`
+}
+
+func (e BLEPairingEvent) String() string {
+	kind := "legacy"
+	if e.SecureConnections == true {
+		kind = "secure connections"
+	}
+	return fmt.Sprintf("%s > %s (%s) %s pairing with %s ( %s / %s )", e.Initiator, e.Advertiser, e.AccessAddress, kind, e.Method, e.InitiatorIO, e.ResponderIO)
+}
+
+// Follows connections and pairing procedures of the link
+// layer packets captured by a sniffer, i.e. an Ubertooth.
+type bleSniffState struct {
+	connections map[uint32]*packets.BLEConnectInd
+	requests    map[uint32]*packets.BLEPairing
+}
+
+func (d *BLERecon) onSniffedPacket(state *bleSniffState, pkt *packets.BLELLPacket) {
+	if conn := pkt.ConnectInd(); conn != nil {
+		state.connections[conn.AccessAddress] = conn
+		delete(state.requests, conn.AccessAddress)
+
+		d.Session.Events.Add("ble.connection.request", BLEConnectionRequest{
+			Initiator:     conn.Initiator,
+			Advertiser:    conn.Advertiser,
+			AccessAddress: fmt.Sprintf("%08x", conn.AccessAddress),
+		})
+		return
+	}
+
+	pairing := pkt.Pairing()
+	if pairing == nil {
+		return
+	} else if pairing.Code == packets.BLESMPPairingRequest {
+		state.requests[pkt.AccessAddress] = pairing
+		return
+	}
+
+	req, found := state.requests[pkt.AccessAddress]
+	if found == false {
+		log.Debug("[ble.sniff] pairing response without request on %08x.", pkt.AccessAddress)
+		return
+	}
+	delete(state.requests, pkt.AccessAddress)
+
+	ev := BLEPairingEvent{
+		AccessAddress:     fmt.Sprintf("%08x", pkt.AccessAddress),
+		Method:            packets.BLEPairingMethod(req, pairing),
+		SecureConnections: req.SecureConnections() && pairing.SecureConnections(),
+		InitiatorIO:       packets.BLEIOCapability(req.IOCapability),
+		ResponderIO:       packets.BLEIOCapability(pairing.IOCapability),
+	}
+
+	if conn, found := state.connections[pkt.AccessAddress]; found == true {
+		ev.Initiator = conn.Initiator
+		ev.Advertiser = conn.Advertiser
+	}
+
+	d.Session.Events.Add("ble.pairing", ev)
+}
+
+// Reads the link layer capture of a BLE sniffer from a file or a named
+// pipe, as standard HCI controllers don't report the connections and
+// pairing procedures of other devices.
+func (d *BLERecon) sniff(filename string) error {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	capture, err := packets.NewBLECapture(fp)
+	if err != nil {
+		fp.Close()
+		return fmt.Errorf("Error while reading %s: %s", filename, err)
+	}
+
+	log.Info("Reading BLE link layer packets from %s ...", filename)
+
+	go func() {
+		defer d.Session.Recover()
+		defer fp.Close()
+
+		state := &bleSniffState{
+			connections: make(map[uint32]*packets.BLEConnectInd),
+			requests:    make(map[uint32]*packets.BLEPairing),
+		}
+
+		read := 0
+		for {
+			data, err := capture.Next()
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			} else if err != nil {
+				log.Error("Error while reading %s: %s", filename, err)
+				break
+			}
+
+			read++
+			if pkt, err := packets.ParseBLELL(capture.LinkType, data); err == nil {
+				d.onSniffedPacket(state, pkt)
+			}
+		}
+
+		log.Info("Done reading %d BLE link layer packets from %s.", read, filename)
+	}()
+
+	return nil
+}
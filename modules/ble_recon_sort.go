@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"github.com/evilsocket/bettercap-ng/net"
+)
+
+type BLEBySignalSorter []*net.BLEDevice
+
+func (a BLEBySignalSorter) Len() int           { return len(a) }
+func (a BLEBySignalSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a BLEBySignalSorter) Less(i, j int) bool { return a[i].RSSI > a[j].RSSI }
+
+type BLEByMACSorter []*net.BLEDevice
+
+func (a BLEByMACSorter) Len() int           { return len(a) }
+func (a BLEByMACSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a BLEByMACSorter) Less(i, j int) bool { return a[i].MAC < a[j].MAC }
+
+type BLEBySeenSorter []*net.BLEDevice
+
+func (a BLEBySeenSorter) Len() int           { return len(a) }
+func (a BLEBySeenSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a BLEBySeenSorter) Less(i, j int) bool { return a[i].LastSeen.After(a[j].LastSeen) }
+
+type BLEByNameSorter []*net.BLEDevice
+
+func (a BLEByNameSorter) Len() int           { return len(a) }
+func (a BLEByNameSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a BLEByNameSorter) Less(i, j int) bool { return a[i].Name < a[j].Name }
@@ -0,0 +1,108 @@
+//go:build windows
+// +build windows
+
+package modules
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+var errBLENotSupported = fmt.Errorf("Bluetooth Low Energy is not supported on this platform.")
+
+type BLERecon struct {
+	session.SessionModule
+}
+
+func NewBLERecon(s *session.Session) *BLERecon {
+	d := &BLERecon{
+		SessionModule: session.NewSessionModule("ble.recon", s),
+	}
+
+	d.AddHandler(session.NewModuleHandler("ble.recon on", "",
+		"Start Bluetooth Low Energy devices discovery.",
+		func(args []string) error {
+			return d.Start()
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.recon off", "",
+		"Stop Bluetooth Low Energy devices discovery.",
+		func(args []string) error {
+			return d.Stop()
+		}))
+
+	return d
+}
+
+func (d BLERecon) Name() string {
+	return "ble.recon"
+}
+
+func (d BLERecon) Description() string {
+	return "Bluetooth Low Energy devices discovery."
+}
+
+func (d BLERecon) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (d *BLERecon) Configure() error {
+	return errBLENotSupported
+}
+
+func (d *BLERecon) Start() error {
+	return errBLENotSupported
+}
+
+func (d *BLERecon) Stop() error {
+	return errBLENotSupported
+}
+
+type BLEAdvertiser struct {
+	session.SessionModule
+}
+
+func NewBLEAdvertiser(s *session.Session) *BLEAdvertiser {
+	d := &BLEAdvertiser{
+		SessionModule: session.NewSessionModule("ble.advertise", s),
+	}
+
+	d.AddHandler(session.NewModuleHandler("ble.advertise on", "",
+		"Start advertising the configured beacon.",
+		func(args []string) error {
+			return d.Start()
+		}))
+
+	d.AddHandler(session.NewModuleHandler("ble.advertise off", "",
+		"Stop advertising the configured beacon.",
+		func(args []string) error {
+			return d.Stop()
+		}))
+
+	return d
+}
+
+func (d BLEAdvertiser) Name() string {
+	return "ble.advertise"
+}
+
+func (d BLEAdvertiser) Description() string {
+	return "Advertise spoofed iBeacon or Eddystone-URL Bluetooth Low Energy beacons."
+}
+
+func (d BLEAdvertiser) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (d *BLEAdvertiser) Configure() error {
+	return errBLENotSupported
+}
+
+func (d *BLEAdvertiser) Start() error {
+	return errBLENotSupported
+}
+
+func (d *BLEAdvertiser) Stop() error {
+	return errBLENotSupported
+}
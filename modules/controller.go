@@ -0,0 +1,402 @@
+package modules
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+	bcaptls "github.com/evilsocket/bettercap-ng/tls"
+)
+
+// Event emitted for each event received from an agent.
+type AgentEvent struct {
+	Agent string          `json:"agent"`
+	Tag   string          `json:"tag"`
+	Time  time.Time       `json:"time"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Event emitted when an agent completes a command.
+type AgentResult struct {
+	Agent   string `json:"agent"`
+	Command string `json:"command"`
+	Error   string `json:"error,omitempty"`
+}
+
+type controllerAgent struct {
+	Name     string
+	Address  string
+	Since    time.Time
+	LastSeen time.Time
+	Events   uint64
+	conn     *agentConn
+	commands map[uint64]string
+}
+
+type Controller struct {
+	session.SessionModule
+	nextID   uint64
+	address  string
+	token    string
+	certFile string
+	keyFile  string
+	listener net.Listener
+	agents   map[string]*controllerAgent
+	lock     *sync.Mutex
+}
+
+func NewController(s *session.Session) *Controller {
+	c := &Controller{
+		SessionModule: session.NewSessionModule("controller", s),
+		agents:        make(map[string]*controllerAgent),
+		lock:          &sync.Mutex{},
+	}
+
+	c.AddParam(session.NewStringParameter("controller.address",
+		session.ParamIfaceAddress,
+		`^(?:[0-9]{1,3}\.){3}[0-9]{1,3}$`,
+		"Address to bind the agents controller to."))
+
+	c.AddParam(session.NewIntParameter("controller.port",
+		"8086",
+		"Port to bind the agents controller to."))
+
+	c.AddParam(session.NewSecretParameter("controller.token",
+		"",
+		"",
+		"Token agents must present in order to register, required."))
+
+	c.AddParam(session.NewStringParameter("controller.certificate",
+		"~/.bcap-controller.certificate.pem",
+		"",
+		"Controller TLS certificate."))
+
+	c.AddParam(session.NewStringParameter("controller.key",
+		"~/.bcap-controller.key.pem",
+		"",
+		"Controller TLS key"))
+
+	c.AddHandler(session.NewModuleHandler("controller on", "",
+		"Start accepting agents.",
+		func(args []string) error {
+			return c.Start()
+		}))
+
+	c.AddHandler(session.NewModuleHandler("controller off", "",
+		"Disconnect all agents and stop accepting new ones.",
+		func(args []string) error {
+			return c.Stop()
+		}))
+
+	c.AddHandler(session.NewModuleHandler("controller.agents", "",
+		"Show connected agents.",
+		func(args []string) error {
+			return c.Show()
+		}))
+
+	c.AddHandler(session.NewModuleHandler("controller.run AGENT COMMAND", `^controller\.run\s+([^\s]+)\s+(.+)$`,
+		"Run COMMAND on the AGENT, use * to run it on every connected agent.",
+		func(args []string) error {
+			return c.runOn(args[0], args[1])
+		}))
+
+	return c
+}
+
+func (c Controller) Name() string {
+	return "controller"
+}
+
+func (c Controller) Description() string {
+	return "Coordinate remote bettercap-ng agents, receiving their events and sending them commands."
+}
+
+func (c Controller) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (c *Controller) Configure() (err error) {
+	var ip string
+	var port int
+
+	if err, ip = c.StringParam("controller.address"); err != nil {
+		return err
+	} else if err, port = c.IntParam("controller.port"); err != nil {
+		return err
+	} else if err, c.token = c.StringParam("controller.token"); err != nil {
+		return err
+	} else if c.token == "" {
+		return fmt.Errorf("controller.token is required.")
+	}
+	c.address = fmt.Sprintf("%s:%d", ip, port)
+
+	if err, c.certFile = c.StringParam("controller.certificate"); err != nil {
+		return err
+	} else if c.certFile, err = core.ExpandPath(c.certFile); err != nil {
+		return err
+	}
+
+	if err, c.keyFile = c.StringParam("controller.key"); err != nil {
+		return err
+	} else if c.keyFile, err = core.ExpandPath(c.keyFile); err != nil {
+		return err
+	}
+
+	if core.Exists(c.certFile) == false || core.Exists(c.keyFile) == false {
+		log.Info("Generating TLS key to %s", c.keyFile)
+		log.Info("Generating TLS certificate to %s", c.certFile)
+		if err := bcaptls.Generate(c.certFile, c.keyFile); err != nil {
+			return err
+		}
+	} else {
+		log.Info("Loading TLS key from %s", c.keyFile)
+		log.Info("Loading TLS certificate from %s", c.certFile)
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	if c.listener, err = tls.Listen("tcp", c.address, &tls.Config{Certificates: []tls.Certificate{cert}}); err != nil {
+		return err
+	}
+
+	// agents can pin it with agent.fingerprint
+	if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+		log.Info("Controller certificate fingerprint is %s", core.Bold(certFingerprint(leaf)))
+	}
+
+	return nil
+}
+
+func (c *Controller) Start() error {
+	if c.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := c.Configure(); err != nil {
+		return err
+	}
+
+	c.SetRunning(true)
+
+	go func() {
+		log.Info("Agents controller started on %s", c.address)
+
+		var delay time.Duration
+		for {
+			conn, err := c.listener.Accept()
+			if err != nil {
+				if c.Running() == false {
+					return
+				}
+
+				retry := false
+				if delay, retry = acceptBackoff(err, delay); retry == false {
+					log.Error("Agents controller stopped accepting connections: %s", err)
+					return
+				}
+				log.Warning("Error while accepting agent connection: %s, retrying in %s.", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			delay = 0
+			go c.handle(newAgentConn(conn))
+		}
+	}()
+
+	return nil
+}
+
+func (c *Controller) register(conn *agentConn) (*controllerAgent, error) {
+	conn.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	hello, err := conn.Receive()
+	if err != nil {
+		return nil, err
+	} else if hello.Type != agentMsgHello || secureEquals(hello.Token, c.token) == false {
+		conn.Send(agentMessage{Type: agentMsgWelcome, Error: "Invalid token."})
+		return nil, fmt.Errorf("invalid token")
+	} else if hello.Name == "" {
+		conn.Send(agentMessage{Type: agentMsgWelcome, Error: "Empty agent name."})
+		return nil, fmt.Errorf("empty agent name")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, found := c.agents[hello.Name]; found == true {
+		conn.Send(agentMessage{Type: agentMsgWelcome, Error: "An agent with this name is already connected."})
+		return nil, fmt.Errorf("agent %s is already connected", hello.Name)
+	}
+
+	agent := &controllerAgent{
+		Name:     hello.Name,
+		Address:  conn.conn.RemoteAddr().String(),
+		Since:    time.Now(),
+		LastSeen: time.Now(),
+		conn:     conn,
+		commands: make(map[uint64]string),
+	}
+	c.agents[agent.Name] = agent
+
+	return agent, conn.Send(agentMessage{Type: agentMsgWelcome})
+}
+
+func (c *Controller) handle(conn *agentConn) {
+	defer c.Session.Recover()
+	defer conn.Close()
+
+	agent, err := c.register(conn)
+	if err != nil {
+		log.Warning("Agent from %s rejected: %s.", conn.conn.RemoteAddr(), err)
+		return
+	}
+
+	log.Info("Agent %s connected from %s.", core.Bold(agent.Name), agent.Address)
+	c.Session.Events.Add("agent.connected", agent.Name)
+
+	defer func() {
+		c.lock.Lock()
+		delete(c.agents, agent.Name)
+		c.lock.Unlock()
+
+		log.Info("Agent %s disconnected.", core.Bold(agent.Name))
+		c.Session.Events.Add("agent.disconnected", agent.Name)
+	}()
+
+	for {
+		// agents ping us periodically, anything slower is dead
+		conn.conn.SetReadDeadline(time.Now().Add(3 * agentPingPeriod))
+		msg, err := conn.Receive()
+		if err != nil {
+			if c.Running() == true {
+				log.Debug("Error while reading from agent %s: %s", agent.Name, err)
+			}
+			return
+		}
+
+		c.lock.Lock()
+		agent.LastSeen = time.Now()
+		command := agent.commands[msg.ID]
+		if msg.Type == agentMsgResult {
+			delete(agent.commands, msg.ID)
+		} else if msg.Type == agentMsgEvent {
+			agent.Events++
+		}
+		c.lock.Unlock()
+
+		switch msg.Type {
+		case agentMsgEvent:
+			c.Session.Events.Add("agent.event", AgentEvent{
+				Agent: agent.Name,
+				Tag:   msg.Tag,
+				Time:  msg.Time,
+				Data:  msg.Data,
+			})
+
+		case agentMsgResult:
+			c.Session.Events.Add("agent.result", AgentResult{
+				Agent:   agent.Name,
+				Command: command,
+				Error:   msg.Error,
+			})
+		}
+	}
+}
+
+func (c *Controller) runOn(name string, command string) error {
+	if c.Running() == false {
+		return fmt.Errorf("The controller is not running.")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	targets := make([]*controllerAgent, 0)
+	if name == "*" {
+		for _, agent := range c.agents {
+			targets = append(targets, agent)
+		}
+	} else if agent, found := c.agents[name]; found == true {
+		targets = append(targets, agent)
+	} else {
+		return fmt.Errorf("Agent %s is not connected.", name)
+	}
+
+	for _, agent := range targets {
+		id := atomic.AddUint64(&c.nextID, 1)
+		agent.commands[id] = command
+		if err := agent.conn.Send(agentMessage{Type: agentMsgCommand, ID: id, Command: command}); err != nil {
+			delete(agent.commands, id)
+			log.Error("Error while sending command to agent %s: %s", agent.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Controller) Show() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if len(c.agents) == 0 {
+		fmt.Println(core.Dim("No agents connected."))
+		return nil
+	}
+
+	names := make([]string, 0, len(c.agents))
+	for name := range c.agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([][]string, 0)
+	for _, name := range names {
+		agent := c.agents[name]
+		pending := make([]string, 0)
+		for _, cmd := range agent.commands {
+			pending = append(pending, cmd)
+		}
+		rows = append(rows, []string{
+			core.Bold(agent.Name),
+			agent.Address,
+			agent.Since.Format("15:04:05"),
+			agent.LastSeen.Format("15:04:05"),
+			fmt.Sprintf("%d", agent.Events),
+			strings.Join(pending, ", "),
+		})
+	}
+
+	fmt.Println()
+	core.AsTable(os.Stdout, []string{"Name", "Address", "Connected", "Last Seen", "Events", "Pending"}, rows)
+	fmt.Println()
+
+	return nil
+}
+
+func (c *Controller) Stop() error {
+	if c.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	c.SetRunning(false)
+
+	c.listener.Close()
+
+	c.lock.Lock()
+	for _, agent := range c.agents {
+		agent.conn.Close()
+	}
+	c.lock.Unlock()
+
+	return nil
+}
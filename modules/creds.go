@@ -0,0 +1,345 @@
+package modules
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+// Columns available to the CSV export.
+var credsCSVFields = map[string]func(c *session.Credential) string{
+	"time":        func(c *session.Credential) string { return c.Time.Format("2006-01-02 15:04:05") },
+	"type":        func(c *session.Credential) string { return c.Type },
+	"protocol":    func(c *session.Credential) string { return c.Protocol },
+	"source":      func(c *session.Credential) string { return c.Source },
+	"destination": func(c *session.Credential) string { return c.Destination },
+	"username":    func(c *session.Credential) string { return c.Username },
+	"domain":      func(c *session.Credential) string { return c.Domain },
+	"password":    func(c *session.Credential) string { return c.Password },
+	"hash":        func(c *session.Credential) string { return credHashcat(c) },
+}
+
+// Hashcat modes of each credential type that can be cracked.
+var credsHashcatModes = map[string]string{
+	session.CredNetNTLMv1: "5500",
+	session.CredNetNTLMv2: "5600",
+	session.CredHandshake: "22000",
+}
+
+// Returns the credential in the format expected by hashcat, which
+// john also accepts for NetNTLM, or an empty string if it's not a hash.
+func credHashcat(c *session.Credential) string {
+	switch c.Type {
+	case session.CredNetNTLMv1:
+		return fmt.Sprintf("%s::%s:%s:%s:%s", c.Username, c.Domain, c.LMResponse, c.NTResponse, c.Challenge)
+
+	case session.CredNetNTLMv2:
+		// the first 16 bytes of the response are the NTProofStr, the rest is the blob
+		if len(c.NTResponse) < 32 {
+			return ""
+		}
+		return fmt.Sprintf("%s::%s:%s:%s:%s", c.Username, c.Domain, c.Challenge, c.NTResponse[:32], c.NTResponse[32:])
+
+	case session.CredHandshake:
+		return c.Hash
+	}
+	return ""
+}
+
+type CredsModule struct {
+	session.SessionModule
+}
+
+func NewCredsModule(s *session.Session) *CredsModule {
+	c := &CredsModule{
+		SessionModule: session.NewSessionModule("creds", s),
+	}
+
+	c.AddParam(session.NewStringParameter("creds.export.csv.fields",
+		"time,type,protocol,source,destination,username,domain,password,hash",
+		"",
+		"Comma separated list of columns of the CSV export."))
+
+	c.AddParam(session.NewStringParameter("creds.vault",
+		"~/bettercap-creds.vault",
+		"",
+		"File where the captured credentials are stored encrypted with AES-GCM once unlocked with creds.unlock."))
+
+	c.AddParam(session.NewStringParameter("creds.vault.keyfile",
+		"",
+		"",
+		"If set, the vault key is derived from the contents of this file instead of a passphrase asked interactively."))
+
+	c.AddHandler(session.NewModuleHandler("creds", "",
+		"Show captured credentials.",
+		func(args []string) error {
+			return c.Show()
+		}))
+
+	c.AddHandler(session.NewModuleHandler("creds.clear", "",
+		"Clear captured credentials.",
+		func(args []string) error {
+			return c.Session.Creds.Clear()
+		}))
+
+	c.AddHandler(session.NewModuleHandler("creds.unlock", "",
+		"Open the vault, creating it if needed, with its passphrase or key file: the credentials it contains are loaded and the ones captured from now on are stored in it.",
+		func(args []string) error {
+			return c.unlock()
+		}))
+
+	c.AddHandler(session.NewModuleHandler("creds.lock", "",
+		"Save and close the vault, forgetting its key and the captured credentials.",
+		func(args []string) error {
+			return c.Session.Creds.CloseVault()
+		}))
+
+	c.AddHandler(session.NewModuleHandler("creds.export FORMAT FILE", `^creds\.export\s+(hashcat|john|csv)\s+(.+)$`,
+		"Export captured credentials as csv, john or hashcat, the latter creates one FILE.MODE file per hashcat mode.",
+		func(args []string) error {
+			return c.export(args[0], args[1])
+		}))
+
+	return c
+}
+
+func (c CredsModule) Name() string {
+	return "creds"
+}
+
+func (c CredsModule) Description() string {
+	return "Show and export the credentials captured by other modules."
+}
+
+func (c CredsModule) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+// Returns the secret the vault key is derived from, either the
+// contents of the key file or a passphrase asked to the operator.
+func (c *CredsModule) vaultSecret(path string) ([]byte, error) {
+	err, keyFile := c.StringParam("creds.vault.keyfile")
+	if err != nil {
+		return nil, err
+	} else if keyFile != "" {
+		if keyFile, err = core.ExpandPath(keyFile); err != nil {
+			return nil, err
+		}
+
+		key, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		} else if len(key) < 16 {
+			return nil, fmt.Errorf("The key file %s must be at least 16 bytes long.", keyFile)
+		}
+		return key, nil
+	}
+
+	passphrase, err := c.Session.ReadInput("Vault passphrase: ", true)
+	if err != nil {
+		return nil, err
+	} else if passphrase == "" {
+		return nil, fmt.Errorf("Empty passphrase.")
+	}
+
+	// a typo would make the new vault unreadable
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		confirm, err := c.Session.ReadInput("Confirm the passphrase: ", true)
+		if err != nil {
+			return nil, err
+		} else if confirm != passphrase {
+			return nil, fmt.Errorf("The passphrases don't match.")
+		}
+	}
+
+	return []byte(passphrase), nil
+}
+
+func (c *CredsModule) unlock() error {
+	err, path := c.StringParam("creds.vault")
+	if err != nil {
+		return err
+	} else if path == "" {
+		return fmt.Errorf("creds.vault is empty.")
+	} else if path, err = core.ExpandPath(path); err != nil {
+		return err
+	} else if open := c.Session.Creds.VaultPath(); open != "" {
+		return fmt.Errorf("The vault %s is already open.", open)
+	}
+
+	secret, err := c.vaultSecret(path)
+	if err != nil {
+		return err
+	}
+
+	return c.Session.Creds.OpenVault(path, secret)
+}
+
+func (c *CredsModule) Show() error {
+	creds := c.Session.Creds.List()
+	if len(creds) == 0 {
+		fmt.Println(core.Dim("No credentials captured."))
+		return nil
+	}
+
+	rows := make([][]string, 0)
+	for _, cred := range creds {
+		secret := cred.Password
+		if hash := credHashcat(cred); hash != "" {
+			secret = hash
+		}
+		if len(secret) > 64 {
+			secret = secret[:61] + "..."
+		}
+
+		user := cred.Username
+		if cred.Domain != "" {
+			user = cred.Domain + "\\" + user
+		}
+
+		rows = append(rows, []string{
+			cred.Time.Format("15:04:05"),
+			core.Bold(cred.Type),
+			cred.Protocol,
+			cred.Source,
+			cred.Destination,
+			user,
+			core.Yellow(secret),
+		})
+	}
+
+	fmt.Println()
+	core.AsTable(os.Stdout, []string{"Time", "Type", "Protocol", "Source", "Destination", "User", "Secret"}, rows)
+	fmt.Println()
+
+	return nil
+}
+
+func (c *CredsModule) export(format string, filename string) (err error) {
+	if filename, err = core.ExpandPath(filename); err != nil {
+		return err
+	}
+
+	creds := c.Session.Creds.List()
+	if len(creds) == 0 {
+		return fmt.Errorf("No credentials captured.")
+	}
+
+	switch format {
+	case "csv":
+		return c.exportFile(filename, creds, c.writeCSV)
+
+	case "john":
+		return c.exportFile(filename, creds, func(w io.Writer, creds []*session.Credential) (int, error) {
+			// john has no native format for handshakes, they need hcxpcapngtool / wpapcap2john
+			return c.writeHashes(w, creds, session.CredNetNTLMv1, session.CredNetNTLMv2)
+		})
+
+	case "hashcat":
+		exported := 0
+		for credType, mode := range credsHashcatModes {
+			n := 0
+			for _, cred := range creds {
+				if cred.Type == credType {
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+
+			credType := credType
+			if err := c.exportFile(filename+"."+mode, creds, func(w io.Writer, creds []*session.Credential) (int, error) {
+				return c.writeHashes(w, creds, credType)
+			}); err != nil {
+				return err
+			}
+			exported++
+		}
+
+		if exported == 0 {
+			return fmt.Errorf("No crackable credentials captured.")
+		}
+	}
+
+	return nil
+}
+
+func (c *CredsModule) exportFile(filename string, creds []*session.Credential, writer func(io.Writer, []*session.Credential) (int, error)) error {
+	out, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	n, err := writer(out, creds)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Exported %d credentials to %s.", n, filename)
+	return nil
+}
+
+func (c *CredsModule) writeHashes(w io.Writer, creds []*session.Credential, types ...string) (int, error) {
+	n := 0
+	for _, cred := range creds {
+		for _, t := range types {
+			if cred.Type != t {
+				continue
+			} else if hash := credHashcat(cred); hash != "" {
+				if _, err := fmt.Fprintln(w, hash); err != nil {
+					return n, err
+				}
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+func (c *CredsModule) writeCSV(w io.Writer, creds []*session.Credential) (int, error) {
+	err, fields := c.ListParam("creds.export.csv.fields")
+	if err != nil {
+		return 0, err
+	}
+
+	for _, field := range fields {
+		if _, found := credsCSVFields[field]; found == false {
+			return 0, fmt.Errorf("Unknown CSV field %s.", field)
+		}
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(fields); err != nil {
+		return 0, err
+	}
+
+	for _, cred := range creds {
+		row := make([]string, len(fields))
+		for i, field := range fields {
+			row[i] = credsCSVFields[field](cred)
+		}
+		if err := out.Write(row); err != nil {
+			return 0, err
+		}
+	}
+
+	out.Flush()
+	return len(creds), out.Error()
+}
+
+// Credentials are collected by the session, there's nothing to start.
+func (c *CredsModule) Start() error {
+	return fmt.Errorf("The %s module can't be started.", c.Name())
+}
+
+func (c *CredsModule) Stop() error {
+	return session.ErrAlreadyStopped
+}
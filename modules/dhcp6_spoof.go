@@ -149,7 +149,7 @@ func (s *DHCP6Spoofer) dhcpAdvertise(pkt gopacket.Packet, solicit dhcp6.Packet,
 	}
 
 	var ip net.IP
-	if t, found := s.Session.Targets.Targets[target.String()]; found == true {
+	if t, found := s.Session.Targets.ByIP(target.String()); found == true {
 		ip = t.IP
 	} else {
 		log.Warning("Address %s not known, using random identity association address.", target.String())
@@ -307,7 +307,7 @@ func (s *DHCP6Spoofer) dhcpReply(toType string, pkt gopacket.Packet, req dhcp6.P
 			addr = net.IP(raw[0])
 		}
 
-		if t, found := s.Session.Targets.Targets[target.String()]; found == true {
+		if t, found := s.Session.Targets.ByIP(target.String()); found == true {
 			log.Info("[%s] IPv6 address %s is now assigned to %s", core.Green("dhcp6"), addr.String(), t)
 		} else {
 			log.Info("[%s] IPv6 address %s is now assigned to %s", core.Green("dhcp6"), addr.String(), target)
@@ -363,6 +363,7 @@ func (s *DHCP6Spoofer) Start() error {
 	s.SetRunning(true)
 
 	go func() {
+		defer s.Session.Recover()
 		defer s.Handle.Close()
 
 		src := gopacket.NewPacketSource(s.Handle, s.Handle.LinkType())
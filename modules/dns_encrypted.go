@@ -0,0 +1,492 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	network "github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/packets"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// the same client and server are reported once in this interval
+const encDNSEventInterval = 60 * time.Second
+
+// Public DNS over HTTPS endpoints, *.domain matches its subdomains.
+var encDNSDomains = []string{
+	"dns.google", "dns.google.com", "8888.google",
+	"cloudflare-dns.com", "*.cloudflare-dns.com", "one.one.one.one",
+	"dns.quad9.net", "*.quad9.net",
+	"doh.opendns.com", "*.opendns.com",
+	"*.nextdns.io",
+	"*.adguard.com", "*.adguard-dns.com",
+	"doh.cleanbrowsing.org",
+	"doh.dns.sb",
+	"dns.twnic.tw",
+	"doh.libredns.gr",
+	"doh.mullvad.net",
+	"dns.controld.com",
+	"doh.applied-privacy.net",
+}
+
+// Public resolvers speaking DNS over HTTPS on port 443.
+var encDNSAddresses = []string{
+	"1.1.1.1", "1.0.0.1", "1.1.1.2", "1.0.0.2", "1.1.1.3", "1.0.0.3",
+	"2606:4700:4700::1111", "2606:4700:4700::1001",
+	"8.8.8.8", "8.8.4.4",
+	"2001:4860:4860::8888", "2001:4860:4860::8844",
+	"9.9.9.9", "149.112.112.112", "9.9.9.11", "149.112.112.11",
+	"2620:fe::fe", "2620:fe::9",
+	"208.67.222.222", "208.67.220.220",
+	"94.140.14.14", "94.140.15.15",
+	"185.228.168.168", "185.228.169.168",
+	"45.90.28.0", "45.90.30.0",
+}
+
+// Hostnames looking like resolvers, i.e. doh.example.com or dns2.example.net.
+var encDNSHeuristic = regexp.MustCompile(`^(doh|dot|dns)[0-9]*\.`)
+
+// Queried by Firefox before enabling DoH, which it won't if the
+// answer is NXDOMAIN.
+const encDNSCanary = "use-application-dns.net"
+
+// Emitted when a client is seen using, or probing for, encrypted DNS.
+type EncryptedDNSEvent struct {
+	Client   string `json:"client"`
+	Server   string `json:"server"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	Hostname string `json:"hostname"`
+	Reason   string `json:"reason"`
+	Blocked  bool   `json:"blocked"`
+}
+
+type encDNSEntry struct {
+	event    EncryptedDNSEvent
+	hits     uint64
+	lastSeen time.Time
+	reported time.Time
+}
+
+type EncryptedDNS struct {
+	session.SessionModule
+	iface      *network.Endpoint
+	handle     *pcap.Handle
+	domains    []string
+	addresses  map[string]bool
+	heuristics bool
+	block      bool
+	// servers recognized by SNI, so that the following
+	// connections to them are recognized too
+	learned map[string]string
+	entries map[string]*encDNSEntry
+	blocked uint64
+	lock    sync.Mutex
+}
+
+func NewEncryptedDNS(s *session.Session) *EncryptedDNS {
+	e := &EncryptedDNS{
+		SessionModule: session.NewSessionModule("dns.encrypted", s),
+		addresses:     make(map[string]bool),
+		learned:       make(map[string]string),
+		entries:       make(map[string]*encDNSEntry),
+	}
+
+	e.AddParam(session.NewStringParameter("dns.encrypted.interface",
+		"",
+		"",
+		"If set, detect encrypted DNS on this interface instead of the session one, connections can only be reset on the session interface."))
+
+	e.AddParam(session.NewStringParameter("dns.encrypted.domains",
+		"",
+		"",
+		"Comma separated list of DNS over HTTPS hostnames, *.domain matches its subdomains, added to the built in ones."))
+
+	e.AddParam(session.NewStringParameter("dns.encrypted.addresses",
+		"",
+		"",
+		"Comma separated list of addresses of DNS over HTTPS resolvers, added to the built in ones."))
+
+	e.AddParam(session.NewBoolParameter("dns.encrypted.heuristics",
+		"true",
+		"If true, TLS connections to hostnames starting with doh., dot. or dns. are considered DNS over HTTPS too."))
+
+	e.AddParam(session.NewBoolParameter("dns.encrypted.block",
+		"false",
+		"If true, connections to encrypted DNS resolvers are reset so that clients fall back to plain DNS, which dns.spoof can answer."))
+
+	e.AddHandler(session.NewModuleHandler("dns.encrypted on", "",
+		"Start detecting encrypted DNS.",
+		func(args []string) error {
+			return e.Start()
+		}))
+
+	e.AddHandler(session.NewModuleHandler("dns.encrypted off", "",
+		"Stop detecting encrypted DNS.",
+		func(args []string) error {
+			return e.Stop()
+		}))
+
+	e.AddHandler(session.NewModuleHandler("dns.encrypted.show", "",
+		"Show the clients seen using encrypted DNS.",
+		func(args []string) error {
+			return e.Show()
+		}))
+
+	return e
+}
+
+func (e *EncryptedDNS) Name() string {
+	return "dns.encrypted"
+}
+
+func (e *EncryptedDNS) Description() string {
+	return "Detects clients using DNS over TLS, QUIC, HTTPS or dnscrypt and optionally resets their connections so that they fall back to plain DNS."
+}
+
+func (e *EncryptedDNS) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (e *EncryptedDNS) Configure() error {
+	var err error
+	var domains []string
+	var addresses []string
+
+	if err, domains = e.ListParam("dns.encrypted.domains"); err != nil {
+		return err
+	} else if err, addresses = e.ListParam("dns.encrypted.addresses"); err != nil {
+		return err
+	} else if err, e.heuristics = e.BoolParam("dns.encrypted.heuristics"); err != nil {
+		return err
+	} else if err, e.block = e.BoolParam("dns.encrypted.block"); err != nil {
+		return err
+	} else if err, e.iface = e.InterfaceParam("dns.encrypted.interface"); err != nil {
+		return err
+	} else if e.block == true && e.iface != e.Session.Interface {
+		// resets are injected by the session packet queue
+		return fmt.Errorf("dns.encrypted.block can only be used on the session interface %s.", e.Session.Interface.Name())
+	}
+
+	e.domains = append(append([]string{}, encDNSDomains...), domains...)
+	for i, domain := range e.domains {
+		e.domains[i] = strings.ToLower(domain)
+	}
+
+	e.addresses = make(map[string]bool)
+	for _, addr := range append(append([]string{}, encDNSAddresses...), addresses...) {
+		if ip := net.ParseIP(addr); ip == nil {
+			return fmt.Errorf("'%s' is not a valid IP address.", addr)
+		} else {
+			e.addresses[ip.String()] = true
+		}
+	}
+
+	if e.handle, err = pcap.OpenLive(e.iface.Name(), 65536, true, pcap.BlockForever); err != nil {
+		return err
+	} else if err = e.handle.SetBPFFilter("tcp port 443 or tcp port 853 or udp port 853 or udp port 53 or udp port 443"); err != nil {
+		e.handle.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Returns how the hostname was recognized as a DoH one, or an
+// empty string if it wasn't.
+func (e *EncryptedDNS) matchDomain(hostname string) string {
+	hostname = strings.ToLower(hostname)
+
+	for _, domain := range e.domains {
+		if domain == hostname {
+			return "sni"
+		} else if strings.HasPrefix(domain, "*.") && strings.HasSuffix(hostname, domain[1:]) {
+			return "sni"
+		}
+	}
+
+	if e.heuristics == true && encDNSHeuristic.MatchString(hostname) {
+		return "heuristic"
+	}
+	return ""
+}
+
+// Sends a reset to both the client and the server, the client is
+// tricked into thinking it comes from the server and vice versa.
+func (e *EncryptedDNS) reset(eth *layers.Ethernet, src net.IP, dst net.IP, tcp *layers.TCP) bool {
+	ours := e.Session.Interface.HW
+	toClient := uint32(0)
+	seq := tcp.Ack
+	if tcp.SYN == true {
+		// refuse the connection
+		toClient = tcp.Seq + 1
+		seq = 0
+	}
+
+	err, raw := packets.NewTCPReset(dst, ours, src, eth.SrcMAC, uint16(tcp.DstPort), uint16(tcp.SrcPort), seq, toClient)
+	if err == nil {
+		err = e.Session.Queue.Send(raw)
+	}
+
+	if err == nil && tcp.SYN == false && e.Session.Gateway != nil {
+		next := uint32(len(tcp.Payload))
+		if err, raw = packets.NewTCPReset(src, ours, dst, e.Session.Gateway.HW, uint16(tcp.SrcPort), uint16(tcp.DstPort), tcp.Seq+next, 0); err == nil {
+			err = e.Session.Queue.Send(raw)
+		}
+	}
+
+	if err != nil {
+		log.Error("Error while resetting connection %s:%d -> %s:%d: %s", src, tcp.SrcPort, dst, tcp.DstPort, err)
+		return false
+	}
+	return true
+}
+
+func (e *EncryptedDNS) report(ev EncryptedDNSEvent) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if ev.Blocked == true {
+		e.blocked++
+	}
+
+	key := fmt.Sprintf("%s|%s|%d|%s", ev.Client, ev.Server, ev.Port, ev.Protocol)
+	entry, found := e.entries[key]
+	if found == false {
+		entry = &encDNSEntry{}
+		e.entries[key] = entry
+	}
+
+	// keep the hostname seen in the handshake for the following connections
+	if ev.Hostname == "" {
+		ev.Hostname = entry.event.Hostname
+	}
+
+	entry.event = ev
+	entry.hits++
+	entry.lastSeen = time.Now()
+
+	if time.Since(entry.reported) < encDNSEventInterval {
+		return
+	}
+	entry.reported = entry.lastSeen
+
+	e.Session.Events.Add("dns.encrypted.detected", ev)
+}
+
+func (e *EncryptedDNS) onTCP(eth *layers.Ethernet, src net.IP, dst net.IP, tcp *layers.TCP) {
+	ev := EncryptedDNSEvent{
+		Client: src.String(),
+		Server: dst.String(),
+		Port:   int(tcp.DstPort),
+	}
+
+	if tcp.DstPort == 853 {
+		ev.Protocol = "dot"
+		ev.Reason = "port"
+	} else if tcp.DstPort == 443 {
+		ev.Protocol = "doh"
+
+		if len(tcp.Payload) > 0 {
+			if m := sniRe.FindSubmatch(tcp.Payload); len(m) >= 2 {
+				ev.Hostname = string(m[1])
+				// heuristics might match servers hosting other sites too
+				if ev.Reason = e.matchDomain(ev.Hostname); ev.Reason == "sni" {
+					e.lock.Lock()
+					e.learned[ev.Server] = ev.Hostname
+					e.lock.Unlock()
+				}
+			}
+		}
+
+		if ev.Reason == "" {
+			e.lock.Lock()
+			if _, found := e.learned[ev.Server]; found == true {
+				ev.Reason = "learned"
+			}
+			e.lock.Unlock()
+		}
+
+		if ev.Reason == "" && e.addresses[ev.Server] == true {
+			ev.Reason = "address"
+		}
+	}
+
+	if ev.Reason == "" {
+		return
+	}
+
+	// only report handshakes, not every segment of the connection
+	isHandshake := tcp.SYN == true || len(ev.Hostname) > 0
+	if e.block == true && tcp.RST == false && tcp.FIN == false {
+		ev.Blocked = e.reset(eth, src, dst, tcp)
+	} else if isHandshake == false {
+		return
+	}
+
+	e.report(ev)
+}
+
+func (e *EncryptedDNS) onUDP(src net.IP, dst net.IP, udp *layers.UDP) {
+	ev := EncryptedDNSEvent{
+		Client: src.String(),
+		Server: dst.String(),
+		Port:   int(udp.DstPort),
+	}
+
+	if udp.DstPort == 853 {
+		ev.Protocol = "doq"
+		ev.Reason = "port"
+	} else {
+		// plain queries to port 53 or to dnscrypt resolvers on 443
+		dns := &layers.DNS{}
+		if dns.DecodeFromBytes(udp.Payload, gopacket.NilDecodeFeedback) != nil || dns.QR == true || len(dns.Questions) == 0 {
+			return
+		}
+
+		name := strings.ToLower(string(dns.Questions[0].Name))
+		if strings.HasPrefix(name, "2.dnscrypt-cert.") {
+			ev.Protocol = "dnscrypt"
+			ev.Reason = "certificate query"
+			ev.Hostname = name
+		} else if name == encDNSCanary {
+			ev.Protocol = "doh"
+			ev.Reason = "canary query"
+			ev.Hostname = name
+		} else {
+			return
+		}
+	}
+
+	// UDP can't be reset, blocking it is up to the firewall
+	e.report(ev)
+}
+
+func (e *EncryptedDNS) onPacket(pkt gopacket.Packet) {
+	leth := pkt.Layer(layers.LayerTypeEthernet)
+	if leth == nil {
+		return
+	}
+
+	eth := leth.(*layers.Ethernet)
+	// our own traffic and the one we forward
+	if bytes.Equal(eth.SrcMAC, e.iface.HW) {
+		return
+	}
+
+	var src, dst net.IP
+	if ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok == true {
+		src, dst = ip4.SrcIP, ip4.DstIP
+	} else if ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok == true {
+		src, dst = ip6.SrcIP, ip6.DstIP
+	} else {
+		return
+	}
+
+	if tcp, ok := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP); ok == true {
+		e.onTCP(eth, src, dst, tcp)
+	} else if udp, ok := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP); ok == true {
+		e.onUDP(src, dst, udp)
+	}
+}
+
+func (e *EncryptedDNS) Show() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if len(e.entries) == 0 {
+		fmt.Println(core.Dim("No encrypted DNS seen so far."))
+		return nil
+	}
+
+	entries := make([]*encDNSEntry, 0, len(e.entries))
+	for _, entry := range e.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastSeen.After(entries[j].lastSeen)
+	})
+
+	data := make([][]string, len(entries))
+	for i, entry := range entries {
+		ev := entry.event
+		blocked := core.OFF
+		if ev.Blocked == true {
+			blocked = core.ON
+		}
+
+		data[i] = []string{
+			ev.Client,
+			ev.Protocol,
+			fmt.Sprintf("%s:%d", ev.Server, ev.Port),
+			ev.Hostname,
+			ev.Reason,
+			fmt.Sprintf("%d", entry.hits),
+			blocked,
+			entry.lastSeen.Format("15:04:05"),
+		}
+	}
+
+	core.AsTable(os.Stdout, []string{"Client", "Protocol", "Server", "Hostname", "Reason", "Hits", "Blocked", "Last Seen"}, data)
+
+	fmt.Println()
+
+	return nil
+}
+
+func (e *EncryptedDNS) Start() error {
+	if e.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := e.Configure(); err != nil {
+		return err
+	}
+
+	e.SetRunning(true)
+
+	go func(handle *pcap.Handle) {
+		defer e.Session.Recover()
+		defer handle.Close()
+
+		src := gopacket.NewPacketSource(handle, handle.LinkType())
+		for packet := range src.Packets() {
+			if e.Running() == false {
+				break
+			}
+
+			e.onPacket(packet)
+		}
+	}(e.handle)
+
+	return nil
+}
+
+func (e *EncryptedDNS) Stop() error {
+	if e.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	e.SetRunning(false)
+	return nil
+}
+
+func (e *EncryptedDNS) Metrics() []session.Metric {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return []session.Metric{
+		session.NewMetric("dns_encrypted_clients", "Number of client and resolver pairs seen using encrypted DNS.", session.MetricGauge, float64(len(e.entries))),
+		session.NewMetric("dns_encrypted_resets_total", "Number of encrypted DNS connections reset.", session.MetricCounter, float64(e.blocked)),
+	}
+}
@@ -0,0 +1,323 @@
+package modules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Emitted for each query answered by dns.server.
+type DNSServerQuery struct {
+	Client    string   `json:"client"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Answers   []string `json:"answers"`
+	Code      string   `json:"code"`
+	Forwarded bool     `json:"forwarded"`
+}
+
+type DNSServer struct {
+	session.SessionModule
+	zone     dnsZone
+	upstream string
+	conns    []*net.UDPConn
+	wg       sync.WaitGroup
+}
+
+func NewDNSServer(s *session.Session) *DNSServer {
+	dns := &DNSServer{
+		SessionModule: session.NewSessionModule("dns.server", s),
+		conns:         make([]*net.UDPConn, 0),
+	}
+
+	dns.AddParam(session.NewStringParameter("dns.server.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the DNS server to, if it's the interface address the server will listen on its IPv6 address too."))
+
+	dns.AddParam(session.NewIntParameter("dns.server.port",
+		"53",
+		"Port to bind the DNS server to."))
+
+	dns.AddParam(session.NewStringParameter("dns.server.zone",
+		"",
+		"",
+		"Path of a file with one record per line in the NAME [TTL] TYPE VALUE format, i.e. '*.corp.local A {iface.ipv4}'."))
+
+	dns.AddParam(session.NewStringParameter("dns.server.records",
+		"",
+		"",
+		"Semicolon separated list of records in the same format of the zone file, added to the ones of the file."))
+
+	dns.AddParam(session.NewIntParameter("dns.server.ttl",
+		"60",
+		"TTL in seconds of the records which don't specify one."))
+
+	dns.AddParam(session.NewStringParameter("dns.server.upstream",
+		"",
+		"",
+		"DNS server, as IP or IP:PORT, to forward the queries for names which are not in the zone to, if empty they are answered with NXDOMAIN."))
+
+	dns.AddHandler(session.NewModuleHandler("dns.server on", "",
+		"Start the DNS server.",
+		func(args []string) error {
+			return dns.Start()
+		}))
+
+	dns.AddHandler(session.NewModuleHandler("dns.server off", "",
+		"Stop the DNS server.",
+		func(args []string) error {
+			return dns.Stop()
+		}))
+
+	return dns
+}
+
+func (d *DNSServer) Name() string {
+	return "dns.server"
+}
+
+func (d *DNSServer) Description() string {
+	return "An authoritative DNS server answering with the records of a zone file or of inline records, victims can be pointed to it with dhcp6.spoof."
+}
+
+func (d *DNSServer) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (d *DNSServer) Configure() error {
+	var err error
+	var address string
+	var port int
+	var zoneFile string
+	var records string
+	var ttl int
+
+	if err, address = d.StringParam("dns.server.address"); err != nil {
+		return err
+	} else if err, port = d.IntParam("dns.server.port"); err != nil {
+		return err
+	} else if err, zoneFile = d.StringParam("dns.server.zone"); err != nil {
+		return err
+	} else if err, records = d.StringParam("dns.server.records"); err != nil {
+		return err
+	} else if err, ttl = d.IntParam("dns.server.ttl"); err != nil {
+		return err
+	} else if err, d.upstream = d.StringParam("dns.server.upstream"); err != nil {
+		return err
+	}
+
+	if d.upstream != "" {
+		if _, _, err := net.SplitHostPort(d.upstream); err != nil {
+			d.upstream = net.JoinHostPort(d.upstream, "53")
+		}
+	}
+
+	data := strings.Replace(records, ";", "\n", -1)
+	if zoneFile != "" {
+		if zoneFile, err = core.ExpandPath(zoneFile); err != nil {
+			return err
+		} else if raw, err := ioutil.ReadFile(zoneFile); err != nil {
+			return err
+		} else {
+			data = string(raw) + "\n" + data
+		}
+	}
+
+	// so that records can refer to {iface.ipv4} and the like
+	if d.zone, err = parseDNSZone(d.Session.Env.Expand(data), uint32(ttl)); err != nil {
+		return fmt.Errorf("Error while parsing the DNS records: %s", err)
+	}
+
+	addrs := []*net.UDPAddr{&net.UDPAddr{IP: net.ParseIP(address), Port: port}}
+	if ip6 := d.Session.Interface.IPv6; ip6 != nil && address == d.Session.Interface.IpAddress {
+		addr6 := &net.UDPAddr{IP: ip6, Port: port}
+		// dhcp6.spoof advertises the link-local address
+		if ip6.IsLinkLocalUnicast() == true {
+			addr6.Zone = d.Session.Interface.Name()
+		}
+		addrs = append(addrs, addr6)
+	}
+
+	d.conns = make([]*net.UDPConn, 0)
+	for i, addr := range addrs {
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			// IPv6 is best effort
+			if i > 0 {
+				log.Warning("Could not listen on %s: %s", addr, err)
+				continue
+			}
+			return err
+		}
+		d.conns = append(d.conns, conn)
+	}
+
+	return nil
+}
+
+func (d *DNSServer) answer(req *layers.DNS) (res *layers.DNS, event DNSServerQuery) {
+	res = &layers.DNS{
+		ID:           req.ID,
+		QR:           true,
+		OpCode:       layers.DNSOpCodeQuery,
+		AA:           true,
+		RD:           req.RD,
+		ResponseCode: layers.DNSResponseCodeNoErr,
+		Questions:    req.Questions,
+		Answers:      make([]layers.DNSResourceRecord, 0),
+	}
+
+	q := req.Questions[0]
+	event.Name = string(q.Name)
+	event.Type = q.Type.String()
+	event.Answers = make([]string, 0)
+
+	records := d.zone.lookup(event.Name)
+	if records == nil {
+		res.ResponseCode = layers.DNSResponseCodeNXDomain
+		event.Code = res.ResponseCode.String()
+		return
+	}
+
+	// names with a CNAME have no other records, if its target is in
+	// the zone as well its records are added too, 255 is ANY
+	name := q.Name
+	for depth := 0; records != nil && depth < 8; depth++ {
+		var next []dnsRecord
+		var nextName []byte
+		for _, r := range records {
+			if r.Type == q.Type || r.Type == layers.DNSTypeCNAME || q.Type == layers.DNSType(255) {
+				res.Answers = append(res.Answers, r.resourceRecord(name))
+				event.Answers = append(event.Answers, r.String())
+
+				if r.Type == layers.DNSTypeCNAME && q.Type != layers.DNSTypeCNAME {
+					nextName = []byte(r.Host)
+					next = d.zone.lookup(r.Host)
+				}
+			}
+		}
+		records, name = next, nextName
+	}
+
+	event.Code = res.ResponseCode.String()
+	return
+}
+
+// Relays the query as it is and returns the raw response.
+func (d *DNSServer) forward(query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", d.upstream, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err = conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+func (d *DNSServer) onQuery(conn *net.UDPConn, from *net.UDPAddr, query []byte) {
+	defer d.Session.Recover()
+	req := &layers.DNS{}
+	if err := req.DecodeFromBytes(query, gopacket.NilDecodeFeedback); err != nil {
+		log.Debug("Error while decoding DNS query from %s: %s", from, err)
+		return
+	} else if req.QR == true || req.OpCode != layers.DNSOpCodeQuery || len(req.Questions) == 0 {
+		return
+	}
+
+	res, event := d.answer(req)
+	event.Client = from.IP.String()
+
+	var raw []byte
+	if res.ResponseCode == layers.DNSResponseCodeNXDomain && d.upstream != "" {
+		var err error
+		if raw, err = d.forward(query); err != nil {
+			log.Debug("Error while forwarding DNS query for %s to %s: %s", event.Name, d.upstream, err)
+			return
+		}
+		event.Forwarded = true
+		event.Code = ""
+	} else {
+		buf := gopacket.NewSerializeBuffer()
+		if err := res.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+			log.Error("Error while serializing DNS response for %s: %s", event.Name, err)
+			return
+		}
+		raw = buf.Bytes()
+	}
+
+	if _, err := conn.WriteToUDP(raw, from); err != nil {
+		log.Debug("Error while sending DNS response to %s: %s", from, err)
+	}
+
+	d.Session.Events.Add("dns.server.query", event)
+}
+
+func (d *DNSServer) worker(conn *net.UDPConn) {
+	defer d.wg.Done()
+
+	buf := make([]byte, 4096)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// closed by Stop
+			return
+		}
+
+		query := append([]byte{}, buf[:n]...)
+		go d.onQuery(conn, from, query)
+	}
+}
+
+func (d *DNSServer) Start() error {
+	if d.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := d.Configure(); err != nil {
+		return err
+	}
+
+	d.SetRunning(true)
+
+	for _, conn := range d.conns {
+		log.Info("DNS server listening on %s with %d names.", conn.LocalAddr(), len(d.zone))
+		d.wg.Add(1)
+		go d.worker(conn)
+	}
+
+	return nil
+}
+
+func (d *DNSServer) Stop() error {
+	if d.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	d.SetRunning(false)
+
+	for _, conn := range d.conns {
+		conn.Close()
+	}
+	d.wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package modules
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+type dnsRecord struct {
+	Name  string
+	Type  layers.DNSType
+	TTL   uint32
+	IP    net.IP
+	Host  string
+	Pref  uint16
+	Texts []string
+}
+
+func (r dnsRecord) String() string {
+	switch r.Type {
+	case layers.DNSTypeA, layers.DNSTypeAAAA:
+		return r.IP.String()
+	case layers.DNSTypeMX:
+		return fmt.Sprintf("%d %s", r.Pref, r.Host)
+	case layers.DNSTypeTXT:
+		return strings.Join(r.Texts, " ")
+	}
+	return r.Host
+}
+
+func (r dnsRecord) resourceRecord(name []byte) layers.DNSResourceRecord {
+	rr := layers.DNSResourceRecord{
+		Name:  name,
+		Type:  r.Type,
+		Class: layers.DNSClassIN,
+		TTL:   r.TTL,
+	}
+
+	switch r.Type {
+	case layers.DNSTypeA, layers.DNSTypeAAAA:
+		rr.IP = r.IP
+	case layers.DNSTypeCNAME:
+		rr.CNAME = []byte(r.Host)
+	case layers.DNSTypePTR:
+		rr.PTR = []byte(r.Host)
+	case layers.DNSTypeNS:
+		rr.NS = []byte(r.Host)
+	case layers.DNSTypeMX:
+		rr.MX = layers.DNSMX{Preference: r.Pref, Name: []byte(r.Host)}
+	case layers.DNSTypeTXT:
+		for _, txt := range r.Texts {
+			rr.TXTs = append(rr.TXTs, []byte(txt))
+		}
+	}
+
+	return rr
+}
+
+// Records indexed by lowercase name without the trailing dot,
+// wildcards are stored as they are, i.e. *.example.com.
+type dnsZone map[string][]dnsRecord
+
+func dnsName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// Parses one record per line in the NAME [TTL] TYPE VALUE format,
+// where NAME can be *.domain to match any of its subdomains or just *
+// to match any name, empty lines and lines starting with # or ; are
+// skipped.
+func parseDNSZone(data string, ttl uint32) (dnsZone, error) {
+	zone := make(dnsZone)
+
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if rec, err := parseDNSRecord(line, ttl); err != nil {
+			return nil, fmt.Errorf("Line %d: %s", i+1, err)
+		} else {
+			zone[rec.Name] = append(zone[rec.Name], rec)
+		}
+	}
+
+	return zone, nil
+}
+
+func parseDNSRecord(line string, ttl uint32) (rec dnsRecord, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return rec, fmt.Errorf("'%s' is not a valid record.", line)
+	}
+
+	rec.Name = dnsName(fields[0])
+	rec.TTL = ttl
+	fields = fields[1:]
+
+	if n, err := strconv.ParseUint(fields[0], 10, 32); err == nil {
+		rec.TTL = uint32(n)
+		fields = fields[1:]
+		if len(fields) < 2 {
+			return rec, fmt.Errorf("'%s' is not a valid record.", line)
+		}
+	}
+
+	value := fields[1]
+
+	switch strings.ToUpper(fields[0]) {
+	case "A":
+		rec.Type = layers.DNSTypeA
+		if rec.IP = net.ParseIP(value).To4(); rec.IP == nil {
+			return rec, fmt.Errorf("'%s' is not a valid IPv4 address.", value)
+		}
+
+	case "AAAA":
+		rec.Type = layers.DNSTypeAAAA
+		if rec.IP = net.ParseIP(value); rec.IP == nil || rec.IP.To4() != nil {
+			return rec, fmt.Errorf("'%s' is not a valid IPv6 address.", value)
+		}
+
+	case "CNAME":
+		rec.Type = layers.DNSTypeCNAME
+		rec.Host = dnsName(value)
+
+	case "PTR":
+		rec.Type = layers.DNSTypePTR
+		rec.Host = dnsName(value)
+
+	case "NS":
+		rec.Type = layers.DNSTypeNS
+		rec.Host = dnsName(value)
+
+	case "MX":
+		rec.Type = layers.DNSTypeMX
+		if len(fields) < 3 {
+			return rec, fmt.Errorf("MX records need a preference and a host.")
+		} else if pref, err := strconv.ParseUint(value, 10, 16); err != nil {
+			return rec, fmt.Errorf("'%s' is not a valid MX preference.", value)
+		} else {
+			rec.Pref = uint16(pref)
+			rec.Host = dnsName(fields[2])
+		}
+
+	case "TXT":
+		rec.Type = layers.DNSTypeTXT
+		rec.Texts = []string{strings.Trim(strings.Join(fields[1:], " "), "\"")}
+
+	default:
+		return rec, fmt.Errorf("Record type '%s' is not supported.", fields[0])
+	}
+
+	return rec, nil
+}
+
+// Returns the records of the name, or of the most specific
+// wildcard matching it, nil if the name is not in the zone.
+func (z dnsZone) lookup(name string) []dnsRecord {
+	name = dnsName(name)
+	if records, found := z[name]; found == true {
+		return records
+	}
+
+	for labels := strings.Split(name, "."); len(labels) > 1; labels = labels[1:] {
+		if records, found := z["*."+strings.Join(labels[1:], ".")]; found == true {
+			return records
+		}
+	}
+
+	return z["*"]
+}
@@ -16,6 +16,14 @@ import (
 	"github.com/google/gopacket/pcap"
 )
 
+// Emitted for each spoofed response, collected by the report module.
+type SpoofEvent struct {
+	Protocol string `json:"protocol"`
+	Request  string `json:"request"`
+	Response string `json:"response"`
+	Target   string `json:"target"`
+}
+
 type DNSSpoofer struct {
 	session.SessionModule
 	Handle  *pcap.Handle
@@ -96,11 +104,17 @@ func (s *DNSSpoofer) dnsReply(pkt gopacket.Packet, peth *layers.Ethernet, pudp *
 	redir := fmt.Sprintf("(->%s)", s.Address)
 	who := target.String()
 
-	if t, found := s.Session.Targets.Targets[target.String()]; found == true {
+	if t, found := s.Session.Targets.ByIP(target.String()); found == true {
 		who = t.String()
 	}
 
 	log.Info("[%s] Sending spoofed DNS reply for %s %s to %s.", core.Green("dns"), core.Red(domain), core.Dim(redir), core.Bold(who))
+	s.Session.Events.Add("dns.spoof", SpoofEvent{
+		Protocol: "dns",
+		Request:  domain,
+		Response: s.Address.String(),
+		Target:   who,
+	})
 
 	var err error
 	var src, dst net.IP
@@ -249,6 +263,7 @@ func (s *DNSSpoofer) Start() error {
 	s.SetRunning(true)
 
 	go func() {
+		defer s.Session.Recover()
 		defer s.Handle.Close()
 
 		src := gopacket.NewPacketSource(s.Handle, s.Handle.LinkType())
@@ -0,0 +1,342 @@
+package modules
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+// Mapping installed as an index template, so that event
+// data of different types doesn't cause mapping conflicts.
+const elasticIndexTemplate = `{
+  "index_patterns": ["%s-*"],
+  "template": {
+    "mappings": {
+      "properties": {
+        "tag":  { "type": "keyword" },
+        "time": { "type": "date" },
+        "data": { "type": "object", "enabled": false }
+      }
+    }
+  }
+}`
+
+const (
+	elasticMaxBackoff = 5 * time.Minute
+	elasticMaxPending = 10000
+)
+
+type ElasticShipper struct {
+	session.SessionModule
+	client    *http.Client
+	url       string
+	mode      string
+	index     string
+	username  string
+	password  string
+	batchSize int
+	flush     time.Duration
+	include   []string
+	exclude   []string
+	pending   []session.Event
+	quit      chan bool
+}
+
+func NewElasticShipper(s *session.Session) *ElasticShipper {
+	es := &ElasticShipper{
+		SessionModule: session.NewSessionModule("elastic", s),
+		include:       make([]string, 0),
+		exclude:       make([]string, 0),
+		pending:       make([]session.Event, 0),
+		quit:          make(chan bool),
+	}
+
+	es.AddParam(session.NewStringParameter("elastic.url",
+		"http://127.0.0.1:9200",
+		"^https?://.+$",
+		"Elasticsearch URL, or Logstash HTTP input URL if elastic.mode is logstash."))
+
+	es.AddParam(session.NewStringParameter("elastic.mode",
+		"elasticsearch",
+		"^(elasticsearch|logstash)$",
+		"Use elasticsearch to bulk index events, logstash to post them as JSON lines to a Logstash http input."))
+
+	es.AddParam(session.NewStringParameter("elastic.index",
+		"bettercap",
+		"^[a-z0-9_\\-]+$",
+		"Index prefix, events are indexed into daily PREFIX-YYYY.MM.DD indexes."))
+
+	es.AddParam(session.NewStringParameter("elastic.username",
+		"",
+		"",
+		"Username for basic authentication, if required."))
+
+	es.AddParam(session.NewSecretParameter("elastic.password",
+		"",
+		"",
+		"Password for basic authentication, if required."))
+
+	es.AddParam(session.NewBoolParameter("elastic.tls.insecure",
+		"false",
+		"If true, the server TLS certificate will not be verified."))
+
+	es.AddParam(session.NewIntParameter("elastic.batch",
+		"100",
+		"Number of events to send in a single request."))
+
+	es.AddParam(session.NewIntParameter("elastic.flush",
+		"5",
+		"Send pending events every this amount of seconds even if the batch is not full."))
+
+	es.AddParam(session.NewStringParameter("elastic.filter",
+		"",
+		"",
+		"If filled, only ship events whose type starts with one of these comma separated prefixes."))
+
+	es.AddParam(session.NewStringParameter("elastic.exclude",
+		"",
+		"",
+		"If filled, do not ship events whose type starts with one of these comma separated prefixes."))
+
+	es.AddHandler(session.NewModuleHandler("elastic on", "",
+		"Start shipping events.",
+		func(args []string) error {
+			return es.Start()
+		}))
+
+	es.AddHandler(session.NewModuleHandler("elastic off", "",
+		"Stop shipping events.",
+		func(args []string) error {
+			return es.Stop()
+		}))
+
+	return es
+}
+
+func (es ElasticShipper) Name() string {
+	return "elastic"
+}
+
+func (es ElasticShipper) Description() string {
+	return "Ship session events to Elasticsearch or Logstash."
+}
+
+func (es ElasticShipper) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (es *ElasticShipper) Configure() (err error) {
+	var insecure bool
+	var flush int
+
+	if err, es.url = es.StringParam("elastic.url"); err != nil {
+		return err
+	} else if err, es.mode = es.StringParam("elastic.mode"); err != nil {
+		return err
+	} else if err, es.index = es.StringParam("elastic.index"); err != nil {
+		return err
+	} else if err, es.username = es.StringParam("elastic.username"); err != nil {
+		return err
+	} else if err, es.password = es.StringParam("elastic.password"); err != nil {
+		return err
+	} else if err, insecure = es.BoolParam("elastic.tls.insecure"); err != nil {
+		return err
+	} else if err, es.batchSize = es.IntParam("elastic.batch"); err != nil {
+		return err
+	} else if err, flush = es.IntParam("elastic.flush"); err != nil {
+		return err
+	} else if err, es.include = es.ListParam("elastic.filter"); err != nil {
+		return err
+	} else if err, es.exclude = es.ListParam("elastic.exclude"); err != nil {
+		return err
+	}
+
+	if es.batchSize < 1 {
+		es.batchSize = 1
+	}
+	if flush < 1 {
+		flush = 1
+	}
+
+	es.url = strings.TrimRight(es.url, "/")
+	es.flush = time.Duration(flush) * time.Second
+	es.client = &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+		},
+	}
+
+	if es.mode == "elasticsearch" {
+		template := fmt.Sprintf(elasticIndexTemplate, es.index)
+		if err = es.request("PUT", es.url+"/_index_template/"+es.index, "application/json", strings.NewReader(template)); err != nil {
+			return fmt.Errorf("Error while creating index template: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (es *ElasticShipper) request(method string, url string, contentType string, body io.Reader) error {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if es.username != "" {
+		req.SetBasicAuth(es.username, es.password)
+	}
+
+	res, err := es.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	raw, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s", method, res.Status, raw)
+	}
+
+	// bulk requests succeed even if some documents failed
+	if es.mode == "elasticsearch" && bytes.Contains(raw, []byte(`"errors":true`)) {
+		log.Debug("Some events have not been indexed: %s", raw)
+	}
+
+	return nil
+}
+
+func (es *ElasticShipper) send(events []session.Event) error {
+	buf := bytes.Buffer{}
+
+	for _, e := range events {
+		doc, err := json.Marshal(e)
+		if err != nil {
+			log.Debug("Error while encoding %s event: %s", e.Tag, err)
+			continue
+		}
+
+		if es.mode == "elasticsearch" {
+			index := fmt.Sprintf("%s-%s", es.index, e.Time.UTC().Format("2006.01.02"))
+			fmt.Fprintf(&buf, "{\"index\":{\"_index\":\"%s\"}}\n", index)
+		}
+		buf.Write(doc)
+		buf.WriteByte('\n')
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	if es.mode == "elasticsearch" {
+		return es.request("POST", es.url+"/_bulk", "application/x-ndjson", &buf)
+	}
+	return es.request("POST", es.url, "application/x-ndjson", &buf)
+}
+
+func (es *ElasticShipper) accept(e session.Event) bool {
+	if len(es.include) > 0 && hasPrefix(e.Tag, es.include) == false {
+		return false
+	}
+	return hasPrefix(e.Tag, es.exclude) == false
+}
+
+func (es *ElasticShipper) Start() error {
+	if es.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := es.Configure(); err != nil {
+		return err
+	}
+
+	es.SetRunning(true)
+
+	go func() {
+		listener := es.Session.Events.Listen()
+		defer es.Session.Events.Unlisten(listener)
+
+		ticker := time.NewTicker(es.flush)
+		defer ticker.Stop()
+
+		backoff := time.Duration(0)
+		retryAt := time.Time{}
+
+		flush := func() {
+			if len(es.pending) == 0 || time.Now().Before(retryAt) {
+				return
+			}
+
+			batch := es.pending
+			if len(batch) > es.batchSize {
+				batch = batch[:es.batchSize]
+			}
+
+			if err := es.send(batch); err != nil {
+				if backoff == 0 {
+					backoff = es.flush
+				} else if backoff *= 2; backoff > elasticMaxBackoff {
+					backoff = elasticMaxBackoff
+				}
+				retryAt = time.Now().Add(backoff)
+				log.Debug("Error while shipping %d events, retrying in %s: %s", len(batch), backoff, err)
+				return
+			}
+
+			backoff = 0
+			es.pending = es.pending[len(batch):]
+		}
+
+		for {
+			select {
+			case e := <-listener:
+				// errors would generate more events to ship
+				if msg, ok := e.Data.(session.LogMessage); ok == true && e.Tag == "sys.log" && strings.HasPrefix(msg.Message, "Error while shipping") {
+					continue
+				} else if es.accept(e) == false {
+					continue
+				}
+
+				// the server has been down for a while, drop the oldest events
+				if es.pending = append(es.pending, e); len(es.pending) > elasticMaxPending {
+					es.pending = es.pending[len(es.pending)-elasticMaxPending:]
+				}
+
+				if len(es.pending) >= es.batchSize {
+					flush()
+				}
+
+			case <-ticker.C:
+				flush()
+
+			case <-es.quit:
+				// one last attempt
+				retryAt = time.Time{}
+				for len(es.pending) > 0 && time.Now().Before(retryAt) == false {
+					flush()
+				}
+				es.pending = make([]session.Event, 0)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (es *ElasticShipper) Stop() error {
+	if es.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	es.SetRunning(false)
+	es.quit <- true
+	return nil
+}
@@ -1,30 +1,55 @@
 package modules
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
 	"github.com/evilsocket/bettercap-ng/session"
 )
 
 type EventsStream struct {
 	session.SessionModule
-	filter string
-	quit   chan bool
+	include    []string
+	exclude    []string
+	outputName string
+	output     *os.File
+	rotateSize int64
+	quit       chan bool
 }
 
 func NewEventsStream(s *session.Session) *EventsStream {
 	stream := &EventsStream{
 		SessionModule: session.NewSessionModule("events.stream", s),
-		filter:        "",
+		include:       make([]string, 0),
+		exclude:       make([]string, 0),
+		outputName:    "",
+		output:        nil,
+		rotateSize:    0,
 		quit:          make(chan bool),
 	}
 
 	stream.AddParam(session.NewStringParameter("events.stream.filter",
 		"",
 		"",
-		"If filled, filter events by this prefix type."))
+		"If filled, only show events whose type starts with one of these comma separated prefixes."))
+
+	stream.AddParam(session.NewStringParameter("events.stream.exclude",
+		"",
+		"",
+		"If filled, hide events whose type starts with one of these comma separated prefixes."))
+
+	stream.AddParam(session.NewStringParameter("events.stream.output",
+		"",
+		"",
+		"If filled, events will also be appended as JSON lines to this file."))
+
+	stream.AddParam(session.NewIntParameter("events.stream.output.rotate",
+		"10",
+		"Rotate the output file when it gets bigger than this size in MB, 0 to disable rotation."))
 
 	stream.AddHandler(session.NewModuleHandler("events.stream on", "",
 		"Start events stream.",
@@ -45,6 +70,32 @@ func NewEventsStream(s *session.Session) *EventsStream {
 			return nil
 		}))
 
+	stream.AddHandler(session.NewModuleHandler("events.ignore FILTER", "^events\\.ignore\\s+([^\\s]+)$",
+		"Do not show events whose type starts with FILTER.",
+		func(args []string) error {
+			stream.Session.Events.Ignore(args[0])
+			return nil
+		}))
+
+	stream.AddHandler(session.NewModuleHandler("events.include FILTER", "^events\\.include\\s+([^\\s]+)$",
+		"Show again events whose type starts with FILTER.",
+		func(args []string) error {
+			return stream.Session.Events.Unignore(args[0])
+		}))
+
+	stream.AddHandler(session.NewModuleHandler("events.filters", "",
+		"Print the list of ignored event types.",
+		func(args []string) error {
+			return stream.showIgnored()
+		}))
+
+	stream.AddHandler(session.NewModuleHandler("events.filters.clear", "",
+		"Show all event types again.",
+		func(args []string) error {
+			stream.Session.Events.ClearIgnoreList()
+			return nil
+		}))
+
 	return stream
 }
 
@@ -60,14 +111,105 @@ func (s EventsStream) Author() string {
 	return "Simone Margaritelli <evilsocket@protonmail.com>"
 }
 
-func (s *EventsStream) Configure() error {
-	var err error
-	if err, s.filter = s.StringParam("events.stream.filter"); err != nil {
+func (s *EventsStream) Configure() (err error) {
+	var rotate int
+
+	if err, s.include = s.ListParam("events.stream.filter"); err != nil {
+		return err
+	} else if err, s.exclude = s.ListParam("events.stream.exclude"); err != nil {
+		return err
+	} else if err, s.outputName = s.StringParam("events.stream.output"); err != nil {
 		return err
+	} else if err, rotate = s.IntParam("events.stream.output.rotate"); err != nil {
+		return err
+	}
+
+	s.rotateSize = int64(rotate) * 1024 * 1024
+
+	if s.outputName != "" {
+		if s.outputName, err = core.ExpandPath(s.outputName); err != nil {
+			return err
+		} else if s.output, err = os.OpenFile(s.outputName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *EventsStream) showIgnored() error {
+	ignored := s.Session.Events.IgnoreList()
+	if len(ignored) == 0 {
+		fmt.Println(core.Dim("No ignored event types."))
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println(core.Bold("Ignored event types:"))
+	fmt.Println()
+	for _, prefix := range ignored {
+		fmt.Printf("  '%s'\n", core.Yellow(prefix))
 	}
+	fmt.Println()
+
 	return nil
 }
 
+func hasPrefix(tag string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *EventsStream) accept(e session.Event) bool {
+	if len(s.include) > 0 && hasPrefix(e.Tag, s.include) == false {
+		return false
+	}
+	return hasPrefix(e.Tag, s.exclude) == false
+}
+
+func (s *EventsStream) rotate() {
+	output, rotated, err := core.RotateFile(s.output, s.outputName, s.rotateSize)
+	if err != nil {
+		log.Error("%s", err)
+	}
+	if rotated != "" {
+		artifactReady("events", rotated)
+	}
+	s.output = output
+}
+
+func (s *EventsStream) save(e session.Event) {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		// don't log, it would generate yet another event
+		fmt.Fprintf(os.Stderr, "Error while encoding %s event: %s\n", e.Tag, err)
+		return
+	}
+
+	if _, err = s.output.Write(append(raw, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "Error while writing to %s: %s\n", s.outputName, err)
+		return
+	}
+
+	if s.rotateSize > 0 {
+		s.rotate()
+	}
+}
+
+func (s *EventsStream) view(e session.Event) {
+	if core.JSONOutput == true {
+		if raw, err := json.Marshal(e); err == nil {
+			fmt.Printf("%s\n", raw)
+		}
+	} else {
+		fmt.Println(viewEvent(e))
+	}
+}
+
 func (s *EventsStream) Start() error {
 	if s.Running() == true {
 		return session.ErrAlreadyStarted
@@ -78,24 +220,31 @@ func (s *EventsStream) Start() error {
 	s.SetRunning(true)
 
 	go func() {
+		listener := s.Session.Events.Listen()
+		defer s.Session.Events.Unlisten(listener)
+
 		for {
 			var e session.Event
 			select {
-			case e = <-s.Session.Events.NewEvents:
-				if s.filter == "" || strings.Contains(e.Tag, s.filter) {
-					tm := e.Time.Format("2006-01-02 15:04:05")
-
-					if e.Tag == "sys.log" {
-						fmt.Printf("[%s] [%s] (%s) %s\n", tm, core.Green(e.Tag), e.Label(), e.Data.(session.LogMessage).Message)
-					} else {
-						fmt.Printf("[%s] [%s] %v\n", tm, core.Green(e.Tag), e.Data)
+			case e = <-listener:
+				if s.accept(e) == true {
+					// ignored events are still saved to the output file
+					if s.output != nil {
+						s.save(e)
 					}
 
-					s.Session.Refresh()
+					if s.Session.Events.Ignored(e.Tag) == false {
+						s.view(e)
+						s.Session.Refresh()
+					}
 				}
 				break
 
 			case <-s.quit:
+				if s.output != nil {
+					s.output.Close()
+					s.output = nil
+				}
 				return
 			}
 		}
@@ -0,0 +1,271 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+func viewEndpointEvent(e session.Event) string {
+	t, ok := e.Data.(*net.Endpoint)
+	if ok == false {
+		return fmt.Sprintf("%v", e.Data)
+	}
+
+	vend := ""
+	name := ""
+
+	if t.Vendor != "" {
+		vend = fmt.Sprintf(" (%s)", t.Vendor)
+	}
+
+	if t.Hostname != "" {
+		name = fmt.Sprintf(" (%s)", core.Yellow(t.Hostname))
+	}
+
+	return fmt.Sprintf("%s %s%s%s", core.Bold(t.IpAddress), core.Dim(t.HwAddress), vend, name)
+}
+
+func viewHIDEvent(e session.Event) string {
+	dev, ok := e.Data.(*net.HIDDevice)
+	if ok == false {
+		return fmt.Sprintf("%v", e.Data)
+	}
+	return fmt.Sprintf("%s device %s on channels %v", core.Yellow(dev.Type), core.Bold(dev.Address), dev.Channels)
+}
+
+// Events carrying unexpected data are rendered as they are.
+func viewEventData(e session.Event) string {
+	switch e.Tag {
+	case "sys.log":
+		if msg, ok := e.Data.(session.LogMessage); ok == true {
+			return fmt.Sprintf("(%s) %s", e.Label(), msg.Message)
+		}
+
+	case "target.new", "target.lost", "target.resolved":
+		return viewEndpointEvent(e)
+
+	case "hid.device.new", "hid.device.lost":
+		return viewHIDEvent(e)
+
+	case "wifi.iface.new":
+		return fmt.Sprintf("wireless adapter %s %s", core.Bold(fmt.Sprintf("%v", e.Data)), core.Green("plugged in"))
+
+	case "wifi.iface.lost":
+		return fmt.Sprintf("wireless adapter %s %s", core.Bold(fmt.Sprintf("%v", e.Data)), core.Red("removed"))
+
+	case "session.diff.new", "session.diff.lost":
+		t, ok := e.Data.(session.SessionTarget)
+		if ok == false {
+			break
+		}
+		return fmt.Sprintf("%s %s %s", core.Bold(t.IpAddress), core.Dim(t.HwAddress), t.Hostname)
+
+	case "session.diff.changed":
+		c, ok := e.Data.(session.TargetChange)
+		if ok == false {
+			break
+		}
+		return fmt.Sprintf("%s %s %s '%s' > '%s'", core.Bold(c.IpAddress), core.Dim(c.HwAddress), c.Field, c.Old, core.Yellow(c.New))
+
+	case "mod.started", "mod.stopped":
+		return core.Bold(fmt.Sprintf("%v", e.Data))
+
+	case "creds.new":
+		cred, ok := e.Data.(*session.Credential)
+		if ok == false {
+			break
+		}
+		secret := cred.Password
+		if hash := credHashcat(cred); hash != "" {
+			secret = hash
+		}
+		return fmt.Sprintf("%s %s %s > %s %s %s", core.Bold(cred.Type), cred.Protocol, cred.Source, cred.Destination, cred.Username, core.Yellow(secret))
+
+	case "http.server.request":
+		req, ok := e.Data.(HttpServerRequest)
+		if ok == false {
+			break
+		}
+		status := core.Green(fmt.Sprintf("%d", req.Status))
+		if req.Status >= 400 {
+			status = core.Red(fmt.Sprintf("%d", req.Status))
+		}
+		return fmt.Sprintf("%s %s %s%s %s", core.Bold(req.Address), req.Method, req.Host, req.Path, status)
+
+	case "dns.server.query":
+		q, ok := e.Data.(DNSServerQuery)
+		if ok == false {
+			break
+		}
+		answer := core.Red(q.Code)
+		if q.Forwarded == true {
+			answer = core.Dim("forwarded")
+		} else if len(q.Answers) > 0 {
+			answer = core.Green(strings.Join(q.Answers, ", "))
+		}
+		return fmt.Sprintf("%s %s %s > %s", core.Bold(q.Client), q.Type, core.Yellow(q.Name), answer)
+
+	case "arp.watch.spoof":
+		alert, ok := e.Data.(ArpSpoofAlert)
+		if ok == false {
+			break
+		}
+		who := core.Bold(alert.IP)
+		if alert.Gateway == true {
+			who = fmt.Sprintf("gateway %s", who)
+		}
+
+		claims := ""
+		if len(alert.Claims) > 0 {
+			claims = fmt.Sprintf(" (also %s)", strings.Join(alert.Claims, ", "))
+		}
+
+		if alert.Expected == "" {
+			return fmt.Sprintf("%s MAC %s is shared%s", who, core.Red(alert.Seen), claims)
+		}
+
+		corrected := ""
+		if alert.Corrected == true {
+			corrected = core.Green(" corrected")
+		}
+		return fmt.Sprintf("%s claimed by %s%s instead of %s%s", who, core.Red(alert.Seen), claims, alert.Expected, corrected)
+
+	case "dns.encrypted.detected":
+		ev, ok := e.Data.(EncryptedDNSEvent)
+		if ok == false {
+			break
+		}
+		server := fmt.Sprintf("%s:%d", ev.Server, ev.Port)
+		if ev.Hostname != "" {
+			server = fmt.Sprintf("%s (%s)", server, ev.Hostname)
+		}
+
+		blocked := ""
+		if ev.Blocked == true {
+			blocked = core.Red(" blocked")
+		}
+		return fmt.Sprintf("%s %s %s > %s%s", core.Bold(ev.Client), core.Yellow(ev.Protocol), core.Dim(ev.Reason), server, blocked)
+
+	case "dns.tunnel-suspect":
+		ev, ok := e.Data.(DNSTunnelSuspect)
+		if ok == false {
+			break
+		}
+		return fmt.Sprintf("%s > %s %s %s", core.Bold(ev.Client), core.Yellow(ev.Domain), core.Red(strings.Join(ev.Reasons, ", ")), core.Dim(ev.Sample))
+
+	case "net.watch.down", "net.watch.up":
+		c, ok := e.Data.(NetworkChange)
+		if ok == false {
+			break
+		}
+		if e.Tag == "net.watch.down" {
+			return fmt.Sprintf("%s %s", core.Bold(c.Interface), core.Red("down"))
+		}
+		return fmt.Sprintf("%s %s %s", core.Bold(c.Interface), core.Green("up"), c.New)
+
+	case "net.watch.changed":
+		c, ok := e.Data.(NetworkChange)
+		if ok == false {
+			break
+		}
+		return fmt.Sprintf("%s %s '%s' > '%s'", core.Bold(c.Interface), c.Field, c.Old, core.Yellow(c.New))
+
+	case "ra.watch.router.new":
+		ev, ok := e.Data.(RAWatchEvent)
+		if ok == false {
+			break
+		}
+		router := ev.Router
+		label := core.Green("new router")
+		if router.Rogue == true {
+			label = core.Red("rogue router")
+		}
+		return fmt.Sprintf("%s %s (%s) advertising %s", label, core.Bold(router.Address), router.MAC, strings.Join(router.Prefixes, ", "))
+
+	case "ra.watch.router.changed":
+		ev, ok := e.Data.(RAWatchEvent)
+		if ok == false {
+			break
+		}
+		return fmt.Sprintf("router %s changed %s", core.Bold(ev.Router.Address), core.Yellow(strings.Join(ev.Changes, ", ")))
+
+	case "gps.fix.acquired":
+		pos, ok := e.Data.(net.GPSPosition)
+		if ok == false {
+			break
+		}
+		return fmt.Sprintf("%s %s (%d satellites)", core.Green("fix acquired"), pos, pos.Satellites)
+
+	case "gps.fix.lost":
+		return core.Red("fix lost")
+
+	case "ftp.honeypot.upload":
+		up, ok := e.Data.(FTPUpload)
+		if ok == false {
+			break
+		}
+		size := fmt.Sprintf("%d bytes", up.Size)
+		if up.Truncated == true {
+			size += core.Dim(" (truncated)")
+		}
+		return fmt.Sprintf("%s (%s) uploaded %s %s", core.Bold(up.Client), up.Username, core.Yellow(up.Filename), size)
+
+	case "smb.relay.success", "smb.relay.failure":
+		res, ok := e.Data.(SMBRelayResult)
+		if ok == false {
+			break
+		}
+		status := core.Red(res.Status)
+		if e.Tag == "smb.relay.success" {
+			status = core.Green(res.Status)
+			if res.SigningRequired == true {
+				status += core.Yellow(" (signing required)")
+			}
+		}
+		return fmt.Sprintf("%s %s\\%s > %s %s", core.Bold(res.Client), res.Domain, res.Username, core.Bold(res.Target), status)
+
+	case "watchdog.budget.exceeded":
+		alert, ok := e.Data.(BudgetAlert)
+		if ok == false {
+			break
+		}
+		stopped := ""
+		if alert.Stopped == true {
+			stopped = core.Red(" stopped")
+		}
+		return fmt.Sprintf("%s using %s%s", core.Bold(alert.Module), core.Yellow(alert.String()), stopped)
+
+	case "agent.event":
+		ev, ok := e.Data.(AgentEvent)
+		if ok == false {
+			break
+		}
+		return fmt.Sprintf("%s %s %s", core.Bold(ev.Agent), core.Green(ev.Tag), ev.Data)
+
+	case "agent.result":
+		res, ok := e.Data.(AgentResult)
+		if ok == false {
+			break
+		}
+		if res.Error != "" {
+			return fmt.Sprintf("%s '%s' %s", core.Bold(res.Agent), res.Command, core.Red(res.Error))
+		}
+		return fmt.Sprintf("%s '%s' %s", core.Bold(res.Agent), res.Command, core.Green("ok"))
+	}
+
+	// platform dependant events ( i.e. ble.* )
+	if view := viewPlatformEvent(e); view != "" {
+		return view
+	}
+
+	return fmt.Sprintf("%v", e.Data)
+}
+
+func viewEvent(e session.Event) string {
+	tm := e.Time.Format("2006-01-02 15:04:05")
+	return fmt.Sprintf("[%s] [%s] %s", tm, core.Green(e.Tag), viewEventData(e))
+}
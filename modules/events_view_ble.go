@@ -0,0 +1,47 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+func viewPlatformEvent(e session.Event) string {
+	if e.Tag != "ble.device.new" && e.Tag != "ble.device.lost" {
+		return ""
+	}
+
+	dev, ok := e.Data.(*net.BLEDevice)
+	if ok == false {
+		return ""
+	}
+
+	name := ""
+	vend := ""
+
+	if dev.Name != "" {
+		name = fmt.Sprintf(" %s", core.Yellow(dev.Name))
+	}
+
+	if dev.Vendor != "" {
+		vend = fmt.Sprintf(" (%s)", dev.Vendor)
+	}
+
+	services := ""
+	if list := dev.Services(); len(list) > 0 {
+		services = fmt.Sprintf(" [%s]", strings.Join(list, ", "))
+	}
+
+	location := ""
+	if dev.Location != nil {
+		location = fmt.Sprintf(" @ %s", dev.Location)
+	}
+
+	return fmt.Sprintf("%s%s%s %d dBm%s%s", core.Bold(dev.MAC), name, vend, dev.RSSI, services, location)
+}
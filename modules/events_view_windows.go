@@ -0,0 +1,9 @@
+package modules
+
+import (
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+func viewPlatformEvent(e session.Event) string {
+	return ""
+}
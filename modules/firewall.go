@@ -0,0 +1,80 @@
+package modules
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/firewall"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+type FirewallModule struct {
+	session.SessionModule
+}
+
+func NewFirewallModule(s *session.Session) *FirewallModule {
+	f := &FirewallModule{
+		SessionModule: session.NewSessionModule("firewall", s),
+	}
+
+	f.AddHandler(session.NewModuleHandler("firewall.include RULE", `^firewall\.include\s+(.+)$`,
+		"Install a custom firewall rule, removed when the session ends. With iptables RULE is a list of arguments such as '-t nat -A PREROUTING -p tcp --dport 25 -j REDIRECT --to-ports 10025', with nftables a statement for the nat prerouting chain such as 'tcp dport 25 redirect to :10025'.",
+		func(args []string) error {
+			if err := firewall.AddRule(f.Session.Firewall, args[0]); err != nil {
+				return err
+			}
+			log.Info("Firewall rule installed.")
+			return nil
+		}))
+
+	f.AddHandler(session.NewModuleHandler("firewall.exclude RULE", `^firewall\.exclude\s+(.+)$`,
+		"Remove a custom firewall rule installed with firewall.include.",
+		func(args []string) error {
+			return firewall.DelRule(f.Session.Firewall, args[0])
+		}))
+
+	f.AddHandler(session.NewModuleHandler("firewall.rules", "",
+		"Show the custom firewall rules installed by this session.",
+		func(args []string) error {
+			return f.Show()
+		}))
+
+	return f
+}
+
+func (f FirewallModule) Name() string {
+	return "firewall"
+}
+
+func (f FirewallModule) Description() string {
+	return "Install custom firewall rules for the duration of the session."
+}
+
+func (f FirewallModule) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (f *FirewallModule) Show() error {
+	rules := firewall.Rules(f.Session.Firewall)
+	if len(rules) == 0 {
+		fmt.Println(core.Dim("No custom firewall rules."))
+		return nil
+	}
+
+	fmt.Println()
+	for _, rule := range rules {
+		fmt.Printf("  %s\n", core.Yellow(rule))
+	}
+	fmt.Println()
+
+	return nil
+}
+
+func (f *FirewallModule) Start() error {
+	return fmt.Errorf("The %s module can't be started.", f.Name())
+}
+
+func (f *FirewallModule) Stop() error {
+	return session.ErrAlreadyStopped
+}
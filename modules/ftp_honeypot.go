@@ -0,0 +1,583 @@
+package modules
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+const ftpIdleTimeout = 120 * time.Second
+
+// Emitted for each file uploaded to ftp.honeypot, Data holds
+// at most ftp.honeypot.upload.size bytes of its contents.
+type FTPUpload struct {
+	Client    string `json:"client"`
+	Username  string `json:"username"`
+	Filename  string `json:"filename"`
+	Size      int64  `json:"size"`
+	Data      []byte `json:"data"`
+	Truncated bool   `json:"truncated"`
+	Path      string `json:"path"`
+}
+
+type FTPHoneypot struct {
+	session.SessionModule
+	listener   net.Listener
+	banner     string
+	upstream   string
+	uploads    string
+	uploadSize int64
+	wg         sync.WaitGroup
+}
+
+type ftpConn struct {
+	net.Conn
+	reader   *bufio.Reader
+	honeypot *FTPHoneypot
+	username string
+	loggedIn bool
+	cwd      string
+	// passive listener or active address for the next transfer
+	passive net.Listener
+	active  string
+}
+
+func NewFTPHoneypot(s *session.Session) *FTPHoneypot {
+	ftp := &FTPHoneypot{
+		SessionModule: session.NewSessionModule("ftp.honeypot", s),
+	}
+
+	ftp.AddParam(session.NewStringParameter("ftp.honeypot.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the FTP listener to."))
+
+	ftp.AddParam(session.NewIntParameter("ftp.honeypot.port",
+		"21",
+		"Port to bind the FTP listener to."))
+
+	ftp.AddParam(session.NewStringParameter("ftp.honeypot.banner",
+		"(vsFTPd 3.0.3)",
+		"",
+		"Banner sent to the clients when they connect."))
+
+	ftp.AddParam(session.NewStringParameter("ftp.honeypot.upstream",
+		"",
+		"",
+		"If set to the HOST:PORT of the real FTP server, sessions are relayed to it after the credentials are captured so that they keep working, otherwise any login is accepted and uploads are captured."))
+
+	ftp.AddParam(session.NewStringParameter("ftp.honeypot.uploads",
+		"",
+		"",
+		"If set, uploaded files are saved to this folder."))
+
+	ftp.AddParam(session.NewIntParameter("ftp.honeypot.upload.size",
+		"65536",
+		"Maximum number of bytes of each uploaded file included in the ftp.honeypot.upload events."))
+
+	ftp.AddHandler(session.NewModuleHandler("ftp.honeypot on", "",
+		"Start the FTP listener.",
+		func(args []string) error {
+			return ftp.Start()
+		}))
+
+	ftp.AddHandler(session.NewModuleHandler("ftp.honeypot off", "",
+		"Stop the FTP listener.",
+		func(args []string) error {
+			return ftp.Stop()
+		}))
+
+	return ftp
+}
+
+func (f *FTPHoneypot) Name() string {
+	return "ftp.honeypot"
+}
+
+func (f *FTPHoneypot) Description() string {
+	return "An FTP listener capturing the credentials and the uploaded files, optionally relaying the sessions to the real server."
+}
+
+func (f *FTPHoneypot) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (f *FTPHoneypot) Configure() error {
+	var err error
+	var address string
+	var port int
+	var uploadSize int
+
+	if err, address = f.StringParam("ftp.honeypot.address"); err != nil {
+		return err
+	} else if err, port = f.IntParam("ftp.honeypot.port"); err != nil {
+		return err
+	} else if err, f.banner = f.StringParam("ftp.honeypot.banner"); err != nil {
+		return err
+	} else if err, f.upstream = f.StringParam("ftp.honeypot.upstream"); err != nil {
+		return err
+	} else if err, f.uploads = f.StringParam("ftp.honeypot.uploads"); err != nil {
+		return err
+	} else if err, uploadSize = f.IntParam("ftp.honeypot.upload.size"); err != nil {
+		return err
+	}
+
+	f.uploadSize = int64(uploadSize)
+
+	if f.upstream != "" {
+		if _, _, err := net.SplitHostPort(f.upstream); err != nil {
+			f.upstream = net.JoinHostPort(f.upstream, "21")
+		}
+	}
+
+	if f.uploads != "" {
+		if f.uploads, err = core.ExpandPath(f.uploads); err != nil {
+			return err
+		} else if err = os.MkdirAll(f.uploads, 0755); err != nil {
+			return err
+		}
+	}
+
+	if f.listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", address, port)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (f *FTPHoneypot) Start() error {
+	if f.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := f.Configure(); err != nil {
+		return err
+	}
+
+	f.SetRunning(true)
+
+	log.Info("FTP honeypot listening on %s", f.listener.Addr())
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+
+		for {
+			conn, err := f.listener.Accept()
+			if err != nil {
+				// closed by Stop
+				return
+			}
+
+			c := &ftpConn{
+				Conn:     conn,
+				reader:   bufio.NewReader(conn),
+				honeypot: f,
+				cwd:      "/",
+			}
+
+			go func() {
+				defer f.Session.Recover()
+				defer c.Close()
+				log.Debug("(%s) New connection from %s", core.Green("ftp"), conn.RemoteAddr())
+				c.serve()
+			}()
+		}
+	}()
+
+	return nil
+}
+
+func (f *FTPHoneypot) Stop() error {
+	if f.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	f.SetRunning(false)
+
+	f.listener.Close()
+	f.wg.Wait()
+
+	return nil
+}
+
+func (c *ftpConn) client() string {
+	host, _, _ := net.SplitHostPort(c.RemoteAddr().String())
+	return host
+}
+
+func (c *ftpConn) send(format string, args ...interface{}) error {
+	c.SetWriteDeadline(time.Now().Add(ftpIdleTimeout))
+	_, err := fmt.Fprintf(c.Conn, format+"\r\n", args...)
+	return err
+}
+
+func (c *ftpConn) readLine() (string, error) {
+	c.SetReadDeadline(time.Now().Add(ftpIdleTimeout))
+	line, isPrefix, err := c.reader.ReadLine()
+	if err != nil {
+		return "", err
+	} else if isPrefix == true {
+		return "", fmt.Errorf("Line too long.")
+	}
+	return string(line), nil
+}
+
+func (c *ftpConn) login(password string) {
+	destination := c.honeypot.upstream
+	if destination == "" {
+		destination = c.LocalAddr().String()
+	}
+
+	c.honeypot.Session.Creds.Add(&session.Credential{
+		Type:        session.CredCleartext,
+		Protocol:    "ftp",
+		Source:      c.client(),
+		Destination: destination,
+		Username:    c.username,
+		Password:    password,
+	})
+}
+
+func (c *ftpConn) serve() {
+	if c.send("220 %s", c.honeypot.banner) != nil {
+		return
+	}
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return
+		}
+
+		cmd, args := mailCommand(line)
+		if c.loggedIn == false {
+			switch cmd {
+			case "USER":
+				c.username = args
+				c.send("331 Please specify the password.")
+
+			case "PASS":
+				if c.username == "" {
+					c.send("503 Login with USER first.")
+					continue
+				}
+
+				c.login(args)
+
+				if c.honeypot.upstream != "" {
+					c.relay(args)
+					return
+				}
+
+				c.loggedIn = true
+				c.send("230 Login successful.")
+
+			case "AUTH":
+				// make clients fall back to cleartext
+				c.send("502 Command not implemented.")
+
+			case "FEAT":
+				c.feat()
+
+			case "SYST":
+				c.send("215 UNIX Type: L8")
+
+			case "QUIT":
+				c.send("221 Goodbye.")
+				return
+
+			default:
+				c.send("530 Please login with USER and PASS.")
+			}
+			continue
+		}
+
+		if c.command(cmd, args) == false {
+			return
+		}
+	}
+}
+
+func (c *ftpConn) feat() {
+	c.send("211-Features:")
+	c.send(" EPSV")
+	c.send(" PASV")
+	c.send(" UTF8")
+	c.send("211 End")
+}
+
+// Handles the commands after a successful login, returns
+// false once the connection must be closed.
+func (c *ftpConn) command(cmd string, args string) bool {
+	switch cmd {
+	case "SYST":
+		c.send("215 UNIX Type: L8")
+	case "FEAT":
+		c.feat()
+	case "PWD", "XPWD":
+		c.send("257 \"%s\" is the current directory", c.cwd)
+	case "CWD", "XCWD":
+		c.cwd = c.path(args)
+		c.send("250 Directory successfully changed.")
+	case "CDUP", "XCUP":
+		c.cwd = path.Dir(c.cwd)
+		c.send("250 Directory successfully changed.")
+	case "TYPE", "MODE", "STRU", "OPTS":
+		c.send("200 Command okay.")
+	case "NOOP":
+		c.send("200 NOOP ok.")
+	case "PASV":
+		c.pasv(false)
+	case "EPSV":
+		c.pasv(true)
+	case "PORT":
+		c.port(args)
+	case "LIST", "NLST", "MLSD":
+		c.list()
+	case "STOR", "APPE", "STOU":
+		c.stor(args)
+	case "RETR", "SIZE", "MDTM", "DELE", "RMD", "RNFR", "RNTO":
+		c.send("550 Failed to open file.")
+	case "MKD", "XMKD":
+		c.send("257 \"%s\" created", c.path(args))
+	case "QUIT":
+		c.send("221 Goodbye.")
+		return false
+	default:
+		c.send("502 Command not implemented.")
+	}
+	return true
+}
+
+func (c *ftpConn) path(name string) string {
+	if strings.HasPrefix(name, "/") == false {
+		name = path.Join(c.cwd, name)
+	}
+	return path.Clean("/" + name)
+}
+
+func (c *ftpConn) closeTransfer() {
+	if c.passive != nil {
+		c.passive.Close()
+		c.passive = nil
+	}
+	c.active = ""
+}
+
+func (c *ftpConn) pasv(extended bool) {
+	c.closeTransfer()
+
+	host, _, _ := net.SplitHostPort(c.LocalAddr().String())
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		c.send("425 Can't open passive connection.")
+		return
+	}
+	c.passive = listener
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	if extended == true {
+		c.send("229 Entering Extended Passive Mode (|||%d|)", port)
+	} else if ip := net.ParseIP(host).To4(); ip != nil {
+		c.send("227 Entering Passive Mode (%d,%d,%d,%d,%d,%d).", ip[0], ip[1], ip[2], ip[3], port>>8, port&0xff)
+	} else {
+		c.closeTransfer()
+		c.send("425 Use EPSV with IPv6.")
+	}
+}
+
+func (c *ftpConn) port(args string) {
+	c.closeTransfer()
+
+	parts := strings.Split(args, ",")
+	if len(parts) != 6 {
+		c.send("501 Illegal PORT command.")
+		return
+	}
+
+	nums := make([]int, 6)
+	for i, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || n < 0 || n > 255 {
+			c.send("501 Illegal PORT command.")
+			return
+		}
+		nums[i] = n
+	}
+
+	// only the address of the client, don't let it use us to scan others
+	ip := fmt.Sprintf("%d.%d.%d.%d", nums[0], nums[1], nums[2], nums[3])
+	if ip != c.client() {
+		c.send("500 Illegal PORT command.")
+		return
+	}
+
+	c.active = net.JoinHostPort(ip, strconv.Itoa(nums[4]<<8|nums[5]))
+	c.send("200 PORT command successful.")
+}
+
+func (c *ftpConn) dataConn() (net.Conn, error) {
+	defer c.closeTransfer()
+
+	if c.passive != nil {
+		c.passive.(*net.TCPListener).SetDeadline(time.Now().Add(30 * time.Second))
+		return c.passive.Accept()
+	} else if c.active != "" {
+		return net.DialTimeout("tcp", c.active, 30*time.Second)
+	}
+	return nil, fmt.Errorf("Use PORT or PASV first.")
+}
+
+func (c *ftpConn) list() {
+	if c.passive == nil && c.active == "" {
+		c.send("425 Use PORT or PASV first.")
+		return
+	}
+
+	c.send("150 Here comes the directory listing.")
+	if conn, err := c.dataConn(); err != nil {
+		c.send("425 Failed to establish connection.")
+	} else {
+		conn.Close()
+		c.send("226 Directory send OK.")
+	}
+}
+
+func (c *ftpConn) stor(name string) {
+	if c.passive == nil && c.active == "" {
+		c.send("425 Use PORT or PASV first.")
+		return
+	}
+
+	c.send("150 Ok to send data.")
+	conn, err := c.dataConn()
+	if err != nil {
+		c.send("425 Failed to establish connection.")
+		return
+	}
+	defer conn.Close()
+
+	upload := FTPUpload{
+		Client:   c.client(),
+		Username: c.username,
+		Filename: c.path(name),
+	}
+
+	var sink io.Writer = ioutil.Discard
+	if c.honeypot.uploads != "" {
+		filename := fmt.Sprintf("%s_%d_%s", upload.Client, time.Now().Unix(), path.Base(upload.Filename))
+		upload.Path = filepath.Join(c.honeypot.uploads, filename)
+		if fd, err := os.Create(upload.Path); err != nil {
+			log.Error("Could not save FTP upload to %s: %s", upload.Path, err)
+			upload.Path = ""
+		} else {
+			defer fd.Close()
+			sink = fd
+		}
+	}
+
+	head := &bytes.Buffer{}
+	conn.SetReadDeadline(time.Now().Add(ftpIdleTimeout))
+	upload.Size, err = io.Copy(io.MultiWriter(sink, &limitedWriter{head, c.honeypot.uploadSize}), conn)
+	upload.Data = head.Bytes()
+	upload.Truncated = upload.Size > int64(len(upload.Data))
+
+	if err != nil {
+		c.send("426 Failure reading network stream.")
+	} else {
+		c.send("226 Transfer complete.")
+	}
+
+	c.honeypot.Session.Events.Add("ftp.honeypot.upload", upload)
+	if upload.Path != "" {
+		artifactReady("ftp", upload.Path)
+	}
+}
+
+// Keeps the first bytes and discards the rest without failing.
+type limitedWriter struct {
+	buf *bytes.Buffer
+	max int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if left := w.max - int64(w.buf.Len()); left > 0 {
+		if int64(len(p)) > left {
+			w.buf.Write(p[:left])
+		} else {
+			w.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// Logs in to the real server with the same credentials and then
+// relays the control connection as it is, data connections go
+// straight to the server as it announces its own address.
+func (c *ftpConn) relay(password string) {
+	upstream, err := net.DialTimeout("tcp", c.honeypot.upstream, 10*time.Second)
+	if err != nil {
+		log.Warning("Could not connect to FTP server %s: %s", c.honeypot.upstream, err)
+		c.send("530 Login incorrect.")
+		return
+	}
+	defer upstream.Close()
+
+	reader := bufio.NewReader(upstream)
+	reply := func() (string, error) {
+		upstream.SetReadDeadline(time.Now().Add(10 * time.Second))
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return "", err
+			}
+			// multiline replies end with "CODE "
+			if len(line) >= 4 && line[3] == ' ' {
+				return line, nil
+			}
+		}
+	}
+
+	if _, err = reply(); err == nil {
+		fmt.Fprintf(upstream, "USER %s\r\n", c.username)
+		if _, err = reply(); err == nil {
+			fmt.Fprintf(upstream, "PASS %s\r\n", password)
+			var line string
+			if line, err = reply(); err == nil {
+				_, err = c.Conn.Write([]byte(line))
+			}
+		}
+	}
+
+	if err != nil {
+		log.Warning("Error while logging in to FTP server %s: %s", c.honeypot.upstream, err)
+		c.send("530 Login incorrect.")
+		return
+	}
+
+	upstream.SetDeadline(time.Time{})
+	c.SetDeadline(time.Time{})
+
+	done := make(chan bool, 2)
+	go func() {
+		io.Copy(upstream, c.reader)
+		done <- true
+	}()
+	go func() {
+		io.Copy(c.Conn, reader)
+		done <- true
+	}()
+	<-done
+}
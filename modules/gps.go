@@ -0,0 +1,275 @@
+package modules
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	gonet "net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+const gpsReconnectDelay = 2 * time.Second
+
+type GPS struct {
+	session.SessionModule
+	device   string
+	baudrate int
+	source   io.ReadCloser
+	hasFix   bool
+	lock     sync.Mutex
+	quit     chan bool
+	wg       sync.WaitGroup
+}
+
+func NewGPS(s *session.Session) *GPS {
+	gps := &GPS{
+		SessionModule: session.NewSessionModule("gps", s),
+	}
+
+	gps.AddParam(session.NewStringParameter("gps.device",
+		"/dev/ttyUSB0",
+		"",
+		"Serial device of the GPS receiver, or HOST:PORT of a gpsd instance, i.e. localhost:2947."))
+
+	gps.AddParam(session.NewIntParameter("gps.baudrate",
+		"4800",
+		"Baud rate of the serial device."))
+
+	gps.AddHandler(session.NewModuleHandler("gps on", "",
+		"Start reading the position from the GPS receiver.",
+		func(args []string) error {
+			return gps.Start()
+		}))
+
+	gps.AddHandler(session.NewModuleHandler("gps off", "",
+		"Stop reading the position from the GPS receiver.",
+		func(args []string) error {
+			return gps.Stop()
+		}))
+
+	gps.AddHandler(session.NewModuleHandler("gps.show", "",
+		"Show the current position.",
+		func(args []string) error {
+			return gps.Show()
+		}))
+
+	return gps
+}
+
+func (g *GPS) Name() string {
+	return "gps"
+}
+
+func (g *GPS) Description() string {
+	return "Reads the position from an NMEA serial receiver or from gpsd and tags the discovered BLE devices with it."
+}
+
+func (g *GPS) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (g *GPS) isGpsd() bool {
+	return strings.HasPrefix(g.device, "/") == false && strings.Contains(g.device, ":")
+}
+
+func (g *GPS) Configure() (err error) {
+	if err, g.device = g.StringParam("gps.device"); err != nil {
+		return err
+	} else if err, g.baudrate = g.IntParam("gps.baudrate"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (g *GPS) open() (io.ReadCloser, error) {
+	if g.isGpsd() == true {
+		conn, err := gonet.DialTimeout("tcp", g.device, 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+
+		// ask for the raw NMEA sentences instead of gpsd's JSON reports
+		if _, err = conn.Write([]byte("?WATCH={\"enable\":true,\"nmea\":true};\n")); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+
+	fd, err := os.Open(g.device)
+	if err != nil {
+		return nil, err
+	}
+
+	// the device is kept open so that the settings are not reset
+	if err = gpsSerialSetup(g.device, g.baudrate); err != nil {
+		fd.Close()
+		return nil, fmt.Errorf("Could not configure %s: %s", g.device, err)
+	}
+	return fd, nil
+}
+
+func (g *GPS) setFix(fix bool, pos net.GPSPosition) {
+	if fix == true {
+		g.Session.GPS.Set(pos)
+	}
+
+	if fix != g.hasFix {
+		g.hasFix = fix
+		if fix == true {
+			g.Session.Events.Add("gps.fix.acquired", pos)
+		} else {
+			g.Session.GPS.Clear()
+			g.Session.Events.Add("gps.fix.lost", pos)
+		}
+	}
+}
+
+func (g *GPS) read(source io.Reader) error {
+	pos := net.GPSPosition{}
+	reader := bufio.NewReader(source)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		} else if strings.HasPrefix(line, "$") == false {
+			// gpsd's own JSON reports
+			continue
+		}
+
+		handled, fix, err := parseNMEA(line, &pos)
+		if err != nil {
+			log.Debug("Error while parsing '%s': %s", strings.TrimSpace(line), err)
+		} else if handled == true {
+			pos.Updated = time.Now()
+			g.setFix(fix, pos)
+		}
+	}
+}
+
+// Returns false if the module is being stopped, in which case the
+// caller must close the source itself.
+func (g *GPS) setSource(source io.ReadCloser) bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	select {
+	case <-g.quit:
+		return false
+	default:
+	}
+
+	g.source = source
+	return true
+}
+
+// Reads from the source until it fails, then reopens it
+// until the module is stopped.
+func (g *GPS) worker(source io.ReadCloser) {
+	defer g.Session.Recover()
+	defer g.wg.Done()
+
+	for {
+		err := g.read(source)
+		source.Close()
+
+		select {
+		case <-g.quit:
+			return
+		default:
+		}
+
+		log.Warning("Error while reading from %s: %s", g.device, err)
+		g.setFix(false, net.GPSPosition{})
+
+		for source = nil; source == nil; {
+			select {
+			case <-g.quit:
+				return
+			case <-time.After(gpsReconnectDelay):
+			}
+
+			if source, err = g.open(); err != nil {
+				log.Debug("Could not open %s: %s", g.device, err)
+			} else if g.setSource(source) == false {
+				source.Close()
+				return
+			}
+		}
+	}
+}
+
+func (g *GPS) Show() error {
+	pos := g.Session.GPS.Position()
+	if pos == nil {
+		fmt.Println(core.Dim("No GPS fix."))
+		return nil
+	}
+
+	core.AsTable(os.Stdout, []string{"Latitude", "Longitude", "Altitude", "Satellites", "HDOP", "Updated"}, [][]string{
+		{
+			fmt.Sprintf("%.6f", pos.Latitude),
+			fmt.Sprintf("%.6f", pos.Longitude),
+			fmt.Sprintf("%.1f m", pos.Altitude),
+			fmt.Sprintf("%d", pos.Satellites),
+			fmt.Sprintf("%.1f", pos.HDOP),
+			pos.Updated.Format("15:04:05"),
+		},
+	})
+
+	fmt.Println()
+
+	return nil
+}
+
+func (g *GPS) Start() error {
+	if g.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := g.Configure(); err != nil {
+		return err
+	}
+
+	source, err := g.open()
+	if err != nil {
+		return err
+	}
+
+	g.SetRunning(true)
+	g.hasFix = false
+	g.quit = make(chan bool)
+	g.source = source
+
+	log.Info("Reading the position from %s ...", g.device)
+
+	g.wg.Add(1)
+	go g.worker(source)
+
+	return nil
+}
+
+func (g *GPS) Stop() error {
+	if g.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	g.SetRunning(false)
+
+	g.lock.Lock()
+	close(g.quit)
+	g.source.Close()
+	g.lock.Unlock()
+
+	g.wg.Wait()
+
+	g.Session.GPS.Clear()
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package modules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/net"
+)
+
+// Validates the checksum of an NMEA sentence and returns its fields,
+// the first one being the sentence type without the talker id, i.e.
+// GGA for both $GPGGA and $GNGGA.
+func nmeaFields(line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if len(line) < 7 || line[0] != '$' {
+		return nil, fmt.Errorf("'%s' is not an NMEA sentence.", line)
+	}
+
+	body := line[1:]
+	if star := strings.LastIndexByte(body, '*'); star != -1 {
+		expected, err := strconv.ParseUint(body[star+1:], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid NMEA checksum '%s'.", body[star+1:])
+		}
+
+		body = body[:star]
+		sum := byte(0)
+		for i := 0; i < len(body); i++ {
+			sum ^= body[i]
+		}
+
+		if sum != byte(expected) {
+			return nil, fmt.Errorf("NMEA checksum mismatch, expected %02X got %02X.", expected, sum)
+		}
+	}
+
+	fields := strings.Split(body, ",")
+	if len(fields[0]) < 3 {
+		return nil, fmt.Errorf("'%s' is not an NMEA sentence.", line)
+	}
+	fields[0] = fields[0][len(fields[0])-3:]
+
+	return fields, nil
+}
+
+// Converts a [d]ddmm.mmmm coordinate and its hemisphere to degrees.
+func nmeaCoordinate(value string, hemisphere string) (float64, error) {
+	raw, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	degrees := float64(int(raw / 100))
+	degrees += (raw - degrees*100) / 60
+
+	if hemisphere == "S" || hemisphere == "W" {
+		degrees = -degrees
+	}
+	return degrees, nil
+}
+
+// Updates the position with a GGA or RMC sentence, returns false if
+// the sentence is of another type, true and whether the receiver has
+// a fix otherwise.
+func parseNMEA(line string, pos *net.GPSPosition) (handled bool, fix bool, err error) {
+	fields, err := nmeaFields(line)
+	if err != nil {
+		return false, false, err
+	}
+
+	var lat, lon float64
+
+	switch fields[0] {
+	case "GGA":
+		// GGA,time,lat,N,lon,E,quality,satellites,hdop,altitude,M,...
+		if len(fields) < 10 {
+			return true, false, fmt.Errorf("Truncated GGA sentence.")
+		} else if fields[6] == "" || fields[6] == "0" {
+			return true, false, nil
+		}
+
+		if lat, err = nmeaCoordinate(fields[2], fields[3]); err != nil {
+			return true, false, err
+		} else if lon, err = nmeaCoordinate(fields[4], fields[5]); err != nil {
+			return true, false, err
+		}
+
+		pos.Latitude, pos.Longitude = lat, lon
+		// these are optional
+		pos.Satellites, _ = strconv.Atoi(fields[7])
+		pos.HDOP, _ = strconv.ParseFloat(fields[8], 64)
+		pos.Altitude, _ = strconv.ParseFloat(fields[9], 64)
+
+		return true, true, nil
+
+	case "RMC":
+		// RMC,time,status,lat,N,lon,E,...
+		if len(fields) < 7 {
+			return true, false, fmt.Errorf("Truncated RMC sentence.")
+		} else if fields[2] != "A" {
+			return true, false, nil
+		}
+
+		if lat, err = nmeaCoordinate(fields[3], fields[4]); err != nil {
+			return true, false, err
+		} else if lon, err = nmeaCoordinate(fields[5], fields[6]); err != nil {
+			return true, false, err
+		}
+
+		pos.Latitude, pos.Longitude = lat, lon
+
+		return true, true, nil
+	}
+
+	return false, false, nil
+}
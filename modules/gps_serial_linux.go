@@ -0,0 +1,16 @@
+//go:build linux
+// +build linux
+
+package modules
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// Sets the serial port speed and disables any line processing.
+func gpsSerialSetup(device string, baudrate int) error {
+	_, err := core.Exec("stty", []string{"-F", device, fmt.Sprintf("%d", baudrate), "raw", "-echo"})
+	return err
+}
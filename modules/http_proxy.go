@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"sync/atomic"
+
 	"github.com/evilsocket/bettercap-ng/session"
 )
 
@@ -12,7 +14,6 @@ type HttpProxy struct {
 func NewHttpProxy(s *session.Session) *HttpProxy {
 	p := &HttpProxy{
 		SessionModule: session.NewSessionModule("http.proxy", s),
-		proxy:         NewHTTPProxy(s),
 	}
 
 	p.AddParam(session.NewIntParameter("http.port",
@@ -28,6 +29,38 @@ func NewHttpProxy(s *session.Session) *HttpProxy {
 		"8080",
 		"Port to bind the HTTP proxy to."))
 
+	p.AddParam(session.NewIntParameter("http.proxy.connections",
+		"512",
+		"Maximum number of connections handled at the same time by the HTTP proxy, new ones wait until a slot is free, 0 for no limit."))
+
+	p.AddParam(session.NewIntParameter("http.proxy.timeout",
+		"60",
+		"Seconds after which idle HTTP proxy connections are closed, 0 to disable."))
+
+	p.AddParam(session.NewStringParameter("http.proxy.dns",
+		"",
+		"",
+		"DNS server, as IP or IP:PORT, used to resolve the hosts the HTTP proxy connects to, empty for the system resolver."))
+
+	p.AddParam(session.NewStringParameter("http.proxy.dns.overrides",
+		"",
+		"",
+		"Comma separated list of HOSTNAME=IP entries the HTTP proxy connects to without resolving them."))
+
+	p.AddParam(session.NewStringParameter("http.proxy.fuzz",
+		"0",
+		`^(0(\.\d+)?|1(\.0+)?)$`,
+		"Share of the responses to mutate, from 0 to 1, to test the robustness of the clients parsers."))
+
+	p.AddParam(session.NewStringParameter("http.proxy.fuzz.fields",
+		"headers, length, body",
+		"",
+		"Comma separated list of the parts of the responses to mutate, among headers, length and body."))
+
+	p.AddParam(session.NewIntParameter("http.proxy.fuzz.bytes",
+		"8",
+		"Maximum number of bytes of each fuzzed body to mutate."))
+
 	p.AddParam(session.NewStringParameter("http.proxy.script",
 		"",
 		"",
@@ -61,11 +94,23 @@ func (p *HttpProxy) Author() string {
 }
 
 func (p *HttpProxy) Configure() error {
+	// built on first use, most sessions never start a proxy
+	if p.proxy == nil {
+		p.proxy = NewHTTPProxy(p.Session)
+	}
+
 	var err error
 	var address string
 	var proxyPort int
 	var httpPort int
 	var scriptPath string
+	var maxConnections int
+	var idleTimeout int
+	var dnsServer string
+	var dnsOverrides string
+	var fuzzRate string
+	var fuzzFields []string
+	var fuzzMaxBytes int
 
 	if err, address = p.StringParam("http.proxy.address"); err != nil {
 		return err
@@ -83,6 +128,34 @@ func (p *HttpProxy) Configure() error {
 		return err
 	}
 
+	if err, maxConnections = p.IntParam("http.proxy.connections"); err != nil {
+		return err
+	} else if err, idleTimeout = p.IntParam("http.proxy.timeout"); err != nil {
+		return err
+	}
+
+	p.proxy.SetLimits(maxConnections, idleTimeout)
+
+	if err, dnsServer = p.StringParam("http.proxy.dns"); err != nil {
+		return err
+	} else if err, dnsOverrides = p.StringParam("http.proxy.dns.overrides"); err != nil {
+		return err
+	} else if overrides, err := parseDNSOverrides(dnsOverrides); err != nil {
+		return err
+	} else {
+		p.proxy.Resolver.Configure(dnsServer, overrides)
+	}
+
+	if err, fuzzRate = p.StringParam("http.proxy.fuzz"); err != nil {
+		return err
+	} else if err, fuzzFields = p.ListParam("http.proxy.fuzz.fields"); err != nil {
+		return err
+	} else if err, fuzzMaxBytes = p.IntParam("http.proxy.fuzz.bytes"); err != nil {
+		return err
+	} else if err = p.proxy.Fuzzer.Configure(fuzzRate, fuzzFields, fuzzMaxBytes); err != nil {
+		return err
+	}
+
 	return p.proxy.Configure(address, proxyPort, httpPort, scriptPath)
 }
 
@@ -107,3 +180,20 @@ func (p *HttpProxy) Stop() error {
 
 	return p.proxy.Stop()
 }
+
+func (p *HttpProxy) Metrics() []session.Metric {
+	if p.proxy == nil {
+		return nil
+	}
+
+	return []session.Metric{
+		session.NewMetric("proxy_requests_total", "Requests handled by the proxy.", session.MetricCounter,
+			float64(atomic.LoadUint64(&p.proxy.Requests)), "proxy", p.Name()),
+		session.NewMetric("proxy_connections", "Connections currently handled by the proxy.", session.MetricGauge,
+			float64(atomic.LoadInt64(&p.proxy.Active)), "proxy", p.Name()),
+		session.NewMetric("proxy_connections_throttled_total", "Connections which had to wait for a free slot.", session.MetricCounter,
+			float64(atomic.LoadUint64(&p.proxy.Throttled)), "proxy", p.Name()),
+		session.NewMetric("proxy_fuzzed_total", "Responses mutated by the fuzzer.", session.MetricCounter,
+			float64(atomic.LoadUint64(&p.proxy.Fuzzer.Fuzzed)), "proxy", p.Name()),
+	}
+}
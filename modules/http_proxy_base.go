@@ -13,32 +13,47 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/evilsocket/bettercap-ng/core"
 	"github.com/evilsocket/bettercap-ng/firewall"
 	"github.com/evilsocket/bettercap-ng/log"
 	"github.com/evilsocket/bettercap-ng/session"
-	btls "github.com/evilsocket/bettercap-ng/tls"
 
 	"github.com/elazarl/goproxy"
 	"github.com/inconshreveable/go-vhost"
 )
 
 type HTTPProxy struct {
+	// first fields to be 64-bit aligned for atomic operations on 32-bit platforms
+	Requests  uint64
+	Throttled uint64
+	Active    int64
+
 	Name        string
 	Address     string
+	Address6    string
 	Server      http.Server
 	Redirection *firewall.Redirection
 	Proxy       *goproxy.ProxyHttpServer
+	Resolver    *proxyResolver
+	Fuzzer      *proxyFuzzer
 	Script      *ProxyScript
 	CertFile    string
 	KeyFile     string
-
-	isTLS       bool
-	isRunning   bool
-	sniListener net.Listener
-	sess        *session.Session
+	CA          *tls.Certificate
+
+	MaxConnections int
+	IdleTimeout    time.Duration
+	SessionTickets bool
+
+	slots        chan bool
+	isTLS        bool
+	isRunning    bool
+	sniListener  net.Listener
+	sniListener6 net.Listener
+	sess         *session.Session
 }
 
 func stripPort(s string) string {
@@ -49,14 +64,38 @@ func stripPort(s string) string {
 	return s[:ix]
 }
 
+// Keeps connections to the origins alive and resumes their TLS sessions, so
+// that repeated requests don't pay a new handshake each time.
+func upstreamTransport(resolver *proxyResolver) *http.Transport {
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           resolver.DialContext,
+		MaxIdleConns:          1024,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			ClientSessionCache: tls.NewLRUClientSessionCache(1024),
+		},
+	}
+}
+
 func NewHTTPProxy(s *session.Session) *HTTPProxy {
 	p := &HTTPProxy{
-		Name:  "http.proxy",
-		Proxy: goproxy.NewProxyHttpServer(),
-		sess:  s,
-		isTLS: false,
+		Name:     "http.proxy",
+		Proxy:    goproxy.NewProxyHttpServer(),
+		Resolver: newProxyResolver(),
+		Fuzzer:   newProxyFuzzer(),
+		sess:     s,
+		isTLS:    false,
+
+		SessionTickets: true,
 	}
 
+	p.Proxy.Tr = upstreamTransport(p.Resolver)
+
 	p.Proxy.NonproxyHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		if p.doProxy(req) == true {
 			req.URL.Host = req.Host
@@ -66,6 +105,7 @@ func NewHTTPProxy(s *session.Session) *HTTPProxy {
 
 	p.Proxy.OnRequest().HandleConnect(goproxy.AlwaysMitm)
 	p.Proxy.OnRequest().DoFunc(func(req *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+		atomic.AddUint64(&p.Requests, 1)
 		log.Debug("(%s) < %s %s %s%s", core.Green(p.Name), req.RemoteAddr, req.Method, req.Host, req.URL.Path)
 		if p.Script != nil {
 			jsres := p.Script.OnRequest(req)
@@ -84,9 +124,12 @@ func NewHTTPProxy(s *session.Session) *HTTPProxy {
 			jsres := p.Script.OnResponse(res)
 			if jsres != nil {
 				p.logAction(res.Request, jsres)
-				return jsres.ToResponse(res.Request)
+				res = jsres.ToResponse(res.Request)
 			}
 		}
+		if p.Fuzzer.Enabled() == true {
+			p.fuzz(req, res)
+		}
 		return res
 	})
 
@@ -109,6 +152,28 @@ func (p *HTTPProxy) logAction(req *http.Request, jsres *JSResponse) {
 	})
 }
 
+func (p *HTTPProxy) fuzz(req *http.Request, res *http.Response) {
+	mutations := p.Fuzzer.Fuzz(res)
+	if len(mutations) == 0 {
+		return
+	}
+
+	log.Debug("(%s) fuzzed %s%s: %s", core.Green(p.Name), req.Host, req.URL.Path, strings.Join(mutations, ", "))
+	p.sess.Events.Add(p.Name+".fuzzed", struct {
+		To        string
+		Method    string
+		Host      string
+		Path      string
+		Mutations []string
+	}{
+		strings.Split(req.RemoteAddr, ":")[0],
+		req.Method,
+		req.Host,
+		req.URL.Path,
+		mutations,
+	})
+}
+
 func (p *HTTPProxy) doProxy(req *http.Request) bool {
 	blacklist := []string{
 		"localhost",
@@ -144,8 +209,9 @@ func (p *HTTPProxy) Configure(address string, proxyPort int, httpPort int, scrip
 	}
 
 	p.Server = http.Server{
-		Addr:    fmt.Sprintf("%s:%d", p.Address, proxyPort),
-		Handler: p.Proxy,
+		Addr:        fmt.Sprintf("%s:%d", p.Address, proxyPort),
+		Handler:     p.Proxy,
+		IdleTimeout: p.IdleTimeout,
 	}
 
 	if p.sess.Firewall.IsForwardingEnabled() == false {
@@ -159,6 +225,13 @@ func (p *HTTPProxy) Configure(address string, proxyPort int, httpPort int, scrip
 		p.Address,
 		proxyPort)
 
+	// capture IPv6 traffic too if we have a routable address to redirect it to
+	p.Address6 = ""
+	if ip6 := p.sess.Interface.IPv6; ip6 != nil && ip6.IsGlobalUnicast() == true && p.Address == p.sess.Interface.IpAddress {
+		p.Address6 = p.sess.Interface.Ip6Address
+		p.Redirection.DstAddress6 = p.Address6
+	}
+
 	if err := p.sess.Firewall.EnableRedirection(p.Redirection, true); err != nil {
 		return err
 	}
@@ -168,7 +241,11 @@ func (p *HTTPProxy) Configure(address string, proxyPort int, httpPort int, scrip
 	return nil
 }
 
-func TLSConfigFromCA(ca *tls.Certificate) func(host string, ctx *goproxy.ProxyCtx) (*tls.Config, error) {
+// Builds the configuration of the client facing handshakes, spoofed
+// certificates are signed without OCSP staples so clients never get one.
+// If tickets is false no session tickets are issued and every connection
+// goes through a full handshake.
+func TLSConfigFromCA(ca *tls.Certificate, tickets bool) func(host string, ctx *goproxy.ProxyCtx) (*tls.Config, error) {
 	return func(host string, ctx *goproxy.ProxyCtx) (c *tls.Config, err error) {
 		parts := strings.SplitN(host, ":", 2)
 		hostname := parts[0]
@@ -180,21 +257,16 @@ func TLSConfigFromCA(ca *tls.Certificate) func(host string, ctx *goproxy.ProxyCt
 			}
 		}
 
-		cert := getCachedCert(hostname, port)
-		if cert == nil {
-			log.Info("Creating spoofed certificate for %s:%d", core.Yellow(hostname), port)
-			cert, err = btls.SignCertificateForHost(ca, hostname, port)
-			if err != nil {
-				log.Warning("Cannot sign host certificate with provided CA: %s", err)
-				return nil, err
-			}
-
-			setCachedCert(hostname, port, cert)
+		cert, err := getOrSignCert(ca, hostname, port)
+		if err != nil {
+			log.Warning("Cannot sign host certificate with provided CA: %s", err)
+			return nil, err
 		}
 
 		config := tls.Config{
-			InsecureSkipVerify: true,
-			Certificates:       []tls.Certificate{*cert},
+			InsecureSkipVerify:     true,
+			Certificates:           []tls.Certificate{*cert},
+			SessionTicketsDisabled: tickets == false,
 		}
 
 		return &config, nil
@@ -224,18 +296,38 @@ func (p *HTTPProxy) ConfigureTLS(address string, proxyPort int, httpPort int, sc
 		return err
 	}
 
+	p.CA = &ourCa
+
 	goproxy.GoproxyCa = ourCa
-	goproxy.OkConnect = &goproxy.ConnectAction{Action: goproxy.ConnectAccept, TLSConfig: TLSConfigFromCA(&ourCa)}
-	goproxy.MitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: TLSConfigFromCA(&ourCa)}
-	goproxy.HTTPMitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectHTTPMitm, TLSConfig: TLSConfigFromCA(&ourCa)}
-	goproxy.RejectConnect = &goproxy.ConnectAction{Action: goproxy.ConnectReject, TLSConfig: TLSConfigFromCA(&ourCa)}
+	goproxy.OkConnect = &goproxy.ConnectAction{Action: goproxy.ConnectAccept, TLSConfig: TLSConfigFromCA(&ourCa, p.SessionTickets)}
+	goproxy.MitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: TLSConfigFromCA(&ourCa, p.SessionTickets)}
+	goproxy.HTTPMitmConnect = &goproxy.ConnectAction{Action: goproxy.ConnectHTTPMitm, TLSConfig: TLSConfigFromCA(&ourCa, p.SessionTickets)}
+	goproxy.RejectConnect = &goproxy.ConnectAction{Action: goproxy.ConnectReject, TLSConfig: TLSConfigFromCA(&ourCa, p.SessionTickets)}
 
 	return nil
 }
 
+func (p *HTTPProxy) address6() string {
+	return fmt.Sprintf("[%s]:%d", p.Address6, p.Redirection.DstPort)
+}
+
 func (p *HTTPProxy) httpWorker() error {
+	listener, err := net.Listen("tcp", p.Server.Addr)
+	if err != nil {
+		return err
+	}
+
 	p.isRunning = true
-	return p.Server.ListenAndServe()
+
+	if p.Address6 != "" {
+		if listener6, err := net.Listen("tcp", p.address6()); err != nil {
+			log.Warning("Could not listen on %s: %s", p.address6(), err)
+		} else {
+			go p.Server.Serve(p.limit(listener6))
+		}
+	}
+
+	return p.Server.Serve(p.limit(listener))
 }
 
 type dumbResponseWriter struct {
@@ -265,29 +357,50 @@ func (p *HTTPProxy) httpsWorker() error {
 	var err error
 
 	// listen to the TLS ClientHello but make it a CONNECT request instead
-	p.sniListener, err = net.Listen("tcp", p.Server.Addr)
+	listener, err := net.Listen("tcp", p.Server.Addr)
 	if err != nil {
 		return err
 	}
+	p.sniListener = p.limit(listener)
 
 	p.isRunning = true
+
+	if p.Address6 != "" {
+		if listener6, err := net.Listen("tcp", p.address6()); err != nil {
+			log.Warning("Could not listen on %s: %s", p.address6(), err)
+		} else {
+			p.sniListener6 = p.limit(listener6)
+			go p.sniWorker(p.sniListener6)
+		}
+	}
+
+	p.sniWorker(p.sniListener)
+
+	return nil
+}
+
+func (p *HTTPProxy) sniWorker(listener net.Listener) {
+	defer session.I.Recover()
 	for p.isRunning {
-		c, err := p.sniListener.Accept()
+		c, err := listener.Accept()
 		if err != nil {
 			log.Warning("Error accepting connection: %s.", err)
 			continue
 		}
 
 		go func(c net.Conn) {
+			defer session.I.Recover()
 			tlsConn, err := vhost.TLS(c)
 			if err != nil {
 				log.Warning("Error reading SNI: %s.", err)
+				c.Close()
 				return
 			}
 
 			hostname := tlsConn.Host()
 			if hostname == "" {
 				log.Warning("Client does not support SNI.")
+				tlsConn.Close()
 				return
 			}
 
@@ -306,8 +419,6 @@ func (p *HTTPProxy) httpsWorker() error {
 			p.Proxy.ServeHTTP(resp, req)
 		}(c)
 	}
-
-	return nil
 }
 
 func (p *HTTPProxy) Start() {
@@ -327,6 +438,8 @@ func (p *HTTPProxy) Start() {
 }
 
 func (p *HTTPProxy) Stop() error {
+	defer p.Proxy.Tr.CloseIdleConnections()
+
 	if p.Redirection != nil {
 		log.Debug("Disabling redirection %s", p.Redirection.String())
 		if err := p.sess.Firewall.EnableRedirection(p.Redirection, false); err != nil {
@@ -338,6 +451,10 @@ func (p *HTTPProxy) Stop() error {
 	if p.isTLS == true {
 		p.isRunning = false
 		p.sniListener.Close()
+		if p.sniListener6 != nil {
+			p.sniListener6.Close()
+			p.sniListener6 = nil
+		}
 		return nil
 	} else {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
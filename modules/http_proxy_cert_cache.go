@@ -3,31 +3,106 @@ package modules
 import (
 	"crypto/tls"
 	"fmt"
+	"runtime"
 	"sync"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	btls "github.com/evilsocket/bettercap-ng/tls"
 )
 
+type certJob struct {
+	ca     *tls.Certificate
+	domain string
+	port   int
+}
+
 var (
-	certCache = make(map[string]*tls.Certificate)
-	certLock  = &sync.Mutex{}
+	certCache  = make(map[string]*tls.Certificate)
+	certLock   = &sync.Mutex{}
+	certHits   = uint64(0)
+	certMisses = uint64(0)
+	// closed once the certificate being signed for the key is ready
+	certPending = make(map[string]chan bool)
+	certJobs    = make(chan certJob, 256)
+	certWorkers = &sync.Once{}
 )
 
-func getCachedCert(domain string, port int) *tls.Certificate {
+// Returns the cached certificate or signs a new one. Handshakes for a
+// host whose certificate is being signed wait for it instead of signing
+// it again, while the ones for other hosts are not blocked.
+func getOrSignCert(ca *tls.Certificate, domain string, port int) (*tls.Certificate, error) {
 	key := fmt.Sprintf("%s:%d", domain, port)
 
-	certLock.Lock()
-	defer certLock.Unlock()
+	for {
+		certLock.Lock()
+		if cert, found := certCache[key]; found == true {
+			certHits++
+			certLock.Unlock()
+			return cert, nil
+		} else if pending, found := certPending[key]; found == true {
+			certLock.Unlock()
+			// if signing failed, we'll try ourselves
+			<-pending
+			continue
+		}
+
+		certMisses++
+		done := make(chan bool)
+		certPending[key] = done
+		certLock.Unlock()
+
+		log.Info("Creating spoofed certificate for %s:%d", core.Yellow(domain), port)
+		cert, err := btls.SignCertificateForHost(ca, domain, port)
+
+		certLock.Lock()
+		delete(certPending, key)
+		if err == nil {
+			certCache[key] = cert
+		}
+		certLock.Unlock()
+		close(done)
+
+		return cert, err
+	}
+}
 
-	if cert, found := certCache[key]; found == true {
-		return cert
+func certWorker() {
+	for job := range certJobs {
+		if _, err := getOrSignCert(job.ca, job.domain, job.port); err != nil {
+			log.Debug("Could not pre-generate certificate for %s:%d: %s", job.domain, job.port, err)
+		}
 	}
-	return nil
 }
 
-func setCachedCert(domain string, port int, cert *tls.Certificate) {
+// Schedules the certificate to be signed in background, before any
+// client asks for it. If the queue is full the handshake will sign it.
+func prewarmCert(ca *tls.Certificate, domain string, port int) {
 	key := fmt.Sprintf("%s:%d", domain, port)
 
 	certLock.Lock()
-	defer certLock.Unlock()
+	_, cached := certCache[key]
+	_, pending := certPending[key]
+	certLock.Unlock()
+
+	if cached == true || pending == true {
+		return
+	}
+
+	certWorkers.Do(func() {
+		for i := 0; i < runtime.NumCPU(); i++ {
+			go certWorker()
+		}
+	})
 
-	certCache[key] = cert
+	select {
+	case certJobs <- certJob{ca, domain, port}:
+	default:
+	}
+}
+
+func certCacheStats() (hits uint64, misses uint64) {
+	certLock.Lock()
+	defer certLock.Unlock()
+	return certHits, certMisses
 }
@@ -0,0 +1,223 @@
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Parts of the responses the fuzzer can mutate.
+const (
+	FuzzHeaders = "headers"
+	FuzzLength  = "length"
+	FuzzBody    = "body"
+)
+
+// Bodies bigger than this are passed through without being mutated.
+const fuzzMaxBody = 16 * 1024 * 1024
+
+// Mutates a share of the responses going through the proxy, so that
+// the parsers of the clients can be tested against malformed data.
+type proxyFuzzer struct {
+	// first field to be 64-bit aligned for atomic operations on 32-bit platforms
+	Fuzzed uint64
+
+	sync.Mutex
+
+	Rate   float64
+	Fields map[string]bool
+	// at most this many bytes of each fuzzed body are mutated
+	Bytes int
+
+	rng *rand.Rand
+}
+
+func newProxyFuzzer() *proxyFuzzer {
+	return &proxyFuzzer{
+		Fields: make(map[string]bool),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (f *proxyFuzzer) Configure(rate string, fields []string, maxBytes int) error {
+	f.Lock()
+	defer f.Unlock()
+
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 || r > 1 {
+		return fmt.Errorf("Invalid fuzzing rate '%s', expected a number between 0 and 1.", rate)
+	} else if maxBytes < 1 {
+		return fmt.Errorf("The number of bytes to fuzz must be positive.")
+	}
+
+	f.Rate = r
+	f.Bytes = maxBytes
+	f.Fields = make(map[string]bool)
+	for _, field := range fields {
+		switch field {
+		case FuzzHeaders, FuzzLength, FuzzBody:
+			f.Fields[field] = true
+		default:
+			return fmt.Errorf("Unknown field '%s', expected %s, %s or %s.", field, FuzzHeaders, FuzzLength, FuzzBody)
+		}
+	}
+
+	return nil
+}
+
+func (f *proxyFuzzer) Enabled() bool {
+	f.Lock()
+	defer f.Unlock()
+	return f.Rate > 0 && len(f.Fields) > 0
+}
+
+func intn(rng *rand.Rand, n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return rng.Intn(n)
+}
+
+// Returns a broken version of a header value.
+func fuzzValue(rng *rand.Rand, value string) string {
+	switch intn(rng, 6) {
+	case 0:
+		return ""
+	case 1:
+		return value[:intn(rng, len(value))]
+	case 2:
+		return strings.Repeat(value+"A", 1+intn(rng, 8192/(len(value)+1)))
+	case 3:
+		// control and non ASCII bytes, CR and LF would be replaced by the server
+		junk := []byte{0x00, 0x01, 0x7f, 0x80, 0xff, '"', ';', ',', '\t'}
+		at := intn(rng, len(value)+1)
+		return value[:at] + string(junk[intn(rng, len(junk))]) + value[at:]
+	case 4:
+		limits := []string{"-1", "0", "2147483648", "4294967296", "18446744073709551616"}
+		return limits[intn(rng, len(limits))]
+	}
+	return value + value
+}
+
+func fuzzHeaders(rng *rand.Rand, res *http.Response) string {
+	names := make([]string, 0, len(res.Header))
+	for name := range res.Header {
+		// the length has its own mutation
+		if name != "Content-Length" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+
+	name := names[intn(rng, len(names))]
+	switch intn(rng, 3) {
+	case 0:
+		res.Header.Del(name)
+		return fmt.Sprintf("header %s removed", name)
+	case 1:
+		res.Header.Add(name, fuzzValue(rng, res.Header.Get(name)))
+		return fmt.Sprintf("header %s duplicated", name)
+	}
+	res.Header.Set(name, fuzzValue(rng, res.Header.Get(name)))
+	return fmt.Sprintf("header %s changed", name)
+}
+
+// Flips bits or replaces up to max random bytes of data, returns how many.
+func fuzzBytes(rng *rand.Rand, data []byte, max int) int {
+	if len(data) == 0 || max < 1 {
+		return 0
+	}
+
+	n := 1 + rng.Intn(max)
+	for i := 0; i < n; i++ {
+		at := rng.Intn(len(data))
+		if rng.Intn(2) == 0 {
+			data[at] ^= 1 << uint(rng.Intn(8))
+		} else {
+			data[at] = byte(rng.Intn(256))
+		}
+	}
+	return n
+}
+
+// Declares a length which doesn't match the one of the body.
+func fuzzLength(rng *rand.Rand, res *http.Response, size int64) string {
+	lengths := []int64{0, size - 1, size + 1, size * 2, int64(intn(rng, int(size)*2+1024))}
+	length := lengths[intn(rng, len(lengths))]
+	if length < 0 || length == size {
+		length = size + 1
+	}
+
+	res.ContentLength = length
+	res.Header.Set("Content-Length", strconv.FormatInt(length, 10))
+	return fmt.Sprintf("length %d instead of %d", length, size)
+}
+
+// Mutates the response if it's selected by the rate, returns the
+// description of the mutations applied, if any.
+func (f *proxyFuzzer) Fuzz(res *http.Response) []string {
+	f.Lock()
+	if f.rng.Float64() >= f.Rate {
+		f.Unlock()
+		return nil
+	}
+	// the body is read without holding the lock
+	rng := rand.New(rand.NewSource(f.rng.Int63()))
+	maxBytes := f.Bytes
+	headers := f.Fields[FuzzHeaders]
+	length := f.Fields[FuzzLength]
+	body := f.Fields[FuzzBody]
+	f.Unlock()
+
+	mutations := make([]string, 0)
+	if headers == true {
+		if m := fuzzHeaders(rng, res); m != "" {
+			mutations = append(mutations, m)
+		}
+	}
+
+	if (body == true || length == true) && res.Body != nil {
+		data, err := ioutil.ReadAll(io.LimitReader(res.Body, fuzzMaxBody+1))
+		if err != nil || len(data) > fuzzMaxBody {
+			// too big or broken, send what was read and the rest as it is
+			res.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(data), res.Body), res.Body}
+		} else {
+			res.Body.Close()
+			res.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+			// empty bodies, as the ones of HEAD requests, keep their length
+			if len(data) > 0 {
+				if body == true {
+					mutations = append(mutations, fmt.Sprintf("%d body bytes", fuzzBytes(rng, data, maxBytes)))
+				}
+
+				res.ContentLength = int64(len(data))
+				res.Header.Set("Content-Length", strconv.Itoa(len(data)))
+
+				if length == true {
+					mutations = append(mutations, fuzzLength(rng, res, int64(len(data))))
+				}
+			}
+		}
+	}
+
+	if len(mutations) > 0 {
+		atomic.AddUint64(&f.Fuzzed, 1)
+	}
+	return mutations
+}
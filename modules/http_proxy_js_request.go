@@ -2,10 +2,11 @@ package modules
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
 )
 
 type JSHeader struct {
@@ -55,12 +56,14 @@ func NewJSRequest(req *http.Request) JSRequest {
 }
 
 func (j *JSRequest) ReadBody() string {
-	raw, err := ioutil.ReadAll(j.req.Body)
-	if err != nil {
+	buf := core.GetBuffer()
+	defer core.PutBuffer(buf)
+
+	if _, err := buf.ReadFrom(j.req.Body); err != nil {
 		return ""
 	}
 
-	j.Body = string(raw)
+	j.Body = buf.String()
 
 	return j.Body
 }
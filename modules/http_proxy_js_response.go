@@ -1,10 +1,12 @@
 package modules
 
 import (
-	"io/ioutil"
+	"bytes"
 	"net/http"
 	"strings"
 
+	"github.com/evilsocket/bettercap-ng/core"
+
 	"github.com/elazarl/goproxy"
 )
 
@@ -43,8 +45,29 @@ func (j *JSResponse) Updated() {
 	j.wasUpdated = true
 }
 
+// Returns the buffer to the pool once the proxy is done sending it.
+type pooledBody struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func (b *pooledBody) Close() error {
+	// pooling it twice would hand the same buffer to two users
+	if b.closed == false {
+		b.closed = true
+		core.PutBuffer(b.Buffer)
+	}
+	return nil
+}
+
 func (j *JSResponse) ToResponse(req *http.Request) (resp *http.Response) {
-	resp = goproxy.NewResponse(req, j.ContentType, j.Status, j.Body)
+	resp = goproxy.NewResponse(req, j.ContentType, j.Status, "")
+
+	buf := core.GetBuffer()
+	buf.WriteString(j.Body)
+	resp.ContentLength = int64(buf.Len())
+	resp.Body = &pooledBody{buf, false}
+
 	if j.Headers != "" {
 		for _, header := range strings.Split(j.Headers, "\n") {
 			header = strings.Trim(header, "\n\r\t ")
@@ -63,12 +86,14 @@ func (j *JSResponse) ToResponse(req *http.Request) (resp *http.Response) {
 func (j *JSResponse) ReadBody() string {
 	defer j.resp.Body.Close()
 
-	raw, err := ioutil.ReadAll(j.resp.Body)
-	if err != nil {
+	buf := core.GetBuffer()
+	defer core.PutBuffer(buf)
+
+	if _, err := buf.ReadFrom(j.resp.Body); err != nil {
 		return ""
 	}
 
-	j.Body = string(raw)
+	j.Body = buf.String()
 
 	return j.Body
 }
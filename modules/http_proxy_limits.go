@@ -0,0 +1,123 @@
+package modules
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errListenerClosed = errors.New("Proxy listener closed.")
+
+// Wraps the proxy listeners so that at most MaxConnections are handled
+// at the same time, Accept blocks while the limit is reached leaving
+// new connections in the kernel backlog.
+type limitedListener struct {
+	net.Listener
+	proxy *HTTPProxy
+	// closed by Close to unblock Accept while waiting for a slot
+	done   chan bool
+	closed sync.Once
+}
+
+// Releases its slot when closed and is closed by the deadlines
+// if no data is exchanged for IdleTimeout.
+type limitedConn struct {
+	net.Conn
+	proxy *HTTPProxy
+	// the proxy ones might be replaced by a restart with new limits
+	slots   chan bool
+	release sync.Once
+}
+
+func (p *HTTPProxy) SetLimits(maxConnections int, idleTimeout int) {
+	p.MaxConnections = maxConnections
+	p.IdleTimeout = time.Duration(idleTimeout) * time.Second
+	p.slots = nil
+	if maxConnections > 0 {
+		p.slots = make(chan bool, maxConnections)
+	}
+}
+
+func (p *HTTPProxy) limit(listener net.Listener) net.Listener {
+	return &limitedListener{
+		Listener: listener,
+		proxy:    p,
+		done:     make(chan bool),
+	}
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	p := l.proxy
+	slots := p.slots
+	if slots != nil {
+		select {
+		case slots <- true:
+		default:
+			atomic.AddUint64(&p.Throttled, 1)
+			select {
+			case slots <- true:
+			case <-l.done:
+				return nil, errListenerClosed
+			}
+		}
+	}
+
+	c, err := l.Listener.Accept()
+	if err != nil {
+		if slots != nil {
+			<-slots
+		}
+		return nil, err
+	}
+
+	atomic.AddInt64(&p.Active, 1)
+	conn := &limitedConn{
+		Conn:  c,
+		proxy: p,
+		slots: slots,
+	}
+	conn.touch()
+
+	return conn, nil
+}
+
+func (l *limitedListener) Close() error {
+	l.closed.Do(func() {
+		close(l.done)
+	})
+	return l.Listener.Close()
+}
+
+func (c *limitedConn) touch() {
+	if c.proxy.IdleTimeout > 0 {
+		c.Conn.SetDeadline(time.Now().Add(c.proxy.IdleTimeout))
+	}
+}
+
+func (c *limitedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *limitedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.touch()
+	}
+	return n, err
+}
+
+func (c *limitedConn) Close() error {
+	c.release.Do(func() {
+		atomic.AddInt64(&c.proxy.Active, -1)
+		if c.slots != nil {
+			<-c.slots
+		}
+	})
+	return c.Conn.Close()
+}
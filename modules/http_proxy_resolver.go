@@ -0,0 +1,150 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	resolverPositiveTTL = 60 * time.Second
+	resolverNegativeTTL = 10 * time.Second
+)
+
+type resolverEntry struct {
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// Resolves the upstream hosts the proxy connects to, caching both
+// successful and failed lookups, optionally through a specific DNS
+// server and with static overrides for some hostnames.
+type proxyResolver struct {
+	lock      *sync.RWMutex
+	resolver  *net.Resolver
+	overrides map[string]string
+	cache     map[string]resolverEntry
+	dialer    *net.Dialer
+}
+
+func newProxyResolver() *proxyResolver {
+	return &proxyResolver{
+		lock:      &sync.RWMutex{},
+		resolver:  net.DefaultResolver,
+		overrides: make(map[string]string),
+		cache:     make(map[string]resolverEntry),
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+	}
+}
+
+// Parses a comma separated list of HOSTNAME=IP entries.
+func parseDNSOverrides(list string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || net.ParseIP(strings.TrimSpace(parts[1])) == nil {
+			return nil, fmt.Errorf("Invalid DNS override '%s', expected HOSTNAME=IP.", entry)
+		}
+		overrides[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return overrides, nil
+}
+
+// An empty server means the system resolver, the cache is flushed.
+func (r *proxyResolver) Configure(server string, overrides map[string]string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.resolver = net.DefaultResolver
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				d := net.Dialer{Timeout: 5 * time.Second}
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	r.overrides = overrides
+	r.cache = make(map[string]resolverEntry)
+}
+
+func (r *proxyResolver) Lookup(ctx context.Context, host string) ([]string, error) {
+	key := strings.ToLower(host)
+
+	r.lock.RLock()
+	if addr, found := r.overrides[key]; found == true {
+		r.lock.RUnlock()
+		return []string{addr}, nil
+	} else if entry, found := r.cache[key]; found == true && time.Now().Before(entry.expires) {
+		r.lock.RUnlock()
+		return entry.addrs, entry.err
+	}
+	resolver := r.resolver
+	r.lock.RUnlock()
+
+	addrs, err := resolver.LookupHost(ctx, host)
+	// don't cache lookups interrupted by the client going away
+	if ctx.Err() != nil {
+		return addrs, err
+	}
+
+	ttl := resolverPositiveTTL
+	if err != nil {
+		ttl = resolverNegativeTTL
+	}
+
+	r.lock.Lock()
+	r.cache[key] = resolverEntry{
+		addrs:   addrs,
+		err:     err,
+		expires: time.Now().Add(ttl),
+	}
+	r.lock.Unlock()
+
+	return addrs, err
+}
+
+// Used by the upstream transport, addresses are tried in order.
+func (r *proxyResolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	} else if net.ParseIP(host) != nil {
+		return r.dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := r.Lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		var conn net.Conn
+		if conn, err = r.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port)); err == nil {
+			return conn, nil
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("No addresses found for %s.", host)
+	}
+	return nil, err
+}
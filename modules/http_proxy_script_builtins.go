@@ -3,6 +3,8 @@ package modules
 import (
 	"fmt"
 	"io/ioutil"
+	"math/rand"
+	"time"
 
 	"github.com/evilsocket/bettercap-ng/log"
 
@@ -27,6 +29,25 @@ func (s *ProxyScript) defineBuiltins() error {
 		return v
 	})
 
+	// callbacks run one at a time, so the generator needs no lock
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	s.VM.Set("fuzz", func(call otto.FunctionCall) otto.Value {
+		data := []byte(call.Argument(0).String())
+		max := 8
+		if n, err := call.Argument(1).ToInteger(); err == nil && n > 0 {
+			max = int(n)
+		}
+
+		fuzzBytes(rng, data, max)
+
+		v, err := s.VM.ToValue(string(data))
+		if err != nil {
+			log.Error("Could not convert to string: %s", err)
+			return otto.Value{}
+		}
+		return v
+	})
+
 	s.VM.Set("log", func(call otto.FunctionCall) otto.Value {
 		for _, v := range call.ArgumentList {
 			fmt.Printf("%s", v.String())
@@ -3,18 +3,51 @@ package modules
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
+	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/evilsocket/bettercap-ng/core"
 	"github.com/evilsocket/bettercap-ng/log"
 	"github.com/evilsocket/bettercap-ng/session"
 )
 
 type HttpServer struct {
 	session.SessionModule
-	server *http.Server
+	server    *http.Server
+	root      http.FileSystem
+	templates []string
+}
+
+// Reported by http.server.request events.
+type HttpServerRequest struct {
+	Address   string `json:"address"`
+	Method    string `json:"method"`
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	UserAgent string `json:"user_agent"`
+	Status    int    `json:"status"`
+	Size      int64  `json:"size"`
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
 }
 
 func NewHttpServer(s *session.Session) *HttpServer {
@@ -37,6 +70,11 @@ func NewHttpServer(s *session.Session) *HttpServer {
 		"80",
 		"Port to bind the http server to."))
 
+	httpd.AddParam(session.NewStringParameter("http.server.templates",
+		"",
+		"",
+		"Comma separated list of file names, like index.html, in which tokens such as {iface.ipv4} or {env.NAME} are replaced with the session variables before serving them."))
+
 	httpd.AddHandler(session.NewModuleHandler("http.server on", "",
 		"Start httpd server.",
 		func(args []string) error {
@@ -64,24 +102,118 @@ func (httpd *HttpServer) Author() string {
 	return "Simone Margaritelli <evilsocket@protonmail.com>"
 }
 
-func wrapHandler(h http.Handler) http.Handler {
+func (httpd *HttpServer) isTemplate(name string) bool {
+	base := path.Base(name)
+	for _, t := range httpd.templates {
+		if t == base {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the template to serve for the requested path, either the file
+// itself or the first template found in the requested folder.
+func (httpd *HttpServer) findTemplate(name string) (string, http.File) {
+	f, err := httpd.root.Open(name)
+	if err != nil {
+		return "", nil
+	}
+
+	if info, err := f.Stat(); err != nil {
+		f.Close()
+		return "", nil
+	} else if info.IsDir() == false {
+		if httpd.isTemplate(name) == true {
+			return name, f
+		}
+		f.Close()
+		return "", nil
+	}
+	f.Close()
+
+	// let the file server redirect to the trailing slash first,
+	// or relative links in the template would be broken
+	if strings.HasSuffix(name, "/") == false {
+		return "", nil
+	}
+
+	for _, t := range httpd.templates {
+		index := path.Join(name, t)
+		if f, err := httpd.root.Open(index); err == nil {
+			if info, err := f.Stat(); err == nil && info.IsDir() == false {
+				return index, f
+			}
+			f.Close()
+		}
+	}
+
+	return "", nil
+}
+
+func (httpd *HttpServer) serveTemplate(w http.ResponseWriter, name string, f http.File) {
+	defer f.Close()
+
+	raw, err := ioutil.ReadAll(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := []byte(httpd.Session.Env.Expand(string(raw)))
+	ctype := mime.TypeByExtension(filepath.Ext(name))
+	if ctype == "" {
+		ctype = http.DetectContentType(data)
+	}
+
+	w.Header().Set("Content-Type", ctype)
+	// the variables might change at any time
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+func (httpd *HttpServer) handler(files http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Info("(%s) %s %s %s%s", core.Green("httpd"), core.Bold(strings.Split(r.RemoteAddr, ":")[0]), r.Method, r.Host, r.URL.Path)
-		h.ServeHTTP(w, r)
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+
+		if len(httpd.templates) == 0 {
+			files.ServeHTTP(sw, r)
+		} else if tpl, f := httpd.findTemplate(r.URL.Path); f != nil {
+			httpd.serveTemplate(sw, tpl, f)
+		} else {
+			files.ServeHTTP(sw, r)
+		}
+
+		httpd.Session.Events.Add("http.server.request", HttpServerRequest{
+			Address:   strings.Split(r.RemoteAddr, ":")[0],
+			Method:    r.Method,
+			Host:      r.Host,
+			Path:      r.URL.Path,
+			UserAgent: r.UserAgent(),
+			Status:    sw.status,
+			Size:      sw.size,
+		})
 	})
 }
 
 func (httpd *HttpServer) Configure() error {
 	var err error
-	var path string
+	var docroot string
 	var address string
 	var port int
 
-	if err, path = httpd.StringParam("http.server.path"); err != nil {
+	if err, docroot = httpd.StringParam("http.server.path"); err != nil {
+		return err
+	} else if err, httpd.templates = httpd.ListParam("http.server.templates"); err != nil {
 		return err
 	}
 
-	http.Handle("/", wrapHandler(http.FileServer(http.Dir(path))))
+	httpd.root = http.Dir(docroot)
+
+	// never the default mux, it would expose whatever else is registered there
+	router := http.NewServeMux()
+	router.Handle("/", httpd.handler(http.FileServer(httpd.root)))
+	httpd.server.Handler = router
 
 	if err, address = httpd.StringParam("http.server.address"); err != nil {
 		return err
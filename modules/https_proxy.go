@@ -1,6 +1,8 @@
 package modules
 
 import (
+	"sync/atomic"
+
 	"github.com/evilsocket/bettercap-ng/core"
 	"github.com/evilsocket/bettercap-ng/log"
 	"github.com/evilsocket/bettercap-ng/session"
@@ -9,13 +11,15 @@ import (
 
 type HttpsProxy struct {
 	session.SessionModule
-	proxy *HTTPProxy
+	proxy   *HTTPProxy
+	prewarm bool
+	quit    chan bool
 }
 
 func NewHttpsProxy(s *session.Session) *HttpsProxy {
 	p := &HttpsProxy{
 		SessionModule: session.NewSessionModule("https.proxy", s),
-		proxy:         NewHTTPProxy(s),
+		quit:          make(chan bool),
 	}
 
 	p.AddParam(session.NewIntParameter("https.port",
@@ -41,11 +45,51 @@ func NewHttpsProxy(s *session.Session) *HttpsProxy {
 		"",
 		"HTTPS proxy certification authority TLS key file."))
 
+	p.AddParam(session.NewIntParameter("https.proxy.connections",
+		"512",
+		"Maximum number of connections handled at the same time by the HTTPS proxy, new ones wait until a slot is free, 0 for no limit."))
+
+	p.AddParam(session.NewIntParameter("https.proxy.timeout",
+		"60",
+		"Seconds after which idle HTTPS proxy connections are closed, 0 to disable."))
+
+	p.AddParam(session.NewStringParameter("https.proxy.dns",
+		"",
+		"",
+		"DNS server, as IP or IP:PORT, used to resolve the hosts the HTTPS proxy connects to, empty for the system resolver."))
+
+	p.AddParam(session.NewStringParameter("https.proxy.dns.overrides",
+		"",
+		"",
+		"Comma separated list of HOSTNAME=IP entries the HTTPS proxy connects to without resolving them."))
+
+	p.AddParam(session.NewStringParameter("https.proxy.fuzz",
+		"0",
+		`^(0(\.\d+)?|1(\.0+)?)$`,
+		"Share of the responses to mutate, from 0 to 1, to test the robustness of the clients parsers."))
+
+	p.AddParam(session.NewStringParameter("https.proxy.fuzz.fields",
+		"headers, length, body",
+		"",
+		"Comma separated list of the parts of the responses to mutate, among headers, length and body."))
+
+	p.AddParam(session.NewIntParameter("https.proxy.fuzz.bytes",
+		"8",
+		"Maximum number of bytes of each fuzzed body to mutate."))
+
 	p.AddParam(session.NewStringParameter("https.proxy.script",
 		"",
 		"",
 		"Path of a proxy JS script."))
 
+	p.AddParam(session.NewBoolParameter("https.proxy.tickets",
+		"true",
+		"If false, no TLS session tickets are issued to the clients so that they can't resume sessions and every connection goes through a full handshake."))
+
+	p.AddParam(session.NewBoolParameter("https.proxy.prewarm",
+		"true",
+		"If true, certificates for the hostnames seen in sniffed DNS answers or spoofed by dns.spoof are generated in background before clients connect."))
+
 	p.AddHandler(session.NewModuleHandler("https.proxy on", "",
 		"Start HTTPS proxy.",
 		func(args []string) error {
@@ -74,11 +118,23 @@ func (p *HttpsProxy) Author() string {
 }
 
 func (p *HttpsProxy) Configure() error {
+	// built on first use, most sessions never start a proxy
+	if p.proxy == nil {
+		p.proxy = NewHTTPProxy(p.Session)
+	}
+
 	var err error
 	var address string
 	var proxyPort int
 	var httpPort int
 	var scriptPath string
+	var maxConnections int
+	var idleTimeout int
+	var dnsServer string
+	var dnsOverrides string
+	var fuzzRate string
+	var fuzzFields []string
+	var fuzzMaxBytes int
 	var certFile string
 	var keyFile string
 
@@ -110,6 +166,40 @@ func (p *HttpsProxy) Configure() error {
 		return err
 	}
 
+	if err, maxConnections = p.IntParam("https.proxy.connections"); err != nil {
+		return err
+	} else if err, idleTimeout = p.IntParam("https.proxy.timeout"); err != nil {
+		return err
+	}
+
+	p.proxy.SetLimits(maxConnections, idleTimeout)
+
+	if err, dnsServer = p.StringParam("https.proxy.dns"); err != nil {
+		return err
+	} else if err, dnsOverrides = p.StringParam("https.proxy.dns.overrides"); err != nil {
+		return err
+	} else if overrides, err := parseDNSOverrides(dnsOverrides); err != nil {
+		return err
+	} else {
+		p.proxy.Resolver.Configure(dnsServer, overrides)
+	}
+
+	if err, fuzzRate = p.StringParam("https.proxy.fuzz"); err != nil {
+		return err
+	} else if err, fuzzFields = p.ListParam("https.proxy.fuzz.fields"); err != nil {
+		return err
+	} else if err, fuzzMaxBytes = p.IntParam("https.proxy.fuzz.bytes"); err != nil {
+		return err
+	} else if err = p.proxy.Fuzzer.Configure(fuzzRate, fuzzFields, fuzzMaxBytes); err != nil {
+		return err
+	}
+
+	if err, p.prewarm = p.BoolParam("https.proxy.prewarm"); err != nil {
+		return err
+	} else if err, p.proxy.SessionTickets = p.BoolParam("https.proxy.tickets"); err != nil {
+		return err
+	}
+
 	if core.Exists(certFile) == false || core.Exists(keyFile) == false {
 		log.Info("Generating proxy certification authority TLS key to %s", keyFile)
 		log.Info("Generating proxy certification authority TLS certificate to %s", certFile)
@@ -134,14 +224,73 @@ func (p *HttpsProxy) Start() error {
 	p.SetRunning(true)
 	p.proxy.Start()
 
+	if p.prewarm == true {
+		go p.prewarmer()
+	}
+
 	return nil
 }
 
+// Generates certificates for the hostnames the targets are resolving,
+// as they are likely to connect to them shortly.
+func (p *HttpsProxy) prewarmer() {
+	sub := p.Session.Events.Subscribe([]string{"net.sniff.leak.dns", "dns.spoof"}, session.DropNewest, 256)
+	defer p.Session.Events.Unsubscribe(sub)
+
+	port := p.proxy.Redirection.SrcPort
+	for {
+		select {
+		case e := <-sub.C:
+			hostname := ""
+			if e.Tag == "net.sniff.leak.dns" {
+				if data, ok := e.Data.(SniffData); ok == true {
+					hostname, _ = data["Hostname"].(string)
+				}
+			} else if e.Tag == "dns.spoof" {
+				if spoof, ok := e.Data.(SpoofEvent); ok == true {
+					hostname = spoof.Request
+				}
+			}
+
+			if hostname != "" {
+				prewarmCert(p.proxy.CA, hostname, port)
+			}
+
+		case <-p.quit:
+			return
+		}
+	}
+}
+
 func (p *HttpsProxy) Stop() error {
 	if p.Running() == false {
 		return session.ErrAlreadyStopped
 	}
 	p.SetRunning(false)
 
+	if p.prewarm == true {
+		p.quit <- true
+	}
+
 	return p.proxy.Stop()
 }
+
+func (p *HttpsProxy) Metrics() []session.Metric {
+	if p.proxy == nil {
+		return nil
+	}
+
+	hits, misses := certCacheStats()
+	return []session.Metric{
+		session.NewMetric("proxy_requests_total", "Requests handled by the proxy.", session.MetricCounter,
+			float64(atomic.LoadUint64(&p.proxy.Requests)), "proxy", p.Name()),
+		session.NewMetric("proxy_connections", "Connections currently handled by the proxy.", session.MetricGauge,
+			float64(atomic.LoadInt64(&p.proxy.Active)), "proxy", p.Name()),
+		session.NewMetric("proxy_connections_throttled_total", "Connections which had to wait for a free slot.", session.MetricCounter,
+			float64(atomic.LoadUint64(&p.proxy.Throttled)), "proxy", p.Name()),
+		session.NewMetric("proxy_fuzzed_total", "Responses mutated by the fuzzer.", session.MetricCounter,
+			float64(atomic.LoadUint64(&p.proxy.Fuzzer.Fuzzed)), "proxy", p.Name()),
+		session.NewMetric("proxy_cert_cache_hits_total", "Spoofed certificates found in the cache.", session.MetricCounter, float64(hits)),
+		session.NewMetric("proxy_cert_cache_misses_total", "Spoofed certificates which had to be generated.", session.MetricCounter, float64(misses)),
+	}
+}
@@ -0,0 +1,179 @@
+package modules
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	network "github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+type MacChanger struct {
+	session.SessionModule
+	originalMac net.HardwareAddr
+	fakeMac     net.HardwareAddr
+}
+
+func NewMacChanger(s *session.Session) *MacChanger {
+	mc := &MacChanger{
+		SessionModule: session.NewSessionModule("mac.changer", s),
+	}
+
+	mc.AddParam(session.NewStringParameter("mac.changer.address",
+		"random",
+		`^(random|vendor|[a-fA-F0-9]{2}(:[a-fA-F0-9]{2}){5})$`,
+		"Hardware address to use, 'random' for a random one or 'vendor' for a random one keeping the vendor prefix of the original address."))
+
+	mc.AddHandler(session.NewModuleHandler("mac.changer on", "",
+		"Change the interface hardware address and look for the gateway and the hosts again.",
+		func(args []string) error {
+			return mc.Start()
+		}))
+
+	mc.AddHandler(session.NewModuleHandler("mac.changer off", "",
+		"Restore the original interface hardware address.",
+		func(args []string) error {
+			return mc.Stop()
+		}))
+
+	return mc
+}
+
+func (mc *MacChanger) Name() string {
+	return "mac.changer"
+}
+
+func (mc *MacChanger) Description() string {
+	return "Change the hardware address of the interface, restoring it when stopped or when the session ends."
+}
+
+func (mc *MacChanger) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+// A random unicast address, locally administered unless the
+// vendor prefix of another address has to be kept.
+func randomMac(prefix net.HardwareAddr) (net.HardwareAddr, error) {
+	hw := make(net.HardwareAddr, 6)
+	if _, err := rand.Read(hw); err != nil {
+		return nil, err
+	}
+
+	if prefix != nil {
+		copy(hw, prefix[:3])
+	} else {
+		hw[0] = (hw[0] | 0x02) & 0xfe
+	}
+
+	return hw, nil
+}
+
+func (mc *MacChanger) Configure() error {
+	var err error
+	var address string
+
+	if err, address = mc.StringParam("mac.changer.address"); err != nil {
+		return err
+	}
+
+	mc.originalMac = append(net.HardwareAddr{}, mc.Session.CurrentInterface().HW...)
+
+	switch address {
+	case "random":
+		mc.fakeMac, err = randomMac(nil)
+	case "vendor":
+		mc.fakeMac, err = randomMac(mc.originalMac)
+	default:
+		mc.fakeMac, err = net.ParseMAC(address)
+	}
+
+	return err
+}
+
+func (mc *MacChanger) setMac(mac net.HardwareAddr) error {
+	iface := mc.Session.CurrentInterface()
+
+	log.Info("Changing %s hardware address to %s ...", iface.Name(), core.Bold(mac.String()))
+
+	if err := network.SetInterfaceMAC(iface.Name(), mac); err != nil {
+		return fmt.Errorf("Could not change the hardware address of %s: %s.", iface.Name(), err)
+	}
+
+	// other modules keep reading the current one
+	changed := *iface
+	changed.HW = mac
+	changed.HwAddress = mac.String()
+	changed.Vendor = network.OuiLookup(changed.HwAddress)
+
+	return mc.Session.SwapInterface(&changed, false)
+}
+
+// The link went down and the kernel forgot its neighbours, make it resolve
+// the gateway again before looking for it, then send a round of probes
+// unless net.probe is already running, so that net.recon finds the hosts.
+func (mc *MacChanger) rediscover() {
+	if gw := mc.Session.CurrentGateway(); gw != mc.Session.CurrentInterface() {
+		if con, err := net.Dial("udp", fmt.Sprintf("%s:137", gw.IpAddress)); err == nil {
+			con.Write([]byte{0x00})
+			con.Close()
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	if _, err := network.ArpUpdate(mc.Session.CurrentInterface().Name()); err != nil {
+		log.Debug("Error while updating the ARP table: %s", err)
+	}
+
+	mc.Session.Rediscover()
+
+	if err, mod := mc.Session.Module("net.probe"); err == nil && mod.Running() == false {
+		if prober, ok := mod.(*Prober); ok == true {
+			go func() {
+				if err := prober.probeOnce(); err != nil {
+					log.Debug("Error while probing for hosts: %s", err)
+				}
+			}()
+		}
+	}
+}
+
+func (mc *MacChanger) restore() error {
+	return mc.setMac(mc.originalMac)
+}
+
+func (mc *MacChanger) Start() error {
+	if mc.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := mc.Configure(); err != nil {
+		return err
+	} else if err := mc.setMac(mc.fakeMac); err != nil {
+		return err
+	}
+
+	mc.SetRunning(true)
+	mc.Session.Journal.Push("mac.changer", fmt.Sprintf("Hardware address of %s changed from %s to %s", mc.Session.CurrentInterface().Name(), mc.originalMac, mc.fakeMac), mc.restore)
+
+	mc.rediscover()
+
+	return nil
+}
+
+func (mc *MacChanger) Stop() error {
+	if mc.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	mc.SetRunning(false)
+
+	if err := mc.restore(); err != nil {
+		return err
+	}
+	mc.Session.Journal.Remove("mac.changer")
+
+	mc.rediscover()
+
+	return nil
+}
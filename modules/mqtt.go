@@ -0,0 +1,221 @@
+package modules
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type MQTTSink struct {
+	session.SessionModule
+	client  mqtt.Client
+	topic   string
+	qos     byte
+	retain  bool
+	include []string
+	exclude []string
+	quit    chan bool
+}
+
+func NewMQTTSink(s *session.Session) *MQTTSink {
+	sink := &MQTTSink{
+		SessionModule: session.NewSessionModule("mqtt", s),
+		include:       make([]string, 0),
+		exclude:       make([]string, 0),
+		quit:          make(chan bool),
+	}
+
+	sink.AddParam(session.NewStringParameter("mqtt.broker",
+		"tcp://127.0.0.1:1883",
+		"^(tcp|ssl|tls|ws|wss)://.+$",
+		"MQTT broker URL, use ssl:// for TLS."))
+
+	sink.AddParam(session.NewStringParameter("mqtt.client.id",
+		"bettercap-ng",
+		".+",
+		"MQTT client identifier."))
+
+	sink.AddParam(session.NewStringParameter("mqtt.username",
+		"",
+		"",
+		"MQTT username, if required by the broker."))
+
+	sink.AddParam(session.NewSecretParameter("mqtt.password",
+		"",
+		"",
+		"MQTT password, if required by the broker."))
+
+	sink.AddParam(session.NewBoolParameter("mqtt.tls.insecure",
+		"false",
+		"If true, the broker TLS certificate will not be verified."))
+
+	sink.AddParam(session.NewStringParameter("mqtt.topic",
+		"bettercap/{event.path}",
+		"",
+		"Topic to publish events to, {event.tag} is replaced with the event type and {event.path} with the event type using / as separator."))
+
+	sink.AddParam(session.NewModuleParameter("mqtt.qos",
+		"0",
+		session.INT,
+		"^[0-2]$",
+		"MQTT quality of service, 0, 1 or 2."))
+
+	sink.AddParam(session.NewBoolParameter("mqtt.retain",
+		"false",
+		"If true, the broker will retain the last message of each topic."))
+
+	sink.AddParam(session.NewStringParameter("mqtt.filter",
+		"",
+		"",
+		"If filled, only publish events whose type starts with one of these comma separated prefixes."))
+
+	sink.AddParam(session.NewStringParameter("mqtt.exclude",
+		"sys.log",
+		"",
+		"If filled, do not publish events whose type starts with one of these comma separated prefixes."))
+
+	sink.AddHandler(session.NewModuleHandler("mqtt on", "",
+		"Start publishing events to the MQTT broker.",
+		func(args []string) error {
+			return sink.Start()
+		}))
+
+	sink.AddHandler(session.NewModuleHandler("mqtt off", "",
+		"Stop publishing events to the MQTT broker.",
+		func(args []string) error {
+			return sink.Stop()
+		}))
+
+	return sink
+}
+
+func (s MQTTSink) Name() string {
+	return "mqtt"
+}
+
+func (s MQTTSink) Description() string {
+	return "Publish session events to a MQTT broker."
+}
+
+func (s MQTTSink) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (s *MQTTSink) Configure() (err error) {
+	var broker, clientID, username, password string
+	var insecure bool
+	var qos int
+
+	if err, broker = s.StringParam("mqtt.broker"); err != nil {
+		return err
+	} else if err, clientID = s.StringParam("mqtt.client.id"); err != nil {
+		return err
+	} else if err, username = s.StringParam("mqtt.username"); err != nil {
+		return err
+	} else if err, password = s.StringParam("mqtt.password"); err != nil {
+		return err
+	} else if err, insecure = s.BoolParam("mqtt.tls.insecure"); err != nil {
+		return err
+	} else if err, s.topic = s.StringParam("mqtt.topic"); err != nil {
+		return err
+	} else if err, qos = s.IntParam("mqtt.qos"); err != nil {
+		return err
+	} else if err, s.retain = s.BoolParam("mqtt.retain"); err != nil {
+		return err
+	} else if err, s.include = s.ListParam("mqtt.filter"); err != nil {
+		return err
+	} else if err, s.exclude = s.ListParam("mqtt.exclude"); err != nil {
+		return err
+	}
+
+	s.qos = byte(qos)
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(username).
+		SetPassword(password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(10 * time.Second).
+		SetTLSConfig(&tls.Config{InsecureSkipVerify: insecure})
+
+	s.client = mqtt.NewClient(opts)
+	if token := s.client.Connect(); token.WaitTimeout(10*time.Second) == false {
+		return fmt.Errorf("Timeout while connecting to %s.", broker)
+	} else if token.Error() != nil {
+		return fmt.Errorf("Error while connecting to %s: %s.", broker, token.Error())
+	}
+
+	return nil
+}
+
+func (s *MQTTSink) accept(e session.Event) bool {
+	if len(s.include) > 0 && hasPrefix(e.Tag, s.include) == false {
+		return false
+	}
+	return hasPrefix(e.Tag, s.exclude) == false
+}
+
+func (s *MQTTSink) topicFor(e session.Event) string {
+	return strings.NewReplacer(
+		"{event.tag}", e.Tag,
+		"{event.path}", strings.Replace(e.Tag, ".", "/", -1),
+	).Replace(s.topic)
+}
+
+func (s *MQTTSink) publish(e session.Event) {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		log.Debug("Error while encoding %s event: %s", e.Tag, err)
+		return
+	}
+
+	// don't wait for the broker, with qos > 0 the client will retry
+	s.client.Publish(s.topicFor(e), s.qos, s.retain, raw)
+}
+
+func (s *MQTTSink) Start() error {
+	if s.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := s.Configure(); err != nil {
+		return err
+	}
+
+	s.SetRunning(true)
+
+	go func() {
+		listener := s.Session.Events.Listen()
+		defer s.Session.Events.Unlisten(listener)
+
+		for {
+			select {
+			case e := <-listener:
+				if s.accept(e) == true {
+					s.publish(e)
+				}
+
+			case <-s.quit:
+				s.client.Disconnect(250)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *MQTTSink) Stop() error {
+	if s.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	s.SetRunning(false)
+	s.quit <- true
+	return nil
+}
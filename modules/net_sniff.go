@@ -3,8 +3,11 @@ package modules
 import (
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/evilsocket/bettercap-ng/core"
 	"github.com/evilsocket/bettercap-ng/session"
 
 	"github.com/google/gopacket"
@@ -23,6 +26,15 @@ func NewSniffer(s *session.Session) *Sniffer {
 		Stats:         nil,
 	}
 
+	sniff.AddParam(session.NewStringParameter("net.sniff.interface",
+		"",
+		"",
+		"If set, sniff from this interface instead of the session one."))
+
+	sniff.AddParam(session.NewBoolParameter("net.sniff.monitor",
+		"false",
+		"If true, net.sniff.interface is put in monitor mode while sniffing and back in managed mode when stopped or when the session ends."))
+
 	sniff.AddParam(session.NewBoolParameter("net.sniff.verbose",
 		"true",
 		"If true, will print every captured packet, otherwise only selected ones."))
@@ -46,6 +58,36 @@ func NewSniffer(s *session.Session) *Sniffer {
 		"",
 		"If set, the sniffer will write captured packets to this file."))
 
+	sniff.AddParam(session.NewStringParameter("net.sniff.source",
+		"pcap",
+		"^(pcap|afpacket)$",
+		"Capture backend, pcap or afpacket to read from memory mapped TPACKETv3 rings on Linux."))
+
+	sniff.AddParam(session.NewIntParameter("net.sniff.fanout",
+		"0",
+		"Number of afpacket sockets sharing the traffic by flow, each one processed by its own goroutine, 0 for one per CPU."))
+
+	sniff.AddParam(session.NewBoolParameter("net.sniff.dns.tunnel",
+		"true",
+		"If true, DNS queries are checked for patterns of data being tunneled through them and dns.tunnel-suspect events are emitted."))
+
+	sniff.AddParam(session.NewIntParameter("net.sniff.dns.tunnel.label",
+		"52",
+		"Subdomain labels longer than this are considered suspicious, 0 to disable the check."))
+
+	sniff.AddParam(session.NewStringParameter("net.sniff.dns.tunnel.entropy",
+		"4.0",
+		`^\d+(\.\d+)?$`,
+		"Subdomains with a Shannon entropy, in bits per character, higher than this are considered suspicious, 0 to disable the check."))
+
+	sniff.AddParam(session.NewIntParameter("net.sniff.dns.tunnel.unique",
+		"50",
+		"Clients querying more unique subdomains of the same domain than this in a minute are considered suspicious, 0 to disable the check."))
+
+	sniff.AddParam(session.NewIntParameter("net.sniff.dns.tunnel.rate",
+		"120",
+		"Clients sending more queries for the same domain than this in a minute are considered suspicious, 0 to disable the check."))
+
 	sniff.AddHandler(session.NewModuleHandler("net.sniff stats", "",
 		"Print sniffer session configuration and statistics.",
 		func(args []string) error {
@@ -100,7 +142,7 @@ func same(a, b net.HardwareAddr) bool {
 }
 
 func (s Sniffer) isLocalPacket(packet gopacket.Packet) bool {
-	local_hw := s.Session.Interface.HW
+	local_hw := s.Ctx.Interface.HW
 	eth := packet.Layer(layers.LayerTypeEthernet)
 	if eth != nil {
 		eth_packet, _ := eth.(*layers.Ethernet)
@@ -113,7 +155,7 @@ func (s Sniffer) isLocalPacket(packet gopacket.Packet) bool {
 
 func (s *Sniffer) onPacketMatched(pkt gopacket.Packet) {
 	if mainParser(pkt, s.Ctx.Verbose) == true {
-		s.Stats.NumDumped++
+		atomic.AddUint64(&s.Stats.NumDumped, 1)
 	}
 }
 
@@ -140,45 +182,87 @@ func (s *Sniffer) Start() error {
 
 	s.SetRunning(true)
 
-	go func() {
-		s.Stats = NewSnifferStats()
-		defer s.Ctx.Close()
+	s.Stats = NewSnifferStats()
 
-		src := gopacket.NewPacketSource(s.Ctx.Handle, s.Ctx.Handle.LinkType())
-		for packet := range src.Packets() {
-			if s.Running() == false {
-				break
-			}
+	wg := &sync.WaitGroup{}
+	for _, handle := range s.Ctx.Handles {
+		wg.Add(1)
+		go s.worker(handle, wg)
+	}
 
-			now := time.Now()
-			if s.Stats.FirstPacket.IsZero() {
-				s.Stats.FirstPacket = now
-			}
-			s.Stats.LastPacket = now
+	go func(ctx *SnifferContext) {
+		defer s.Session.Recover()
+		wg.Wait()
+		ctx.Close()
+	}(s.Ctx)
 
-			is_local := false
-			if s.isLocalPacket(packet) {
-				is_local = true
-				s.Stats.NumLocal++
-			}
+	return nil
+}
 
-			if s.Ctx.DumpLocal == true || is_local == false {
-				data := packet.Data()
-				if s.Ctx.Compiled == nil || s.Ctx.Compiled.Match(data) == true {
-					s.Stats.NumMatched++
+func (s *Sniffer) worker(handle captureHandle, wg *sync.WaitGroup) {
+	defer s.Session.Recover()
+	defer wg.Done()
 
-					s.onPacketMatched(packet)
+	options := gopacket.DecodeOptions{Lazy: true, NoCopy: true}
+	linkType := handle.LinkType()
 
-					if s.Ctx.OutputWriter != nil {
-						s.Ctx.OutputWriter.WritePacket(packet.Metadata().CaptureInfo, data)
-						s.Stats.NumWrote++
-					}
-				}
+	for s.Running() == true {
+		data, ci, err := handle.ZeroCopyReadPacketData()
+		if err != nil {
+			if captureClosed(err) == true {
+				return
 			}
+			// timeouts and temporary errors
+			time.Sleep(5 * time.Millisecond)
+			continue
 		}
-	}()
 
-	return nil
+		// the handle reuses data at the next read, parsers and events
+		// don't keep references to the packet so the copy can be pooled
+		buf := core.GetBuffer()
+		buf.Write(data)
+
+		packet := gopacket.NewPacket(buf.Bytes(), linkType, options)
+		packet.Metadata().CaptureInfo = ci
+		s.process(packet)
+
+		core.PutBuffer(buf)
+	}
+}
+
+func (s *Sniffer) onDNSTunnel(packet gopacket.Packet) {
+	for _, suspect := range s.Ctx.Tunnels.Inspect(packet) {
+		s.Session.Events.Add("dns.tunnel-suspect", *suspect)
+	}
+}
+
+func (s *Sniffer) process(packet gopacket.Packet) {
+	s.Stats.Seen(time.Now())
+
+	is_local := false
+	if s.isLocalPacket(packet) {
+		is_local = true
+		atomic.AddUint64(&s.Stats.NumLocal, 1)
+	}
+
+	if s.Ctx.DumpLocal == true || is_local == false {
+		// regardless of the regular expression, which is meant to select what to dump
+		if s.Ctx.Tunnels != nil {
+			s.onDNSTunnel(packet)
+		}
+
+		data := packet.Data()
+		if s.Ctx.Compiled == nil || s.Ctx.Compiled.Match(data) == true {
+			atomic.AddUint64(&s.Stats.NumMatched, 1)
+
+			s.onPacketMatched(packet)
+
+			if s.Ctx.OutputWriter != nil {
+				s.Ctx.WritePacket(packet)
+				atomic.AddUint64(&s.Stats.NumWrote, 1)
+			}
+		}
+	}
 }
 
 func (s *Sniffer) Stop() error {
@@ -188,3 +272,24 @@ func (s *Sniffer) Stop() error {
 	s.SetRunning(false)
 	return nil
 }
+
+func (s *Sniffer) Metrics() []session.Metric {
+	stats := s.Stats
+	if stats == nil {
+		return nil
+	}
+
+	help := "Packets processed by the sniffer by kind."
+	metrics := []session.Metric{
+		session.NewMetric("net_sniff_packets_total", help, session.MetricCounter, float64(atomic.LoadUint64(&stats.NumLocal)), "kind", "local"),
+		session.NewMetric("net_sniff_packets_total", help, session.MetricCounter, float64(atomic.LoadUint64(&stats.NumMatched)), "kind", "matched"),
+		session.NewMetric("net_sniff_packets_total", help, session.MetricCounter, float64(atomic.LoadUint64(&stats.NumDumped)), "kind", "dumped"),
+		session.NewMetric("net_sniff_packets_total", help, session.MetricCounter, float64(atomic.LoadUint64(&stats.NumWrote)), "kind", "wrote"),
+	}
+
+	if ctx := s.Ctx; ctx != nil && ctx.Tunnels != nil {
+		metrics = append(metrics, session.NewMetric("net_sniff_dns_tunnel_suspects_total", "DNS tunneling suspects reported by the sniffer.", session.MetricCounter, float64(atomic.LoadUint64(&ctx.Tunnels.Suspects))))
+	}
+
+	return metrics
+}
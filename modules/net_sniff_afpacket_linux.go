@@ -0,0 +1,146 @@
+package modules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	network "github.com/evilsocket/bettercap-ng/net"
+
+	"github.com/google/gopacket/afpacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"golang.org/x/net/bpf"
+)
+
+const (
+	afpacketFrameSize = 65536
+	afpacketBlockSize = afpacketFrameSize * 128
+	afpacketNumBlocks = 64
+	// IFF_PROMISC
+	ifacePromiscFlag = 0x100
+)
+
+// A TPACKETv3 socket, packets are read from a ring shared with the
+// kernel instead of being copied by a syscall each.
+type afpacketHandle struct {
+	*afpacket.TPacket
+	iface   string
+	promisc bool
+}
+
+func (h *afpacketHandle) LinkType() layers.LinkType {
+	return layers.LinkTypeEthernet
+}
+
+func (h *afpacketHandle) Close() {
+	h.TPacket.Close()
+	if h.promisc == true {
+		network.SetInterfacePromisc(h.iface, false)
+	}
+}
+
+func isPromisc(iface string) bool {
+	raw, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/flags", iface))
+	if err != nil {
+		return false
+	}
+
+	flags, err := strconv.ParseUint(strings.TrimSpace(string(raw)), 0, 32)
+	return err == nil && flags&ifacePromiscFlag != 0
+}
+
+// libpcap is only used to compile the filter to BPF bytecode.
+func compileFilter(filter string) ([]bpf.RawInstruction, error) {
+	compiled, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, afpacketFrameSize, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]bpf.RawInstruction, len(compiled))
+	for i, ins := range compiled {
+		raw[i] = bpf.RawInstruction{
+			Op: ins.Code,
+			Jt: ins.Jt,
+			Jf: ins.Jf,
+			K:  ins.K,
+		}
+	}
+
+	return raw, nil
+}
+
+func openAfpacket(iface string, filter string, fanout int) (handles []captureHandle, err error) {
+	var program []bpf.RawInstruction
+
+	if fanout <= 0 {
+		fanout = runtime.NumCPU()
+	}
+
+	if filter != "" {
+		if program, err = compileFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			for _, h := range handles {
+				h.Close()
+			}
+			handles = nil
+		}
+	}()
+
+	// sockets with the same id on the same interface share the traffic,
+	// packets of the same flow are always delivered to the same socket
+	group := uint16(os.Getpid() & 0xffff)
+	promisc := isPromisc(iface) == false
+
+	for i := 0; i < fanout; i++ {
+		var tpacket *afpacket.TPacket
+
+		tpacket, err = afpacket.NewTPacket(
+			afpacket.OptInterface(iface),
+			afpacket.OptFrameSize(afpacketFrameSize),
+			afpacket.OptBlockSize(afpacketBlockSize),
+			afpacket.OptNumBlocks(afpacketNumBlocks),
+			afpacket.OptTPacketVersion(afpacket.TPacketVersion3),
+			afpacket.OptPollTimeout(time.Second))
+		if err != nil {
+			return handles, err
+		}
+
+		h := &afpacketHandle{
+			TPacket: tpacket,
+			iface:   iface,
+			// only the first socket restores the flag
+			promisc: promisc && i == 0,
+		}
+		handles = append(handles, h)
+
+		if program != nil {
+			if err = tpacket.SetBPF(program); err != nil {
+				return handles, err
+			}
+		}
+
+		if fanout > 1 {
+			if err = tpacket.SetFanout(afpacket.FanoutHashWithDefrag, group); err != nil {
+				return handles, err
+			}
+		}
+	}
+
+	if promisc == true {
+		if err = network.SetInterfacePromisc(iface, true); err != nil {
+			return handles, err
+		}
+	}
+
+	return handles, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package modules
+
+import "fmt"
+
+func openAfpacket(iface string, filter string, fanout int) ([]captureHandle, error) {
+	return nil, fmt.Errorf("The afpacket capture source is only available on Linux.")
+}
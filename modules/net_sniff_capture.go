@@ -0,0 +1,55 @@
+package modules
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// A source of packets for the sniffer, either a libpcap handle
+// or one of the sockets of an afpacket fanout group.
+type captureHandle interface {
+	gopacket.ZeroCopyPacketDataSource
+	LinkType() layers.LinkType
+	Close()
+}
+
+func openCapture(source string, iface string, filter string, fanout int) ([]captureHandle, error) {
+	switch source {
+	case "pcap":
+		handle, err := pcap.OpenLive(iface, 65536, true, pcap.BlockForever)
+		if err != nil {
+			return nil, err
+		}
+
+		if filter != "" {
+			if err = handle.SetBPFFilter(filter); err != nil {
+				handle.Close()
+				return nil, err
+			}
+		}
+
+		return []captureHandle{handle}, nil
+
+	case "afpacket":
+		return openAfpacket(iface, filter, fanout)
+	}
+
+	return nil, fmt.Errorf("Unknown capture source '%s'.", source)
+}
+
+// Same criteria gopacket.PacketSource uses to stop reading.
+func captureClosed(err error) bool {
+	return err == io.EOF ||
+		err == io.ErrUnexpectedEOF ||
+		err == io.ErrNoProgress ||
+		err == io.ErrClosedPipe ||
+		err == io.ErrShortBuffer ||
+		err == syscall.EBADF ||
+		strings.Contains(err.Error(), "use of closed file")
+}
@@ -1,20 +1,27 @@
 package modules
 
 import (
+	"fmt"
 	"os"
 	"regexp"
+	"strconv"
+	"sync"
 
 	"github.com/evilsocket/bettercap-ng/core"
 	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/net"
 	"github.com/evilsocket/bettercap-ng/session"
 
-	"github.com/google/gopacket/layers"
-	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket"
 	"github.com/google/gopacket/pcapgo"
 )
 
 type SnifferContext struct {
-	Handle       *pcap.Handle
+	Interface    *net.Endpoint
+	Monitor      bool
+	Source       string
+	Fanout       int
+	Handles      []captureHandle
 	DumpLocal    bool
 	Verbose      bool
 	Filter       string
@@ -23,6 +30,10 @@ type SnifferContext struct {
 	Output       string
 	OutputFile   *os.File
 	OutputWriter *pcapgo.Writer
+	Tunnels      *dnsTunnelDetector
+
+	restoreMode func()
+	lock        *sync.Mutex
 }
 
 func (s *Sniffer) GetContext() (error, *SnifferContext) {
@@ -30,8 +41,16 @@ func (s *Sniffer) GetContext() (error, *SnifferContext) {
 
 	ctx := NewSnifferContext()
 
-	if ctx.Handle, err = pcap.OpenLive(s.Session.Interface.Name(), 65536, true, pcap.BlockForever); err != nil {
+	if err, ctx.Interface = s.InterfaceParam("net.sniff.interface"); err != nil {
+		return err, ctx
+	}
+
+	if err, ctx.Monitor = s.BoolParam("net.sniff.monitor"); err != nil {
 		return err, ctx
+	} else if ctx.Monitor == true {
+		if err = s.enterMonitorMode(ctx); err != nil {
+			return err, ctx
+		}
 	}
 
 	if err, ctx.Verbose = s.BoolParam("net.sniff.verbose"); err != nil {
@@ -44,11 +63,14 @@ func (s *Sniffer) GetContext() (error, *SnifferContext) {
 
 	if err, ctx.Filter = s.StringParam("net.sniff.filter"); err != nil {
 		return err, ctx
-	} else if ctx.Filter != "" {
-		err = ctx.Handle.SetBPFFilter(ctx.Filter)
-		if err != nil {
-			return err, ctx
-		}
+	}
+
+	if err, ctx.Source = s.StringParam("net.sniff.source"); err != nil {
+		return err, ctx
+	} else if err, ctx.Fanout = s.IntParam("net.sniff.fanout"); err != nil {
+		return err, ctx
+	} else if ctx.Handles, err = openCapture(ctx.Source, ctx.Interface.Name(), ctx.Filter, ctx.Fanout); err != nil {
+		return err, ctx
 	}
 
 	if err, ctx.Expression = s.StringParam("net.sniff.regexp"); err != nil {
@@ -59,6 +81,28 @@ func (s *Sniffer) GetContext() (error, *SnifferContext) {
 		}
 	}
 
+	if err, tunnels := s.BoolParam("net.sniff.dns.tunnel"); err != nil {
+		return err, ctx
+	} else if tunnels == true {
+		var label, unique, rate int
+		var entropy string
+		var maxEntropy float64
+
+		if err, label = s.IntParam("net.sniff.dns.tunnel.label"); err != nil {
+			return err, ctx
+		} else if err, entropy = s.StringParam("net.sniff.dns.tunnel.entropy"); err != nil {
+			return err, ctx
+		} else if maxEntropy, err = strconv.ParseFloat(entropy, 64); err != nil {
+			return err, ctx
+		} else if err, unique = s.IntParam("net.sniff.dns.tunnel.unique"); err != nil {
+			return err, ctx
+		} else if err, rate = s.IntParam("net.sniff.dns.tunnel.rate"); err != nil {
+			return err, ctx
+		}
+
+		ctx.Tunnels = newDNSTunnelDetector(label, maxEntropy, unique, rate)
+	}
+
 	if err, ctx.Output = s.StringParam("net.sniff.output"); err != nil {
 		return err, ctx
 	} else if ctx.Output != "" {
@@ -67,15 +111,55 @@ func (s *Sniffer) GetContext() (error, *SnifferContext) {
 		}
 
 		ctx.OutputWriter = pcapgo.NewWriter(ctx.OutputFile)
-		ctx.OutputWriter.WriteFileHeader(65536, layers.LinkTypeEthernet)
+		ctx.OutputWriter.WriteFileHeader(65536, ctx.Handles[0].LinkType())
 	}
 
 	return nil, ctx
 }
 
+// Puts the interface in monitor mode, making sure managed mode
+// is restored even if the session ends without stopping the sniffer.
+func (s *Sniffer) enterMonitorMode(ctx *SnifferContext) error {
+	name := ctx.Interface.Name()
+	if name == s.Session.Interface.Name() {
+		return fmt.Errorf("The session interface %s can't be put in monitor mode, set net.sniff.interface to another wireless adapter.", name)
+	}
+
+	mode, err := net.InterfaceMode(name)
+	if err != nil {
+		return err
+	} else if mode == net.WirelessModeMonitor {
+		// already in monitor mode, not ours to restore
+		return nil
+	} else if err = net.SetInterfaceMode(name, net.WirelessModeMonitor); err != nil {
+		return err
+	}
+
+	key := "net.sniff.monitor." + name
+	undo := func() error {
+		return net.SetInterfaceMode(name, mode)
+	}
+	s.Session.Journal.Push(key, fmt.Sprintf("%s put in monitor mode", name), undo)
+
+	ctx.restoreMode = func() {
+		if err := undo(); err != nil {
+			log.Error("Error while restoring %s mode of %s: %s", mode, name, err)
+		} else {
+			s.Session.Journal.Remove(key)
+		}
+	}
+
+	log.Info("%s is now in monitor mode.", core.Bold(name))
+	return nil
+}
+
 func NewSnifferContext() *SnifferContext {
 	return &SnifferContext{
-		Handle:       nil,
+		Interface:    nil,
+		Monitor:      false,
+		Source:       "pcap",
+		Fanout:       0,
+		Handles:      nil,
 		DumpLocal:    false,
 		Verbose:      true,
 		Filter:       "",
@@ -84,15 +168,19 @@ func NewSnifferContext() *SnifferContext {
 		Output:       "",
 		OutputFile:   nil,
 		OutputWriter: nil,
+		Tunnels:      nil,
+		restoreMode:  nil,
+		lock:         &sync.Mutex{},
 	}
 }
 
-var (
-	no  = core.Red("no")
-	yes = core.Green("yes")
-)
-
 func (c *SnifferContext) Log(sess *session.Session) {
+	no := core.Red("no")
+	yes := core.Green("yes")
+
+	log.Info("Interface          : %s", core.Bold(c.Interface.Name()))
+	log.Info("Capture source     : %s (%d sockets)", core.Bold(c.Source), len(c.Handles))
+
 	if c.DumpLocal {
 		log.Info("Skip local packets : %s", no)
 	} else {
@@ -113,19 +201,41 @@ func (c *SnifferContext) Log(sess *session.Session) {
 		log.Info("Regular expression : '%s'", core.Yellow(c.Expression))
 	}
 
+	if c.Tunnels != nil {
+		log.Info("DNS tunnels        : %s (label > %d, entropy > %.2f, unique > %d/min, queries > %d/min)", yes, c.Tunnels.MaxLabel, c.Tunnels.MaxEntropy, c.Tunnels.MaxUnique, c.Tunnels.MaxQueries)
+	} else {
+		log.Info("DNS tunnels        : %s", no)
+	}
+
+	if c.Monitor {
+		log.Info("Monitor mode       : %s", yes)
+	}
+
 	if c.Output != "" {
 		log.Info("File output        : '%s'", core.Yellow(c.Output))
 	}
 }
 
+func (c *SnifferContext) WritePacket(pkt gopacket.Packet) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.OutputWriter.WritePacket(pkt.Metadata().CaptureInfo, pkt.Data())
+}
+
 func (c *SnifferContext) Close() {
-	if c.Handle != nil {
-		c.Handle.Close()
-		c.Handle = nil
+	for _, h := range c.Handles {
+		h.Close()
+	}
+	c.Handles = nil
+
+	if c.restoreMode != nil {
+		c.restoreMode()
+		c.restoreMode = nil
 	}
 
 	if c.OutputFile != nil {
 		c.OutputFile.Close()
 		c.OutputFile = nil
+		artifactReady("pcap", c.Output)
 	}
 }
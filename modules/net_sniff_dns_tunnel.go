@@ -0,0 +1,222 @@
+package modules
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Emitted when the DNS queries of a client for a domain look like
+// data being carried in the subdomains.
+type DNSTunnelSuspect struct {
+	Client   string   `json:"client"`
+	Domain   string   `json:"domain"`
+	Reasons  []string `json:"reasons"`
+	Queries  int      `json:"queries"`
+	Unique   int      `json:"unique"`
+	MaxLabel int      `json:"max_label"`
+	Entropy  float64  `json:"entropy"`
+	Sample   string   `json:"sample"`
+}
+
+// Subdomains shorter than this are not checked for entropy, as
+// the one of a few characters is meaningless.
+const dnsTunnelMinEntropyLen = 24
+
+type dnsTunnelEntry struct {
+	started  time.Time
+	queries  int
+	unique   map[string]bool
+	maxLabel int
+	entropy  float64
+	sample   string
+	reported time.Time
+}
+
+// Keeps per client and domain statistics of the queries seen in
+// the last window and flags the ones exceeding the thresholds.
+type dnsTunnelDetector struct {
+	// first field to be 64-bit aligned for atomic operations on 32-bit platforms
+	Suspects uint64
+
+	Window     time.Duration
+	MaxLabel   int
+	MaxEntropy float64
+	MaxUnique  int
+	MaxQueries int
+
+	lock    sync.Mutex
+	entries map[string]*dnsTunnelEntry
+	pruned  time.Time
+}
+
+func newDNSTunnelDetector(maxLabel int, maxEntropy float64, maxUnique int, maxQueries int) *dnsTunnelDetector {
+	return &dnsTunnelDetector{
+		Window:     60 * time.Second,
+		MaxLabel:   maxLabel,
+		MaxEntropy: maxEntropy,
+		MaxUnique:  maxUnique,
+		MaxQueries: maxQueries,
+		entries:    make(map[string]*dnsTunnelEntry),
+	}
+}
+
+// Shannon entropy in bits per character.
+func dnsEntropy(s string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+
+	freq := make(map[rune]int)
+	for _, c := range s {
+		freq[c]++
+	}
+
+	entropy := 0.0
+	total := float64(len(s))
+	for _, n := range freq {
+		p := float64(n) / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Splits a name into the domain it belongs to and its subdomain,
+// second level domains like co.uk are kept with the domain.
+func dnsSplitName(name string) (domain string, sub string) {
+	labels := strings.Split(strings.Trim(strings.ToLower(name), "."), ".")
+	keep := 2
+	if n := len(labels); n > 2 && len(labels[n-1]) == 2 && len(labels[n-2]) <= 3 {
+		keep = 3
+	}
+
+	if len(labels) <= keep {
+		return strings.Join(labels, "."), ""
+	}
+
+	at := len(labels) - keep
+	return strings.Join(labels[at:], "."), strings.Join(labels[:at], ".")
+}
+
+// Accounts a query, returns the suspect to report if the client
+// exceeded any of the thresholds and wasn't reported in this window.
+func (d *dnsTunnelDetector) Check(client string, name string, now time.Time) *DNSTunnelSuspect {
+	domain, sub := dnsSplitName(name)
+	if sub == "" {
+		return nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if now.Sub(d.pruned) > d.Window {
+		for key, entry := range d.entries {
+			if now.Sub(entry.started) > d.Window && now.Sub(entry.reported) > d.Window {
+				delete(d.entries, key)
+			}
+		}
+		d.pruned = now
+	}
+
+	key := client + "|" + domain
+	entry, found := d.entries[key]
+	if found == false {
+		entry = &dnsTunnelEntry{}
+		d.entries[key] = entry
+	}
+
+	if now.Sub(entry.started) > d.Window {
+		entry.started = now
+		entry.queries = 0
+		entry.unique = make(map[string]bool)
+		entry.maxLabel = 0
+		entry.entropy = 0
+	}
+
+	entry.queries++
+	// no need to keep more than what's needed to cross the threshold
+	if len(entry.unique) <= d.MaxUnique {
+		entry.unique[sub] = true
+	}
+
+	for _, label := range strings.Split(sub, ".") {
+		if len(label) > entry.maxLabel {
+			entry.maxLabel = len(label)
+			entry.sample = name
+		}
+	}
+
+	if data := strings.Replace(sub, ".", "", -1); len(data) >= dnsTunnelMinEntropyLen {
+		if e := dnsEntropy(data); e > entry.entropy {
+			entry.entropy = e
+			entry.sample = name
+		}
+	}
+
+	reasons := make([]string, 0)
+	if d.MaxLabel > 0 && entry.maxLabel > d.MaxLabel {
+		reasons = append(reasons, fmt.Sprintf("%d characters label", entry.maxLabel))
+	}
+	if d.MaxEntropy > 0 && entry.entropy > d.MaxEntropy {
+		reasons = append(reasons, fmt.Sprintf("%.2f bits entropy", entry.entropy))
+	}
+	if d.MaxUnique > 0 && len(entry.unique) > d.MaxUnique {
+		reasons = append(reasons, fmt.Sprintf("more than %d unique subdomains", d.MaxUnique))
+	}
+	if d.MaxQueries > 0 && entry.queries > d.MaxQueries {
+		reasons = append(reasons, fmt.Sprintf("%d queries", entry.queries))
+	}
+
+	if len(reasons) == 0 || now.Sub(entry.reported) < d.Window {
+		return nil
+	}
+	entry.reported = now
+	atomic.AddUint64(&d.Suspects, 1)
+
+	return &DNSTunnelSuspect{
+		Client:   client,
+		Domain:   domain,
+		Reasons:  reasons,
+		Queries:  entry.queries,
+		Unique:   len(entry.unique),
+		MaxLabel: entry.maxLabel,
+		Entropy:  entry.entropy,
+		Sample:   entry.sample,
+	}
+}
+
+// Returns the suspects found in the queries of the packet, if it's a DNS one.
+func (d *dnsTunnelDetector) Inspect(pkt gopacket.Packet) []*DNSTunnelSuspect {
+	dns, ok := pkt.Layer(layers.LayerTypeDNS).(*layers.DNS)
+	if ok == false || dns.QR == true || dns.OpCode != layers.DNSOpCodeQuery {
+		return nil
+	}
+
+	var client string
+	if ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok == true {
+		client = ip4.SrcIP.String()
+	} else if ip6, ok := pkt.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok == true {
+		client = ip6.SrcIP.String()
+	} else {
+		return nil
+	}
+
+	at := pkt.Metadata().Timestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	suspects := make([]*DNSTunnelSuspect, 0)
+	for _, q := range dns.Questions {
+		if suspect := d.Check(client, string(q.Name), at); suspect != nil {
+			suspects = append(suspects, suspect)
+		}
+	}
+	return suspects
+}
@@ -0,0 +1,140 @@
+package modules
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDNSEntropy(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected float64
+	}{
+		{"", 0},
+		{"aaaaaaaa", 0},
+		{"abababab", 1},
+		{"abcd", 2},
+		{"0123456789abcdef", 4},
+		{"aab", 0.9183},
+	}
+
+	for _, test := range tests {
+		if e := dnsEntropy(test.data); math.Abs(e-test.expected) > 0.0001 {
+			t.Fatalf("Expected %f bits for '%s', got %f", test.expected, test.data, e)
+		}
+	}
+}
+
+func TestDNSSplitName(t *testing.T) {
+	tests := []struct {
+		name   string
+		domain string
+		sub    string
+	}{
+		{"example.com", "example.com", ""},
+		{"example.com.", "example.com", ""},
+		{"com", "com", ""},
+		{"www.example.com", "example.com", "www"},
+		{"A.B.Example.COM.", "example.com", "a.b"},
+		{"www.example.co.uk", "example.co.uk", "www"},
+		{"example.co.uk", "example.co.uk", ""},
+		{"www.example.com.au", "example.com.au", "www"},
+		{"data.tunnel.example.io", "example.io", "data.tunnel"},
+		{"www.example.de", "example.de", "www"},
+	}
+
+	for _, test := range tests {
+		if domain, sub := dnsSplitName(test.name); domain != test.domain || sub != test.sub {
+			t.Fatalf("Expected '%s' / '%s' for '%s', got '%s' / '%s'", test.domain, test.sub, test.name, domain, sub)
+		}
+	}
+}
+
+func TestDNSTunnelDetector(t *testing.T) {
+	now := time.Now()
+	encoded := "mzxw6ytboi3tmnzyhe2dcmrtgq2tmnzy"
+
+	tests := []struct {
+		name    string
+		queries []string
+		reason  string
+	}{
+		{"normal", []string{"www.example.com", "mail.example.com", "www.example.com"}, ""},
+		{"long label", []string{strings.Repeat("a", 60) + ".example.com"}, "60 characters label"},
+		{"entropy", []string{encoded + ".example.com"}, "bits entropy"},
+		{"short high entropy", []string{"x7k9q.example.com"}, ""},
+		{"unique", []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com"}, "more than 3 unique subdomains"},
+		{"queries", []string{"a.example.com", "a.example.com", "a.example.com", "a.example.com", "a.example.com", "a.example.com"}, "6 queries"},
+		{"domain only", []string{"example.com", "example.com", "example.com", "example.com", "example.com", "example.com"}, ""},
+	}
+
+	for _, test := range tests {
+		d := newDNSTunnelDetector(50, 3.5, 3, 5)
+
+		var suspect *DNSTunnelSuspect
+		for i, query := range test.queries {
+			if s := d.Check("192.168.1.2", query, now.Add(time.Duration(i)*time.Second)); s != nil {
+				suspect = s
+			}
+		}
+
+		if test.reason == "" && suspect != nil {
+			t.Fatalf("%s: unexpected suspect %+v", test.name, suspect)
+		} else if test.reason != "" && suspect == nil {
+			t.Fatalf("%s: expected a suspect", test.name)
+		} else if suspect != nil && strings.Contains(strings.Join(suspect.Reasons, ", "), test.reason) == false {
+			t.Fatalf("%s: expected '%s' in %v", test.name, test.reason, suspect.Reasons)
+		} else if suspect != nil && suspect.Domain != "example.com" {
+			t.Fatalf("%s: unexpected domain %s", test.name, suspect.Domain)
+		}
+	}
+}
+
+func TestDNSTunnelDetectorWindow(t *testing.T) {
+	now := time.Now()
+	d := newDNSTunnelDetector(0, 0, 0, 2)
+
+	check := func(client string, at time.Duration) *DNSTunnelSuspect {
+		return d.Check(client, "a.example.com", now.Add(at))
+	}
+
+	check("192.168.1.2", 0)
+	check("192.168.1.2", time.Second)
+	if s := check("192.168.1.2", 2*time.Second); s == nil {
+		t.Fatalf("Expected a suspect after 3 queries.")
+	} else if s.Queries != 3 {
+		t.Fatalf("Expected 3 queries, got %d", s.Queries)
+	}
+
+	// reported once per window
+	if s := check("192.168.1.2", 3*time.Second); s != nil {
+		t.Fatalf("Expected the suspect to be reported once per window.")
+	}
+
+	// clients are accounted separately
+	if s := check("192.168.1.3", 3*time.Second); s != nil {
+		t.Fatalf("Unexpected suspect for another client.")
+	}
+
+	// counters start over in a new window
+	if s := check("192.168.1.2", d.Window+3*time.Second); s != nil {
+		t.Fatalf("Unexpected suspect at the start of a new window: %+v", s)
+	}
+
+	check("192.168.1.2", d.Window+4*time.Second)
+	if s := check("192.168.1.2", d.Window+5*time.Second); s == nil {
+		t.Fatalf("Expected a suspect in the new window.")
+	} else if s.Queries != 3 {
+		t.Fatalf("Expected 3 queries in the new window, got %d", s.Queries)
+	}
+
+	// idle clients are forgotten
+	check("192.168.1.2", 2*d.Window+10*time.Second)
+	if _, found := d.entries["192.168.1.3|example.com"]; found == true {
+		t.Fatalf("Expected the idle client to be forgotten.")
+	} else if len(d.entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(d.entries))
+	}
+}
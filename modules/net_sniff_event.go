@@ -31,6 +31,13 @@ func NewSnifferEvent(t time.Time, proto string, src string, dst string, data Sni
 
 func (e SnifferEvent) Push() {
 	fmt.Printf("%s\n", e.Message)
+	// needed to correlate the event with the devices involved
+	if _, found := e.Data["Source"]; found == false {
+		e.Data["Source"] = e.Source
+	}
+	if _, found := e.Data["Destination"]; found == false {
+		e.Data["Destination"] = e.Destination
+	}
 	session.I.Events.Add("net.sniff.leak."+e.Protocol, e.Data)
 	session.I.Refresh()
 }
@@ -1,10 +1,13 @@
 package modules
 
 import (
+	"encoding/base64"
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/evilsocket/bettercap-ng/core"
-	"regexp"
+	"github.com/evilsocket/bettercap-ng/session"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -12,6 +15,36 @@ import (
 
 var httpRe = regexp.MustCompile("(?s).*(GET|HEAD|POST|PUT|DELETE|CONNECT|OPTIONS|TRACE|PATCH) (.+) HTTP/\\d\\.\\d.+Host: ([^\\s]+)")
 var uaRe = regexp.MustCompile("(?s).*User-Agent: ([^\\n]+).+")
+var basicAuthRe = regexp.MustCompile("(?i)\\nAuthorization: Basic ([A-Za-z0-9+/=]+)")
+var cookieRe = regexp.MustCompile("(?i)\\nCookie: ([^\\r\\n]+)")
+
+// Stores credentials sent in cleartext with the request.
+func httpCredentials(data []byte, src string, hostname string) {
+	if m := basicAuthRe.FindSubmatch(data); len(m) == 2 {
+		if raw, err := base64.StdEncoding.DecodeString(string(m[1])); err == nil {
+			if parts := strings.SplitN(string(raw), ":", 2); len(parts) == 2 {
+				session.I.Creds.Add(&session.Credential{
+					Type:        session.CredCleartext,
+					Protocol:    "http",
+					Source:      src,
+					Destination: hostname,
+					Username:    parts[0],
+					Password:    parts[1],
+				})
+			}
+		}
+	}
+
+	if m := cookieRe.FindSubmatch(data); len(m) == 2 {
+		session.I.Creds.Add(&session.Credential{
+			Type:        session.CredCookie,
+			Protocol:    "http",
+			Source:      src,
+			Destination: hostname,
+			Password:    strings.TrimSpace(string(m[1])),
+		})
+	}
+}
 
 func httpParser(ip *layers.IPv4, pkt gopacket.Packet, tcp *layers.TCP) bool {
 	data := tcp.Payload
@@ -35,6 +68,8 @@ func httpParser(ip *layers.IPv4, pkt gopacket.Packet, tcp *layers.TCP) bool {
 		ua = string(mu[1])
 	}
 
+	httpCredentials(data, ip.SrcIP.String(), string(m[3]))
+
 	url := fmt.Sprintf("%s", core.Yellow(path))
 	if tcp.DstPort != 80 {
 		url += fmt.Sprintf(":%s", vPort(tcp.DstPort))
@@ -1,8 +1,11 @@
 package modules
 
 import (
-	"github.com/evilsocket/bettercap-ng/log"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/evilsocket/bettercap-ng/log"
 )
 
 type SnifferStats struct {
@@ -13,6 +16,8 @@ type SnifferStats struct {
 	Started     time.Time
 	FirstPacket time.Time
 	LastPacket  time.Time
+
+	lock *sync.Mutex
 }
 
 func NewSnifferStats() *SnifferStats {
@@ -24,10 +29,25 @@ func NewSnifferStats() *SnifferStats {
 		Started:     time.Now(),
 		FirstPacket: time.Time{},
 		LastPacket:  time.Time{},
+		lock:        &sync.Mutex{},
 	}
 }
 
+// Called by the capture workers for each packet.
+func (s *SnifferStats) Seen(now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.FirstPacket.IsZero() {
+		s.FirstPacket = now
+	}
+	s.LastPacket = now
+}
+
 func (s *SnifferStats) Print() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
 	first := "never"
 	last := "never"
 
@@ -42,10 +62,10 @@ func (s *SnifferStats) Print() error {
 	log.Info("Sniffer Started    : %s", s.Started)
 	log.Info("First Packet Seen  : %s", first)
 	log.Info("Last Packet Seen   : %s", last)
-	log.Info("Local Packets      : %d", s.NumLocal)
-	log.Info("Matched Packets    : %d", s.NumMatched)
-	log.Info("Dumped Packets     : %d", s.NumDumped)
-	log.Info("Wrote Packets      : %d", s.NumWrote)
+	log.Info("Local Packets      : %d", atomic.LoadUint64(&s.NumLocal))
+	log.Info("Matched Packets    : %d", atomic.LoadUint64(&s.NumMatched))
+	log.Info("Dumped Packets     : %d", atomic.LoadUint64(&s.NumDumped))
+	log.Info("Wrote Packets      : %d", atomic.LoadUint64(&s.NumWrote))
 
 	return nil
 }
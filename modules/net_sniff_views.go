@@ -23,7 +23,7 @@ func vIP(ip net.IP) string {
 	}
 
 	address := ip.String()
-	host, found := session.I.Targets.Targets[address]
+	host, found := session.I.Targets.ByIP(address)
 
 	if found == true {
 		if host.Hostname != "" {
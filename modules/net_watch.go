@@ -0,0 +1,309 @@
+package modules
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	network "github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+// Emitted when the link of the interface goes down or up, or
+// when one of its addresses or the gateway changes.
+type NetworkChange struct {
+	Interface string `json:"interface"`
+	Field     string `json:"field"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+}
+
+// Modules with their own capture handles on the interface, which
+// don't survive the link going down and are always restarted.
+var netWatchCaptureModules = []string{"arp.watch", "dns.encrypted", "net.sniff", "ra.watch"}
+
+// Follows the configuration of the session interface, so that the
+// session survives link flaps, DHCP renewals and roaming to other
+// networks: the modules depending on it are stopped while the link
+// is down or changing and started again with the new addresses,
+// which also recreates their firewall redirections and capture handles.
+type NetWatcher struct {
+	session.SessionModule
+
+	period  time.Duration
+	modules []string
+	paused  []string
+	down    bool
+	changes uint64
+	quit    chan bool
+}
+
+func NewNetWatcher(s *session.Session) *NetWatcher {
+	w := &NetWatcher{
+		SessionModule: session.NewSessionModule("net.watch", s),
+		paused:        make([]string, 0),
+	}
+
+	w.AddParam(session.NewIntParameter("net.watch.period",
+		"2",
+		"Seconds between checks of the interface and the gateway."))
+
+	w.AddParam(session.NewStringParameter("net.watch.modules",
+		"arp.spoof, dhcp6.spoof, dns.spoof, net.quarantine, http.proxy, https.proxy",
+		"",
+		"Comma separated list of modules to pause while the network changes, the running ones are started again once the new configuration is detected."))
+
+	w.AddHandler(session.NewModuleHandler("net.watch on", "",
+		"Start watching the interface and the gateway for changes.",
+		func(args []string) error {
+			return w.Start()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("net.watch off", "",
+		"Stop watching the interface and the gateway for changes.",
+		func(args []string) error {
+			return w.Stop()
+		}))
+
+	return w
+}
+
+func (w *NetWatcher) Name() string {
+	return "net.watch"
+}
+
+func (w *NetWatcher) Description() string {
+	return "Detects link flaps, address and gateway changes of the interface, pausing and resuming the attacks so that they follow the network."
+}
+
+func (w *NetWatcher) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (w *NetWatcher) Configure() error {
+	var err error
+	var period int
+
+	if err, period = w.IntParam("net.watch.period"); err != nil {
+		return err
+	} else if period < 1 {
+		return fmt.Errorf("net.watch.period must be at least 1 second.")
+	} else if err, w.modules = w.ListParam("net.watch.modules"); err != nil {
+		return err
+	}
+
+	for _, name := range w.modules {
+		if err, _ := w.Session.Module(name); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range netWatchCaptureModules {
+		listed := false
+		for _, other := range w.modules {
+			if other == name {
+				listed = true
+				break
+			}
+		}
+
+		if err, _ := w.Session.Module(name); err == nil && listed == false {
+			w.modules = append(w.modules, name)
+		}
+	}
+
+	w.period = time.Duration(period) * time.Second
+	w.paused = make([]string, 0)
+	w.down = false
+
+	return nil
+}
+
+func (w *NetWatcher) notify(tag string, field string, old string, new string) {
+	atomic.AddUint64(&w.changes, 1)
+	w.Session.Events.Add(tag, NetworkChange{
+		Interface: w.Session.CurrentInterface().Name(),
+		Field:     field,
+		Old:       old,
+		New:       new,
+	})
+}
+
+// Stops the running modules of the list, remembering them.
+func (w *NetWatcher) pause() {
+	for _, name := range w.modules {
+		if err, m := w.Session.Module(name); err == nil && m.Running() == true {
+			log.Info("[%s] pausing %s.", core.Green("net.watch"), name)
+			if err := m.Stop(); err != nil {
+				log.Error("Error while stopping %s: %s", name, err)
+			}
+			w.paused = append(w.paused, name)
+		}
+	}
+}
+
+func (w *NetWatcher) resume() {
+	for _, name := range w.paused {
+		if err, m := w.Session.Module(name); err == nil && m.Running() == false {
+			log.Info("[%s] resuming %s.", core.Green("net.watch"), name)
+			if err := w.Session.StartModule(m); err != nil {
+				log.Error("Error while starting %s: %s", name, err)
+			}
+		}
+	}
+	w.paused = make([]string, 0)
+}
+
+// Returns the description of what changed between the cur and iface
+// configurations of the interface.
+func interfaceChanges(cur *network.Endpoint, iface *network.Endpoint) []NetworkChange {
+	changes := make([]NetworkChange, 0)
+
+	if cur.CIDR() != iface.CIDR() || cur.IpAddress != iface.IpAddress {
+		changes = append(changes, NetworkChange{cur.Name(), "ipv4",
+			fmt.Sprintf("%s/%d", cur.IpAddress, cur.SubnetBits),
+			fmt.Sprintf("%s/%d", iface.IpAddress, iface.SubnetBits)})
+	}
+
+	if cur.Ip6Address != iface.Ip6Address {
+		changes = append(changes, NetworkChange{cur.Name(), "ipv6", cur.Ip6Address, iface.Ip6Address})
+	}
+
+	if cur.HwAddress != iface.HwAddress {
+		changes = append(changes, NetworkChange{cur.Name(), "mac", cur.HwAddress, iface.HwAddress})
+	}
+
+	return changes
+}
+
+// Returns the gateway as found in the routing table, the interface itself
+// if there's none as the session does, or nil if its hardware address
+// isn't known yet.
+func (w *NetWatcher) gateway(iface *network.Endpoint) *network.Endpoint {
+	gw, err := network.FindGateway(iface)
+	if err != nil || gw == nil || gw.IpAddress == iface.IpAddress {
+		return iface
+	} else if gw.HwAddress == "" {
+		// make the kernel resolve it for the next round
+		if con, err := net.Dial("udp", fmt.Sprintf("%s:137", gw.IpAddress)); err == nil {
+			con.Write([]byte{0x00})
+			con.Close()
+		}
+		return nil
+	}
+	return gw
+}
+
+func (w *NetWatcher) check() {
+	cur := w.Session.CurrentInterface()
+	name := cur.Name()
+
+	iface, err := network.FindInterface(name)
+	if err != nil {
+		if w.down == false {
+			log.Warning("[%s] %s is down.", core.Green("net.watch"), name)
+			w.down = true
+			w.notify("net.watch.down", "link", cur.IpAddress, "")
+			w.pause()
+		}
+		return
+	}
+	iface.FirstSeen = cur.FirstSeen
+
+	gw := w.gateway(iface)
+	if gw == nil {
+		// wait for the gateway to be known before resuming anything
+		return
+	}
+
+	changes := make([]NetworkChange, 0)
+	prev := w.Session.CurrentGateway()
+	if (gw == iface) != (prev == cur) || (gw != iface && (gw.IpAddress != prev.IpAddress || gw.HwAddress != prev.HwAddress)) {
+		changes = append(changes, NetworkChange{name, "gateway",
+			fmt.Sprintf("%s %s", prev.IpAddress, prev.HwAddress),
+			fmt.Sprintf("%s %s", gw.IpAddress, gw.HwAddress)})
+	}
+
+	moved := len(changes) > 0 || iface.CIDR() != cur.CIDR() || iface.IpAddress != cur.IpAddress || iface.HwAddress != cur.HwAddress
+	if moved == false && w.down == false {
+		// IPv6 addresses come and go, attacks don't need to be paused for them
+		if iface.Ip6Address != cur.Ip6Address {
+			w.Session.SwapInterface(iface, false)
+			w.report(interfaceChanges(cur, iface))
+		}
+		return
+	}
+
+	if w.down == false {
+		w.pause()
+	}
+
+	// the capture handles are gone with the link, or filter by the old addresses
+	if err := w.Session.SwapInterface(iface, true); err != nil {
+		log.Error("[%s] error while opening %s again: %s", core.Green("net.watch"), name, err)
+		w.down = true
+		return
+	}
+	changes = append(interfaceChanges(cur, iface), changes...)
+
+	if w.down == true {
+		w.down = false
+		log.Info("[%s] %s is up with address %s.", core.Green("net.watch"), name, iface.IpAddress)
+		w.notify("net.watch.up", "link", "", iface.IpAddress)
+	}
+
+	w.report(changes)
+	w.resume()
+}
+
+func (w *NetWatcher) report(changes []NetworkChange) {
+	for _, c := range changes {
+		log.Info("[%s] %s %s changed from '%s' to '%s'.", core.Green("net.watch"), c.Interface, c.Field, c.Old, c.New)
+		w.notify("net.watch.changed", c.Field, c.Old, c.New)
+	}
+}
+
+func (w *NetWatcher) Start() error {
+	if w.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := w.Configure(); err != nil {
+		return err
+	}
+
+	w.quit = make(chan bool)
+	w.SetRunning(true)
+
+	go func(quit chan bool) {
+		defer w.Session.Recover()
+		log.Info("[%s] watching %s every %s.", core.Green("net.watch"), w.Session.CurrentInterface().Name(), w.period)
+
+		for {
+			select {
+			case <-quit:
+				return
+			case <-time.After(w.period):
+				w.check()
+			}
+		}
+	}(w.quit)
+
+	return nil
+}
+
+func (w *NetWatcher) Stop() error {
+	if w.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	close(w.quit)
+	w.SetRunning(false)
+	return nil
+}
+
+func (w *NetWatcher) Metrics() []session.Metric {
+	return []session.Metric{
+		session.NewMetric("net_watch_changes_total", "Link and configuration changes of the interface.", session.MetricCounter, float64(atomic.LoadUint64(&w.changes))),
+	}
+}
@@ -0,0 +1,382 @@
+package modules
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/packets"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// Layers whose checksum covers the addresses of the network layer.
+type checksumLayer interface {
+	SetNetworkLayerForChecksum(gopacket.NetworkLayer) error
+}
+
+type PacketsReplay struct {
+	session.SessionModule
+
+	file   string
+	filter string
+	speed  float64
+	loops  int
+	ipMap  map[string]net.IP
+	macMap map[string]net.HardwareAddr
+	quit   chan bool
+
+	sent    uint64
+	errors  uint64
+	skipped uint64
+}
+
+func NewPacketsReplay(s *session.Session) *PacketsReplay {
+	r := &PacketsReplay{
+		SessionModule: session.NewSessionModule("packets.replay", s),
+		ipMap:         make(map[string]net.IP),
+		macMap:        make(map[string]net.HardwareAddr),
+	}
+
+	r.AddParam(session.NewStringParameter("packets.replay.file",
+		"",
+		"",
+		"Pcap file to replay."))
+
+	r.AddParam(session.NewStringParameter("packets.replay.filter",
+		"",
+		"",
+		"BPF filter to select the packets to replay, all of them if empty."))
+
+	r.AddParam(session.NewStringParameter("packets.replay.speed",
+		"1",
+		`^\d+(\.\d+)?$`,
+		"Multiplier of the original timing, 2 replays twice as fast, 0 sends the packets as fast as possible."))
+
+	r.AddParam(session.NewIntParameter("packets.replay.loop",
+		"1",
+		"How many times to replay the file, 0 to loop until the module is stopped."))
+
+	r.AddParam(session.NewStringParameter("packets.replay.ip.map",
+		"",
+		"",
+		"Comma separated list of FROM=TO addresses to rewrite, e.g. 10.0.0.5=192.168.1.20, checksums are fixed accordingly."))
+
+	r.AddParam(session.NewStringParameter("packets.replay.mac.map",
+		"",
+		"",
+		"Comma separated list of FROM=TO hardware addresses to rewrite, in the ethernet and ARP headers."))
+
+	r.AddHandler(session.NewModuleHandler("packets.replay on", "",
+		"Start replaying the packets of the pcap file.",
+		func(args []string) error {
+			return r.Start()
+		}))
+
+	r.AddHandler(session.NewModuleHandler("packets.replay off", "",
+		"Stop replaying packets.",
+		func(args []string) error {
+			return r.Stop()
+		}))
+
+	return r
+}
+
+func (r *PacketsReplay) Name() string {
+	return "packets.replay"
+}
+
+func (r *PacketsReplay) Description() string {
+	return "Injects the packets of a pcap file with their original timing, optionally rewriting IP and MAC addresses."
+}
+
+func (r *PacketsReplay) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+var replayMapSeparator = regexp.MustCompile(`\s*=\s*`)
+
+// Parses a list of FROM=TO pairs.
+func parseReplayMap(list string, each func(from string, to string) error) error {
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		parts := replayMapSeparator.Split(item, 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid mapping '%s', expected FROM=TO.", item)
+		} else if err := each(parts[0], parts[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PacketsReplay) Configure() error {
+	var err error
+	var speed, ipMap, macMap string
+
+	if err, r.file = r.StringParam("packets.replay.file"); err != nil {
+		return err
+	} else if r.file == "" {
+		return fmt.Errorf("packets.replay.file is empty.")
+	} else if r.file, err = core.ExpandPath(r.file); err != nil {
+		return err
+	} else if err, r.filter = r.StringParam("packets.replay.filter"); err != nil {
+		return err
+	} else if err, speed = r.StringParam("packets.replay.speed"); err != nil {
+		return err
+	} else if r.speed, err = strconv.ParseFloat(speed, 64); err != nil {
+		return err
+	} else if err, r.loops = r.IntParam("packets.replay.loop"); err != nil {
+		return err
+	} else if err, ipMap = r.StringParam("packets.replay.ip.map"); err != nil {
+		return err
+	} else if err, macMap = r.StringParam("packets.replay.mac.map"); err != nil {
+		return err
+	}
+
+	r.ipMap = make(map[string]net.IP)
+	err = parseReplayMap(ipMap, func(from string, to string) error {
+		src := net.ParseIP(from)
+		dst := net.ParseIP(to)
+		if src == nil || dst == nil {
+			return fmt.Errorf("Invalid IP mapping %s=%s.", from, to)
+		} else if (src.To4() == nil) != (dst.To4() == nil) {
+			return fmt.Errorf("Can't map %s to %s, addresses must be of the same family.", from, to)
+		}
+
+		if dst.To4() != nil {
+			dst = dst.To4()
+		}
+		r.ipMap[src.String()] = dst
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.macMap = make(map[string]net.HardwareAddr)
+	err = parseReplayMap(macMap, func(from string, to string) error {
+		src, err := net.ParseMAC(from)
+		if err != nil {
+			return err
+		}
+		dst, err := net.ParseMAC(to)
+		if err != nil {
+			return err
+		}
+		r.macMap[src.String()] = dst
+		return nil
+	})
+
+	return err
+}
+
+func (r *PacketsReplay) open() (*pcap.Handle, error) {
+	handle, err := pcap.OpenOffline(r.file)
+	if err != nil {
+		return nil, err
+	} else if handle.LinkType() != layers.LinkTypeEthernet {
+		handle.Close()
+		return nil, fmt.Errorf("Only ethernet captures can be replayed, %s is %s.", r.file, handle.LinkType())
+	} else if r.filter != "" {
+		if err = handle.SetBPFFilter(r.filter); err != nil {
+			handle.Close()
+			return nil, err
+		}
+	}
+	return handle, nil
+}
+
+func (r *PacketsReplay) mapIP(ip net.IP) net.IP {
+	if to, found := r.ipMap[ip.String()]; found == true {
+		return to
+	}
+	return ip
+}
+
+func (r *PacketsReplay) mapMAC(mac net.HardwareAddr) net.HardwareAddr {
+	if to, found := r.macMap[mac.String()]; found == true {
+		return to
+	}
+	return mac
+}
+
+// Applies the address maps, the packet is serialized again only
+// if something changed so that the rest is sent as it was captured.
+func (r *PacketsReplay) rewrite(data []byte) ([]byte, error) {
+	if len(r.ipMap) == 0 && len(r.macMap) == 0 {
+		return data, nil
+	}
+
+	pkt := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	var network gopacket.NetworkLayer
+
+	for _, layer := range pkt.Layers() {
+		switch l := layer.(type) {
+		case *layers.Ethernet:
+			l.SrcMAC = r.mapMAC(l.SrcMAC)
+			l.DstMAC = r.mapMAC(l.DstMAC)
+
+		case *layers.ARP:
+			l.SourceHwAddress = r.mapMAC(l.SourceHwAddress)
+			l.DstHwAddress = r.mapMAC(l.DstHwAddress)
+			l.SourceProtAddress = r.mapIP(l.SourceProtAddress)
+			l.DstProtAddress = r.mapIP(l.DstProtAddress)
+
+		case *layers.IPv4:
+			l.SrcIP = r.mapIP(l.SrcIP)
+			l.DstIP = r.mapIP(l.DstIP)
+			network = l
+
+		case *layers.IPv6:
+			l.SrcIP = r.mapIP(l.SrcIP)
+			l.DstIP = r.mapIP(l.DstIP)
+			network = l
+		}
+	}
+
+	serializable := make([]gopacket.SerializableLayer, 0)
+	for _, layer := range pkt.Layers() {
+		s, ok := layer.(gopacket.SerializableLayer)
+		if ok == false {
+			return nil, fmt.Errorf("%s layers can't be rewritten", layer.LayerType())
+		}
+
+		if c, ok := layer.(checksumLayer); ok == true && network != nil {
+			c.SetNetworkLayerForChecksum(network)
+		}
+		serializable = append(serializable, s)
+	}
+
+	err, raw := packets.Serialize(serializable...)
+	return raw, err
+}
+
+// Waits for the given time, returns false if the module has been stopped meanwhile.
+func (r *PacketsReplay) wait(d time.Duration) bool {
+	if d <= 0 {
+		return r.Running()
+	}
+
+	select {
+	case <-r.quit:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// Replays the file once, returns false if the module has been stopped.
+func (r *PacketsReplay) replay() (bool, error) {
+	handle, err := r.open()
+	if err != nil {
+		return false, err
+	}
+	defer handle.Close()
+
+	var prev time.Time
+	for {
+		data, ci, err := handle.ReadPacketData()
+		if err == io.EOF {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		if r.speed > 0 && prev.IsZero() == false {
+			if r.wait(time.Duration(float64(ci.Timestamp.Sub(prev))/r.speed)) == false {
+				return false, nil
+			}
+		} else if r.Running() == false {
+			return false, nil
+		}
+		prev = ci.Timestamp
+
+		raw, err := r.rewrite(data)
+		if err != nil {
+			log.Debug("Skipping packet of %s: %s.", ci.Timestamp, err)
+			atomic.AddUint64(&r.skipped, 1)
+			continue
+		}
+
+		r.Session.RateLimit.Wait("packets.replay")
+		if err := r.Session.Queue.Send(raw); err != nil {
+			log.Debug("Error while replaying packet: %s", err)
+			atomic.AddUint64(&r.errors, 1)
+		} else {
+			atomic.AddUint64(&r.sent, 1)
+		}
+	}
+}
+
+func (r *PacketsReplay) Start() error {
+	if r.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := r.Configure(); err != nil {
+		return err
+	}
+
+	// fail early if the file can't be read
+	handle, err := r.open()
+	if err != nil {
+		return err
+	}
+	handle.Close()
+
+	r.quit = make(chan bool)
+	r.SetRunning(true)
+
+	go func(quit chan bool) {
+		defer r.Session.Recover()
+		sent := atomic.LoadUint64(&r.sent)
+		for loop := 0; r.loops == 0 || loop < r.loops; loop++ {
+			if completed, err := r.replay(); err != nil {
+				log.Error("Error while replaying %s: %s", r.file, err)
+				break
+			} else if completed == false {
+				break
+			}
+		}
+
+		log.Info("[%s] %d packets of %s replayed.", core.Green("packets.replay"), atomic.LoadUint64(&r.sent)-sent, r.file)
+
+		// stopped once the replay is over
+		select {
+		case <-quit:
+		default:
+			r.SetRunning(false)
+		}
+	}(r.quit)
+
+	return nil
+}
+
+func (r *PacketsReplay) Stop() error {
+	if r.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	close(r.quit)
+	r.SetRunning(false)
+	return nil
+}
+
+func (r *PacketsReplay) Metrics() []session.Metric {
+	return []session.Metric{
+		session.NewMetric("packets_replay_sent_total", "Packets injected from pcap files.", session.MetricCounter, float64(atomic.LoadUint64(&r.sent))),
+		session.NewMetric("packets_replay_errors_total", "Packets which could not be injected.", session.MetricCounter, float64(atomic.LoadUint64(&r.errors))),
+		session.NewMetric("packets_replay_skipped_total", "Packets which could not be rewritten.", session.MetricCounter, float64(atomic.LoadUint64(&r.skipped))),
+	}
+}
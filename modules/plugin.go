@@ -0,0 +1,358 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+const (
+	// time an external module has to describe itself
+	pluginHelloTimeout = 5 * time.Second
+	// time an external module has to answer a command
+	pluginResultTimeout = 30 * time.Second
+)
+
+var pluginNameParser = regexp.MustCompile(`^[a-z0-9_\-]+(\.[a-z0-9_\-]+)*$`)
+
+// A module implemented by an external process, see plugin_proto.go.
+type ExternalModule struct {
+	session.SessionModule
+
+	path        string
+	description string
+	author      string
+	cmd         *exec.Cmd
+	conn        *pluginConn
+
+	lock    sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan pluginMessage
+	exited  bool
+}
+
+func NewExternalModule(s *session.Session, path string) (*ExternalModule, error) {
+	m := &ExternalModule{
+		path:    path,
+		pending: make(map[uint64]chan pluginMessage),
+	}
+
+	m.cmd = exec.Command(path)
+	in, err := m.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := m.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := m.cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = m.cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	m.conn = newPluginConn(in, out)
+	// a full stderr pipe would block the process before its hello
+	go m.logStderr(stderr)
+
+	timeout := time.AfterFunc(pluginHelloTimeout, func() {
+		m.cmd.Process.Kill()
+	})
+	hello, err := m.conn.Receive()
+	timeout.Stop()
+
+	if err == nil {
+		err = m.setup(s, hello)
+	}
+	if err != nil {
+		m.conn.Close()
+		m.cmd.Process.Kill()
+		m.cmd.Wait()
+		return nil, err
+	}
+
+	go m.worker()
+
+	return m, nil
+}
+
+// Registers the parameters and commands the process declared.
+func (m *ExternalModule) setup(s *session.Session, hello pluginMessage) error {
+	name := hello.Name
+	if hello.Type != pluginMsgHello {
+		return fmt.Errorf("Expected a %s message, got '%s'.", pluginMsgHello, hello.Type)
+	} else if pluginNameParser.MatchString(name) == false {
+		return fmt.Errorf("Invalid module name '%s'.", name)
+	} else if err, _ := s.Module(name); err == nil {
+		return fmt.Errorf("Module %s already exists.", name)
+	}
+
+	m.SessionModule = session.NewSessionModule(name, s)
+	m.description = hello.Description
+	m.author = hello.Author
+
+	for _, p := range hello.Parameters {
+		if strings.HasPrefix(p.Name, name+".") == false {
+			return fmt.Errorf("Parameter %s must start with '%s.'.", p.Name, name)
+		}
+
+		switch p.Type {
+		case "", "string":
+			if _, err := regexp.Compile(p.Validator); err != nil {
+				return fmt.Errorf("Invalid validator for parameter %s: %s", p.Name, err)
+			}
+			m.AddParam(session.NewStringParameter(p.Name, p.Default, p.Validator, p.Description))
+		case "bool":
+			m.AddParam(session.NewBoolParameter(p.Name, p.Default, p.Description))
+		case "int":
+			m.AddParam(session.NewIntParameter(p.Name, p.Default, p.Description))
+		default:
+			return fmt.Errorf("Unknown type '%s' for parameter %s.", p.Type, p.Name)
+		}
+	}
+
+	m.AddHandler(session.NewModuleHandler(name+" on", "",
+		fmt.Sprintf("Start the %s external module.", name),
+		func(args []string) error {
+			return m.Start()
+		}))
+
+	m.AddHandler(session.NewModuleHandler(name+" off", "",
+		fmt.Sprintf("Stop the %s external module.", name),
+		func(args []string) error {
+			return m.Stop()
+		}))
+
+	for _, c := range hello.Commands {
+		if strings.HasPrefix(c.Name, name+".") == false {
+			return fmt.Errorf("Command %s must start with '%s.'.", c.Name, name)
+		} else if _, err := regexp.Compile(c.Parser); err != nil {
+			return fmt.Errorf("Invalid parser for command %s: %s", c.Name, err)
+		}
+
+		command := c.Name
+		m.AddHandler(session.NewModuleHandler(c.Name, c.Parser, c.Description,
+			func(args []string) error {
+				_, err := m.request(pluginMessage{
+					Type:    pluginMsgCommand,
+					Command: command,
+					Args:    args,
+					Params:  m.params(),
+				})
+				return err
+			}))
+	}
+
+	return nil
+}
+
+func (m *ExternalModule) Name() string {
+	return m.SessionModule.Name
+}
+
+func (m *ExternalModule) Description() string {
+	return m.description
+}
+
+func (m *ExternalModule) Author() string {
+	return m.author
+}
+
+// Current values of the module parameters.
+func (m *ExternalModule) params() map[string]string {
+	params := make(map[string]string)
+	for name := range m.Parameters() {
+		_, params[name] = m.Session.Env.Get(name)
+	}
+	return params
+}
+
+func (m *ExternalModule) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		// the name is only known after the hello
+		log.Debug("[%s] %s", filepath.Base(m.path), scanner.Text())
+	}
+}
+
+func (m *ExternalModule) worker() {
+	for {
+		msg, err := m.conn.Receive()
+		if err != nil {
+			if err != io.EOF {
+				log.Error("[%s] %s", m.Name(), err)
+			}
+			break
+		}
+
+		switch msg.Type {
+		case pluginMsgResult:
+			m.lock.Lock()
+			if ch, found := m.pending[msg.ID]; found == true {
+				ch <- msg
+				delete(m.pending, msg.ID)
+			}
+			m.lock.Unlock()
+
+		case pluginMsgEvent:
+			var data interface{}
+			if len(msg.Data) > 0 {
+				if err := json.Unmarshal(msg.Data, &data); err != nil {
+					log.Debug("[%s] invalid event data: %s", m.Name(), err)
+					continue
+				}
+			}
+			// external modules can only fire events of their own, under a
+			// prefix no built in module uses so they can't be mistaken for them
+			m.Session.Events.Add("plugin."+m.Name()+"."+msg.Tag, data)
+
+		case pluginMsgLog:
+			switch msg.Level {
+			case "debug":
+				log.Debug("[%s] %s", m.Name(), msg.Message)
+			case "warning":
+				log.Warning("[%s] %s", m.Name(), msg.Message)
+			case "error":
+				log.Error("[%s] %s", m.Name(), msg.Message)
+			default:
+				log.Info("[%s] %s", m.Name(), msg.Message)
+			}
+
+		default:
+			log.Debug("[%s] unexpected message '%s'.", m.Name(), msg.Type)
+		}
+	}
+
+	m.cmd.Wait()
+
+	m.lock.Lock()
+	m.exited = true
+	for id, ch := range m.pending {
+		close(ch)
+		delete(m.pending, id)
+	}
+	m.lock.Unlock()
+
+	log.Warning("External module %s ( %s ) exited.", core.Bold(m.Name()), m.path)
+	if m.Running() == true {
+		m.SetRunning(false)
+	}
+}
+
+// Sends a message and waits for its result.
+func (m *ExternalModule) request(msg pluginMessage) (pluginMessage, error) {
+	m.lock.Lock()
+	if m.exited == true {
+		m.lock.Unlock()
+		return msg, fmt.Errorf("External module %s exited.", m.Name())
+	}
+	m.nextID++
+	msg.ID = m.nextID
+	ch := make(chan pluginMessage, 1)
+	m.pending[msg.ID] = ch
+	m.lock.Unlock()
+
+	if err := m.conn.Send(msg); err != nil {
+		m.lock.Lock()
+		delete(m.pending, msg.ID)
+		m.lock.Unlock()
+		return msg, err
+	}
+
+	select {
+	case res, ok := <-ch:
+		if ok == false {
+			return res, fmt.Errorf("External module %s exited.", m.Name())
+		} else if res.Error != "" {
+			return res, fmt.Errorf("%s", res.Error)
+		}
+		return res, nil
+
+	case <-time.After(pluginResultTimeout):
+		m.lock.Lock()
+		delete(m.pending, msg.ID)
+		m.lock.Unlock()
+		return msg, fmt.Errorf("External module %s did not answer in %s.", m.Name(), pluginResultTimeout)
+	}
+}
+
+func (m *ExternalModule) Start() error {
+	if m.Running() == true {
+		return session.ErrAlreadyStarted
+	}
+
+	// validate the parameters before sending them
+	for _, p := range m.Parameters() {
+		if err, _ := p.Get(m.Session); err != nil {
+			return err
+		}
+	}
+
+	if _, err := m.request(pluginMessage{Type: pluginMsgStart, Params: m.params()}); err != nil {
+		return err
+	}
+
+	m.SetRunning(true)
+	return nil
+}
+
+func (m *ExternalModule) Stop() error {
+	if m.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+
+	m.SetRunning(false)
+	_, err := m.request(pluginMessage{Type: pluginMsgStop})
+	return err
+}
+
+// Starts and registers every executable in the folder as an external
+// module, the ones failing to do so are reported and skipped.
+func LoadPlugins(s *session.Session, folder string) error {
+	folder, err := core.ExpandPath(folder)
+	if err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() == true {
+			continue
+		} else if runtime.GOOS != "windows" && entry.Mode()&0111 == 0 {
+			continue
+		}
+
+		path := filepath.Join(folder, entry.Name())
+		if err := checkPluginFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Refusing to load external module: %s\n", err)
+		} else if m, err := NewExternalModule(s, path); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not load external module %s: %s\n", path, err)
+		} else {
+			s.Register(m)
+		}
+	}
+
+	return nil
+}
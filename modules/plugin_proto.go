@@ -0,0 +1,105 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// External modules are executables exchanging newline delimited
+// JSON messages with the session over their stdin and stdout.
+const (
+	// plugin -> session, the first message, describes the module
+	pluginMsgHello = "hello"
+	// session -> plugin, answered with a result
+	pluginMsgStart   = "start"
+	pluginMsgStop    = "stop"
+	pluginMsgCommand = "command"
+	// plugin -> session
+	pluginMsgResult = "result"
+	pluginMsgEvent  = "event"
+	pluginMsgLog    = "log"
+
+	pluginMaxMessage = 4 * 1024 * 1024
+)
+
+type pluginParam struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Default     string `json:"default"`
+	Validator   string `json:"validator"`
+	Description string `json:"description"`
+}
+
+type pluginCommand struct {
+	Name        string `json:"name"`
+	Parser      string `json:"parser"`
+	Description string `json:"description"`
+}
+
+type pluginMessage struct {
+	Type string `json:"type"`
+	ID   uint64 `json:"id,omitempty"`
+
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Author      string          `json:"author,omitempty"`
+	Parameters  []pluginParam   `json:"parameters,omitempty"`
+	Commands    []pluginCommand `json:"commands,omitempty"`
+
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Params  map[string]string `json:"params,omitempty"`
+
+	Error   string          `json:"error,omitempty"`
+	Tag     string          `json:"tag,omitempty"`
+	Level   string          `json:"level,omitempty"`
+	Message string          `json:"message,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type pluginConn struct {
+	in      io.WriteCloser
+	scanner *bufio.Scanner
+	lock    *sync.Mutex
+}
+
+func newPluginConn(in io.WriteCloser, out io.Reader) *pluginConn {
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64*1024), pluginMaxMessage)
+
+	return &pluginConn{
+		in:      in,
+		scanner: scanner,
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (c *pluginConn) Send(msg pluginMessage) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, err = c.in.Write(append(raw, '\n'))
+	return err
+}
+
+func (c *pluginConn) Receive() (msg pluginMessage, err error) {
+	if c.scanner.Scan() == false {
+		if err = c.scanner.Err(); err == nil {
+			err = io.EOF
+		}
+		return
+	}
+	err = json.Unmarshal(c.scanner.Bytes(), &msg)
+	return
+}
+
+func (c *pluginConn) Close() error {
+	return c.in.Close()
+}
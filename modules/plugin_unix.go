@@ -0,0 +1,58 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// External modules run with the privileges of the session, so they and the
+// folders they are in must only be writable by root or by the user running
+// it ( even through sudo ), or they could be replaced before being executed.
+func checkPluginFile(path string) error {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	// symlinks are checked on their target
+	for _, check := range []string{path, filepath.Dir(path), filepath.Dir(target)} {
+		if err := checkPluginOwner(check); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkPluginOwner(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	} else if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s is writable by group or others (%s).", path, info.Mode().Perm())
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if ok == false {
+		return fmt.Errorf("Could not read the owner of %s.", path)
+	}
+
+	owners := []uint32{0, uint32(os.Getuid())}
+	if uid, err := strconv.ParseUint(os.Getenv("SUDO_UID"), 10, 32); err == nil {
+		owners = append(owners, uint32(uid))
+	}
+
+	for _, uid := range owners {
+		if stat.Uid == uid {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is owned by uid %d, neither root nor the current user.", path, stat.Uid)
+}
@@ -0,0 +1,6 @@
+package modules
+
+// File permissions are not checked on Windows.
+func checkPluginFile(path string) error {
+	return nil
+}
@@ -0,0 +1,380 @@
+package modules
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	network "github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// Recursive DNS server option, RFC 8106.
+const icmpv6OptRDNSS = layers.ICMPv6Opt(25)
+
+// A router as described by its last advertisement.
+type IPv6Router struct {
+	Address    string    `json:"address"`
+	MAC        string    `json:"mac"`
+	Vendor     string    `json:"vendor"`
+	Preference string    `json:"preference"`
+	Lifetime   uint16    `json:"lifetime"`
+	Managed    bool      `json:"managed"`
+	Other      bool      `json:"other"`
+	MTU        uint32    `json:"mtu"`
+	Prefixes   []string  `json:"prefixes"`
+	DNS        []string  `json:"dns"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Adverts    uint64    `json:"adverts"`
+	Rogue      bool      `json:"rogue"`
+}
+
+// Emitted for new routers and when the advertisement of a known one changes.
+type RAWatchEvent struct {
+	Router  IPv6Router `json:"router"`
+	Changes []string   `json:"changes"`
+}
+
+type RAWatcher struct {
+	session.SessionModule
+	handle  *pcap.Handle
+	trusted []string
+	routers map[string]*IPv6Router
+	lock    sync.Mutex
+}
+
+func NewRAWatcher(s *session.Session) *RAWatcher {
+	w := &RAWatcher{
+		SessionModule: session.NewSessionModule("ra.watch", s),
+		trusted:       make([]string, 0),
+		routers:       make(map[string]*IPv6Router),
+	}
+
+	w.AddParam(session.NewStringParameter("ra.watch.interface",
+		"",
+		"",
+		"If set, watch the router advertisements of this interface instead of the session one."))
+
+	w.AddParam(session.NewStringParameter("ra.watch.routers",
+		"",
+		"",
+		"Comma separated list of the addresses or MACs of the legit routers, if set any other router is reported as rogue."))
+
+	w.AddHandler(session.NewModuleHandler("ra.watch on", "",
+		"Start watching IPv6 router advertisements.",
+		func(args []string) error {
+			return w.Start()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("ra.watch off", "",
+		"Stop watching IPv6 router advertisements.",
+		func(args []string) error {
+			return w.Stop()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("ra.watch.show", "",
+		"Show the IPv6 routers seen on the link.",
+		func(args []string) error {
+			return w.Show()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("ra.watch.clear", "",
+		"Clear the IPv6 routers table.",
+		func(args []string) error {
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			w.routers = make(map[string]*IPv6Router)
+			return nil
+		}))
+
+	return w
+}
+
+func (w *RAWatcher) Name() string {
+	return "ra.watch"
+}
+
+func (w *RAWatcher) Description() string {
+	return "Records the IPv6 router advertisements seen on the link and reports new or rogue routers and changed prefixes."
+}
+
+func (w *RAWatcher) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (w *RAWatcher) Configure() error {
+	var err error
+	var iface *network.Endpoint
+
+	if err, w.trusted = w.ListParam("ra.watch.routers"); err != nil {
+		return err
+	}
+
+	for i, router := range w.trusted {
+		w.trusted[i] = strings.ToLower(router)
+	}
+
+	if err, iface = w.InterfaceParam("ra.watch.interface"); err != nil {
+		return err
+	} else if w.handle, err = pcap.OpenLive(iface.Name(), 65536, true, pcap.BlockForever); err != nil {
+		return err
+	} else if err = w.handle.SetBPFFilter("icmp6"); err != nil {
+		w.handle.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (w *RAWatcher) isTrusted(router *IPv6Router) bool {
+	if len(w.trusted) == 0 {
+		return true
+	}
+
+	for _, t := range w.trusted {
+		if t == router.Address || t == router.MAC {
+			return true
+		}
+	}
+	return false
+}
+
+// Router preference of RFC 4191, the reserved value is treated as medium.
+func raPreference(flags uint8) string {
+	switch (flags >> 3) & 0x03 {
+	case 1:
+		return "high"
+	case 3:
+		return "low"
+	}
+	return "medium"
+}
+
+func parseRA(from net.IP, srcMAC net.HardwareAddr, ra *layers.ICMPv6RouterAdvertisement) *IPv6Router {
+	router := &IPv6Router{
+		Address:    from.String(),
+		MAC:        srcMAC.String(),
+		Preference: raPreference(ra.Flags),
+		Lifetime:   ra.RouterLifetime,
+		Managed:    ra.ManagedAddressConfig(),
+		Other:      ra.OtherConfig(),
+		Prefixes:   make([]string, 0),
+		DNS:        make([]string, 0),
+	}
+
+	for _, opt := range ra.Options {
+		switch opt.Type {
+		case layers.ICMPv6OptSourceAddress:
+			if len(opt.Data) >= 6 {
+				router.MAC = net.HardwareAddr(opt.Data[:6]).String()
+			}
+
+		case layers.ICMPv6OptMTU:
+			if len(opt.Data) >= 6 {
+				router.MTU = binary.BigEndian.Uint32(opt.Data[2:6])
+			}
+
+		case layers.ICMPv6OptPrefixInfo:
+			// length, flags, valid and preferred lifetimes, reserved, prefix
+			if len(opt.Data) >= 30 {
+				prefix := &net.IPNet{
+					IP:   net.IP(opt.Data[14:30]),
+					Mask: net.CIDRMask(int(opt.Data[0]), 128),
+				}
+				router.Prefixes = append(router.Prefixes, prefix.String())
+			}
+
+		case icmpv6OptRDNSS:
+			// reserved, lifetime, addresses
+			if len(opt.Data) >= 6 {
+				for data := opt.Data[6:]; len(data) >= 16; data = data[16:] {
+					router.DNS = append(router.DNS, net.IP(data[:16]).String())
+				}
+			}
+		}
+	}
+
+	router.Vendor = network.OuiLookup(router.MAC)
+
+	sort.Strings(router.Prefixes)
+	sort.Strings(router.DNS)
+
+	return router
+}
+
+// Describes what changed between two advertisements of the same router.
+func raChanges(prev *IPv6Router, curr *IPv6Router) []string {
+	changes := make([]string, 0)
+
+	if prev.MAC != curr.MAC {
+		changes = append(changes, fmt.Sprintf("MAC %s -> %s", prev.MAC, curr.MAC))
+	}
+	if strings.Join(prev.Prefixes, ",") != strings.Join(curr.Prefixes, ",") {
+		changes = append(changes, fmt.Sprintf("prefixes %s -> %s", strings.Join(prev.Prefixes, ", "), strings.Join(curr.Prefixes, ", ")))
+	}
+	if strings.Join(prev.DNS, ",") != strings.Join(curr.DNS, ",") {
+		changes = append(changes, fmt.Sprintf("DNS %s -> %s", strings.Join(prev.DNS, ", "), strings.Join(curr.DNS, ", ")))
+	}
+	if prev.Preference != curr.Preference {
+		changes = append(changes, fmt.Sprintf("preference %s -> %s", prev.Preference, curr.Preference))
+	}
+	if (prev.Lifetime == 0) != (curr.Lifetime == 0) {
+		// a zero lifetime means it's not a default router anymore
+		changes = append(changes, fmt.Sprintf("lifetime %d -> %d", prev.Lifetime, curr.Lifetime))
+	}
+	if prev.Managed != curr.Managed || prev.Other != curr.Other {
+		changes = append(changes, fmt.Sprintf("flags %s -> %s", raFlags(prev), raFlags(curr)))
+	}
+
+	return changes
+}
+
+func raFlags(router *IPv6Router) string {
+	flags := ""
+	if router.Managed == true {
+		flags += "M"
+	}
+	if router.Other == true {
+		flags += "O"
+	}
+	if flags == "" {
+		flags = "-"
+	}
+	return flags
+}
+
+func (w *RAWatcher) onPacket(pkt gopacket.Packet) {
+	leth := pkt.Layer(layers.LayerTypeEthernet)
+	lip6 := pkt.Layer(layers.LayerTypeIPv6)
+	lra := pkt.Layer(layers.LayerTypeICMPv6RouterAdvertisement)
+	if leth == nil || lip6 == nil || lra == nil {
+		return
+	}
+
+	eth := leth.(*layers.Ethernet)
+	ip6 := lip6.(*layers.IPv6)
+	router := parseRA(ip6.SrcIP, eth.SrcMAC, lra.(*layers.ICMPv6RouterAdvertisement))
+	router.Rogue = w.isTrusted(router) == false
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	now := time.Now()
+	prev, found := w.routers[router.Address]
+	if found == false {
+		router.FirstSeen = now
+		router.LastSeen = now
+		router.Adverts = 1
+		w.routers[router.Address] = router
+
+		if router.Rogue == true {
+			log.Warning("[%s] Rogue router %s (%s) advertising %s.", core.Red("ra.watch"), core.Bold(router.Address), router.MAC, strings.Join(router.Prefixes, ", "))
+		} else {
+			log.Info("[%s] New router %s (%s) advertising %s.", core.Green("ra.watch"), core.Bold(router.Address), router.MAC, strings.Join(router.Prefixes, ", "))
+		}
+
+		w.Session.Events.Add("ra.watch.router.new", RAWatchEvent{Router: *router, Changes: []string{}})
+		return
+	}
+
+	router.FirstSeen = prev.FirstSeen
+	router.LastSeen = now
+	router.Adverts = prev.Adverts + 1
+	w.routers[router.Address] = router
+
+	if changes := raChanges(prev, router); len(changes) > 0 {
+		log.Warning("[%s] Router %s changed its advertisement: %s.", core.Yellow("ra.watch"), core.Bold(router.Address), strings.Join(changes, ", "))
+		w.Session.Events.Add("ra.watch.router.changed", RAWatchEvent{Router: *router, Changes: changes})
+	}
+}
+
+func (w *RAWatcher) Show() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.routers) == 0 {
+		fmt.Println(core.Dim("No IPv6 routers seen so far."))
+		return nil
+	}
+
+	routers := make([]*IPv6Router, 0, len(w.routers))
+	for _, router := range w.routers {
+		routers = append(routers, router)
+	}
+	sort.Slice(routers, func(i, j int) bool {
+		return routers[i].FirstSeen.Before(routers[j].FirstSeen)
+	})
+
+	data := make([][]string, len(routers))
+	for i, router := range routers {
+		address := router.Address
+		if router.Rogue == true {
+			address = core.Red(address)
+		}
+
+		data[i] = []string{
+			address,
+			router.MAC,
+			router.Vendor,
+			router.Preference,
+			fmt.Sprintf("%ds", router.Lifetime),
+			raFlags(router),
+			strings.Join(router.Prefixes, ", "),
+			strings.Join(router.DNS, ", "),
+			fmt.Sprintf("%d", router.Adverts),
+			router.LastSeen.Format("15:04:05"),
+		}
+	}
+
+	core.AsTable(os.Stdout, []string{"Router", "MAC", "Vendor", "Preference", "Lifetime", "Flags", "Prefixes", "DNS", "Adverts", "Last Seen"}, data)
+
+	fmt.Println()
+
+	return nil
+}
+
+func (w *RAWatcher) Start() error {
+	if w.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := w.Configure(); err != nil {
+		return err
+	}
+
+	w.SetRunning(true)
+
+	go func(handle *pcap.Handle) {
+		defer w.Session.Recover()
+		defer handle.Close()
+
+		src := gopacket.NewPacketSource(handle, handle.LinkType())
+		for packet := range src.Packets() {
+			if w.Running() == false {
+				break
+			}
+
+			w.onPacket(packet)
+		}
+	}(w.handle)
+
+	return nil
+}
+
+func (w *RAWatcher) Stop() error {
+	if w.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	w.SetRunning(false)
+	return nil
+}
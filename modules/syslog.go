@@ -0,0 +1,246 @@
+//go:build !windows
+// +build !windows
+
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"security": syslog.LOG_AUTHPRIV,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+var syslogSeverities = map[string]syslog.Priority{
+	"debug":   syslog.LOG_DEBUG,
+	"info":    syslog.LOG_INFO,
+	"notice":  syslog.LOG_NOTICE,
+	"warning": syslog.LOG_WARNING,
+	"err":     syslog.LOG_ERR,
+	"crit":    syslog.LOG_CRIT,
+}
+
+// how log messages levels are mapped to syslog severities
+var syslogLevels = map[int]syslog.Priority{
+	core.DEBUG:     syslog.LOG_DEBUG,
+	core.INFO:      syslog.LOG_INFO,
+	core.IMPORTANT: syslog.LOG_NOTICE,
+	core.WARNING:   syslog.LOG_WARNING,
+	core.ERROR:     syslog.LOG_ERR,
+	core.FATAL:     syslog.LOG_CRIT,
+}
+
+type SyslogSink struct {
+	session.SessionModule
+	writer   *syslog.Writer
+	include  []string
+	exclude  []string
+	severity syslog.Priority
+	quit     chan bool
+}
+
+func NewSyslogSink(s *session.Session) *SyslogSink {
+	sink := &SyslogSink{
+		SessionModule: session.NewSessionModule("syslog", s),
+		writer:        nil,
+		include:       make([]string, 0),
+		exclude:       make([]string, 0),
+		severity:      syslog.LOG_NOTICE,
+		quit:          make(chan bool),
+	}
+
+	sink.AddParam(session.NewStringParameter("syslog.address",
+		"",
+		"",
+		"Address of a remote syslog server as HOST:PORT, if empty the local syslog daemon will be used."))
+
+	sink.AddParam(session.NewStringParameter("syslog.protocol",
+		"udp",
+		"^(udp|tcp)$",
+		"Protocol to use with a remote syslog server, udp or tcp."))
+
+	sink.AddParam(session.NewStringParameter("syslog.facility",
+		"local0",
+		"^(kern|user|daemon|auth|syslog|security|local[0-7])$",
+		"Syslog facility."))
+
+	sink.AddParam(session.NewStringParameter("syslog.severity",
+		"notice",
+		"^(debug|info|notice|warning|err|crit)$",
+		"Syslog severity of events which are not log messages, log messages severity depends on their level."))
+
+	sink.AddParam(session.NewStringParameter("syslog.tag",
+		core.Name,
+		"",
+		"Syslog tag."))
+
+	sink.AddParam(session.NewStringParameter("syslog.filter",
+		"",
+		"",
+		"If filled, only send events whose type starts with one of these comma separated prefixes."))
+
+	sink.AddParam(session.NewStringParameter("syslog.exclude",
+		"",
+		"",
+		"If filled, do not send events whose type starts with one of these comma separated prefixes."))
+
+	sink.AddHandler(session.NewModuleHandler("syslog on", "",
+		"Start sending events to syslog.",
+		func(args []string) error {
+			return sink.Start()
+		}))
+
+	sink.AddHandler(session.NewModuleHandler("syslog off", "",
+		"Stop sending events to syslog.",
+		func(args []string) error {
+			return sink.Stop()
+		}))
+
+	return sink
+}
+
+func (s SyslogSink) Name() string {
+	return "syslog"
+}
+
+func (s SyslogSink) Description() string {
+	return "Send session events and log messages to a local or remote syslog server."
+}
+
+func (s SyslogSink) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (s *SyslogSink) Configure() (err error) {
+	var address, protocol, facility, severity, tag string
+
+	if err, address = s.StringParam("syslog.address"); err != nil {
+		return err
+	} else if err, protocol = s.StringParam("syslog.protocol"); err != nil {
+		return err
+	} else if err, facility = s.StringParam("syslog.facility"); err != nil {
+		return err
+	} else if err, severity = s.StringParam("syslog.severity"); err != nil {
+		return err
+	} else if err, tag = s.StringParam("syslog.tag"); err != nil {
+		return err
+	} else if err, s.include = s.ListParam("syslog.filter"); err != nil {
+		return err
+	} else if err, s.exclude = s.ListParam("syslog.exclude"); err != nil {
+		return err
+	}
+
+	s.severity = syslogSeverities[severity]
+
+	if address == "" {
+		protocol = ""
+	}
+
+	if s.writer, err = syslog.Dial(protocol, address, syslogFacilities[facility]|s.severity, tag); err != nil {
+		return fmt.Errorf("Error while connecting to syslog: %s.", err)
+	}
+
+	return nil
+}
+
+func (s *SyslogSink) accept(e session.Event) bool {
+	if len(s.include) > 0 && hasPrefix(e.Tag, s.include) == false {
+		return false
+	}
+	return hasPrefix(e.Tag, s.exclude) == false
+}
+
+func (s *SyslogSink) send(e session.Event) error {
+	severity := s.severity
+	message := ""
+
+	if log, ok := e.Data.(session.LogMessage); ok == true && e.Tag == "sys.log" {
+		severity = syslogLevels[log.Level]
+		message = core.StripColors(log.Message)
+	} else if e.Data == nil {
+		message = e.Tag
+	} else if raw, err := json.Marshal(e.Data); err != nil {
+		message = fmt.Sprintf("%s %v", e.Tag, e.Data)
+	} else {
+		message = fmt.Sprintf("%s %s", e.Tag, raw)
+	}
+
+	message = strings.Replace(message, "\n", " ", -1)
+
+	switch severity {
+	case syslog.LOG_DEBUG:
+		return s.writer.Debug(message)
+	case syslog.LOG_INFO:
+		return s.writer.Info(message)
+	case syslog.LOG_NOTICE:
+		return s.writer.Notice(message)
+	case syslog.LOG_WARNING:
+		return s.writer.Warning(message)
+	case syslog.LOG_ERR:
+		return s.writer.Err(message)
+	default:
+		return s.writer.Crit(message)
+	}
+}
+
+func (s *SyslogSink) Start() error {
+	if s.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := s.Configure(); err != nil {
+		return err
+	}
+
+	s.SetRunning(true)
+
+	go func() {
+		listener := s.Session.Events.Listen()
+		defer s.Session.Events.Unlisten(listener)
+
+		for {
+			select {
+			case e := <-listener:
+				if s.accept(e) == true {
+					if err := s.send(e); err != nil {
+						// don't log, it would generate yet another event
+						fmt.Printf("Error while sending %s event to syslog: %s\n", e.Tag, err)
+					}
+				}
+
+			case <-s.quit:
+				s.writer.Close()
+				s.writer = nil
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *SyslogSink) Stop() error {
+	if s.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	s.SetRunning(false)
+	s.quit <- true
+	return nil
+}
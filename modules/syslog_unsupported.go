@@ -0,0 +1,60 @@
+//go:build windows
+// +build windows
+
+package modules
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+var errSyslogNotSupported = fmt.Errorf("Syslog is not supported on this platform.")
+
+type SyslogSink struct {
+	session.SessionModule
+}
+
+func NewSyslogSink(s *session.Session) *SyslogSink {
+	sink := &SyslogSink{
+		SessionModule: session.NewSessionModule("syslog", s),
+	}
+
+	sink.AddHandler(session.NewModuleHandler("syslog on", "",
+		"Start sending events to syslog.",
+		func(args []string) error {
+			return sink.Start()
+		}))
+
+	sink.AddHandler(session.NewModuleHandler("syslog off", "",
+		"Stop sending events to syslog.",
+		func(args []string) error {
+			return sink.Stop()
+		}))
+
+	return sink
+}
+
+func (s SyslogSink) Name() string {
+	return "syslog"
+}
+
+func (s SyslogSink) Description() string {
+	return "Send session events and log messages to a local or remote syslog server."
+}
+
+func (s SyslogSink) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (s *SyslogSink) Configure() error {
+	return errSyslogNotSupported
+}
+
+func (s *SyslogSink) Start() error {
+	return errSyslogNotSupported
+}
+
+func (s *SyslogSink) Stop() error {
+	return errSyslogNotSupported
+}
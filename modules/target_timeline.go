@@ -0,0 +1,130 @@
+package modules
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	network "github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+// Strips the port from addresses like 192.168.1.2:1234 or [fe80::1]:1234.
+func hostOf(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// Returns true if the event describes the activity of the device with
+// the given addresses, mac can be empty if the device is not known.
+func eventInvolves(e session.Event, ip string, mac string) bool {
+	// the data of events with an unexpected type is ignored
+	switch e.Tag {
+	case "target.new", "target.lost", "target.resolved":
+		t, ok := e.Data.(*network.Endpoint)
+		return ok && (t.IpAddress == ip || (mac != "" && t.HwAddress == mac))
+
+	case "session.diff.new", "session.diff.lost":
+		t, ok := e.Data.(session.SessionTarget)
+		return ok && (t.IpAddress == ip || (mac != "" && t.HwAddress == mac))
+
+	case "session.diff.changed":
+		c, ok := e.Data.(session.TargetChange)
+		return ok && (c.IpAddress == ip || (mac != "" && c.HwAddress == mac))
+
+	case "creds.new":
+		cred, ok := e.Data.(*session.Credential)
+		return ok && hostOf(cred.Source) == ip
+
+	case "http.server.request":
+		req, ok := e.Data.(HttpServerRequest)
+		return ok && hostOf(req.Address) == ip
+
+	case "dns.server.query":
+		q, ok := e.Data.(DNSServerQuery)
+		return ok && hostOf(q.Client) == ip
+
+	case "dns.spoof":
+		// the target is the hardware address unless the endpoint is known
+		spoof, ok := e.Data.(SpoofEvent)
+		return ok && ((mac != "" && spoof.Target == mac) || strings.HasPrefix(spoof.Target, ip+" "))
+
+	case "dns.encrypted.detected":
+		ev, ok := e.Data.(EncryptedDNSEvent)
+		return ok && ev.Client == ip
+
+	case "dns.tunnel-suspect":
+		ev, ok := e.Data.(DNSTunnelSuspect)
+		return ok && ev.Client == ip
+
+	case "ftp.honeypot.upload":
+		up, ok := e.Data.(FTPUpload)
+		return ok && up.Client == ip
+
+	case "arp.watch.spoof":
+		alert, ok := e.Data.(ArpSpoofAlert)
+		return ok && alert.IP == ip
+	}
+
+	if strings.HasPrefix(e.Tag, "net.sniff.leak.") {
+		if data, ok := e.Data.(SniffData); ok == true {
+			for _, key := range []string{"Source", "Destination"} {
+				if addr, ok := data[key].(string); ok == true && hostOf(addr) == ip {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns the events involving a device from the oldest to the most recent.
+func targetTimeline(s *session.Session, ip string) []session.Event {
+	mac := ""
+	if t, found := s.Targets.ByIP(ip); found == true {
+		mac = t.HwAddress
+	} else if s.Interface.IpAddress == ip {
+		mac = s.Interface.HwAddress
+	} else if s.Gateway != nil && s.Gateway.IpAddress == ip {
+		mac = s.Gateway.HwAddress
+	}
+
+	events := s.Events.Events()
+	timeline := make([]session.Event, 0)
+	for i := len(events) - 1; i >= 0; i-- {
+		if eventInvolves(events[i], ip, mac) == true {
+			timeline = append(timeline, events[i])
+		}
+	}
+
+	return timeline
+}
+
+func showTargetTimeline(s *session.Session, ip string) error {
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("'%s' is not a valid IP address.", ip)
+	}
+
+	name := ip
+	if t, found := s.Targets.ByIP(ip); found == true {
+		name = t.String()
+	}
+
+	timeline := targetTimeline(s, ip)
+	if len(timeline) == 0 {
+		fmt.Println(core.Dim(fmt.Sprintf("No activity recorded for %s.", name)))
+		return nil
+	}
+
+	fmt.Printf("\n%s (%d events)\n\n", core.Bold(name), len(timeline))
+	for _, e := range timeline {
+		fmt.Println(viewEvent(e))
+	}
+	fmt.Println()
+
+	return nil
+}
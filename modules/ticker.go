@@ -0,0 +1,115 @@
+package modules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+type Ticker struct {
+	session.SessionModule
+	period   time.Duration
+	commands []string
+}
+
+func NewTicker(s *session.Session) *Ticker {
+	t := &Ticker{
+		SessionModule: session.NewSessionModule("ticker", s),
+		period:        time.Second,
+		commands:      make([]string, 0),
+	}
+
+	t.AddParam(session.NewStringParameter("ticker.commands",
+		"clear; net.show",
+		"",
+		"List of commands to execute, separated by a semicolon."))
+
+	t.AddParam(session.NewIntParameter("ticker.period",
+		"1",
+		"Ticker period in seconds."))
+
+	t.AddHandler(session.NewModuleHandler("ticker on", "",
+		"Start the ticker.",
+		func(args []string) error {
+			return t.Start()
+		}))
+
+	t.AddHandler(session.NewModuleHandler("ticker off", "",
+		"Stop the ticker.",
+		func(args []string) error {
+			return t.Stop()
+		}))
+
+	return t
+}
+
+func (t Ticker) Name() string {
+	return "ticker"
+}
+
+func (t Ticker) Description() string {
+	return "A module to execute one or more commands every given amount of seconds."
+}
+
+func (t Ticker) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (t *Ticker) Configure() error {
+	var err error
+	var commands string
+	var period int
+
+	if err, commands = t.StringParam("ticker.commands"); err != nil {
+		return err
+	} else if err, period = t.IntParam("ticker.period"); err != nil {
+		return err
+	} else if period < 1 {
+		return fmt.Errorf("Ticker period must be at least 1 second.")
+	}
+
+	t.commands = session.ParseCommands(commands)
+	if len(t.commands) == 0 {
+		return fmt.Errorf("No commands to execute.")
+	}
+
+	t.period = time.Duration(period) * time.Second
+
+	return nil
+}
+
+func (t *Ticker) Start() error {
+	if t.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := t.Configure(); err != nil {
+		return err
+	}
+
+	t.SetRunning(true)
+
+	go func() {
+		log.Info("Ticker running with period %s.", t.period)
+
+		for t.Running() {
+			for _, cmd := range t.commands {
+				if err := t.Session.Run(cmd); err != nil {
+					log.Error("%s", err)
+				}
+			}
+
+			time.Sleep(t.period)
+		}
+	}()
+
+	return nil
+}
+
+func (t *Ticker) Stop() error {
+	if t.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	t.SetRunning(false)
+	return nil
+}
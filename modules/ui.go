@@ -0,0 +1,178 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+	"github.com/evilsocket/bettercap-ng/tls"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebUI struct {
+	session.SessionModule
+	router   *gin.Engine
+	server   *http.Server
+	certFile string
+	keyFile  string
+}
+
+func NewWebUI(s *session.Session) *WebUI {
+	ui := &WebUI{
+		SessionModule: session.NewSessionModule("ui", s),
+		server:        &http.Server{},
+	}
+
+	ui.AddParam(session.NewStringParameter("ui.address",
+		session.ParamIfaceAddress,
+		session.IPv4Validator,
+		"Address to bind the web UI to."))
+
+	ui.AddParam(session.NewIntParameter("ui.port",
+		"8084",
+		"Port to bind the web UI to."))
+
+	ui.AddParam(session.NewStringParameter("ui.username",
+		"",
+		".+",
+		"Web UI authentication username."))
+
+	ui.AddParam(session.NewSecretParameter("ui.password",
+		"",
+		".+",
+		"Web UI authentication password."))
+
+	ui.AddParam(session.NewStringParameter("ui.certificate",
+		"~/.bcap-ui.certificate.pem",
+		"",
+		"Web UI TLS certificate."))
+
+	ui.AddParam(session.NewStringParameter("ui.key",
+		"~/.bcap-ui.key.pem",
+		"",
+		"Web UI TLS key"))
+
+	ui.AddHandler(session.NewModuleHandler("ui on", "",
+		"Start the web UI.",
+		func(args []string) error {
+			return ui.Start()
+		}))
+
+	ui.AddHandler(session.NewModuleHandler("ui off", "",
+		"Stop the web UI.",
+		func(args []string) error {
+			return ui.Stop()
+		}))
+
+	return ui
+}
+
+func (ui *WebUI) Name() string {
+	return "ui"
+}
+
+func (ui *WebUI) Description() string {
+	return "A web dashboard to show hosts and events, start and stop modules and change their parameters."
+}
+
+func (ui *WebUI) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (ui *WebUI) Configure() error {
+	var err error
+	var username string
+	var password string
+	var ip string
+	var port int
+
+	if err, ip = ui.StringParam("ui.address"); err != nil {
+		return err
+	} else if err, port = ui.IntParam("ui.port"); err != nil {
+		return err
+	}
+	ui.server = &http.Server{Addr: fmt.Sprintf("%s:%d", ip, port)}
+
+	if err, ui.certFile = ui.StringParam("ui.certificate"); err != nil {
+		return err
+	} else if ui.certFile, err = core.ExpandPath(ui.certFile); err != nil {
+		return err
+	}
+
+	if err, ui.keyFile = ui.StringParam("ui.key"); err != nil {
+		return err
+	} else if ui.keyFile, err = core.ExpandPath(ui.keyFile); err != nil {
+		return err
+	}
+
+	if err, username = ui.StringParam("ui.username"); err != nil {
+		return err
+	}
+
+	if err, password = ui.StringParam("ui.password"); err != nil {
+		return err
+	}
+
+	if core.Exists(ui.certFile) == false || core.Exists(ui.keyFile) == false {
+		log.Info("Generating TLS key to %s", ui.keyFile)
+		log.Info("Generating TLS certificate to %s", ui.certFile)
+		if err := tls.Generate(ui.certFile, ui.keyFile); err != nil {
+			return err
+		}
+	} else {
+		log.Info("Loading TLS key from %s", ui.keyFile)
+		log.Info("Loading TLS certificate from %s", ui.certFile)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+
+	ui.router = gin.New()
+	ui.router.Use(SecurityMiddleware())
+	ui.router.Use(gin.BasicAuth(gin.Accounts{username: password}))
+
+	ui.router.GET("/", func(c *gin.Context) {
+		c.Data(200, "text/html; charset=utf-8", []byte(uiIndexHTML))
+	})
+
+	// the dashboard is a client of the REST API
+	setupRestRoutes(ui.router)
+
+	ui.server.Handler = ui.router
+
+	return nil
+}
+
+func (ui *WebUI) Start() error {
+	if ui.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := ui.Configure(); err != nil {
+		return err
+	}
+
+	ui.SetRunning(true)
+	go func() {
+		log.Info("Web UI starting on https://%s", ui.server.Addr)
+		err := ui.server.ListenAndServeTLS(ui.certFile, ui.keyFile)
+		if err != nil && err != http.ErrServerClosed {
+			log.Error("%s", err)
+			ui.SetRunning(false)
+		}
+	}()
+
+	return nil
+}
+
+func (ui *WebUI) Stop() error {
+	if ui.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	ui.SetRunning(false)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	return ui.server.Shutdown(ctx)
+}
@@ -0,0 +1,172 @@
+package modules
+
+// The web UI single page dashboard, it only uses the REST API.
+const uiIndexHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>bettercap-ng</title>
+<style>
+body { font-family: monospace; background: #1e1e1e; color: #ddd; margin: 0; padding: 0 10px 10px 10px; }
+h1 { font-size: 1.3em; } h2 { font-size: 1.1em; border-bottom: 1px solid #444; }
+table { border-collapse: collapse; width: 100%; }
+td, th { border-bottom: 1px solid #333; padding: 4px; text-align: left; vertical-align: top; }
+input { background: #2d2d2d; color: #ddd; border: 1px solid #555; width: 95%; }
+button { background: #444; color: #ddd; border: 1px solid #666; cursor: pointer; }
+.on { color: #6c6; } .off { color: #c66; } .dim { color: #888; }
+#events { height: 300px; overflow-y: scroll; background: #111; padding: 4px; }
+#cmd { width: 80%; }
+.panel { margin-bottom: 20px; }
+@media (min-width: 1000px) { .cols { display: flex; } .cols > div { flex: 1; margin-right: 10px; } }
+</style>
+</head>
+<body>
+<h1>bettercap-ng <span id="iface" class="dim"></span></h1>
+
+<div class="panel">
+  <input id="cmd" placeholder="command, e.g. net.probe on"> <button onclick="runInput()">run</button>
+  <span id="status" class="dim"></span>
+</div>
+
+<div class="cols">
+  <div class="panel">
+    <h2>Hosts</h2>
+    <table><thead><tr><th>IP</th><th>MAC</th><th>Hostname</th><th>Vendor</th><th>Last Seen</th></tr></thead><tbody id="hosts"></tbody></table>
+  </div>
+  <div class="panel">
+    <h2>Events</h2>
+    <div id="events"></div>
+  </div>
+</div>
+
+<div class="panel">
+  <h2>Modules</h2>
+  <table><tbody id="modules"></tbody></table>
+</div>
+
+<script>
+function esc(s) {
+  var d = document.createElement('div');
+  d.textContent = s === undefined || s === null ? '' : String(s);
+  // the output also goes into attributes
+  return d.innerHTML.replace(/"/g, '&quot;').replace(/'/g, '&#39;');
+}
+
+// for strings passed to quoted javascript arguments in attributes, the
+// attribute value is unescaped before the code runs
+function jsq(s) {
+  return esc(String(s).replace(/\\/g, '\\\\').replace(/'/g, "\\'"));
+}
+
+function api(method, path, body, cb) {
+  var xhr = new XMLHttpRequest();
+  xhr.open(method, path);
+  xhr.setRequestHeader('Content-Type', 'application/json');
+  xhr.onload = function() {
+    var obj = null;
+    try { obj = JSON.parse(xhr.responseText); } catch(e) {}
+    if( cb ) cb(xhr.status, obj);
+  };
+  xhr.send(body ? JSON.stringify(body) : null);
+}
+
+function run(cmd) {
+  api('POST', '/api/session', {cmd: cmd}, function(status, res) {
+    document.getElementById('status').textContent = status == 200 ? 'ok: ' + cmd : 'error: ' + (res ? res.msg : status);
+    refresh();
+  });
+}
+
+function runInput() {
+  var input = document.getElementById('cmd');
+  if( input.value ) {
+    run(input.value);
+    input.value = '';
+  }
+}
+
+function setParam(name) {
+  var value = document.getElementById('param-' + name).value;
+  run('set ' + name + ' "' + value + '"');
+}
+
+function showHosts(session) {
+  document.getElementById('iface').textContent = session.interface.hostname + ' ' + session.interface.ipv4 + ' > ' + session.gateway.ipv4;
+  var rows = '', targets = session.targets.Targets || {};
+  Object.keys(targets).forEach(function(mac) {
+    var t = targets[mac];
+    rows += '<tr><td>' + esc(t.ipv4) + '</td><td>' + esc(t.mac) + '</td><td>' + esc(t.hostname) +
+            '</td><td>' + esc(t.vendor) + '</td><td class="dim">' + esc(new Date(t.last_seen).toLocaleTimeString()) + '</td></tr>';
+  });
+  document.getElementById('hosts').innerHTML = rows;
+}
+
+function showModules(modules) {
+  // don't redraw while the user is editing a parameter
+  if( document.activeElement && document.activeElement.id.indexOf('param-') == 0 ) {
+    return;
+  }
+
+  var rows = '';
+  modules.forEach(function(m) {
+    var toggle = m.running ? m.name + ' off' : m.name + ' on';
+    rows += '<tr><td><b>' + esc(m.name) + '</b><br><span class="dim">' + esc(m.description) + '</span></td>' +
+            '<td class="' + (m.running ? 'on' : 'off') + '">' + (m.running ? 'running' : 'stopped') + '</td>' +
+            '<td><button onclick="run(\'' + jsq(toggle) + '\')">' + (m.running ? 'stop' : 'start') + '</button></td><td><table>';
+    m.parameters.forEach(function(p) {
+      rows += '<tr><td title="' + esc(p.description) + '">' + esc(p.name) + '</td>' +
+              '<td><input id="param-' + esc(p.name) + '" value="' + esc(p.value) + '" placeholder="' + esc(p.default) + '"></td>' +
+              '<td><button onclick="setParam(\'' + jsq(p.name) + '\')">set</button></td></tr>';
+    });
+    rows += '</table></td></tr>';
+  });
+  document.getElementById('modules').innerHTML = rows;
+}
+
+function showEvent(e) {
+  var box = document.getElementById('events');
+  var line = document.createElement('div');
+  var data = e.tag == 'sys.log' ? e.data.Message : JSON.stringify(e.data);
+  line.innerHTML = '<span class="dim">' + esc(new Date(e.time).toLocaleTimeString()) + '</span> <b>' + esc(e.tag) + '</b> ' + esc(data);
+  box.appendChild(line);
+  while( box.childNodes.length > 500 ) {
+    box.removeChild(box.firstChild);
+  }
+  box.scrollTop = box.scrollHeight;
+}
+
+function refresh() {
+  api('GET', '/api/session', null, function(status, session) {
+    if( status == 200 ) showHosts(session);
+  });
+  api('GET', '/api/session/modules', null, function(status, modules) {
+    if( status == 200 ) showModules(modules);
+  });
+}
+
+function connect() {
+  var ws = new WebSocket((location.protocol == 'https:' ? 'wss://' : 'ws://') + location.host + '/api/events');
+  ws.onmessage = function(msg) {
+    showEvent(JSON.parse(msg.data));
+  };
+  ws.onclose = function() {
+    setTimeout(connect, 2000);
+  };
+}
+
+document.getElementById('cmd').addEventListener('keyup', function(e) {
+  if( e.keyCode == 13 ) runInput();
+});
+
+api('GET', '/api/events?n=50', null, function(status, events) {
+  if( status == 200 ) events.reverse().forEach(showEvent);
+  connect();
+});
+
+refresh();
+setInterval(refresh, 3000);
+</script>
+</body>
+</html>
+`
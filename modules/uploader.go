@@ -0,0 +1,411 @@
+package modules
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+const uploaderMaxBackoff = 10 * time.Minute
+
+// A finished file produced by a module, such as a pcap.
+type Artifact struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+}
+
+// Notifies that a capture file is complete and can be collected.
+func artifactReady(kind string, path string) {
+	if path, err := filepath.Abs(path); err == nil {
+		session.I.Events.Add("artifact.new", Artifact{Kind: kind, Path: path})
+	}
+}
+
+type Uploader struct {
+	session.SessionModule
+	client  *http.Client
+	url     string
+	token   string
+	chunk   int64
+	remove  bool
+	include []string
+	queue   []Artifact
+	wakeup  chan bool
+	quit    chan bool
+	lock    *sync.Mutex
+}
+
+func NewUploader(s *session.Session) *Uploader {
+	u := &Uploader{
+		SessionModule: session.NewSessionModule("uploader", s),
+		include:       make([]string, 0),
+		queue:         make([]Artifact, 0),
+		lock:          &sync.Mutex{},
+	}
+
+	u.AddParam(session.NewStringParameter("uploader.url",
+		"",
+		"^(https?://.+)?$",
+		"Base URL of the collection point, artifacts are uploaded to URL/HOSTNAME/FILENAME."))
+
+	u.AddParam(session.NewSecretParameter("uploader.token",
+		"",
+		"",
+		"If filled, sent as a bearer token with every request."))
+
+	u.AddParam(session.NewBoolParameter("uploader.tls.insecure",
+		"false",
+		"If true, the server TLS certificate will not be verified."))
+
+	u.AddParam(session.NewIntParameter("uploader.chunk",
+		"1024",
+		"Size in KB of each uploaded chunk."))
+
+	u.AddParam(session.NewBoolParameter("uploader.delete",
+		"false",
+		"If true, artifacts will be deleted locally once uploaded."))
+
+	u.AddParam(session.NewStringParameter("uploader.kinds",
+		"",
+		"",
+		"If filled, only upload artifacts of these comma separated kinds ( pcap, events )."))
+
+	u.AddHandler(session.NewModuleHandler("uploader on", "",
+		"Start uploading finished artifacts.",
+		func(args []string) error {
+			return u.Start()
+		}))
+
+	u.AddHandler(session.NewModuleHandler("uploader off", "",
+		"Stop uploading artifacts.",
+		func(args []string) error {
+			return u.Stop()
+		}))
+
+	u.AddHandler(session.NewModuleHandler("uploader.push FILE", `^uploader\.push\s+(.+)$`,
+		"Queue FILE for upload.",
+		func(args []string) error {
+			if u.Running() == false {
+				return fmt.Errorf("The uploader is not running.")
+			}
+			path, err := core.ExpandPath(args[0])
+			if err != nil {
+				return err
+			} else if core.Exists(path) == false {
+				return fmt.Errorf("%s does not exist.", path)
+			}
+			u.enqueue(Artifact{Kind: "file", Path: path})
+			return nil
+		}))
+
+	u.AddHandler(session.NewModuleHandler("uploader.queue", "",
+		"Show artifacts waiting to be uploaded.",
+		func(args []string) error {
+			return u.Show()
+		}))
+
+	return u
+}
+
+func (u Uploader) Name() string {
+	return "uploader"
+}
+
+func (u Uploader) Description() string {
+	return "Upload finished capture files to a remote collection point."
+}
+
+func (u Uploader) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (u *Uploader) Configure() (err error) {
+	var insecure bool
+	var chunk int
+
+	if err, u.url = u.StringParam("uploader.url"); err != nil {
+		return err
+	} else if err, u.token = u.StringParam("uploader.token"); err != nil {
+		return err
+	} else if err, insecure = u.BoolParam("uploader.tls.insecure"); err != nil {
+		return err
+	} else if err, chunk = u.IntParam("uploader.chunk"); err != nil {
+		return err
+	} else if err, u.remove = u.BoolParam("uploader.delete"); err != nil {
+		return err
+	} else if err, u.include = u.ListParam("uploader.kinds"); err != nil {
+		return err
+	}
+
+	if u.url == "" {
+		return fmt.Errorf("No uploader.url specified.")
+	} else if chunk < 1 {
+		chunk = 1
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "bettercap"
+	}
+
+	u.url = fmt.Sprintf("%s/%s", strings.TrimRight(u.url, "/"), host)
+	u.chunk = int64(chunk) * 1024
+	u.client = &http.Client{
+		Timeout: 60 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+		},
+	}
+
+	return nil
+}
+
+func (u *Uploader) enqueue(a Artifact) {
+	u.lock.Lock()
+	u.queue = append(u.queue, a)
+	u.lock.Unlock()
+
+	select {
+	case u.wakeup <- true:
+	default:
+	}
+}
+
+func (u *Uploader) request(method string, url string, body io.Reader, size int64, contentRange string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = size
+	if contentRange != "" {
+		req.Header.Set("Content-Range", contentRange)
+	}
+	if u.token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.token)
+	}
+
+	return u.client.Do(req)
+}
+
+// Asks the server how many bytes of the file it already has.
+func (u *Uploader) offset(url string) (int64, error) {
+	res, err := u.request("HEAD", url, nil, 0, "")
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return 0, nil
+	} else if res.StatusCode >= 300 {
+		return 0, fmt.Errorf("HEAD %s", res.Status)
+	}
+
+	return strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+}
+
+// Uploads the file in chunks using Content-Range, resuming
+// from whatever the server already received.
+func (u *Uploader) upload(a Artifact) error {
+	file, err := os.Open(a.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	url := fmt.Sprintf("%s/%s", u.url, filepath.Base(a.Path))
+
+	start, err := u.offset(url)
+	if err != nil {
+		return err
+	} else if start > size {
+		return fmt.Errorf("Remote file is bigger than the local one.")
+	} else if start > 0 {
+		log.Debug("Resuming upload of %s from byte %d.", a.Path, start)
+	}
+
+	for start < size || size == 0 {
+		end := start + u.chunk
+		if end > size {
+			end = size
+		}
+
+		contentRange := ""
+		if size > 0 {
+			contentRange = fmt.Sprintf("bytes %d-%d/%d", start, end-1, size)
+		}
+
+		body := io.NewSectionReader(file, start, end-start)
+		res, err := u.request("PUT", url, body, end-start, contentRange)
+		if err != nil {
+			return err
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+
+		if res.StatusCode >= 300 {
+			return fmt.Errorf("PUT %s", res.Status)
+		} else if size == 0 {
+			break
+		}
+
+		start = end
+	}
+
+	return nil
+}
+
+func (u *Uploader) accept(a Artifact) bool {
+	if len(u.include) == 0 {
+		return true
+	}
+	for _, kind := range u.include {
+		if kind == a.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *Uploader) worker(quit chan bool) {
+	backoff := time.Duration(0)
+
+	for {
+		u.lock.Lock()
+		if len(u.queue) == 0 {
+			u.lock.Unlock()
+			select {
+			case <-u.wakeup:
+				continue
+			case <-quit:
+				return
+			}
+		}
+		a := u.queue[0]
+		u.lock.Unlock()
+
+		err := u.upload(a)
+		if err != nil && os.IsNotExist(err) == false {
+			if backoff = 2 * backoff; backoff == 0 {
+				backoff = 5 * time.Second
+			} else if backoff > uploaderMaxBackoff {
+				backoff = uploaderMaxBackoff
+			}
+			log.Warning("Error while uploading %s, retrying in %s: %s", a.Path, backoff, err)
+
+			select {
+			case <-time.After(backoff):
+				continue
+			case <-quit:
+				return
+			}
+		}
+
+		backoff = 0
+		u.lock.Lock()
+		u.queue = u.queue[1:]
+		u.lock.Unlock()
+
+		if err != nil {
+			log.Warning("Artifact %s does not exist anymore.", a.Path)
+			continue
+		}
+
+		log.Info("Uploaded %s.", a.Path)
+		u.Session.Events.Add("artifact.uploaded", a)
+
+		if u.remove == true {
+			if err := os.Remove(a.Path); err != nil {
+				log.Warning("Error while deleting %s: %s", a.Path, err)
+			}
+		}
+	}
+}
+
+func (u *Uploader) Show() error {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if len(u.queue) == 0 {
+		fmt.Println(core.Dim("No artifacts waiting to be uploaded."))
+		return nil
+	}
+
+	rows := make([][]string, 0)
+	for _, a := range u.queue {
+		size := "?"
+		if info, err := os.Stat(a.Path); err == nil {
+			size = fmt.Sprintf("%d", info.Size())
+		}
+		rows = append(rows, []string{a.Kind, a.Path, size})
+	}
+
+	fmt.Println()
+	core.AsTable(os.Stdout, []string{"Kind", "Path", "Size"}, rows)
+	fmt.Println()
+
+	return nil
+}
+
+func (u *Uploader) Start() error {
+	if u.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := u.Configure(); err != nil {
+		return err
+	}
+
+	u.SetRunning(true)
+	u.wakeup = make(chan bool, 1)
+	u.quit = make(chan bool)
+
+	go u.worker(u.quit)
+
+	go func(quit chan bool) {
+		listener := u.Session.Events.Listen()
+		defer u.Session.Events.Unlisten(listener)
+
+		for {
+			select {
+			case e := <-listener:
+				if e.Tag == "artifact.new" {
+					if a, ok := e.Data.(Artifact); ok == true && u.accept(a) == true {
+						u.enqueue(a)
+					}
+				}
+
+			case <-quit:
+				return
+			}
+		}
+	}(u.quit)
+
+	return nil
+}
+
+func (u *Uploader) Stop() error {
+	if u.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	u.SetRunning(false)
+	close(u.quit)
+	return nil
+}
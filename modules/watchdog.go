@@ -0,0 +1,430 @@
+package modules
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Resources the watchdog keeps within budget.
+const (
+	BudgetGoroutines = "goroutines"
+	BudgetMemory     = "memory"
+	BudgetFiles      = "fds"
+)
+
+// Modules taking this long to stop are left alone.
+const watchdogStopTimeout = 10 * time.Second
+
+// Emitted when a module, or the whole session for open files,
+// uses more than its budget of a resource.
+type BudgetAlert struct {
+	Module   string `json:"module"`
+	Resource string `json:"resource"`
+	Usage    uint64 `json:"usage"`
+	Budget   uint64 `json:"budget"`
+	Stopped  bool   `json:"stopped"`
+}
+
+func (a BudgetAlert) String() string {
+	if a.Resource == BudgetMemory {
+		return fmt.Sprintf("%s of %s", humanize.Bytes(a.Usage), humanize.Bytes(a.Budget))
+	}
+	return fmt.Sprintf("%d of %d %s", a.Usage, a.Budget, a.Resource)
+}
+
+type moduleBudget struct {
+	goroutines uint64
+	memory     uint64
+}
+
+type Watchdog struct {
+	session.SessionModule
+
+	period  time.Duration
+	grace   int
+	stop    bool
+	fds     uint64
+	def     moduleBudget
+	budgets map[string]moduleBudget
+	// consecutive checks over budget by module and resource
+	over    map[string]int
+	usage   map[string]moduleBudget
+	stopped uint64
+	lock    sync.Mutex
+	quit    chan bool
+}
+
+func NewWatchdog(s *session.Session) *Watchdog {
+	w := &Watchdog{
+		SessionModule: session.NewSessionModule("watchdog", s),
+		budgets:       make(map[string]moduleBudget),
+		over:          make(map[string]int),
+		usage:         make(map[string]moduleBudget),
+	}
+
+	w.AddParam(session.NewIntParameter("watchdog.period",
+		"10",
+		"Seconds between checks of the resources used by the modules."))
+
+	w.AddParam(session.NewIntParameter("watchdog.goroutines",
+		"0",
+		"Number of goroutines each module can run, 0 for no limit."))
+
+	w.AddParam(session.NewStringParameter("watchdog.memory",
+		"0",
+		"",
+		"Heap memory each module can use, i.e. 256MB, 0 for no limit."))
+
+	w.AddParam(session.NewStringParameter("watchdog.budgets",
+		"",
+		"",
+		"Comma separated list of per module budgets overriding the defaults, i.e. 'net.sniff memory=128MB goroutines=64, http.proxy goroutines=2000'."))
+
+	w.AddParam(session.NewIntParameter("watchdog.fds",
+		"0",
+		"Number of file descriptors the session can keep open, as they can't be accounted to modules exceeding it is only reported, 0 for no limit."))
+
+	w.AddParam(session.NewBoolParameter("watchdog.stop",
+		"false",
+		"If true, modules exceeding their budget for watchdog.grace consecutive checks are stopped."))
+
+	w.AddParam(session.NewIntParameter("watchdog.grace",
+		"3",
+		"Consecutive checks a module can be over budget before being stopped."))
+
+	w.AddHandler(session.NewModuleHandler("watchdog on", "",
+		"Start checking the resources used by the modules.",
+		func(args []string) error {
+			return w.Start()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("watchdog off", "",
+		"Stop checking the resources used by the modules.",
+		func(args []string) error {
+			return w.Stop()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("watchdog.show", "",
+		"Show the resources used by each module as of the last check and their budgets.",
+		func(args []string) error {
+			return w.Show()
+		}))
+
+	return w
+}
+
+func (w *Watchdog) Name() string {
+	return "watchdog"
+}
+
+func (w *Watchdog) Description() string {
+	return "Keeps the goroutines and the memory used by each module within a budget, reporting and optionally stopping the ones exceeding it."
+}
+
+func (w *Watchdog) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func parseBudgetValue(resource string, value string) (uint64, error) {
+	switch resource {
+	case BudgetGoroutines:
+		return strconv.ParseUint(value, 10, 64)
+	case BudgetMemory:
+		return humanize.ParseBytes(value)
+	}
+	return 0, fmt.Errorf("Unknown resource '%s', expected %s or %s.", resource, BudgetGoroutines, BudgetMemory)
+}
+
+// Parses 'MODULE RESOURCE=VALUE ...' entries, resources not
+// specified get the default budget.
+func (w *Watchdog) parseBudgets(list []string) error {
+	w.budgets = make(map[string]moduleBudget)
+	for _, entry := range list {
+		fields := strings.Fields(entry)
+		if len(fields) < 2 {
+			return fmt.Errorf("Invalid budget '%s', expected MODULE RESOURCE=VALUE.", entry)
+		} else if err, _ := w.Session.Module(fields[0]); err != nil {
+			return err
+		}
+
+		budget := w.def
+		for _, field := range fields[1:] {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("Invalid budget '%s', expected RESOURCE=VALUE.", field)
+			}
+
+			value, err := parseBudgetValue(parts[0], parts[1])
+			if err != nil {
+				return err
+			} else if parts[0] == BudgetGoroutines {
+				budget.goroutines = value
+			} else {
+				budget.memory = value
+			}
+		}
+		w.budgets[fields[0]] = budget
+	}
+	return nil
+}
+
+func (w *Watchdog) Configure() error {
+	var err error
+	var period, goroutines, fds int
+	var memory string
+	var budgets []string
+
+	if err, period = w.IntParam("watchdog.period"); err != nil {
+		return err
+	} else if period < 1 {
+		return fmt.Errorf("watchdog.period must be at least 1 second.")
+	} else if err, goroutines = w.IntParam("watchdog.goroutines"); err != nil {
+		return err
+	} else if err, memory = w.StringParam("watchdog.memory"); err != nil {
+		return err
+	} else if err, budgets = w.ListParam("watchdog.budgets"); err != nil {
+		return err
+	} else if err, fds = w.IntParam("watchdog.fds"); err != nil {
+		return err
+	} else if err, w.stop = w.BoolParam("watchdog.stop"); err != nil {
+		return err
+	} else if err, w.grace = w.IntParam("watchdog.grace"); err != nil {
+		return err
+	} else if goroutines < 0 || fds < 0 || w.grace < 1 {
+		return fmt.Errorf("Budgets can't be negative and watchdog.grace must be at least 1.")
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.period = time.Duration(period) * time.Second
+	w.fds = uint64(fds)
+	w.def.goroutines = uint64(goroutines)
+	if w.def.memory, err = parseBudgetValue(BudgetMemory, memory); err != nil {
+		return err
+	} else if err = w.parseBudgets(budgets); err != nil {
+		return err
+	}
+
+	w.over = make(map[string]int)
+	w.usage = make(map[string]moduleBudget)
+
+	return nil
+}
+
+func (w *Watchdog) budgetOf(name string) moduleBudget {
+	if budget, found := w.budgets[name]; found == true {
+		return budget
+	}
+	return w.def
+}
+
+func (w *Watchdog) stopModule(m session.Module) {
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Stop()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Error("[%s] error while stopping %s: %s", core.Green("watchdog"), m.Name(), err)
+		}
+	case <-time.After(watchdogStopTimeout):
+		log.Error("[%s] %s didn't stop in %s.", core.Green("watchdog"), m.Name(), watchdogStopTimeout)
+	}
+}
+
+// Accounts a check of a resource, returns the alert to report if the
+// usage exceeds the budget, with Stopped set if the module must be stopped.
+func (w *Watchdog) account(module string, resource string, usage uint64, budget uint64) *BudgetAlert {
+	key := module + "|" + resource
+	if budget == 0 || usage <= budget {
+		delete(w.over, key)
+		return nil
+	}
+
+	w.over[key]++
+	alert := &BudgetAlert{
+		Module:   module,
+		Resource: resource,
+		Usage:    usage,
+		Budget:   budget,
+	}
+
+	if w.stop == true && resource != BudgetFiles && w.over[key] >= w.grace {
+		delete(w.over, key)
+		alert.Stopped = true
+	} else if w.over[key] > 1 {
+		// reported once until it goes back within budget
+		return nil
+	}
+
+	return alert
+}
+
+func (w *Watchdog) check() {
+	goroutines := session.ModuleGoroutines()
+	memory := session.ModuleMemory(w.Session.Modules)
+
+	w.lock.Lock()
+	alerts := make([]*BudgetAlert, 0)
+	w.usage = make(map[string]moduleBudget)
+	for _, m := range w.Session.Modules {
+		name := m.Name()
+		usage := moduleBudget{uint64(goroutines[name]), memory[name]}
+		w.usage[name] = usage
+
+		if m.Running() == false {
+			continue
+		}
+
+		budget := w.budgetOf(name)
+		if alert := w.account(name, BudgetGoroutines, usage.goroutines, budget.goroutines); alert != nil {
+			alerts = append(alerts, alert)
+		}
+		if alert := w.account(name, BudgetMemory, usage.memory, budget.memory); alert != nil {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	if fds, err := ioutil.ReadDir("/proc/self/fd"); err == nil {
+		if alert := w.account("session", BudgetFiles, uint64(len(fds)), w.fds); alert != nil {
+			alerts = append(alerts, alert)
+		}
+	}
+	w.lock.Unlock()
+
+	for _, alert := range alerts {
+		if alert.Stopped == true {
+			// it might have been stopped for another resource already
+			if err, m := w.Session.Module(alert.Module); err == nil && m.Running() == true {
+				log.Warning("[%s] stopping %s, using %s.", core.Green("watchdog"), alert.Module, alert.String())
+				w.stopModule(m)
+
+				w.lock.Lock()
+				w.stopped++
+				w.lock.Unlock()
+			} else {
+				continue
+			}
+		} else {
+			log.Warning("[%s] %s is using %s.", core.Green("watchdog"), alert.Module, alert.String())
+		}
+
+		w.Session.Events.Add("watchdog.budget.exceeded", *alert)
+	}
+}
+
+func (w *Watchdog) Show() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if len(w.usage) == 0 {
+		fmt.Println(core.Dim("No checks done yet."))
+		return nil
+	}
+
+	names := make([]string, 0, len(w.usage))
+	for name := range w.usage {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	limit := func(v uint64, memory bool) string {
+		if v == 0 {
+			return core.Dim("-")
+		} else if memory == true {
+			return humanize.Bytes(v)
+		}
+		return fmt.Sprintf("%d", v)
+	}
+
+	rows := make([][]string, 0)
+	for _, name := range names {
+		usage := w.usage[name]
+		if usage.goroutines == 0 && usage.memory == 0 {
+			continue
+		}
+
+		budget := w.budgetOf(name)
+		rows = append(rows, []string{
+			core.Bold(name),
+			fmt.Sprintf("%d", usage.goroutines),
+			limit(budget.goroutines, false),
+			humanize.Bytes(usage.memory),
+			limit(budget.memory, true),
+		})
+	}
+
+	fmt.Println()
+	core.AsTable(os.Stdout, []string{"Module", "Goroutines", "Budget", "Memory", "Budget"}, rows)
+	fmt.Println()
+
+	return nil
+}
+
+func (w *Watchdog) Start() error {
+	if w.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := w.Configure(); err != nil {
+		return err
+	}
+
+	w.quit = make(chan bool)
+	w.SetRunning(true)
+
+	go func(quit chan bool) {
+		for {
+			select {
+			case <-quit:
+				return
+			case <-time.After(w.period):
+				w.check()
+			}
+		}
+	}(w.quit)
+
+	return nil
+}
+
+func (w *Watchdog) Stop() error {
+	if w.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	close(w.quit)
+	w.SetRunning(false)
+	return nil
+}
+
+func (w *Watchdog) Metrics() []session.Metric {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	metrics := []session.Metric{
+		session.NewMetric("watchdog_stopped_total", "Modules stopped for exceeding their budget.", session.MetricCounter, float64(w.stopped)),
+	}
+
+	for name, usage := range w.usage {
+		if usage.goroutines > 0 {
+			metrics = append(metrics, session.NewMetric("watchdog_module_goroutines", "Goroutines run by each module.", session.MetricGauge, float64(usage.goroutines), "module", name))
+		}
+		if usage.memory > 0 {
+			metrics = append(metrics, session.NewMetric("watchdog_module_memory_bytes", "Estimated heap memory in use by each module.", session.MetricGauge, float64(usage.memory), "module", name))
+		}
+	}
+
+	return metrics
+}
@@ -0,0 +1,312 @@
+package modules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/log"
+	"github.com/evilsocket/bettercap-ng/session"
+)
+
+// Bodies that can be used by name instead of a full template.
+var webhookPresets = map[string]string{
+	"json":    `{"tag":{{json .Tag}},"time":{{json .Time}},"host":{{json .Host}},"message":{{json .Message}},"data":{{json .Data}}}`,
+	"slack":   `{"text":{{json .Message}}}`,
+	"discord": `{"content":{{json .Message}}}`,
+}
+
+const webhookQueueSize = 256
+
+// Data available to webhook templates.
+type WebhookContext struct {
+	Tag     string
+	Time    time.Time
+	Host    string
+	Data    interface{}
+	Message string
+}
+
+type webhookRequest struct {
+	tag  string
+	body []byte
+}
+
+type Webhook struct {
+	session.SessionModule
+	client  *http.Client
+	urls    []string
+	host    string
+	message *template.Template
+	body    *template.Template
+	include []string
+	exclude []string
+	queue   chan webhookRequest
+	quit    chan bool
+}
+
+func NewWebhook(s *session.Session) *Webhook {
+	w := &Webhook{
+		SessionModule: session.NewSessionModule("webhook", s),
+		urls:          make([]string, 0),
+		include:       make([]string, 0),
+		exclude:       make([]string, 0),
+		quit:          make(chan bool),
+	}
+
+	w.AddParam(session.NewStringParameter("webhook.urls",
+		"",
+		"",
+		"Comma separated list of URLs to POST notifications to."))
+
+	w.AddParam(session.NewStringParameter("webhook.message",
+		"[{{.Host}}] {{.Tag}}: {{.Data}}",
+		"",
+		"Template of the notification text, available as .Message to the body template."))
+
+	w.AddParam(session.NewStringParameter("webhook.body",
+		"json",
+		"",
+		"Either json, slack, discord or a custom template of the JSON body, fields are .Tag, .Time, .Host, .Data and .Message, {{json .Field}} encodes a field and {{env \"name\"}} returns a session variable."))
+
+	w.AddParam(session.NewIntParameter("webhook.timeout",
+		"10",
+		"Timeout in seconds of each request."))
+
+	w.AddParam(session.NewStringParameter("webhook.filter",
+		"creds.new",
+		"",
+		"If filled, only notify events whose type starts with one of these comma separated prefixes."))
+
+	w.AddParam(session.NewStringParameter("webhook.exclude",
+		"",
+		"",
+		"If filled, do not notify events whose type starts with one of these comma separated prefixes."))
+
+	w.AddHandler(session.NewModuleHandler("webhook on", "",
+		"Start sending event notifications.",
+		func(args []string) error {
+			return w.Start()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("webhook off", "",
+		"Stop sending event notifications.",
+		func(args []string) error {
+			return w.Stop()
+		}))
+
+	w.AddHandler(session.NewModuleHandler("webhook.test", "",
+		"Send a test notification to the configured URLs.",
+		func(args []string) error {
+			if err := w.Configure(); err != nil {
+				return err
+			}
+			return w.test()
+		}))
+
+	return w
+}
+
+func (w Webhook) Name() string {
+	return "webhook"
+}
+
+func (w Webhook) Description() string {
+	return "POST notifications of selected events to webhook URLs."
+}
+
+func (w Webhook) Author() string {
+	return "Simone Margaritelli <evilsocket@protonmail.com>"
+}
+
+func (w *Webhook) funcs() template.FuncMap {
+	return template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			raw, err := json.Marshal(v)
+			return string(raw), err
+		},
+		"env": func(name string) string {
+			_, value := w.Session.Env.Get(name)
+			return value
+		},
+	}
+}
+
+func (w *Webhook) Configure() (err error) {
+	var message, body string
+	var timeout int
+
+	if err, w.urls = w.ListParam("webhook.urls"); err != nil {
+		return err
+	} else if err, message = w.StringParam("webhook.message"); err != nil {
+		return err
+	} else if err, body = w.StringParam("webhook.body"); err != nil {
+		return err
+	} else if err, timeout = w.IntParam("webhook.timeout"); err != nil {
+		return err
+	} else if err, w.include = w.ListParam("webhook.filter"); err != nil {
+		return err
+	} else if err, w.exclude = w.ListParam("webhook.exclude"); err != nil {
+		return err
+	}
+
+	if len(w.urls) == 0 {
+		return fmt.Errorf("No webhook.urls specified.")
+	}
+
+	if preset, found := webhookPresets[body]; found == true {
+		body = preset
+	}
+
+	if w.message, err = template.New("message").Funcs(w.funcs()).Parse(message); err != nil {
+		return fmt.Errorf("Error while parsing webhook.message: %s", err)
+	} else if w.body, err = template.New("body").Funcs(w.funcs()).Parse(body); err != nil {
+		return fmt.Errorf("Error while parsing webhook.body: %s", err)
+	}
+
+	if w.host, err = os.Hostname(); err != nil {
+		w.host = "bettercap"
+	}
+
+	w.client = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	return nil
+}
+
+func (w *Webhook) accept(e session.Event) bool {
+	if len(w.include) > 0 && hasPrefix(e.Tag, w.include) == false {
+		return false
+	}
+	return hasPrefix(e.Tag, w.exclude) == false
+}
+
+func (w *Webhook) render(tag string, when time.Time, data interface{}) ([]byte, error) {
+	ctx := WebhookContext{
+		Tag:  tag,
+		Time: when,
+		Host: w.host,
+		Data: data,
+	}
+
+	if msg, ok := data.(session.LogMessage); ok == true {
+		ctx.Data = msg.Message
+	}
+
+	buf := bytes.Buffer{}
+	if err := w.message.Execute(&buf, ctx); err != nil {
+		return nil, err
+	}
+	ctx.Message = buf.String()
+
+	buf.Reset()
+	if err := w.body.Execute(&buf, ctx); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (w *Webhook) post(url string, body []byte) error {
+	res, err := w.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s", res.Status)
+	}
+	return nil
+}
+
+func (w *Webhook) send(req webhookRequest) {
+	for _, url := range w.urls {
+		if err := w.post(url, req.body); err != nil {
+			log.Debug("Error while sending webhook for %s to %s: %s", req.tag, url, err)
+		}
+	}
+}
+
+func (w *Webhook) test() error {
+	body, err := w.render("webhook.test", time.Now(), "This is a test notification.")
+	if err != nil {
+		return err
+	}
+
+	for _, url := range w.urls {
+		if err := w.post(url, body); err != nil {
+			return fmt.Errorf("Error while sending webhook to %s: %s", url, err)
+		}
+		log.Info("Test notification sent to %s.", url)
+	}
+	return nil
+}
+
+func (w *Webhook) Start() error {
+	if w.Running() == true {
+		return session.ErrAlreadyStarted
+	} else if err := w.Configure(); err != nil {
+		return err
+	}
+
+	w.SetRunning(true)
+
+	// slow endpoints must not block the events listener
+	w.queue = make(chan webhookRequest, webhookQueueSize)
+	go func(queue chan webhookRequest) {
+		for req := range queue {
+			w.send(req)
+		}
+	}(w.queue)
+
+	go func() {
+		listener := w.Session.Events.Listen()
+		defer w.Session.Events.Unlisten(listener)
+
+		for {
+			select {
+			case e := <-listener:
+				if w.accept(e) == false {
+					continue
+				} else if msg, ok := e.Data.(session.LogMessage); ok == true && e.Tag == "sys.log" && strings.HasPrefix(msg.Message, "Error while sending webhook") {
+					continue
+				}
+
+				body, err := w.render(e.Tag, e.Time, e.Data)
+				if err != nil {
+					log.Debug("Error while rendering webhook for %s: %s", e.Tag, err)
+					continue
+				}
+
+				select {
+				case w.queue <- webhookRequest{tag: e.Tag, body: body}:
+				default:
+					log.Debug("Webhook queue is full, dropping %s event.", e.Tag)
+				}
+
+			case <-w.quit:
+				close(w.queue)
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (w *Webhook) Stop() error {
+	if w.Running() == false {
+		return session.ErrAlreadyStopped
+	}
+	w.SetRunning(false)
+	w.quit <- true
+	return nil
+}
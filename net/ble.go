@@ -0,0 +1,99 @@
+//go:build !windows
+// +build !windows
+
+package net
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/paypal/gatt"
+)
+
+type BLEDevNewCallback func(dev *BLEDevice)
+type BLEDevLostCallback func(dev *BLEDevice)
+
+type BLE struct {
+	sync.Mutex
+
+	devices map[string]*BLEDevice
+	newCb   BLEDevNewCallback
+	lostCb  BLEDevLostCallback
+}
+
+func NewBLE(newcb BLEDevNewCallback, lostcb BLEDevLostCallback) *BLE {
+	return &BLE{
+		devices: make(map[string]*BLEDevice),
+		newCb:   newcb,
+		lostCb:  lostcb,
+	}
+}
+
+func (b *BLE) AddIfNew(p gatt.Peripheral, a *gatt.Advertisement, rssi int) *BLEDevice {
+	b.Lock()
+	defer b.Unlock()
+
+	mac := normalizeMac(strings.ToLower(p.ID()))
+	if dev, found := b.devices[mac]; found == true {
+		dev.Update(p, a, rssi)
+		return dev
+	}
+
+	dev := NewBLEDevice(p, a, rssi)
+	b.devices[mac] = dev
+
+	if b.newCb != nil {
+		b.newCb(dev)
+	}
+
+	return nil
+}
+
+func (b *BLE) Get(mac string) (dev *BLEDevice, found bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	dev, found = b.devices[normalizeMac(strings.ToLower(mac))]
+	return
+}
+
+func (b *BLE) Remove(mac string) {
+	b.Lock()
+	defer b.Unlock()
+
+	mac = normalizeMac(strings.ToLower(mac))
+	if dev, found := b.devices[mac]; found == true {
+		delete(b.devices, mac)
+		if b.lostCb != nil {
+			b.lostCb(dev)
+		}
+	}
+}
+
+func (b *BLE) Devices() []*BLEDevice {
+	b.Lock()
+	defer b.Unlock()
+
+	devices := make([]*BLEDevice, 0, len(b.devices))
+	for _, dev := range b.devices {
+		devices = append(devices, dev)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].MAC < devices[j].MAC
+	})
+
+	return devices
+}
+
+func (b *BLE) Clear() {
+	b.Lock()
+	defer b.Unlock()
+	b.devices = make(map[string]*BLEDevice)
+}
+
+func (b *BLE) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Devices())
+}
@@ -0,0 +1,112 @@
+//go:build !windows
+// +build !windows
+
+package net
+
+import (
+	"strings"
+	"time"
+
+	"github.com/paypal/gatt"
+)
+
+type BLECharacteristic struct {
+	UUID       string   `json:"uuid"`
+	Name       string   `json:"name"`
+	Handle     uint16   `json:"handle"`
+	Properties []string `json:"properties"`
+	Data       string   `json:"data"`
+}
+
+type BLEService struct {
+	UUID            string              `json:"uuid"`
+	Name            string              `json:"name"`
+	Handle          uint16              `json:"handle"`
+	Characteristics []BLECharacteristic `json:"characteristics"`
+}
+
+type BLEDevice struct {
+	Device        gatt.Peripheral     `json:"-"`
+	Advertisement *gatt.Advertisement `json:"-"`
+	MAC           string              `json:"mac"`
+	Name          string              `json:"name"`
+	Vendor        string              `json:"vendor"`
+	RSSI          int                 `json:"rssi"`
+	Connectable   bool                `json:"connectable"`
+	FirstSeen     time.Time           `json:"first_seen"`
+	LastSeen      time.Time           `json:"last_seen"`
+	GATT          []BLEService        `json:"gatt"`
+	Location      *GPSPosition        `json:"location,omitempty"`
+	prevRSSI      int
+	locationRSSI  int
+}
+
+func NewBLEDevice(p gatt.Peripheral, a *gatt.Advertisement, rssi int) *BLEDevice {
+	mac := normalizeMac(strings.ToLower(p.ID()))
+	now := time.Now()
+
+	dev := &BLEDevice{
+		Device:        p,
+		Advertisement: a,
+		MAC:           mac,
+		Vendor:        OuiLookup(mac),
+		RSSI:          rssi,
+		Connectable:   a != nil && a.Connectable,
+		prevRSSI:      rssi,
+		FirstSeen:     now,
+		LastSeen:      now,
+	}
+
+	dev.updateName()
+
+	return dev
+}
+
+func (d *BLEDevice) updateName() {
+	if d.Advertisement != nil && d.Advertisement.LocalName != "" {
+		d.Name = d.Advertisement.LocalName
+	} else if d.Device.Name() != "" {
+		d.Name = d.Device.Name()
+	}
+}
+
+func (d *BLEDevice) Update(p gatt.Peripheral, a *gatt.Advertisement, rssi int) {
+	d.Device = p
+	d.Advertisement = a
+	d.prevRSSI = d.RSSI
+	d.RSSI = rssi
+	d.Connectable = a != nil && a.Connectable
+	d.LastSeen = time.Now()
+	d.updateName()
+}
+
+// Tags the device with the position, which is kept only if it's the
+// first one or the signal is at least as strong as when the previous
+// one was taken, so that it ends up being the closest to the device.
+func (d *BLEDevice) Locate(pos *GPSPosition) {
+	if pos != nil && (d.Location == nil || d.RSSI >= d.locationRSSI) {
+		d.Location = pos
+		d.locationRSSI = d.RSSI
+	}
+}
+
+// Returns the difference between the last two RSSI readings,
+// positive if the device is getting closer.
+func (d *BLEDevice) RSSITrend() int {
+	return d.RSSI - d.prevRSSI
+}
+
+// Returns the advertised services, using their name if known.
+func (d *BLEDevice) Services() []string {
+	services := make([]string, 0)
+	if d.Advertisement != nil {
+		for _, uuid := range d.Advertisement.Services {
+			if name := gatt.NewService(uuid).Name(); name != "" {
+				services = append(services, name)
+			} else {
+				services = append(services, uuid.String())
+			}
+		}
+	}
+	return services
+}
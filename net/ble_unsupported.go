@@ -0,0 +1,39 @@
+//go:build windows
+// +build windows
+
+package net
+
+import "time"
+
+type BLEDevice struct {
+	MAC       string       `json:"mac"`
+	Name      string       `json:"name"`
+	Vendor    string       `json:"vendor"`
+	RSSI      int          `json:"rssi"`
+	FirstSeen time.Time    `json:"first_seen"`
+	LastSeen  time.Time    `json:"last_seen"`
+	Location  *GPSPosition `json:"location,omitempty"`
+}
+
+func (d *BLEDevice) Locate(pos *GPSPosition) {
+	if pos != nil {
+		d.Location = pos
+	}
+}
+
+type BLEDevNewCallback func(dev *BLEDevice)
+type BLEDevLostCallback func(dev *BLEDevice)
+
+type BLE struct {
+}
+
+func NewBLE(newcb BLEDevNewCallback, lostcb BLEDevLostCallback) *BLE {
+	return &BLE{}
+}
+
+func (b *BLE) Devices() []*BLEDevice {
+	return []*BLEDevice{}
+}
+
+func (b *BLE) Clear() {
+}
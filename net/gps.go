@@ -0,0 +1,20 @@
+package net
+
+import (
+	"fmt"
+	"time"
+)
+
+// A position reported by the gps module.
+type GPSPosition struct {
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Altitude   float64   `json:"altitude"`
+	Satellites int       `json:"satellites"`
+	HDOP       float64   `json:"hdop"`
+	Updated    time.Time `json:"updated"`
+}
+
+func (p GPSPosition) String() string {
+	return fmt.Sprintf("%.6f,%.6f", p.Latitude, p.Longitude)
+}
@@ -0,0 +1,94 @@
+package net
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type HIDDevNewCallback func(dev *HIDDevice)
+type HIDDevLostCallback func(dev *HIDDevice)
+
+type HID struct {
+	sync.Mutex
+
+	devices map[string]*HIDDevice
+	newCb   HIDDevNewCallback
+	lostCb  HIDDevLostCallback
+}
+
+func NewHID(newcb HIDDevNewCallback, lostcb HIDDevLostCallback) *HID {
+	return &HID{
+		devices: make(map[string]*HIDDevice),
+		newCb:   newcb,
+		lostCb:  lostcb,
+	}
+}
+
+func (h *HID) AddIfNew(address []byte, channel int, dtype string) *HIDDevice {
+	h.Lock()
+	defer h.Unlock()
+
+	addr := HIDAddress(address)
+	if dev, found := h.devices[addr]; found == true {
+		dev.Update(channel, dtype)
+		return dev
+	}
+
+	dev := NewHIDDevice(address, channel, dtype)
+	h.devices[addr] = dev
+
+	if h.newCb != nil {
+		h.newCb(dev)
+	}
+
+	return nil
+}
+
+func (h *HID) Get(address string) (dev *HIDDevice, found bool) {
+	h.Lock()
+	defer h.Unlock()
+
+	dev, found = h.devices[strings.ToLower(address)]
+	return
+}
+
+func (h *HID) Remove(address string) {
+	h.Lock()
+	defer h.Unlock()
+
+	address = strings.ToLower(address)
+	if dev, found := h.devices[address]; found == true {
+		delete(h.devices, address)
+		if h.lostCb != nil {
+			h.lostCb(dev)
+		}
+	}
+}
+
+func (h *HID) Devices() []*HIDDevice {
+	h.Lock()
+	defer h.Unlock()
+
+	devices := make([]*HIDDevice, 0, len(h.devices))
+	for _, dev := range h.devices {
+		devices = append(devices, dev)
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Address < devices[j].Address
+	})
+
+	return devices
+}
+
+func (h *HID) Clear() {
+	h.Lock()
+	defer h.Unlock()
+	h.devices = make(map[string]*HIDDevice)
+}
+
+func (h *HID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.Devices())
+}
@@ -0,0 +1,65 @@
+package net
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type HIDDevice struct {
+	Address   string    `json:"address"`
+	Type      string    `json:"type"`
+	Channels  []int     `json:"channels"`
+	Payloads  uint64    `json:"payloads"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	raw       []byte
+}
+
+func HIDAddress(raw []byte) string {
+	parts := make([]string, len(raw))
+	for i, b := range raw {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+func NewHIDDevice(address []byte, channel int, dtype string) *HIDDevice {
+	now := time.Now()
+	raw := make([]byte, len(address))
+	copy(raw, address)
+
+	return &HIDDevice{
+		Address:   HIDAddress(raw),
+		Type:      dtype,
+		Channels:  []int{channel},
+		Payloads:  1,
+		FirstSeen: now,
+		LastSeen:  now,
+		raw:       raw,
+	}
+}
+
+func (d *HIDDevice) RawAddress() []byte {
+	return d.raw
+}
+
+func (d *HIDDevice) Update(channel int, dtype string) {
+	d.Payloads++
+	d.LastSeen = time.Now()
+
+	// only overwrite the type once we know it
+	if d.Type == "" || dtype != "Unknown" {
+		d.Type = dtype
+	}
+
+	for _, ch := range d.Channels {
+		if ch == channel {
+			return
+		}
+	}
+
+	d.Channels = append(d.Channels, channel)
+	sort.Ints(d.Channels)
+}
@@ -10,6 +10,23 @@ import (
 	"github.com/evilsocket/bettercap-ng/core"
 )
 
+var dryRun func(format string, args ...interface{})
+
+// Makes the functions changing the interfaces only report what
+// they would do instead, used by the -dry-run mode.
+func SetDryRun(report func(format string, args ...interface{})) {
+	dryRun = report
+}
+
+// Returns true if the change has been reported and must not be applied.
+func isDryRun(format string, args ...interface{}) bool {
+	if dryRun == nil {
+		return false
+	}
+	dryRun("[dry-run] Would "+format, args...)
+	return true
+}
+
 func FindInterface(name string) (*Endpoint, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -42,9 +59,10 @@ func FindInterface(name string) (*Endpoint, error) {
 						}
 					} else if e != nil {
 						parts := strings.SplitN(ip, "/", 2)
-						e.IPv6 = net.ParseIP(parts[0])
-						if e.IPv6 != nil {
-							e.Ip6Address = e.IPv6.String()
+						// prefer a global address over the link local one
+						if ip6 := net.ParseIP(parts[0]); ip6 != nil && (e.IPv6 == nil || e.IPv6.IsGlobalUnicast() == false) {
+							e.IPv6 = ip6
+							e.Ip6Address = ip6.String()
 						}
 					}
 				}
@@ -66,6 +84,22 @@ func FindInterface(name string) (*Endpoint, error) {
 	}
 }
 
+// Same as FindInterface, but it will also return interfaces without an
+// IPv4 address, like wireless interfaces in monitor mode, which are
+// still good for packet capture.
+func FindCaptureInterface(name string) (*Endpoint, error) {
+	if e, err := FindInterface(name); err == nil {
+		return e, nil
+	}
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("Could not find interface '%s'.", name)
+	}
+
+	return NewEndpointNoResolve("0.0.0.0", iface.HardwareAddr.String(), iface.Name, 0), nil
+}
+
 func FindGateway(iface *Endpoint) (*Endpoint, error) {
 	output, err := core.Exec(IPv4RouteCmd, IPv4RouteCmdOpts)
 	if err != nil {
@@ -1,6 +1,11 @@
 package net
 
-import "regexp"
+import (
+	"net"
+	"regexp"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
 
 var IPv4RouteParser = regexp.MustCompile("^([a-z]+)+\\s+(\\d+\\.+\\d+.\\d.+\\d)+\\s+([a-zA-z]+)+\\s+(\\d+)+\\s+(\\d+)+\\s+([a-zA-Z]+\\d+)$")
 var IPv4RouteTokens = 7
@@ -18,3 +23,12 @@ func IPv4RouteIsGateway(ifname string, tokens []string, f func(gateway string) (
 
 	return nil, nil
 }
+
+func SetInterfaceMAC(iface string, mac net.HardwareAddr) error {
+	if isDryRun("set the MAC address of %s to %s.", iface, mac) {
+		return nil
+	}
+
+	_, err := core.Exec("ifconfig", []string{iface, "ether", mac.String()})
+	return err
+}
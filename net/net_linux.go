@@ -1,6 +1,11 @@
 package net
 
-import "regexp"
+import (
+	"net"
+	"regexp"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
 
 // only matches gateway lines
 var IPv4RouteParser = regexp.MustCompile("^(default|[0-9\\.]+)\\svia\\s([0-9\\.]+)\\sdev\\s(\\w+)\\s.*$")
@@ -18,3 +23,38 @@ func IPv4RouteIsGateway(ifname string, tokens []string, f func(gateway string) (
 
 	return nil, nil
 }
+
+// Most drivers refuse to change the hardware address
+// while the interface is up.
+func SetInterfaceMAC(iface string, mac net.HardwareAddr) error {
+	if isDryRun("set the MAC address of %s to %s.", iface, mac) {
+		return nil
+	}
+
+	if _, err := core.Exec("ip", []string{"link", "set", "dev", iface, "down"}); err != nil {
+		return err
+	}
+
+	_, err := core.Exec("ip", []string{"link", "set", "dev", iface, "address", mac.String()})
+
+	// bring it back up even if the address has been rejected
+	if _, uperr := core.Exec("ip", []string{"link", "set", "dev", iface, "up"}); uperr != nil && err == nil {
+		err = uperr
+	}
+
+	return err
+}
+
+func SetInterfacePromisc(iface string, enabled bool) error {
+	state := "off"
+	if enabled == true {
+		state = "on"
+	}
+
+	if isDryRun("turn promiscuous mode of %s %s.", iface, state) {
+		return nil
+	}
+
+	_, err := core.Exec("ip", []string{"link", "set", iface, "promisc", state})
+	return err
+}
@@ -2,13 +2,21 @@ package net
 
 import (
 	"strings"
+	"sync"
 )
 
 var (
-	oui = make(map[string]string)
+	oui     = make(map[string]string)
+	ouiOnce = sync.Once{}
 )
 
+// Parses the embedded vendors database, it's called on the
+// first lookup so it's never paid if nobody needs it.
 func OuiInit() {
+	ouiOnce.Do(ouiLoad)
+}
+
+func ouiLoad() {
 	bytes, err := Asset("net/oui.dat")
 	if err != nil {
 		panic(err)
@@ -36,6 +44,8 @@ func OuiInit() {
 }
 
 func OuiLookup(mac string) string {
+	OuiInit()
+
 	octects := strings.Split(mac, ":")
 	if len(octects) > 3 {
 		prefix := octects[0] + octects[1] + octects[2]
@@ -0,0 +1,107 @@
+package net
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	WirelessModeManaged = "managed"
+	WirelessModeMonitor = "monitor"
+)
+
+type WirelessNewCallback func(name string)
+type WirelessLostCallback func(name string)
+
+// Polls the wireless interfaces of the system in order to detect
+// USB adapters being plugged in or removed while the session runs.
+type WirelessWatcher struct {
+	sync.Mutex
+
+	known  map[string]bool
+	newCb  WirelessNewCallback
+	lostCb WirelessLostCallback
+	quit   chan bool
+}
+
+func NewWirelessWatcher(newcb WirelessNewCallback, lostcb WirelessLostCallback) *WirelessWatcher {
+	w := &WirelessWatcher{
+		known:  make(map[string]bool),
+		newCb:  newcb,
+		lostCb: lostcb,
+	}
+
+	// the ones already there are not reported
+	for _, name := range WirelessInterfaces() {
+		w.known[name] = true
+	}
+
+	return w
+}
+
+func (w *WirelessWatcher) check() {
+	w.Lock()
+	defer w.Unlock()
+
+	found := make(map[string]bool)
+	for _, name := range WirelessInterfaces() {
+		found[name] = true
+		if w.known[name] == false && w.newCb != nil {
+			w.newCb(name)
+		}
+	}
+
+	for name := range w.known {
+		if found[name] == false && w.lostCb != nil {
+			w.lostCb(name)
+		}
+	}
+
+	w.known = found
+}
+
+func (w *WirelessWatcher) Start(period time.Duration) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.quit != nil {
+		return
+	}
+
+	w.quit = make(chan bool)
+	go func(quit chan bool) {
+		for {
+			select {
+			case <-quit:
+				return
+			case <-time.After(period):
+				w.check()
+			}
+		}
+	}(w.quit)
+}
+
+func (w *WirelessWatcher) Stop() {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.quit != nil {
+		close(w.quit)
+		w.quit = nil
+	}
+}
+
+// Returns the wireless interfaces found as of the last check.
+func (w *WirelessWatcher) Interfaces() []string {
+	w.Lock()
+	defer w.Unlock()
+
+	names := make([]string, 0, len(w.known))
+	for name := range w.known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,121 @@
+package net
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var wirelessTypeParser = regexp.MustCompile(`(?m)^\s*type\s+(\S+)`)
+
+func IsWireless(name string) bool {
+	_, err := ioutil.ReadDir(fmt.Sprintf("/sys/class/net/%s/phy80211", name))
+	return err == nil
+}
+
+func WirelessInterfaces() []string {
+	names := make([]string, 0)
+
+	entries, err := ioutil.ReadDir("/sys/class/net")
+	if err != nil {
+		return names
+	}
+
+	for _, entry := range entries {
+		if IsWireless(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names
+}
+
+// Turns the errors of the tools into something the user can act upon,
+// instead of exit codes and raw syscall messages.
+func wirelessError(name string, action string, output string, err error) error {
+	switch {
+	case strings.Contains(output, "Operation not permitted"):
+		return fmt.Errorf("Not enough privileges to %s %s, bettercap must run as root.", action, name)
+	case strings.Contains(output, "Device or resource busy"):
+		return fmt.Errorf("%s is busy, make sure it's not managed by NetworkManager or wpa_supplicant ( i.e. nmcli device set %s managed no ) and try again.", name, name)
+	case strings.Contains(output, "Operation not supported"), strings.Contains(output, "Invalid argument"):
+		return fmt.Errorf("The driver of %s doesn't support the requested mode, use an adapter supporting monitor mode.", name)
+	case strings.Contains(output, "No such device"):
+		return fmt.Errorf("Could not find interface '%s', the adapter might have been unplugged.", name)
+	}
+
+	if output = strings.TrimSpace(output); output != "" {
+		return fmt.Errorf("Could not %s %s: %s.", action, name, output)
+	}
+	return fmt.Errorf("Could not %s %s: %s.", action, name, err)
+}
+
+func wirelessExec(name string, action string, executable string, args ...string) (string, error) {
+	path, err := exec.LookPath(executable)
+	if err != nil {
+		return "", fmt.Errorf("The %s tool is needed to %s %s, install it with the package manager of your distribution.", executable, action, name)
+	}
+
+	raw, err := exec.Command(path, args...).CombinedOutput()
+	if err != nil {
+		return "", wirelessError(name, action, string(raw), err)
+	}
+
+	return string(raw), nil
+}
+
+func checkWireless(name string) error {
+	if _, err := net.InterfaceByName(name); err != nil {
+		return fmt.Errorf("Could not find interface '%s', is the adapter plugged in?", name)
+	} else if IsWireless(name) == false {
+		return fmt.Errorf("%s is not a wireless interface.", name)
+	}
+	return nil
+}
+
+// Returns the mode of the wireless interface, i.e. managed or monitor.
+func InterfaceMode(name string) (string, error) {
+	if err := checkWireless(name); err != nil {
+		return "", err
+	}
+
+	out, err := wirelessExec(name, "read the mode of", "iw", "dev", name, "info")
+	if err != nil {
+		return "", err
+	}
+
+	m := wirelessTypeParser.FindStringSubmatch(out)
+	if m == nil {
+		return "", fmt.Errorf("Could not read the mode of %s.", name)
+	}
+
+	return m[1], nil
+}
+
+// Drivers refuse to change the mode of the interface while it's up,
+// it's brought back up even if the mode has been rejected.
+func SetInterfaceMode(name string, mode string) error {
+	if current, err := InterfaceMode(name); err != nil {
+		return err
+	} else if current == mode {
+		return nil
+	} else if isDryRun("switch %s from %s to %s mode.", name, current, mode) {
+		return nil
+	}
+
+	action := "change the mode of"
+	if _, err := wirelessExec(name, action, "ip", "link", "set", "dev", name, "down"); err != nil {
+		return err
+	}
+
+	_, err := wirelessExec(name, action, "iw", "dev", name, "set", "type", mode)
+
+	if _, uperr := wirelessExec(name, action, "ip", "link", "set", "dev", name, "up"); uperr != nil && err == nil {
+		err = uperr
+	}
+
+	return err
+}
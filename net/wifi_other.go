@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package net
+
+import "fmt"
+
+func IsWireless(name string) bool {
+	return false
+}
+
+func WirelessInterfaces() []string {
+	return []string{}
+}
+
+func InterfaceMode(name string) (string, error) {
+	return "", fmt.Errorf("Reading the mode of wireless interfaces is only supported on Linux.")
+}
+
+func SetInterfaceMode(name string, mode string) error {
+	return fmt.Errorf("Changing the mode of wireless interfaces is only supported on Linux.")
+}
@@ -0,0 +1,168 @@
+package nrf24
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/gousb"
+)
+
+// CrazyRadio PA dongles flashed with the RFStorm research firmware
+// https://github.com/BastilleResearch/nrf-research-firmware
+const (
+	VendorID  = 0x1915
+	ProductID = 0x0102
+
+	TRANSMIT_PAYLOAD         = 0x04
+	ENTER_SNIFFER_MODE       = 0x05
+	ENTER_PROMISCUOUS_MODE   = 0x06
+	ENTER_TONE_TEST_MODE     = 0x07
+	TRANSMIT_ACK_PAYLOAD     = 0x08
+	SET_CHANNEL              = 0x09
+	GET_CHANNEL              = 0x0a
+	ENABLE_LNA_PA            = 0x0b
+	TRANSMIT_PAYLOAD_GENERIC = 0x0c
+	RECEIVE_PAYLOAD          = 0x12
+
+	MinChannel = 2
+	MaxChannel = 83
+
+	AddressSize = 5
+
+	usbTimeout = 2500 * time.Millisecond
+)
+
+var (
+	ErrNoDongle = errors.New("Could not find any nRF24 dongle, make sure it's flashed with the RFStorm research firmware.")
+)
+
+type Dongle struct {
+	ctx    *gousb.Context
+	dev    *gousb.Device
+	cfg    *gousb.Config
+	intf   *gousb.Interface
+	in     *gousb.InEndpoint
+	out    *gousb.OutEndpoint
+	buffer []byte
+}
+
+func Open() (d *Dongle, err error) {
+	d = &Dongle{
+		ctx:    gousb.NewContext(),
+		buffer: make([]byte, 64),
+	}
+
+	if d.dev, err = d.ctx.OpenDeviceWithVIDPID(VendorID, ProductID); err != nil {
+		d.Close()
+		return nil, err
+	} else if d.dev == nil {
+		d.Close()
+		return nil, ErrNoDongle
+	}
+
+	d.dev.SetAutoDetach(true)
+
+	if d.cfg, err = d.dev.Config(1); err != nil {
+		d.Close()
+		return nil, err
+	} else if d.intf, err = d.cfg.Interface(0, 0); err != nil {
+		d.Close()
+		return nil, err
+	} else if d.in, err = d.intf.InEndpoint(1); err != nil {
+		d.Close()
+		return nil, err
+	} else if d.out, err = d.intf.OutEndpoint(1); err != nil {
+		d.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *Dongle) Close() {
+	if d.intf != nil {
+		d.intf.Close()
+	}
+	if d.cfg != nil {
+		d.cfg.Close()
+	}
+	if d.dev != nil {
+		d.dev.Close()
+	}
+	d.ctx.Close()
+}
+
+// every command is answered by the firmware with a response buffer.
+func (d *Dongle) command(request byte, data ...byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), usbTimeout)
+	defer cancel()
+
+	if _, err := d.out.WriteContext(ctx, append([]byte{request}, data...)); err != nil {
+		return nil, err
+	}
+
+	n, err := d.in.ReadContext(ctx, d.buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.buffer[:n], nil
+}
+
+func (d *Dongle) EnableLNA() error {
+	_, err := d.command(ENABLE_LNA_PA)
+	return err
+}
+
+func (d *Dongle) SetChannel(channel int) error {
+	if channel > 125 {
+		channel = 125
+	}
+	_, err := d.command(SET_CHANNEL, byte(channel))
+	return err
+}
+
+// Receive packets from any device, each payload will start
+// with the 5 bytes address of the transmitter.
+func (d *Dongle) EnterPromiscMode() error {
+	_, err := d.command(ENTER_PROMISCUOUS_MODE, 0)
+	return err
+}
+
+// Receive and transmit packets as the device with the given
+// address (most significant byte first).
+func (d *Dongle) EnterSnifferMode(address []byte) error {
+	data := []byte{byte(len(address))}
+	// the firmware wants the address in little endian
+	for i := len(address) - 1; i >= 0; i-- {
+		data = append(data, address[i])
+	}
+	_, err := d.command(ENTER_SNIFFER_MODE, data...)
+	return err
+}
+
+// Returns a received payload or nil if nothing was received.
+func (d *Dongle) ReceivePayload() ([]byte, error) {
+	buf, err := d.command(RECEIVE_PAYLOAD)
+	if err != nil {
+		return nil, err
+	} else if len(buf) == 0 || (len(buf) == 1 && buf[0] == 0xff) {
+		return nil, nil
+	}
+
+	data := make([]byte, len(buf))
+	copy(data, buf)
+	return data, nil
+}
+
+// Transmits a payload in sniffer mode and returns true if
+// the packet has been acknowledged by the receiver.
+func (d *Dongle) TransmitPayload(payload []byte, timeout byte, retransmits byte) (bool, error) {
+	data := append([]byte{byte(len(payload)), timeout, retransmits}, payload...)
+	buf, err := d.command(TRANSMIT_PAYLOAD, data...)
+	if err != nil {
+		return false, err
+	}
+	return len(buf) > 0 && buf[0] > 0, nil
+}
@@ -0,0 +1,272 @@
+package packets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Link types of the captures of BLE sniffers like Ubertooth
+// or the nRF Sniffer.
+const (
+	LinkTypeBLELL         = 251
+	LinkTypeBLELLWithPHDR = 256
+)
+
+const (
+	BLEAdvAccessAddress = 0x8e89bed6
+
+	bleAdvConnectInd = 0x05
+	bleLLIDStart     = 0x02
+	bleSMPChannel    = 0x0006
+	blePHDRSize      = 10
+)
+
+// SMP operation codes.
+const (
+	BLESMPPairingRequest  = 0x01
+	BLESMPPairingResponse = 0x02
+)
+
+// IO capabilities exchanged with the pairing request and response.
+const (
+	BLEIODisplayOnly     = 0x00
+	BLEIODisplayYesNo    = 0x01
+	BLEIOKeyboardOnly    = 0x02
+	BLEIONoInputNoOutput = 0x03
+	BLEIOKeyboardDisplay = 0x04
+)
+
+const (
+	bleAuthMITM = 0x04
+	bleAuthSC   = 0x08
+)
+
+const (
+	BLEPairingJustWorks         = "Just Works"
+	BLEPairingPasskey           = "Passkey Entry"
+	BLEPairingNumericComparison = "Numeric Comparison"
+	BLEPairingOOB               = "Out of Band"
+)
+
+var bleIOCapabilities = map[byte]string{
+	BLEIODisplayOnly:     "DisplayOnly",
+	BLEIODisplayYesNo:    "DisplayYesNo",
+	BLEIOKeyboardOnly:    "KeyboardOnly",
+	BLEIONoInputNoOutput: "NoInputNoOutput",
+	BLEIOKeyboardDisplay: "KeyboardDisplay",
+}
+
+func BLEIOCapability(io byte) string {
+	if name, found := bleIOCapabilities[io]; found == true {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", io)
+}
+
+// A CONNECT_IND advertising PDU, sent by the initiator
+// to establish a connection with the advertiser.
+type BLEConnectInd struct {
+	Initiator     string
+	Advertiser    string
+	AccessAddress uint32
+	// in units of 1.25ms
+	Interval uint16
+}
+
+// Pairing request or response of the Security Manager Protocol.
+type BLEPairing struct {
+	Code          byte
+	IOCapability  byte
+	OOB           bool
+	AuthReq       byte
+	MaxKeySize    byte
+	InitiatorKeys byte
+	ResponderKeys byte
+}
+
+func (p BLEPairing) MITM() bool {
+	return p.AuthReq&bleAuthMITM != 0
+}
+
+func (p BLEPairing) SecureConnections() bool {
+	return p.AuthReq&bleAuthSC != 0
+}
+
+// A link layer packet as captured by a BLE sniffer.
+type BLELLPacket struct {
+	AccessAddress uint32
+	Header        byte
+	Payload       []byte
+}
+
+func (p BLELLPacket) Advertising() bool {
+	return p.AccessAddress == BLEAdvAccessAddress
+}
+
+// Reads the packets of a pcap capture of a BLE sniffer, pcapgo
+// can't be used as it truncates the link type to 8 bits.
+type BLECapture struct {
+	LinkType int
+
+	r     io.Reader
+	order binary.ByteOrder
+}
+
+func NewBLECapture(r io.Reader) (*BLECapture, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	c := &BLECapture{r: r}
+	switch binary.LittleEndian.Uint32(header[0:4]) {
+	case 0xa1b2c3d4, 0xa1b23c4d:
+		c.order = binary.LittleEndian
+	case 0xd4c3b2a1, 0x4d3cb2a1:
+		c.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("Not a pcap capture.")
+	}
+
+	c.LinkType = int(c.order.Uint32(header[20:24]))
+	if c.LinkType != LinkTypeBLELL && c.LinkType != LinkTypeBLELLWithPHDR {
+		return nil, fmt.Errorf("Unsupported link type %d, a BLE link layer capture is needed.", c.LinkType)
+	}
+
+	return c, nil
+}
+
+// Returns the next packet, or io.EOF once the capture is over.
+func (c *BLECapture) Next() ([]byte, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return nil, err
+	}
+
+	size := c.order.Uint32(header[8:12])
+	if size > 0xffff {
+		return nil, fmt.Errorf("Invalid packet size %d.", size)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Link layer addresses are transmitted little endian.
+func bleAddress(raw []byte) string {
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", raw[5], raw[4], raw[3], raw[2], raw[1], raw[0])
+}
+
+// Parses a link layer packet of the given capture link type,
+// the trailing CRC is not included in the payload.
+func ParseBLELL(linkType int, data []byte) (*BLELLPacket, error) {
+	switch linkType {
+	case LinkTypeBLELLWithPHDR:
+		if len(data) < blePHDRSize {
+			return nil, fmt.Errorf("Invalid BLE pseudo header.")
+		}
+		data = data[blePHDRSize:]
+	case LinkTypeBLELL:
+	default:
+		return nil, fmt.Errorf("Unsupported link type %d, a BLE link layer capture is needed.", linkType)
+	}
+
+	// access address, header, length and CRC
+	if len(data) < 4+2+3 {
+		return nil, fmt.Errorf("BLE link layer packet too short.")
+	}
+
+	size := int(data[5])
+	if len(data) < 6+size {
+		return nil, fmt.Errorf("BLE link layer packet truncated.")
+	}
+
+	return &BLELLPacket{
+		AccessAddress: binary.LittleEndian.Uint32(data[0:4]),
+		Header:        data[4],
+		Payload:       data[6 : 6+size],
+	}, nil
+}
+
+// Returns the connection request carried by the packet, if any.
+func (p BLELLPacket) ConnectInd() *BLEConnectInd {
+	// InitA, AdvA and at least the access address and CRC
+	// initialization value, window size and offset, interval
+	if p.Advertising() == false || p.Header&0x0f != bleAdvConnectInd || len(p.Payload) < 12+12 {
+		return nil
+	}
+
+	return &BLEConnectInd{
+		Initiator:     bleAddress(p.Payload[0:6]),
+		Advertiser:    bleAddress(p.Payload[6:12]),
+		AccessAddress: binary.LittleEndian.Uint32(p.Payload[12:16]),
+		Interval:      binary.LittleEndian.Uint16(p.Payload[22:24]),
+	}
+}
+
+// Returns the pairing request or response carried by
+// the packet, if any, fragmented ones are ignored.
+func (p BLELLPacket) Pairing() *BLEPairing {
+	// L2CAP header, SMP code and the pairing parameters
+	if p.Advertising() == true || p.Header&0x03 != bleLLIDStart || len(p.Payload) < 4+7 {
+		return nil
+	} else if binary.LittleEndian.Uint16(p.Payload[2:4]) != bleSMPChannel {
+		return nil
+	}
+
+	smp := p.Payload[4:]
+	if smp[0] != BLESMPPairingRequest && smp[0] != BLESMPPairingResponse {
+		return nil
+	}
+
+	return &BLEPairing{
+		Code:          smp[0],
+		IOCapability:  smp[1],
+		OOB:           smp[2] != 0,
+		AuthReq:       smp[3],
+		MaxKeySize:    smp[4],
+		InitiatorKeys: smp[5],
+		ResponderKeys: smp[6],
+	}
+}
+
+// Selects the pairing method the devices will use as the Security
+// Manager specification does, given the request and the response.
+func BLEPairingMethod(req *BLEPairing, rsp *BLEPairing) string {
+	sc := req.SecureConnections() && rsp.SecureConnections()
+
+	// LE legacy pairing needs both devices to have the OOB data
+	if (sc == true && (req.OOB || rsp.OOB)) || (sc == false && req.OOB && rsp.OOB) {
+		return BLEPairingOOB
+	} else if req.MITM() == false && rsp.MITM() == false {
+		return BLEPairingJustWorks
+	}
+
+	i, r := req.IOCapability, rsp.IOCapability
+	if i == BLEIONoInputNoOutput || r == BLEIONoInputNoOutput {
+		return BLEPairingJustWorks
+	}
+
+	display := func(io byte) bool {
+		return io == BLEIODisplayOnly || io == BLEIODisplayYesNo
+	}
+	confirm := func(io byte) bool {
+		return io == BLEIODisplayYesNo || io == BLEIOKeyboardDisplay
+	}
+
+	if display(i) && display(r) {
+		if sc == true && i == BLEIODisplayYesNo && r == BLEIODisplayYesNo {
+			return BLEPairingNumericComparison
+		}
+		return BLEPairingJustWorks
+	} else if sc == true && confirm(i) && confirm(r) {
+		return BLEPairingNumericComparison
+	}
+
+	return BLEPairingPasskey
+}
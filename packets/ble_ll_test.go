@@ -0,0 +1,146 @@
+package packets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestBLEPairingMethod(t *testing.T) {
+	const (
+		bond = 0x01
+		mitm = bond | bleAuthMITM
+		sc   = mitm | bleAuthSC
+	)
+
+	tests := []struct {
+		reqIO, rspIO     byte
+		reqAuth, rspAuth byte
+		reqOOB, rspOOB   bool
+		expected         string
+	}{
+		{BLEIOKeyboardDisplay, BLEIODisplayOnly, bond, bond, false, false, BLEPairingJustWorks},
+		{BLEIOKeyboardDisplay, BLEIONoInputNoOutput, mitm, mitm, false, false, BLEPairingJustWorks},
+		{BLEIODisplayYesNo, BLEIODisplayYesNo, mitm, mitm, false, false, BLEPairingJustWorks},
+		{BLEIODisplayYesNo, BLEIODisplayYesNo, sc, sc, false, false, BLEPairingNumericComparison},
+		{BLEIOKeyboardDisplay, BLEIOKeyboardDisplay, mitm, mitm, false, false, BLEPairingPasskey},
+		{BLEIOKeyboardDisplay, BLEIOKeyboardDisplay, sc, sc, false, false, BLEPairingNumericComparison},
+		{BLEIOKeyboardDisplay, BLEIOKeyboardDisplay, sc, mitm, false, false, BLEPairingPasskey},
+		{BLEIODisplayOnly, BLEIOKeyboardOnly, sc, sc, false, false, BLEPairingPasskey},
+		{BLEIOKeyboardOnly, BLEIOKeyboardOnly, mitm, mitm, false, false, BLEPairingPasskey},
+		{BLEIOKeyboardOnly, BLEIODisplayOnly, mitm, bond, false, false, BLEPairingPasskey},
+		{BLEIOKeyboardDisplay, BLEIOKeyboardDisplay, mitm, mitm, true, false, BLEPairingPasskey},
+		{BLEIOKeyboardDisplay, BLEIOKeyboardDisplay, mitm, mitm, true, true, BLEPairingOOB},
+		{BLEIONoInputNoOutput, BLEIONoInputNoOutput, sc, sc, false, true, BLEPairingOOB},
+	}
+
+	for _, test := range tests {
+		req := &BLEPairing{Code: BLESMPPairingRequest, IOCapability: test.reqIO, AuthReq: test.reqAuth, OOB: test.reqOOB}
+		rsp := &BLEPairing{Code: BLESMPPairingResponse, IOCapability: test.rspIO, AuthReq: test.rspAuth, OOB: test.rspOOB}
+		if method := BLEPairingMethod(req, rsp); method != test.expected {
+			t.Fatalf("Expected %s for %s/%s, got %s", test.expected, BLEIOCapability(test.reqIO), BLEIOCapability(test.rspIO), method)
+		}
+	}
+}
+
+func bleLL(aa uint32, header byte, payload []byte) []byte {
+	raw := make([]byte, 4)
+	binary.LittleEndian.PutUint32(raw, aa)
+	raw = append(raw, header, byte(len(payload)))
+	raw = append(raw, payload...)
+	// CRC
+	return append(raw, 0, 0, 0)
+}
+
+func TestBLELLParsing(t *testing.T) {
+	connect := []byte{
+		// InitA and AdvA
+		0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa,
+		// access address, CRC init, window size and offset, interval
+		0x78, 0x56, 0x34, 0x12, 0x01, 0x02, 0x03, 0x02, 0x00, 0x00, 0x18, 0x00,
+		// latency, timeout, channel map, hop
+		0x00, 0x00, 0x48, 0x00, 0xff, 0xff, 0xff, 0xff, 0x1f, 0x05,
+	}
+
+	pkt, err := ParseBLELL(LinkTypeBLELLWithPHDR, append(make([]byte, blePHDRSize), bleLL(BLEAdvAccessAddress, bleAdvConnectInd, connect)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := pkt.ConnectInd()
+	if conn == nil {
+		t.Fatalf("Expected a connection request.")
+	} else if conn.Initiator != "11:22:33:44:55:66" || conn.Advertiser != "aa:bb:cc:dd:ee:ff" || conn.AccessAddress != 0x12345678 || conn.Interval != 0x18 {
+		t.Fatalf("Unexpected connection request %+v", conn)
+	} else if pkt.Pairing() != nil {
+		t.Fatalf("Unexpected pairing in a connection request.")
+	}
+
+	// L2CAP header on the SMP channel and a pairing request
+	smp := []byte{0x07, 0x00, 0x06, 0x00, BLESMPPairingRequest, BLEIOKeyboardDisplay, 0x00, 0x0d, 0x10, 0x07, 0x07}
+	if pkt, err = ParseBLELL(LinkTypeBLELL, bleLL(0x12345678, bleLLIDStart, smp)); err != nil {
+		t.Fatal(err)
+	}
+
+	pairing := pkt.Pairing()
+	if pairing == nil {
+		t.Fatalf("Expected a pairing request.")
+	} else if pairing.IOCapability != BLEIOKeyboardDisplay || pairing.MITM() == false || pairing.SecureConnections() == false || pairing.MaxKeySize != 0x10 {
+		t.Fatalf("Unexpected pairing request %+v", pairing)
+	} else if pkt.ConnectInd() != nil {
+		t.Fatalf("Unexpected connection request in a data packet.")
+	}
+
+	// same payload on the ATT channel
+	smp[2] = 0x04
+	if pkt, err = ParseBLELL(LinkTypeBLELL, bleLL(0x12345678, bleLLIDStart, smp)); err != nil {
+		t.Fatal(err)
+	} else if pkt.Pairing() != nil {
+		t.Fatalf("Unexpected pairing on the ATT channel.")
+	}
+
+	if _, err = ParseBLELL(LinkTypeBLELL, []byte{0xd6, 0xbe, 0x89, 0x8e, 0x05, 0x22, 0x00}); err == nil {
+		t.Fatalf("Expected an error for a truncated packet.")
+	} else if _, err = ParseBLELL(1, bleLL(BLEAdvAccessAddress, bleAdvConnectInd, connect)); err == nil {
+		t.Fatalf("Expected an error for an ethernet capture.")
+	}
+}
+
+func TestBLECapture(t *testing.T) {
+	data := bleLL(BLEAdvAccessAddress, 0x00, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+
+	raw := bytes.Buffer{}
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], 65535)
+	binary.LittleEndian.PutUint32(header[20:], LinkTypeBLELL)
+	raw.Write(header)
+
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(data)))
+	raw.Write(record)
+	raw.Write(data)
+
+	capture, err := NewBLECapture(&raw)
+	if err != nil {
+		t.Fatal(err)
+	} else if capture.LinkType != LinkTypeBLELL {
+		t.Fatalf("Expected link type %d, got %d", LinkTypeBLELL, capture.LinkType)
+	}
+
+	if read, err := capture.Next(); err != nil {
+		t.Fatal(err)
+	} else if bytes.Equal(read, data) == false {
+		t.Fatalf("Expected %x, got %x", data, read)
+	} else if _, err = capture.Next(); err == nil {
+		t.Fatalf("Expected the end of the capture.")
+	}
+
+	binary.LittleEndian.PutUint32(header[20:], 1)
+	if _, err := NewBLECapture(bytes.NewReader(header)); err == nil {
+		t.Fatalf("Expected an error for an ethernet capture.")
+	}
+}
@@ -0,0 +1,42 @@
+package packets
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Returns a short human readable description of a raw ethernet frame.
+func Describe(raw []byte) string {
+	pkt := gopacket.NewPacket(raw, layers.LayerTypeEthernet, gopacket.Default)
+
+	if l := pkt.Layer(layers.LayerTypeARP); l != nil {
+		arp := l.(*layers.ARP)
+		op := "request"
+		if arp.Operation == layers.ARPReply {
+			op = "reply"
+		}
+		return fmt.Sprintf("ARP %s %s is-at %s to %s ( %s )",
+			op,
+			net.IP(arp.SourceProtAddress),
+			net.HardwareAddr(arp.SourceHwAddress),
+			net.IP(arp.DstProtAddress),
+			net.HardwareAddr(arp.DstHwAddress))
+	}
+
+	names := make([]string, 0)
+	for _, l := range pkt.Layers() {
+		names = append(names, l.LayerType().String())
+	}
+
+	desc := strings.Join(names, "/")
+	if l := pkt.NetworkLayer(); l != nil {
+		flow := l.NetworkFlow()
+		desc += fmt.Sprintf(" %s > %s", flow.Src(), flow.Dst())
+	}
+
+	return fmt.Sprintf("%s ( %d bytes )", desc, len(raw))
+}
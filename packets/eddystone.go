@@ -0,0 +1,99 @@
+package packets
+
+import (
+	"errors"
+	"strings"
+)
+
+const EddystoneUUID = 0xfeaa
+const EddystoneFrameURL = 0x10
+
+// https://github.com/google/eddystone/tree/master/eddystone-url
+const EddystoneMaxURLSize = 17
+
+var (
+	ErrEddystoneURLScheme = errors.New("Eddystone URLs must start with http:// or https://")
+	ErrEddystoneURLSize   = errors.New("Eddystone URL is too long, the encoded value must fit in 17 bytes.")
+
+	eddystoneSchemes = []string{
+		"http://www.",
+		"https://www.",
+		"http://",
+		"https://",
+	}
+
+	eddystoneExpansions = []string{
+		".com/",
+		".org/",
+		".edu/",
+		".net/",
+		".info/",
+		".biz/",
+		".gov/",
+		".com",
+		".org",
+		".edu",
+		".net",
+		".info",
+		".biz",
+		".gov",
+	}
+)
+
+func EddystoneEncodeURL(url string) (err error, encoded []byte) {
+	scheme := -1
+	for i, prefix := range eddystoneSchemes {
+		if strings.HasPrefix(url, prefix) {
+			scheme = i
+			url = url[len(prefix):]
+			break
+		}
+	}
+
+	if scheme == -1 {
+		return ErrEddystoneURLScheme, nil
+	}
+
+	encoded = []byte{byte(scheme)}
+	for len(url) > 0 {
+		expanded := false
+		for i, exp := range eddystoneExpansions {
+			if strings.HasPrefix(url, exp) {
+				encoded = append(encoded, byte(i))
+				url = url[len(exp):]
+				expanded = true
+				break
+			}
+		}
+
+		if expanded == false {
+			encoded = append(encoded, url[0])
+			url = url[1:]
+		}
+	}
+
+	// the scheme byte is not counted
+	if len(encoded)-1 > EddystoneMaxURLSize {
+		return ErrEddystoneURLSize, nil
+	}
+
+	return nil, encoded
+}
+
+// Returns the service data payload of an Eddystone-URL frame, including
+// the 16 bit Eddystone service UUID.
+func EddystoneURLFrame(url string, txPower int8) (err error, frame []byte) {
+	err, encoded := EddystoneEncodeURL(url)
+	if err != nil {
+		return err, nil
+	}
+
+	frame = []byte{
+		EddystoneUUID & 0xff,
+		EddystoneUUID >> 8,
+		EddystoneFrameURL,
+		byte(txPower),
+	}
+
+	return nil, append(frame, encoded...)
+}
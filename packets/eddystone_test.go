@@ -0,0 +1,34 @@
+package packets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEddystoneEncodeURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected []byte
+	}{
+		{"https://www.google.com/", []byte{0x01, 'g', 'o', 'o', 'g', 'l', 'e', 0x00}},
+		{"http://bettercap.org", []byte{0x02, 'b', 'e', 't', 't', 'e', 'r', 'c', 'a', 'p', 0x08}},
+		{"https://goo.gl/S6zT6P", []byte{0x03, 'g', 'o', 'o', '.', 'g', 'l', '/', 'S', '6', 'z', 'T', '6', 'P'}},
+	}
+
+	for _, test := range tests {
+		err, encoded := EddystoneEncodeURL(test.url)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", test.url, err)
+		} else if bytes.Equal(encoded, test.expected) == false {
+			t.Fatalf("Expected %x for %s, got %x", test.expected, test.url, encoded)
+		}
+	}
+
+	if err, _ := EddystoneEncodeURL("ftp://example.com"); err != ErrEddystoneURLScheme {
+		t.Fatalf("Expected scheme error, got %v", err)
+	}
+
+	if err, _ := EddystoneEncodeURL("https://a-very-long-hostname.example/"); err != ErrEddystoneURLSize {
+		t.Fatalf("Expected size error, got %v", err)
+	}
+}
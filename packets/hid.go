@@ -0,0 +1,79 @@
+package packets
+
+const (
+	HIDTypeUnknown   = "Unknown"
+	HIDTypeLogitech  = "Logitech"
+	HIDTypeMicrosoft = "Microsoft"
+)
+
+// Logitech Unifying frame types.
+const (
+	LogitechKeepAlive      = 0x40
+	LogitechSetKeepAlive   = 0x4f
+	LogitechMouse          = 0xc2
+	LogitechKeystroke      = 0xc1
+	LogitechEncKeystroke   = 0xd3
+	LogitechMultimediaKeys = 0xc3
+)
+
+// Logitech keep alive timeout in milliseconds.
+const LogitechKeepAliveTimeout = 1200
+
+func LogitechChecksum(frame []byte) byte {
+	sum := byte(0)
+	for _, b := range frame {
+		sum += b
+	}
+	return byte(0x100 - int(sum))
+}
+
+func logitechFrame(data ...byte) []byte {
+	frame := append([]byte{0x00}, data...)
+	return append(frame, LogitechChecksum(frame))
+}
+
+func IsLogitechFrame(payload []byte) bool {
+	if len(payload) < 5 || payload[0] != 0x00 {
+		return false
+	}
+
+	switch payload[1] {
+	case LogitechKeepAlive, LogitechSetKeepAlive, LogitechMouse, LogitechKeystroke, LogitechEncKeystroke, LogitechMultimediaKeys:
+		return LogitechChecksum(payload[:len(payload)-1]) == payload[len(payload)-1]
+	}
+
+	return false
+}
+
+// Microsoft mice and keyboards use 19 bytes long frames
+// with a 0x08 ( mouse ) or 0x0a ( keyboard ) device type.
+func IsMicrosoftFrame(payload []byte) bool {
+	return len(payload) == 19 && (payload[0] == 0x08 || payload[0] == 0x0a)
+}
+
+func HIDDeviceType(payload []byte) string {
+	if IsLogitechFrame(payload) {
+		return HIDTypeLogitech
+	} else if IsMicrosoftFrame(payload) {
+		return HIDTypeMicrosoft
+	}
+	return HIDTypeUnknown
+}
+
+func LogitechSetKeepAliveFrame(timeout uint16) []byte {
+	return logitechFrame(LogitechSetKeepAlive, 0x00, byte(timeout>>8), byte(timeout&0xff), 0x10, 0x00, 0x00, 0x00)
+}
+
+func LogitechKeepAliveFrame(timeout uint16) []byte {
+	return logitechFrame(LogitechKeepAlive, byte(timeout>>8), byte(timeout&0xff))
+}
+
+// An unencrypted keystroke, the dongle will happily accept
+// it even if the keyboard is using encryption.
+func LogitechKeystrokeFrame(modifiers byte, key byte) []byte {
+	return logitechFrame(LogitechKeystroke, modifiers, key, 0x00, 0x00, 0x00, 0x00, 0x00)
+}
+
+func LogitechKeyReleaseFrame() []byte {
+	return LogitechKeystrokeFrame(0x00, 0x00)
+}
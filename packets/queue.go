@@ -31,6 +31,7 @@ type Queue struct {
 	handle *pcap.Handle
 	source *gopacket.PacketSource
 	active bool
+	dryRun func(raw []byte)
 
 	Activities  chan Activity `json:"-"`
 	Sent        uint64
@@ -49,6 +50,7 @@ func NewQueue(iface *bnet.Endpoint) (*Queue, error) {
 		handle:      nil,
 		active:      true,
 		source:      nil,
+		dryRun:      nil,
 		Sent:        0,
 		Received:    0,
 		PktReceived: 0,
@@ -70,6 +72,9 @@ func NewQueue(iface *bnet.Endpoint) (*Queue, error) {
 }
 
 func (q *Queue) worker() {
+	// let the readers know the queue is gone
+	defer close(q.Activities)
+
 	for pkt := range q.source.Packets() {
 		if q.active == false {
 			return
@@ -142,7 +147,10 @@ func (q *Queue) Send(raw []byte) error {
 	q.Lock()
 	defer q.Unlock()
 
-	if q.active {
+	if q.dryRun != nil {
+		q.dryRun(raw)
+		return nil
+	} else if q.active {
 		err := q.handle.WritePacketData(raw)
 		if err == nil {
 			q.Sent += uint64(len(raw))
@@ -155,6 +163,13 @@ func (q *Queue) Send(raw []byte) error {
 	}
 }
 
+// Packets will be passed to cb instead of being sent.
+func (q *Queue) SetDryRun(cb func(raw []byte)) {
+	q.Lock()
+	defer q.Unlock()
+	q.dryRun = cb
+}
+
 func (q *Queue) Stop() {
 	q.Lock()
 	defer q.Unlock()
@@ -0,0 +1,52 @@
+package packets
+
+import (
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Builds a TCP reset from one endpoint to the other, if ack is not
+// zero the ACK flag is set too, as needed to refuse a SYN.
+func NewTCPReset(from net.IP, from_hw net.HardwareAddr, to net.IP, to_hw net.HardwareAddr, srcPort uint16, dstPort uint16, seq uint32, ack uint32) (error, []byte) {
+	eth := layers.Ethernet{
+		SrcMAC:       from_hw,
+		DstMAC:       to_hw,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	tcp := layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		Seq:     seq,
+		Ack:     ack,
+		RST:     true,
+		ACK:     ack != 0,
+	}
+
+	var ip gopacket.NetworkLayer
+	if from.To4() != nil {
+		ip4 := &layers.IPv4{
+			Protocol: layers.IPProtocolTCP,
+			Version:  4,
+			TTL:      64,
+			SrcIP:    from.To4(),
+			DstIP:    to.To4(),
+		}
+		ip = ip4
+	} else {
+		eth.EthernetType = layers.EthernetTypeIPv6
+		ip = &layers.IPv6{
+			Version:    6,
+			NextHeader: layers.IPProtocolTCP,
+			HopLimit:   64,
+			SrcIP:      from,
+			DstIP:      to,
+		}
+	}
+
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	return Serialize(&eth, ip.(gopacket.SerializableLayer), &tcp)
+}
@@ -0,0 +1,138 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+const AliasesFile = "~/.bettercap_aliases"
+
+// Maximum number of nested alias expansions.
+const maxAliasDepth = 16
+
+type Aliases struct {
+	sync.Mutex
+
+	filename string
+	data     map[string]string
+	// restored with a session, never saved to the file
+	volatile map[string]string
+}
+
+// Aliases are saved one per line as "name = commands".
+func LoadAliases(filename string) (*Aliases, error) {
+	a := &Aliases{
+		filename: filename,
+		data:     make(map[string]string),
+		volatile: make(map[string]string),
+	}
+
+	filename, err := core.ExpandPath(filename)
+	if err != nil {
+		return a, err
+	}
+	a.filename = filename
+
+	fp, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return a, nil
+		}
+		return a, err
+	}
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return a, fmt.Errorf("Invalid alias line in %s: %s", filename, line)
+		}
+
+		a.data[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return a, scanner.Err()
+}
+
+func (a *Aliases) save() error {
+	names := make([]string, 0, len(a.data))
+	for name := range a.data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := ""
+	for _, name := range names {
+		data += fmt.Sprintf("%s = %s\n", name, a.data[name])
+	}
+
+	return ioutil.WriteFile(a.filename, []byte(data), 0644)
+}
+
+func (a *Aliases) Set(name, commands string) error {
+	a.Lock()
+	defer a.Unlock()
+	delete(a.volatile, name)
+	a.data[name] = commands
+	return a.save()
+}
+
+// Defines the alias for the current session only, overriding
+// the saved one with the same name if any, without saving it.
+func (a *Aliases) SetVolatile(name, commands string) {
+	a.Lock()
+	defer a.Unlock()
+	a.volatile[name] = commands
+}
+
+func (a *Aliases) Del(name string) error {
+	a.Lock()
+	defer a.Unlock()
+	if _, found := a.volatile[name]; found == true {
+		delete(a.volatile, name)
+		return nil
+	} else if _, found := a.data[name]; found == false {
+		return fmt.Errorf("Alias '%s' not found.", name)
+	}
+	delete(a.data, name)
+	return a.save()
+}
+
+func (a *Aliases) Get(name string) (string, bool) {
+	a.Lock()
+	defer a.Unlock()
+	if commands, found := a.volatile[name]; found == true {
+		return commands, true
+	}
+	commands, found := a.data[name]
+	return commands, found
+}
+
+func (a *Aliases) Names() []string {
+	a.Lock()
+	defer a.Unlock()
+	names := make([]string, 0, len(a.data)+len(a.volatile))
+	for name := range a.data {
+		names = append(names, name)
+	}
+	for name := range a.volatile {
+		if _, found := a.data[name]; found == false {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,97 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Capability struct {
+	Name string
+	// bit in the capabilities mask, negative if root is needed
+	Bit int
+}
+
+var (
+	CapNetBindService = Capability{"cap_net_bind_service", 10}
+	CapNetAdmin       = Capability{"cap_net_admin", 12}
+	CapNetRaw         = Capability{"cap_net_raw", 13}
+	// changing the firewall and the kernel parameters runs external
+	// tools and writes to /proc, this can't be granted with setcap
+	CapRoot = Capability{"root", -1}
+)
+
+// Needed to open the interface with pcap and inject packets.
+var SessionCapabilities = []Capability{CapNetRaw, CapNetAdmin}
+
+var ModuleCapabilities = map[string][]Capability{
+	"arp.spoof":      []Capability{CapNetRaw, CapNetAdmin, CapRoot},
+	"net.quarantine": []Capability{CapNetRaw, CapNetAdmin, CapRoot},
+	"arp.watch":      []Capability{CapNetRaw, CapNetAdmin},
+	"ra.watch":       []Capability{CapNetRaw, CapNetAdmin},
+	"dns.spoof":      []Capability{CapNetRaw, CapNetAdmin},
+	"dhcp6.spoof":    []Capability{CapNetRaw, CapNetAdmin},
+	"dns.server":     []Capability{CapNetBindService},
+	"dns.encrypted":  []Capability{CapNetRaw, CapNetAdmin},
+	"mail.honeypot":  []Capability{CapNetBindService},
+	"ftp.honeypot":   []Capability{CapNetBindService},
+	"smb.relay":      []Capability{CapNetBindService},
+	"net.probe":      []Capability{CapNetRaw, CapNetAdmin},
+	"net.sniff":      []Capability{CapNetRaw, CapNetAdmin},
+	"packets.replay": []Capability{CapNetRaw, CapNetAdmin},
+	"ble.recon":      []Capability{CapNetRaw, CapNetAdmin},
+	"ble.advertise":  []Capability{CapNetRaw, CapNetAdmin},
+	"http.proxy":     []Capability{CapRoot},
+	"https.proxy":    []Capability{CapRoot},
+	"firewall":       []Capability{CapRoot},
+	"mac.changer":    []Capability{CapRoot},
+}
+
+func isRoot() bool {
+	return os.Geteuid() == 0
+}
+
+func missingCapabilities(caps []Capability) []Capability {
+	missing := make([]Capability, 0)
+	if isRoot() == true {
+		return missing
+	}
+
+	for _, c := range caps {
+		if c.Bit < 0 || hasCapability(c) == false {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// Returns an error explaining what is missing and how to grant it.
+func checkCapabilities(what string, caps []Capability) error {
+	missing := missingCapabilities(caps)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0)
+	for _, c := range missing {
+		if c.Bit < 0 {
+			return fmt.Errorf("%s must run as root since it changes the firewall or the kernel parameters.", what)
+		}
+		names = append(names, c.Name)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+
+	return fmt.Errorf("%s needs %s, run as root or grant them with: sudo setcap %s+eip %s",
+		what,
+		strings.Join(names, ", "),
+		strings.Join(names, ","),
+		exe)
+}
+
+func (s *Session) checkModuleCapabilities(name string) error {
+	return checkCapabilities("The "+name+" module", ModuleCapabilities[name])
+}
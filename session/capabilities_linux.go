@@ -0,0 +1,27 @@
+package session
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Reads the effective capabilities set from /proc.
+func hasCapability(c Capability) bool {
+	raw, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.HasPrefix(line, "CapEff:") {
+			mask, err := strconv.ParseUint(strings.TrimSpace(line[7:]), 16, 64)
+			if err != nil {
+				return false
+			}
+			return mask&(1<<uint(c.Bit)) != 0
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package session
+
+// Capabilities are a Linux thing, elsewhere only root has them.
+func hasCapability(c Capability) bool {
+	return false
+}
@@ -0,0 +1,195 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+const CapletExtension = ".cap"
+
+// Colon separated list of folders to look for caplets into.
+const CapletsPathEnv = "CAPSPATH"
+
+// Changes how errors of the following commands are handled.
+var capletOnErrorParser = regexp.MustCompile(`^on-error\s+([^\s]+)$`)
+
+// Includes are run by the caplet itself, see runCapletLine.
+var capletIncludeParser = regexp.MustCompile(`^include\s+(.+)`)
+
+// Positional arguments like $1, $2, etc.
+var capletArgParser = regexp.MustCompile(`\$(\d+)`)
+
+// Splits the include command arguments on spaces, words
+// between double quotes are considered as a single argument.
+func capletArgs(line string) []string {
+	args := make([]string, 0)
+	quoted := false
+	arg := ""
+	for _, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if (c == ' ' || c == '\t') && quoted == false {
+			if arg != "" {
+				args = append(args, arg)
+				arg = ""
+			}
+		} else {
+			arg += string(c)
+		}
+	}
+	if arg != "" {
+		args = append(args, arg)
+	}
+	return args
+}
+
+// Replaces $1, $2, etc. with the given arguments, $0 is the caplet name.
+func capletExpand(line string, filename string, args []string) string {
+	return capletArgParser.ReplaceAllStringFunc(line, func(token string) string {
+		idx, _ := strconv.Atoi(token[1:])
+		if idx == 0 {
+			return filename
+		} else if idx <= len(args) {
+			return args[idx-1]
+		}
+		return ""
+	})
+}
+
+func capletCandidates(filename string) []string {
+	candidates := []string{filename}
+	if filepath.Ext(filename) != CapletExtension {
+		candidates = append(candidates, filename+CapletExtension)
+	}
+	return candidates
+}
+
+// Resolves a caplet name to a file, looking first at the given path
+// (with and without the .cap extension) and then into $CAPSPATH.
+func FindCaplet(filename string) (string, error) {
+	filename, err := core.ExpandPath(filename)
+	if err != nil {
+		return "", err
+	}
+
+	folders := []string{""}
+	if filepath.IsAbs(filename) == false {
+		for _, folder := range filepath.SplitList(os.Getenv(CapletsPathEnv)) {
+			if folder != "" {
+				folders = append(folders, folder)
+			}
+		}
+	}
+
+	for _, folder := range folders {
+		for _, candidate := range capletCandidates(filename) {
+			path := filepath.Join(folder, candidate)
+			if info, err := os.Stat(path); err == nil && info.IsDir() == false {
+				return path, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("Could not find caplet %s.", filename)
+}
+
+func (s *Session) RunCaplet(filename string, args ...string) error {
+	return s.runCaplet(filename, args, nil)
+}
+
+// Runs the caplet, stack holds the caplets including it in this
+// call chain, as caplets can run concurrently from triggers, the
+// scheduler, the API and so on.
+func (s *Session) runCaplet(filename string, args []string, stack []string) error {
+	filename, err := FindCaplet(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, running := range stack {
+		if running == filename {
+			return fmt.Errorf("Caplet %s is already being executed, recursive includes are not allowed.", filename)
+		}
+	}
+
+	// never share the backing array with the other includes of the caller
+	stack = append(stack[:len(stack):len(stack)], filename)
+
+	s.Events.Log(core.INFO, "Reading from caplet %s ...", filename)
+
+	input, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	scanner := bufio.NewScanner(input)
+	scanner.Split(bufio.ScanLines)
+
+	lineno := 0
+	abort := true
+	for scanner.Scan() {
+		lineno++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		line = capletExpand(line, filename, args)
+
+		if m := capletOnErrorParser.FindStringSubmatch(line); len(m) == 2 {
+			switch m[1] {
+			case "abort":
+				abort = true
+			case "continue":
+				abort = false
+			default:
+				return fmt.Errorf("%s:%d: on-error expects abort or continue, got '%s'.", filename, lineno, m[1])
+			}
+			continue
+		}
+
+		if err = s.runCapletLine(line, stack, 1); err != nil {
+			if abort == true {
+				return fmt.Errorf("%s:%d: %s", filename, lineno, err)
+			}
+			s.Events.Log(core.ERROR, "%s:%d: %s", filename, lineno, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Runs a line of the caplet on top of the stack, includes, also the ones
+// in aliases, are handled here so that they know the caplets being run.
+func (s *Session) runCapletLine(line string, stack []string, depth int) error {
+	expanded := s.Env.Expand(line)
+
+	if m := capletIncludeParser.FindStringSubmatch(expanded); len(m) == 2 {
+		args := capletArgs(m[1])
+		if len(args) == 0 {
+			return fmt.Errorf("No caplet specified.")
+		}
+		return s.runCaplet(args[0], args[1:], stack)
+	} else if commands, found := s.Aliases.Get(expanded); found == true {
+		if depth > maxAliasDepth {
+			return fmt.Errorf("Too many nested aliases, check your aliases for loops.")
+		}
+		for _, cmd := range ParseCommands(commands) {
+			if err := s.runCapletLine(cmd, stack, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return s.Run(line)
+}
@@ -0,0 +1,235 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newTestSession(t *testing.T) *Session {
+	s := &Session{
+		CoreHandlers: make([]CommandHandler, 0),
+		Modules:      make([]Module, 0),
+		triggersLock: &sync.Mutex{},
+		triggerRuns:  make(map[string]*triggerRun),
+	}
+	s.Env = NewEnvironment(s)
+	s.Events = NewEventPool(false, true, 64)
+	s.Aliases, _ = LoadAliases(filepath.Join(os.TempDir(), "bettercap-test-aliases-missing"))
+	s.registerCoreHandlers()
+	return s
+}
+
+func runTestCaplet(t *testing.T, s *Session, source string, args ...string) error {
+	dir, err := ioutil.TempDir("", "bettercap-caplets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "test.cap")
+	if err = ioutil.WriteFile(filename, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return s.RunCaplet(filename, args...)
+}
+
+func TestCapletArgs(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"a", []string{"a"}},
+		{"a b\tc", []string{"a", "b", "c"}},
+		{"  a   b  ", []string{"a", "b"}},
+		{`a "b c" d`, []string{"a", "b c", "d"}},
+		{`"a b"`, []string{"a b"}},
+		{`a"b c"d`, []string{"ab cd"}},
+		{`"" a`, []string{"a"}},
+		{`"a  b`, []string{"a  b"}},
+	}
+
+	for _, test := range tests {
+		if got := capletArgs(test.line); reflect.DeepEqual(got, test.expected) == false {
+			t.Fatalf("Expected %q for '%s', got %q", test.expected, test.line, got)
+		}
+	}
+}
+
+func TestCapletExpand(t *testing.T) {
+	args := []string{"eth0", "192.168.1.0/24"}
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"net.recon on", "net.recon on"},
+		{"set arp.spoof.targets $2", "set arp.spoof.targets 192.168.1.0/24"},
+		{"set iface $1; set targets $2", "set iface eth0; set targets 192.168.1.0/24"},
+		{"echo $0", "echo test.cap"},
+		{"set missing $3", "set missing "},
+		{"set twice $1$1", "set twice eth0eth0"},
+		{"set $ $a", "set $ $a"},
+	}
+
+	for _, test := range tests {
+		if got := capletExpand(test.line, "test.cap", args); got != test.expected {
+			t.Fatalf("Expected '%s' for '%s', got '%s'", test.expected, test.line, got)
+		}
+	}
+}
+
+func TestCapletOnErrorParser(t *testing.T) {
+	tests := []struct {
+		line     string
+		matches  bool
+		behavior string
+	}{
+		{"on-error abort", true, "abort"},
+		{"on-error continue", true, "continue"},
+		{"on-error   continue", true, "continue"},
+		{"on-error whatever", true, "whatever"},
+		{"on-error", false, ""},
+		{"on-error abort now", false, ""},
+		{"set on-error abort", false, ""},
+	}
+
+	for _, test := range tests {
+		m := capletOnErrorParser.FindStringSubmatch(test.line)
+		if matches := len(m) == 2; matches != test.matches {
+			t.Fatalf("Expected '%s' to match: %v", test.line, test.matches)
+		} else if matches == true && m[1] != test.behavior {
+			t.Fatalf("Expected '%s' for '%s', got '%s'", test.behavior, test.line, m[1])
+		}
+	}
+}
+
+func TestCapletOnError(t *testing.T) {
+	tests := []struct {
+		source string
+		fails  bool
+		value  string
+	}{
+		{"set a 1\nunknown.command\nset a 2", true, "1"},
+		{"on-error continue\nset a 1\nunknown.command\nset a 2", false, "2"},
+		{"on-error continue\nunknown.command\non-error abort\nunknown.command\nset a 2", true, ""},
+		{"on-error maybe\nset a 1", true, ""},
+	}
+
+	for _, test := range tests {
+		s := newTestSession(t)
+		err := runTestCaplet(t, s, test.source)
+		if (err != nil) != test.fails {
+			t.Fatalf("Unexpected result for %q: %v", test.source, err)
+		} else if _, value := s.Env.Get("a"); value != test.value {
+			t.Fatalf("Expected a='%s' for %q, got '%s'", test.value, test.source, value)
+		}
+	}
+}
+
+func TestCapletIf(t *testing.T) {
+	tests := []struct {
+		line     string
+		parsed   bool
+		expected string
+	}{
+		{"if x == 1 then set a yes", true, "yes"},
+		{"if x != 1 then set a yes", true, ""},
+		{"if x == 2 then set a yes", true, ""},
+		{"if x != 2 then set a yes", true, "yes"},
+		{`if y == "" then set a yes`, true, "yes"},
+		{`if z == "a b" then set a yes`, true, "yes"},
+		{`if x == 1 then "set a yes; set b yes"`, true, "yes"},
+		{"if undefined == undefined then set a yes", true, "yes"},
+		{"if x = 1 then set a yes", false, ""},
+		{"if x == 1 set a yes", false, ""},
+	}
+
+	for _, test := range tests {
+		s := newTestSession(t)
+		s.Env.Set("x", "1")
+		s.Env.Set("y", "")
+		s.Env.Set("z", "a b")
+
+		err := s.Run(test.line)
+		if (err == nil) != test.parsed {
+			t.Fatalf("Unexpected result for '%s': %v", test.line, err)
+		} else if _, value := s.Env.Get("a"); value != test.expected {
+			t.Fatalf("Expected a='%s' for '%s', got '%s'", test.expected, test.line, value)
+		}
+	}
+
+	// the condition applies to all the quoted commands
+	s := newTestSession(t)
+	if err := s.Run(`if x == 1 then "set a yes; set b yes"`); err != nil {
+		t.Fatal(err)
+	} else if _, value := s.Env.Get("b"); value != "" {
+		t.Fatalf("Expected b to be unset, got '%s'", value)
+	}
+}
+
+func TestCapletExpandWithoutArgs(t *testing.T) {
+	s := newTestSession(t)
+	if err := runTestCaplet(t, s, "set a x$1\nset b $0"); err != nil {
+		t.Fatal(err)
+	} else if _, value := s.Env.Get("a"); value != "x" {
+		t.Fatalf("Expected a='x', got '%s'", value)
+	} else if _, value = s.Env.Get("b"); filepath.Base(value) != "test.cap" {
+		t.Fatalf("Expected b to be the caplet name, got '%s'", value)
+	}
+}
+
+func TestCapletIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bettercap-caplets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	caplets := map[string]string{
+		"common.cap":    "set common yes",
+		"main.cap":      "include {dir}/common\ninclude {dir}/common\nset main yes",
+		"recursive.cap": "include {dir}/indirect",
+		"indirect.cap":  "include {dir}/recursive",
+		"aliased.cap":   "again",
+	}
+	for name, source := range caplets {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newTestSession(t)
+	s.Env.Set("dir", dir)
+	s.Aliases.SetVolatile("again", "include {dir}/aliased")
+
+	for _, name := range []string{"recursive", "aliased"} {
+		if err = s.RunCaplet(filepath.Join(dir, name)); err == nil || strings.Contains(err.Error(), "recursive includes") == false {
+			t.Fatalf("Expected %s to fail because of the recursive include, got %v", name, err)
+		}
+	}
+
+	// unrelated callers including the same caplet at the same time
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.RunCaplet(filepath.Join(dir, "main"))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package session
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/firewall"
+)
+
+type ContainerInfo struct {
+	Runtime     string `json:"runtime"`
+	HostNetwork bool   `json:"host_network"`
+}
+
+// Modules which, in a container with its own network namespace, would
+// only affect the container itself while appearing to work.
+var ContainerHostModules = []string{
+	"arp.spoof",
+	"dhcp6.spoof",
+	"dns.spoof",
+	"http.proxy",
+	"https.proxy",
+	"net.quarantine",
+}
+
+func readTrimmed(filename string) string {
+	if raw, err := ioutil.ReadFile(filename); err == nil {
+		return strings.TrimSpace(string(raw))
+	}
+	return ""
+}
+
+func containerRuntime() string {
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	} else if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	} else if runtime := os.Getenv("container"); runtime != "" {
+		return runtime
+	}
+
+	cgroup := readTrimmed("/proc/1/cgroup")
+	for _, runtime := range []string{"docker", "kubepods", "containerd", "lxc"} {
+		if strings.Contains(cgroup, runtime) {
+			return runtime
+		}
+	}
+
+	return ""
+}
+
+// Interfaces of a namespaced container are veth peers, whose
+// iflink is the index of the other end living on the host.
+func isHostNetwork(iface string) bool {
+	ifindex := readTrimmed(fmt.Sprintf("/sys/class/net/%s/ifindex", iface))
+	iflink := readTrimmed(fmt.Sprintf("/sys/class/net/%s/iflink", iface))
+	return ifindex == "" || ifindex == iflink
+}
+
+// Returns nil if not running inside a container.
+func DetectContainer(iface string) *ContainerInfo {
+	runtime := containerRuntime()
+	if runtime == "" {
+		return nil
+	}
+
+	return &ContainerInfo{
+		Runtime:     runtime,
+		HostNetwork: isHostNetwork(iface),
+	}
+}
+
+func (s *Session) setupContainer() {
+	info := DetectContainer(s.Interface.Name())
+
+	if *s.Options.Container == false {
+		if info != nil {
+			s.Events.Log(core.WARNING, "Running inside a %s container, use -container to skip the operations which would have no effect.", info.Runtime)
+		}
+		return
+	} else if info == nil {
+		info = &ContainerInfo{
+			Runtime:     "unknown",
+			HostNetwork: isHostNetwork(s.Interface.Name()),
+		}
+	}
+
+	s.Container = info
+	firewall.Container = true
+
+	if info.HostNetwork == false {
+		s.Events.Log(core.WARNING, "The container has its own network namespace, %s will be disabled, run it with --net=host to attack the host network.", strings.Join(ContainerHostModules, ", "))
+	}
+}
+
+func (s *Session) checkContainer(name string) error {
+	if s.Container == nil || s.Container.HostNetwork == true {
+		return nil
+	}
+
+	for _, mod := range ContainerHostModules {
+		if mod == name {
+			return fmt.Errorf("The %s module would only affect the network namespace of the container, run it with --net=host.", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package session
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credential types.
+const (
+	CredCleartext = "cleartext"
+	CredCookie    = "cookie"
+	CredNetNTLMv1 = "netntlmv1"
+	CredNetNTLMv2 = "netntlmv2"
+	CredHandshake = "handshake"
+)
+
+// A credential captured by any module, fields which don't
+// apply to the credential type are left empty.
+type Credential struct {
+	Type        string    `json:"type"`
+	Protocol    string    `json:"protocol"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Username    string    `json:"username"`
+	Domain      string    `json:"domain"`
+	Password    string    `json:"password"`
+	Challenge   string    `json:"challenge"`
+	LMResponse  string    `json:"lm_response"`
+	NTResponse  string    `json:"nt_response"`
+	Hash        string    `json:"hash"`
+	Time        time.Time `json:"time"`
+}
+
+func (c *Credential) key() string {
+	return strings.Join([]string{
+		c.Type, c.Protocol, c.Destination, c.Username, c.Domain,
+		c.Password, c.Challenge, c.NTResponse, c.Hash,
+	}, "\x00")
+}
+
+type Credentials struct {
+	sync.Mutex
+	session *Session
+	list    []*Credential
+	seen    map[string]bool
+	// if set, the credentials are kept encrypted on disk too
+	vault     *credsVault
+	saveTimer *time.Timer
+}
+
+func NewCredentials(s *Session) *Credentials {
+	return &Credentials{
+		session: s,
+		list:    make([]*Credential, 0),
+		seen:    make(map[string]bool),
+	}
+}
+
+// Stores the credential and emits a creds.new event, returns
+// false if the very same credential was already captured.
+func (c *Credentials) Add(cred *Credential) bool {
+	if cred.Time.IsZero() {
+		cred.Time = time.Now()
+	}
+
+	c.Lock()
+	key := cred.key()
+	if c.seen[key] == true {
+		c.Unlock()
+		return false
+	}
+	c.seen[key] = true
+	c.list = append(c.list, cred)
+	if c.vault != nil {
+		c.scheduleSave()
+	}
+	c.Unlock()
+
+	c.session.Events.Add("creds.new", cred)
+	return true
+}
+
+func (c *Credentials) List() []*Credential {
+	c.Lock()
+	defer c.Unlock()
+
+	list := make([]*Credential, len(c.list))
+	copy(list, c.list)
+	return list
+}
+
+// Forgets the captured credentials, removing them from the vault too if open.
+func (c *Credentials) Clear() error {
+	c.Lock()
+	defer c.Unlock()
+
+	c.list = make([]*Credential, 0)
+	c.seen = make(map[string]bool)
+	return c.saveNow()
+}
@@ -0,0 +1,296 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+const (
+	vaultVersion    = 1
+	vaultKDF        = "pbkdf2-sha256"
+	vaultIterations = 200000
+	vaultSaltSize   = 16
+	vaultKeySize    = 32
+	// credentials often come in bursts, save them all at once
+	vaultSaveDelay = 2 * time.Second
+)
+
+// Encrypted container of the captured credentials as stored on disk,
+// the credentials are encrypted with AES-256-GCM using a key derived
+// from the passphrase or the contents of the key file.
+type vaultFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+type credsVault struct {
+	path       string
+	salt       []byte
+	iterations int
+	key        []byte
+}
+
+// PBKDF2 as defined by RFC 8018 with HMAC-SHA256.
+func pbkdf2SHA256(password []byte, salt []byte, iterations int, size int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, size)
+	block := make([]byte, 4)
+
+	for n := uint32(1); len(key) < size; n++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block, n)
+		prf.Write(block)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:size]
+}
+
+func (v *credsVault) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(v.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Opens the vault at path, or prepares a new one if the file doesn't
+// exist yet, returning the credentials it contains.
+func openVault(path string, secret []byte) (*credsVault, []*Credential, error) {
+	v := &credsVault{
+		path:       path,
+		iterations: vaultIterations,
+	}
+	creds := make([]*Credential, 0)
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		v.salt = make([]byte, vaultSaltSize)
+		if _, err = rand.Read(v.salt); err != nil {
+			return nil, nil, err
+		}
+		v.key = pbkdf2SHA256(secret, v.salt, v.iterations, vaultKeySize)
+		return v, creds, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	var file vaultFile
+	if err = json.Unmarshal(raw, &file); err != nil {
+		return nil, nil, fmt.Errorf("Error while parsing %s: %s", path, err)
+	} else if file.Version != vaultVersion || file.KDF != vaultKDF || file.Iterations < 1 {
+		return nil, nil, fmt.Errorf("Unsupported vault format in %s.", path)
+	}
+
+	v.salt = file.Salt
+	v.iterations = file.Iterations
+	v.key = pbkdf2SHA256(secret, v.salt, v.iterations, vaultKeySize)
+
+	aead, err := v.aead()
+	if err != nil {
+		return nil, nil, err
+	} else if len(file.Nonce) != aead.NonceSize() {
+		return nil, nil, fmt.Errorf("Invalid nonce in %s.", path)
+	}
+
+	plain, err := aead.Open(nil, file.Nonce, file.Data, []byte(file.KDF))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Could not decrypt %s, wrong passphrase or key file.", path)
+	} else if err = json.Unmarshal(plain, &creds); err != nil {
+		return nil, nil, fmt.Errorf("Error while parsing the credentials of %s: %s", path, err)
+	}
+
+	return v, creds, nil
+}
+
+// Encrypts the credentials with a fresh nonce and replaces the file
+// atomically, so that a crash never leaves a truncated vault behind.
+func (v *credsVault) save(creds []*Credential) error {
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	aead, err := v.aead()
+	if err != nil {
+		return err
+	}
+
+	file := vaultFile{
+		Version:    vaultVersion,
+		KDF:        vaultKDF,
+		Iterations: v.iterations,
+		Salt:       v.salt,
+		Nonce:      make([]byte, aead.NonceSize()),
+	}
+	if _, err = rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plain, []byte(file.KDF))
+
+	raw, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(v.path), "."+filepath.Base(v.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(raw); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), v.path)
+}
+
+func (v *credsVault) wipe() {
+	for i := range v.key {
+		v.key[i] = 0
+	}
+}
+
+// Returns the path of the vault the credentials are stored
+// in, or an empty string if none is open.
+func (c *Credentials) VaultPath() string {
+	c.Lock()
+	defer c.Unlock()
+	if c.vault == nil {
+		return ""
+	}
+	return c.vault.path
+}
+
+// Decrypts the vault at path, creating it if needed, merges its
+// credentials with the ones captured so far and keeps it updated
+// with the following ones until the vault is closed.
+func (c *Credentials) OpenVault(path string, secret []byte) error {
+	path, err := core.ExpandPath(path)
+	if err != nil {
+		return err
+	}
+
+	if open := c.VaultPath(); open != "" {
+		return fmt.Errorf("The vault %s is already open.", open)
+	}
+
+	// deriving the key takes a while, don't block the modules adding credentials
+	v, stored, err := openVault(path, secret)
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.vault != nil {
+		v.wipe()
+		return fmt.Errorf("The vault %s is already open.", c.vault.path)
+	}
+
+	for _, cred := range stored {
+		if key := cred.key(); c.seen[key] == false {
+			c.seen[key] = true
+			c.list = append(c.list, cred)
+		}
+	}
+
+	if err = v.save(c.list); err != nil {
+		v.wipe()
+		return err
+	}
+	c.vault = v
+
+	c.session.Events.Log(core.INFO, "Vault %s unlocked, %d credentials loaded.", path, len(stored))
+	return nil
+}
+
+// Schedules a save of the vault, the caller must hold the lock.
+func (c *Credentials) scheduleSave() {
+	if c.saveTimer == nil {
+		c.saveTimer = time.AfterFunc(vaultSaveDelay, func() {
+			if err := c.SaveVault(); err != nil {
+				c.session.Events.Log(core.ERROR, "Error while saving the vault: %s", err)
+			}
+		})
+	}
+}
+
+// Saves the vault right away, the caller must hold the lock.
+func (c *Credentials) saveNow() error {
+	if c.saveTimer != nil {
+		c.saveTimer.Stop()
+		c.saveTimer = nil
+	}
+	if c.vault == nil {
+		return nil
+	}
+	return c.vault.save(c.list)
+}
+
+// Writes the credentials captured since the last save to the
+// vault, if one is open.
+func (c *Credentials) SaveVault() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.saveNow()
+}
+
+// Saves the vault and forgets both its key and the credentials.
+func (c *Credentials) CloseVault() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.vault == nil {
+		return fmt.Errorf("No vault is open.")
+	} else if err := c.saveNow(); err != nil {
+		// keep it open so the credentials are not lost
+		return err
+	}
+
+	path := c.vault.path
+	c.vault.wipe()
+	c.vault = nil
+	c.list = make([]*Credential, 0)
+	c.seen = make(map[string]bool)
+
+	c.session.Events.Log(core.INFO, "Vault %s locked.", path)
+	return nil
+}
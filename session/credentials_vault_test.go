@@ -0,0 +1,136 @@
+package session
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914, section 11
+	tests := []struct {
+		password   string
+		salt       string
+		iterations int
+		expected   string
+	}{
+		{
+			"passwd", "salt", 1,
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+				"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+		},
+		{
+			"Password", "NaCl", 80000,
+			"4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+				"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d",
+		},
+	}
+
+	for _, test := range tests {
+		key := hex.EncodeToString(pbkdf2SHA256([]byte(test.password), []byte(test.salt), test.iterations, 64))
+		if key != test.expected {
+			t.Fatalf("Expected %s for '%s' / '%s', got %s", test.expected, test.password, test.salt, key)
+		}
+		// shorter keys are a prefix of the longer ones
+		key = hex.EncodeToString(pbkdf2SHA256([]byte(test.password), []byte(test.salt), test.iterations, vaultKeySize))
+		if key != test.expected[:2*vaultKeySize] {
+			t.Fatalf("Expected %s for a %d bytes key, got %s", test.expected[:2*vaultKeySize], vaultKeySize, key)
+		}
+	}
+}
+
+func newTestVaultPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "bettercap-vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "creds.vault")
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := newTestVaultPath(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	v, creds, err := openVault(path, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(creds) != 0 {
+		t.Fatalf("Expected no credentials in a new vault, got %d", len(creds))
+	}
+
+	stored := []*Credential{
+		{Type: CredCleartext, Protocol: "FTP", Username: "admin", Password: "hunter2"},
+		{Type: CredNetNTLMv2, Protocol: "SMB", Username: "user", Domain: "CORP", NTResponse: "0011"},
+	}
+	if err = v.save(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	_, creds, err = openVault(path, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(creds) != len(stored) {
+		t.Fatalf("Expected %d credentials, got %d", len(stored), len(creds))
+	}
+	for i, cred := range creds {
+		if cred.key() != stored[i].key() {
+			t.Fatalf("Expected %+v, got %+v", stored[i], cred)
+		}
+	}
+}
+
+func TestVaultWrongPassword(t *testing.T) {
+	path := newTestVaultPath(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	v, _, err := openVault(path, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	} else if err = v.save([]*Credential{{Type: CredCleartext, Password: "hunter2"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err = openVault(path, []byte("Secret")); err == nil {
+		t.Fatalf("Expected an error with the wrong password.")
+	}
+}
+
+func TestVaultDelayedSave(t *testing.T) {
+	path := newTestVaultPath(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	s := &Session{}
+	s.Events = NewEventPool(false, true, 64)
+	c := NewCredentials(s)
+
+	if err := c.OpenVault(path, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range []string{"a", "b", "c"} {
+		c.Add(&Credential{Type: CredCleartext, Password: password})
+	}
+
+	// the burst is saved at once later on
+	if _, creds, err := openVault(path, []byte("secret")); err != nil {
+		t.Fatal(err)
+	} else if len(creds) != 0 {
+		t.Fatalf("Expected the vault not to be saved yet, got %d credentials", len(creds))
+	}
+
+	if err := c.SaveVault(); err != nil {
+		t.Fatal(err)
+	} else if _, creds, err := openVault(path, []byte("secret")); err != nil {
+		t.Fatal(err)
+	} else if len(creds) != 3 {
+		t.Fatalf("Expected 3 credentials, got %d", len(creds))
+	} else if c.saveTimer != nil {
+		t.Fatalf("Expected the delayed save to be cancelled.")
+	}
+
+	if err := c.CloseVault(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,11 +2,15 @@ package session
 
 import (
 	"fmt"
+	"os"
+	"regexp"
 	"sort"
 	"strconv"
 	"sync"
 )
 
+var envVarParser = regexp.MustCompile(`{(env\.)?([a-zA-Z0-9_\.\-]+)}`)
+
 type Environment struct {
 	sync.Mutex
 
@@ -41,6 +45,11 @@ func (env *Environment) Set(name, value string) string {
 	old, _ := env.Storage[name]
 	env.Storage[name] = value
 
+	// events end up in logs and are streamed to the API clients
+	if env.sess.IsSecret(name) == true {
+		value = RedactedValue
+	}
+
 	env.sess.Events.Add("env.change", struct {
 		Name  string
 		Value string
@@ -91,3 +100,22 @@ func (env *Environment) Sorted() []string {
 	sort.Strings(keys)
 	return keys
 }
+
+// Replaces {NAME} tokens with the value of the NAME variable and
+// {env.NAME} ones with the NAME environment variable of the process,
+// or the session variable if there's none. Tokens of undefined
+// variables are left untouched.
+func (env *Environment) Expand(buffer string) string {
+	return envVarParser.ReplaceAllStringFunc(buffer, func(token string) string {
+		m := envVarParser.FindStringSubmatch(token)
+		if m[1] != "" {
+			if value, found := os.LookupEnv(m[2]); found == true {
+				return value
+			}
+		}
+		if found, value := env.Get(m[2]); found == true {
+			return value
+		}
+		return token
+	})
+}
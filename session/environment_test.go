@@ -0,0 +1,41 @@
+package session
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnvironmentExpand(t *testing.T) {
+	s := &Session{}
+	s.Env = NewEnvironment(s)
+	s.Events = NewEventPool(false, true, 64)
+
+	s.Env.Set("iface.ipv4", "192.168.1.2")
+	s.Env.Set("BETTERCAP_TEST_SHADOWED", "session")
+	os.Setenv("BETTERCAP_TEST_VAR", "process")
+	os.Setenv("BETTERCAP_TEST_SHADOWED", "process")
+	os.Unsetenv("BETTERCAP_TEST_UNSET")
+	defer os.Unsetenv("BETTERCAP_TEST_VAR")
+	defer os.Unsetenv("BETTERCAP_TEST_SHADOWED")
+
+	tests := []struct {
+		buffer   string
+		expected string
+	}{
+		{"set a {iface.ipv4}", "set a 192.168.1.2"},
+		{"set a {env.BETTERCAP_TEST_VAR}", "set a process"},
+		{"set a {env.BETTERCAP_TEST_SHADOWED}", "set a process"},
+		{"set a {BETTERCAP_TEST_SHADOWED}", "set a session"},
+		{"set a {BETTERCAP_TEST_VAR}", "set a {BETTERCAP_TEST_VAR}"},
+		// the session variable if the process has none
+		{"set a {env.iface.ipv4}", "set a 192.168.1.2"},
+		{"set a {env.BETTERCAP_TEST_UNSET}", "set a {env.BETTERCAP_TEST_UNSET}"},
+		{"set a {undefined}", "set a {undefined}"},
+	}
+
+	for _, test := range tests {
+		if got := s.Env.Expand(test.buffer); got != test.expected {
+			t.Fatalf("Expected '%s' for '%s', got '%s'", test.expected, test.buffer, got)
+		}
+	}
+}
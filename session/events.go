@@ -3,7 +3,9 @@ package session
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/evilsocket/bettercap-ng/core"
@@ -29,70 +31,381 @@ func NewEvent(tag string, data interface{}) Event {
 }
 
 func (e Event) Label() string {
-	log := e.Data.(LogMessage)
+	log, ok := e.Data.(LogMessage)
+	if ok == false {
+		return ""
+	}
+
 	label := core.LogLabels[log.Level]
 	color := core.LogColors[log.Level]
 	return color + label + core.RESET
 }
 
+type logEntry struct {
+	time    time.Time
+	level   int
+	message string
+	// if set, closed once the previous entries have been handled
+	flushed chan bool
+}
+
+// Maximum number of log lines waiting to be handled.
+const logQueueSize = 4096
+
+// Events are dropped for listeners which are not keeping up.
+const eventsListenerBuffer = 255
+
+// What to do when the buffer of a subscriber is full.
+type OverflowPolicy int
+
+const (
+	// the new event is not delivered
+	DropNewest OverflowPolicy = iota
+	// the oldest buffered event is discarded to make room for the new one
+	DropOldest
+)
+
+type Subscription struct {
+	C       chan Event
+	Dropped uint64
+
+	prefixes []string
+	policy   OverflowPolicy
+}
+
+func (sub *Subscription) accepts(e Event) bool {
+	if len(sub.prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range sub.prefixes {
+		if strings.HasPrefix(e.Tag, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns false if the event, or an older one, had to be dropped.
+func (sub *Subscription) deliver(e Event) bool {
+	select {
+	case sub.C <- e:
+		return true
+	default:
+	}
+
+	switch sub.policy {
+	case DropOldest:
+		select {
+		case <-sub.C:
+		default:
+		}
+
+		select {
+		case sub.C <- e:
+		default:
+		}
+	}
+
+	sub.Dropped++
+	return false
+}
+
 type EventPool struct {
 	sync.Mutex
 
-	NewEvents chan Event
-	debug     bool
-	silent    bool
-	events    []Event
+	// first field to be 64-bit aligned for atomic operations on 32-bit platforms
+	logsDropped uint64
+	logs        chan logEntry
+
+	debug  bool
+	silent bool
+	// ring buffer of the last events, head is where the next one goes
+	events      []Event
+	head        int
+	count       int
+	subscribers []*Subscription
+	dropped     uint64
+	ignored     []string
+	logFile     *LogFile
+	counters    map[string]uint64
 }
 
-func NewEventPool(debug bool, silent bool) *EventPool {
-	return &EventPool{
-		NewEvents: make(chan Event),
-		debug:     debug,
-		silent:    silent,
-		events:    make([]Event, 0),
+func NewEventPool(debug bool, silent bool, capacity int) *EventPool {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	p := &EventPool{
+		logsDropped: 0,
+		logs:        make(chan logEntry, logQueueSize),
+		debug:       debug,
+		silent:      silent,
+		events:      make([]Event, capacity),
+		head:        0,
+		count:       0,
+		subscribers: make([]*Subscription, 0),
+		dropped:     0,
+		ignored:     make([]string, 0),
+		counters:    make(map[string]uint64),
+	}
+
+	go p.logWorker()
+
+	return p
+}
+
+// Returns a subscription receiving the events whose type starts with
+// one of the prefixes, or all of them if no prefix is given.
+func (p *EventPool) Subscribe(prefixes []string, policy OverflowPolicy, buffer int) *Subscription {
+	p.Lock()
+	defer p.Unlock()
+	sub := &Subscription{
+		C:        make(chan Event, buffer),
+		Dropped:  0,
+		prefixes: prefixes,
+		policy:   policy,
+	}
+	p.subscribers = append(p.subscribers, sub)
+	return sub
+}
+
+func (p *EventPool) Unsubscribe(sub *Subscription) {
+	p.Lock()
+	defer p.Unlock()
+	for i, s := range p.subscribers {
+		if s == sub {
+			p.subscribers = append(p.subscribers[:i], p.subscribers[i+1:]...)
+			close(s.C)
+			return
+		}
+	}
+}
+
+func (p *EventPool) Listen() chan Event {
+	return p.Subscribe(nil, DropNewest, eventsListenerBuffer).C
+}
+
+func (p *EventPool) Unlisten(listener chan Event) {
+	p.Lock()
+	var sub *Subscription
+	for _, s := range p.subscribers {
+		if s.C == listener {
+			sub = s
+			break
+		}
+	}
+	p.Unlock()
+
+	if sub != nil {
+		p.Unsubscribe(sub)
 	}
 }
 
 func (p *EventPool) Add(tag string, data interface{}) {
 	p.Lock()
 	defer p.Unlock()
-	e := NewEvent(tag, data)
-	p.events = append([]Event{e}, p.events...)
+	p.add(NewEvent(tag, data))
+}
 
-	select {
-	case p.NewEvents <- e:
-	default:
+// Must be called with the lock held.
+func (p *EventPool) add(e Event) {
+	p.events[p.head] = e
+	p.head = (p.head + 1) % len(p.events)
+	if p.count < len(p.events) {
+		p.count++
+	}
+	p.counters[e.Tag]++
+
+	for _, sub := range p.subscribers {
+		if sub.accepts(e) == true && sub.deliver(e) == false {
+			p.dropped++
+		}
 	}
 }
 
+// Number of events which could not be delivered to subscribers.
+func (p *EventPool) Dropped() uint64 {
+	p.Lock()
+	defer p.Unlock()
+	return p.dropped
+}
+
+// Log lines will also be written to f, according to its own level.
+func (p *EventPool) SetLogFile(f *LogFile) {
+	p.Lock()
+	defer p.Unlock()
+	p.logFile = f
+}
+
+// Log lines are queued and handled by a single goroutine, so that callers
+// in hot paths never wait for the log file, the event pool or the terminal.
+// Debug lines are dropped if the queue is full, the other levels wait.
 func (p *EventPool) Log(level int, format string, args ...interface{}) {
-	if level == core.DEBUG && p.debug == false {
-		return
-	} else if level < core.ERROR && p.silent == true {
+	p.Lock()
+	logFile := p.logFile
+	p.Unlock()
+
+	// don't even format lines nobody is going to read
+	if level == core.DEBUG && p.debug == false && (logFile == nil || logFile.Level > core.DEBUG) {
 		return
 	}
 
-	message := fmt.Sprintf(format, args...)
+	entry := logEntry{
+		time:    time.Now(),
+		level:   level,
+		message: fmt.Sprintf(format, args...),
+	}
+
+	if level == core.DEBUG {
+		select {
+		case p.logs <- entry:
+		default:
+			atomic.AddUint64(&p.logsDropped, 1)
+		}
+		return
+	}
 
-	p.Add("sys.log", LogMessage{
-		level,
-		message,
-	})
+	p.logs <- entry
 
 	if level == core.FATAL {
-		fmt.Fprintf(os.Stderr, "%s\n", message)
+		p.Flush()
+		fmt.Fprintf(os.Stderr, "%s\n", entry.message)
 		os.Exit(1)
 	}
 }
 
+// Waits for the log lines queued so far to be handled.
+func (p *EventPool) Flush() {
+	flushed := make(chan bool)
+	p.logs <- logEntry{flushed: flushed}
+	<-flushed
+}
+
+func (p *EventPool) logWorker() {
+	for entry := range p.logs {
+		if entry.flushed != nil {
+			close(entry.flushed)
+			continue
+		}
+
+		p.Lock()
+		logFile := p.logFile
+		p.Unlock()
+
+		if logFile != nil {
+			logFile.Write(entry.time, entry.level, entry.message)
+		}
+
+		if entry.level == core.DEBUG && p.debug == false {
+			continue
+		} else if entry.level < core.ERROR && p.silent == true {
+			continue
+		}
+
+		p.Lock()
+		p.add(Event{
+			Tag:  "sys.log",
+			Time: entry.time,
+			Data: LogMessage{
+				entry.level,
+				entry.message,
+			},
+		})
+		p.Unlock()
+	}
+}
+
+// Number of debug lines dropped because the log queue was full.
+func (p *EventPool) LogsDropped() uint64 {
+	return atomic.LoadUint64(&p.logsDropped)
+}
+
+// Number of events fired since the beginning of the session by type.
+func (p *EventPool) Counters() map[string]uint64 {
+	p.Lock()
+	defer p.Unlock()
+	counters := make(map[string]uint64)
+	for tag, n := range p.counters {
+		counters[tag] = n
+	}
+	return counters
+}
+
+func (p *EventPool) Last() (Event, bool) {
+	p.Lock()
+	defer p.Unlock()
+	if p.count == 0 {
+		return Event{}, false
+	}
+	return p.events[(p.head+len(p.events)-1)%len(p.events)], true
+}
+
 func (p *EventPool) Clear() {
 	p.Lock()
 	defer p.Unlock()
-	p.events = make([]Event, 0)
+	p.events = make([]Event, len(p.events))
+	p.head = 0
+	p.count = 0
 }
 
+// The events still in the buffer, from the newest to the oldest.
 func (p *EventPool) Events() []Event {
 	p.Lock()
 	defer p.Unlock()
-	return p.events
+	events := make([]Event, p.count)
+	for i := 0; i < p.count; i++ {
+		events[i] = p.events[(p.head+len(p.events)-1-i)%len(p.events)]
+	}
+	return events
+}
+
+// Event types starting with one of the ignored prefixes are still
+// stored and dispatched, but they won't be shown to the user.
+func (p *EventPool) Ignore(prefix string) {
+	p.Lock()
+	defer p.Unlock()
+	for _, ignored := range p.ignored {
+		if ignored == prefix {
+			return
+		}
+	}
+	p.ignored = append(p.ignored, prefix)
+}
+
+func (p *EventPool) Unignore(prefix string) error {
+	p.Lock()
+	defer p.Unlock()
+	for i, ignored := range p.ignored {
+		if ignored == prefix {
+			p.ignored = append(p.ignored[:i], p.ignored[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("Events of type '%s' are not being ignored.", prefix)
+}
+
+func (p *EventPool) Ignored(tag string) bool {
+	p.Lock()
+	defer p.Unlock()
+	for _, ignored := range p.ignored {
+		if strings.HasPrefix(tag, ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *EventPool) IgnoreList() []string {
+	p.Lock()
+	defer p.Unlock()
+	list := make([]string, len(p.ignored))
+	copy(list, p.ignored)
+	return list
+}
+
+func (p *EventPool) ClearIgnoreList() {
+	p.Lock()
+	defer p.Unlock()
+	p.ignored = make([]string, 0)
 }
@@ -0,0 +1,246 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// Commands to execute when an event whose tag matches (or starts
+// with) the rest of the variable name is fired, for instance:
+//
+//	set events.on.target.new https.proxy on
+//	set events.on.net.sniff.leak ! notify-send "{event.data}"
+const EventTriggerPrefix = "events.on."
+
+var eventFieldParser = regexp.MustCompile(`{event\.([a-zA-Z0-9_\.\-]+)}`)
+
+func eventValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	} else if raw, err := json.Marshal(v); err == nil {
+		return string(raw)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Resolves event.tag, event.time, event.data and event.data.FIELD.
+func (e Event) Field(name string) (bool, string) {
+	switch name {
+	case "tag":
+		return true, e.Tag
+	case "time":
+		return true, e.Time.Format("2006-01-02 15:04:05")
+	case "data":
+		return true, eventValue(e.Data)
+	}
+
+	if strings.HasPrefix(name, "data.") == false {
+		return false, ""
+	}
+
+	raw, err := json.Marshal(e.Data)
+	if err != nil {
+		return false, ""
+	}
+
+	var obj interface{}
+	if err = json.Unmarshal(raw, &obj); err != nil {
+		return false, ""
+	}
+
+	for _, field := range strings.Split(name[5:], ".") {
+		m, ok := obj.(map[string]interface{})
+		if ok == false {
+			return false, ""
+		} else if obj, ok = m[field]; ok == false {
+			return false, ""
+		}
+	}
+
+	return true, eventValue(obj)
+}
+
+// Replaces the event placeholders of a command after it has been parsed,
+// so that values are never executed: shell commands reference them as
+// environment variables, which are returned, the others get them as
+// quoted arguments.
+func (e Event) expandCommand(cmd string) (string, []string) {
+	shell := strings.HasPrefix(cmd, "!")
+	env := make([]string, 0)
+	expanded := strings.Builder{}
+	quote := rune(0)
+	last := 0
+
+	for _, m := range eventFieldParser.FindAllStringSubmatchIndex(cmd, -1) {
+		for _, c := range cmd[last:m[0]] {
+			if quote == 0 && (c == '"' || (shell == true && c == '\'')) {
+				quote = c
+			} else if c == quote {
+				quote = 0
+			}
+		}
+		expanded.WriteString(cmd[last:m[0]])
+		last = m[1]
+
+		found, value := e.Field(cmd[m[2]:m[3]])
+		if found == false {
+			expanded.WriteString(cmd[m[0]:m[1]])
+		} else if shell == true {
+			name := fmt.Sprintf("BETTERCAP_EVENT_%d", len(env))
+			env = append(env, name+"="+strings.Replace(value, "\x00", "", -1))
+			expanded.WriteString(core.ShellVariable(name, quote))
+		} else {
+			value = strings.NewReplacer("\"", "'", "\r", " ", "\n", " ").Replace(value)
+			if quote == '"' {
+				expanded.WriteString(value)
+			} else {
+				expanded.WriteString("\"" + value + "\"")
+			}
+		}
+	}
+	expanded.WriteString(cmd[last:])
+
+	return expanded.String(), env
+}
+
+type eventTrigger struct {
+	Name     string
+	Commands string
+}
+
+// Number of events queued while the commands of a trigger are running.
+const triggerQueueSize = 64
+
+func triggerMatches(name string, tag string) bool {
+	match := name[len(EventTriggerPrefix):]
+	return tag == match || strings.HasPrefix(tag, match+".")
+}
+
+func (s *Session) eventTriggers(tag string) []eventTrigger {
+	s.Env.Lock()
+	defer s.Env.Unlock()
+
+	triggers := make([]eventTrigger, 0)
+	for name, cmd := range s.Env.Storage {
+		if strings.HasPrefix(name, EventTriggerPrefix) == false || cmd == "" {
+			continue
+		} else if triggerMatches(name, tag) == true {
+			triggers = append(triggers, eventTrigger{name, cmd})
+		}
+	}
+
+	return triggers
+}
+
+// The commands of a trigger run for one event at a time, the events
+// fired meanwhile are queued. The lines the trigger logs itself don't
+// fire it, or a trigger for sys.log failing would loop forever.
+type triggerRun struct {
+	running  bool
+	queue    []Event
+	overflow bool
+	logged   map[string]int
+}
+
+// Returns true if the trigger must run for the event right away.
+func (s *Session) triggerStarted(name string, e Event) bool {
+	s.triggersLock.Lock()
+	defer s.triggersLock.Unlock()
+
+	run, found := s.triggerRuns[name]
+	if found == false {
+		run = &triggerRun{
+			queue:  make([]Event, 0),
+			logged: make(map[string]int),
+		}
+		s.triggerRuns[name] = run
+	}
+
+	if msg, ok := e.Data.(LogMessage); ok == true && e.Tag == "sys.log" && run.logged[msg.Message] > 0 {
+		if run.logged[msg.Message]--; run.logged[msg.Message] == 0 {
+			delete(run.logged, msg.Message)
+		}
+		return false
+	} else if run.running == false {
+		run.running = true
+		return true
+	} else if len(run.queue) < triggerQueueSize {
+		run.queue = append(run.queue, e)
+	} else if run.overflow == false {
+		run.overflow = true
+		go s.triggerLog(name, core.WARNING, "Too many events for %s, the following ones are dropped until the queued ones are handled.", name)
+	}
+
+	return false
+}
+
+// Returns the next queued event, if any, or marks the trigger as not running.
+func (s *Session) triggerNext(name string) (Event, bool) {
+	s.triggersLock.Lock()
+	defer s.triggersLock.Unlock()
+
+	run := s.triggerRuns[name]
+	if len(run.queue) == 0 {
+		run.running = false
+		run.overflow = false
+		return Event{}, false
+	}
+
+	e := run.queue[0]
+	run.queue = run.queue[1:]
+	return e, true
+}
+
+// Logs a line which won't fire the trigger itself.
+func (s *Session) triggerLog(name string, level int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+
+	if triggerMatches(name, "sys.log") == true {
+		s.triggersLock.Lock()
+		s.triggerRuns[name].logged[msg]++
+		s.triggersLock.Unlock()
+	}
+
+	s.Events.Log(level, "%s", msg)
+}
+
+func (s *Session) runTrigger(trigger eventTrigger, e Event) {
+	defer s.Recover()
+
+	for more := true; more == true; e, more = s.triggerNext(trigger.Name) {
+		for _, cmd := range ParseCommands(trigger.Commands) {
+			var err error
+
+			line, env := e.expandCommand(s.Env.Expand(cmd))
+			if strings.HasPrefix(line, "!") {
+				var out string
+				if out, err = core.ShellWithEnv(strings.TrimSpace(line[1:]), env); err == nil {
+					fmt.Printf("%s\n", out)
+				}
+			} else {
+				// already expanded, values of the event must not be
+				err = s.dispatch(line)
+			}
+
+			if err != nil {
+				s.triggerLog(trigger.Name, core.ERROR, "Error while executing '%s' for event %s: %s", cmd, e.Tag, err)
+			}
+		}
+	}
+}
+
+func (s *Session) triggersListener() {
+	defer s.Recover()
+
+	for e := range s.Events.Listen() {
+		for _, trigger := range s.eventTriggers(e.Tag) {
+			if s.triggerStarted(trigger.Name, e) == true {
+				go s.runTrigger(trigger, e)
+			}
+		}
+	}
+}
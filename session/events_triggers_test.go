@@ -0,0 +1,70 @@
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTriggerQueuedEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bettercap-triggers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+
+	s := newTestSession(t)
+	s.Env.Set("events.on.test.event", `!sleep 0.2 && echo "{event.data}" >> `+out)
+	go s.triggersListener()
+	// let the listener subscribe
+	time.Sleep(50 * time.Millisecond)
+
+	// the second and third events arrive while the first one is handled
+	s.Events.Add("test.event", "first")
+	time.Sleep(50 * time.Millisecond)
+	s.Events.Add("test.event", "second")
+	s.Events.Add("test.event", "third")
+
+	expected := "first\nsecond\nthird\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if raw, _ := ioutil.ReadFile(out); string(raw) == expected {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	raw, _ := ioutil.ReadFile(out)
+	t.Fatalf("Expected %q, got %q", expected, string(raw))
+}
+
+func TestTriggerOwnLogs(t *testing.T) {
+	s := newTestSession(t)
+	name := "events.on.sys.log"
+
+	log := func(msg string) Event {
+		return Event{Tag: "sys.log", Time: time.Now(), Data: LogMessage{0, msg}}
+	}
+
+	if s.triggerStarted(name, log("external")) == false {
+		t.Fatalf("Expected the trigger to run.")
+	}
+
+	s.triggerRuns[name].logged["Error while executing 'x' for event sys.log: failed"]++
+	if s.triggerStarted(name, log("Error while executing 'x' for event sys.log: failed")) == true {
+		t.Fatalf("Expected the line logged by the trigger to be ignored.")
+	} else if s.triggerStarted(name, log("another one")) == true {
+		t.Fatalf("Expected the event to be queued while running.")
+	} else if e, found := s.triggerNext(name); found == false || strings.Contains(e.Data.(LogMessage).Message, "another one") == false {
+		t.Fatalf("Expected the queued event, got %+v", e)
+	} else if _, found = s.triggerNext(name); found == true {
+		t.Fatalf("Expected no more queued events.")
+	} else if s.triggerStarted(name, log("Error while executing 'x' for event sys.log: failed")) == false {
+		t.Fatalf("Expected the same line logged by someone else to fire the trigger.")
+	}
+}
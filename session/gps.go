@@ -0,0 +1,57 @@
+package session
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/net"
+)
+
+// Positions older than this are not used to tag anything,
+// i.e. when the receiver lost the fix or was unplugged.
+const gpsPositionTTL = 10 * time.Second
+
+// The last position read by the gps module.
+type GPS struct {
+	sync.Mutex
+	position *net.GPSPosition
+}
+
+func NewGPS() *GPS {
+	return &GPS{}
+}
+
+func (g *GPS) Set(pos net.GPSPosition) {
+	g.Lock()
+	defer g.Unlock()
+
+	if pos.Updated.IsZero() {
+		pos.Updated = time.Now()
+	}
+	g.position = &pos
+}
+
+func (g *GPS) Clear() {
+	g.Lock()
+	defer g.Unlock()
+	g.position = nil
+}
+
+// Returns a copy of the current position, nil if there's no
+// recent fix.
+func (g *GPS) Position() *net.GPSPosition {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.position == nil || time.Since(g.position.Updated) > gpsPositionTTL {
+		return nil
+	}
+
+	pos := *g.position
+	return &pos
+}
+
+func (g *GPS) MarshalJSON() ([]byte, error) {
+	return json.Marshal(g.Position())
+}
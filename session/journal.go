@@ -0,0 +1,108 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A change applied to the network or to the system, and how to revert it.
+type JournalEntry struct {
+	Key         string
+	Description string
+	Time        time.Time
+	Undo        func() error
+}
+
+// Ordered list of the changes applied by the session, used to
+// restore the previous state even if a module fails to do so.
+type Journal struct {
+	sync.Mutex
+	entries []*JournalEntry
+}
+
+func NewJournal() *Journal {
+	return &Journal{
+		entries: make([]*JournalEntry, 0),
+	}
+}
+
+// Records a change unless one with the same key is already recorded.
+func (j *Journal) Push(key string, description string, undo func() error) {
+	j.Lock()
+	defer j.Unlock()
+
+	for _, e := range j.entries {
+		if e.Key == key {
+			return
+		}
+	}
+
+	j.entries = append(j.entries, &JournalEntry{
+		Key:         key,
+		Description: description,
+		Time:        time.Now(),
+		Undo:        undo,
+	})
+}
+
+// Forgets a change which has been already reverted.
+func (j *Journal) Remove(key string) {
+	j.Lock()
+	defer j.Unlock()
+
+	for i, e := range j.entries {
+		if e.Key == key {
+			j.entries = append(j.entries[:i], j.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (j *Journal) Has(key string) bool {
+	j.Lock()
+	defer j.Unlock()
+
+	for _, e := range j.entries {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *Journal) Entries() []*JournalEntry {
+	j.Lock()
+	defer j.Unlock()
+	return append([]*JournalEntry{}, j.entries...)
+}
+
+func (j *Journal) pop() *JournalEntry {
+	j.Lock()
+	defer j.Unlock()
+
+	if len(j.entries) == 0 {
+		return nil
+	}
+
+	last := j.entries[len(j.entries)-1]
+	j.entries = j.entries[:len(j.entries)-1]
+	return last
+}
+
+// Reverts all the recorded changes, the most recent first, calling
+// report for each one of them.
+func (j *Journal) Rollback(report func(e *JournalEntry, err error)) (failed int) {
+	for e := j.pop(); e != nil; e = j.pop() {
+		err := e.Undo()
+		if err != nil {
+			failed++
+		}
+		report(e, err)
+	}
+	return
+}
+
+func (e *JournalEntry) String() string {
+	return fmt.Sprintf("[%s] %s", e.Time.Format("15:04:05"), e.Description)
+}
@@ -0,0 +1,108 @@
+package session
+
+import (
+	"fmt"
+
+	"github.com/evilsocket/bettercap-ng/firewall"
+)
+
+// Wraps a FirewallManager recording every change into the session journal.
+type journaledFirewall struct {
+	firewall.FirewallManager
+	journal    *Journal
+	forwarding bool
+}
+
+func newJournaledFirewall(fw firewall.FirewallManager, journal *Journal) firewall.FirewallManager {
+	return &journaledFirewall{
+		FirewallManager: fw,
+		journal:         journal,
+		forwarding:      fw.IsForwardingEnabled(),
+	}
+}
+
+// records a boolean feature change, which is forgotten
+// as soon as the feature goes back to its original value.
+func (f *journaledFirewall) record(key string, name string, enabled bool, original bool, set func(bool) error) {
+	if enabled == original {
+		f.journal.Remove(key)
+	} else {
+		f.journal.Push(key, fmt.Sprintf("%s set to %v", name, enabled), func() error {
+			return set(original)
+		})
+	}
+}
+
+func (f *journaledFirewall) EnableForwarding(enabled bool) error {
+	if err := f.FirewallManager.EnableForwarding(enabled); err != nil {
+		return err
+	}
+	f.record("firewall.forwarding", "IPv4 packet forwarding", enabled, f.forwarding, f.EnableForwarding)
+	return nil
+}
+
+func (f *journaledFirewall) EnableIcmpBcast(enabled bool) error {
+	if err := f.FirewallManager.EnableIcmpBcast(enabled); err != nil {
+		return err
+	}
+	// the original state is not known, assume it was the opposite
+	if f.journal.Has("firewall.icmp_bcast") == true {
+		f.journal.Remove("firewall.icmp_bcast")
+	} else {
+		f.record("firewall.icmp_bcast", "ICMP broadcast replies", enabled, !enabled, f.EnableIcmpBcast)
+	}
+	return nil
+}
+
+func (f *journaledFirewall) EnableSendRedirects(enabled bool) error {
+	if err := f.FirewallManager.EnableSendRedirects(enabled); err != nil {
+		return err
+	}
+	// the original state is not known, assume it was the opposite
+	if f.journal.Has("firewall.send_redirects") == true {
+		f.journal.Remove("firewall.send_redirects")
+	} else {
+		f.record("firewall.send_redirects", "ICMP send redirects", enabled, !enabled, f.EnableSendRedirects)
+	}
+	return nil
+}
+
+func (f *journaledFirewall) EnableRedirection(r *firewall.Redirection, enabled bool) error {
+	if err := f.FirewallManager.EnableRedirection(r, enabled); err != nil {
+		return err
+	}
+
+	key := "firewall.redirection " + r.String()
+	if enabled == true {
+		f.journal.Push(key, "Firewall redirection "+r.String(), func() error {
+			return f.EnableRedirection(r, false)
+		})
+	} else {
+		f.journal.Remove(key)
+	}
+	return nil
+}
+
+func (f *journaledFirewall) AddRule(rule string) error {
+	if err := firewall.AddRule(f.FirewallManager, rule); err != nil {
+		return err
+	}
+
+	f.journal.Push("firewall.rule "+rule, "Firewall rule "+rule, func() error {
+		return f.DelRule(rule)
+	})
+	return nil
+}
+
+func (f *journaledFirewall) DelRule(rule string) error {
+	if err := firewall.DelRule(f.FirewallManager, rule); err != nil {
+		return err
+	}
+
+	f.journal.Remove("firewall.rule " + rule)
+	return nil
+}
+
+func (f *journaledFirewall) Rules() []string {
+	return firewall.Rules(f.FirewallManager)
+}
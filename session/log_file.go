@@ -0,0 +1,126 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// A log sink writing to a file with its own level threshold,
+// independently from the console verbosity.
+type LogFile struct {
+	sync.Mutex
+
+	Path    string
+	Level   int
+	JSON    bool
+	MaxSize int64
+
+	fp *os.File
+}
+
+type logFileEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+var logFileLevels = map[string]int{
+	"debug":     core.DEBUG,
+	"info":      core.INFO,
+	"important": core.IMPORTANT,
+	"warning":   core.WARNING,
+	"error":     core.ERROR,
+}
+
+func NewLogFile(path string, level string, format string, maxSizeMB int) (*LogFile, error) {
+	var err error
+
+	l := &LogFile{
+		MaxSize: int64(maxSizeMB) * 1024 * 1024,
+	}
+
+	if l.Path, err = core.ExpandPath(path); err != nil {
+		return nil, err
+	}
+
+	lvl, found := logFileLevels[strings.ToLower(level)]
+	if found == false {
+		return nil, fmt.Errorf("Unknown log level '%s'.", level)
+	}
+	l.Level = lvl
+
+	switch format {
+	case "plain":
+		l.JSON = false
+	case "json":
+		l.JSON = true
+	default:
+		return nil, fmt.Errorf("Unknown log format '%s', expected plain or json.", format)
+	}
+
+	if l.fp, err = os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *LogFile) Write(now time.Time, level int, message string) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.fp == nil || level < l.Level {
+		return
+	}
+
+	message = core.StripColors(message)
+	line := ""
+
+	if l.JSON == true {
+		raw, err := json.Marshal(logFileEntry{
+			Time:    now,
+			Level:   core.LogLabels[level],
+			Message: message,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error while encoding log line: %s\n", err)
+			return
+		}
+		line = string(raw)
+	} else {
+		line = fmt.Sprintf("%s [%s] %s", now.Format("2006-01-02 15:04:05"), core.LogLabels[level], message)
+	}
+
+	if _, err := l.fp.WriteString(line + "\n"); err != nil {
+		fmt.Fprintf(os.Stderr, "Error while writing to %s: %s\n", l.Path, err)
+		return
+	}
+
+	if l.MaxSize > 0 {
+		l.rotate()
+	}
+}
+
+func (l *LogFile) rotate() {
+	var err error
+	// can't use the logger, it would end up writing to this file again
+	if l.fp, _, err = core.RotateFile(l.fp, l.Path, l.MaxSize); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+}
+
+func (l *LogFile) Close() {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.fp != nil {
+		l.fp.Close()
+		l.fp = nil
+	}
+}
@@ -0,0 +1,127 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+const MetricsPrefix = "bettercap_"
+
+const (
+	MetricCounter = "counter"
+	MetricGauge   = "gauge"
+)
+
+type Metric struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels map[string]string
+	Value  float64
+}
+
+func NewMetric(name string, help string, t string, value float64, labels ...string) Metric {
+	m := Metric{
+		Name:   MetricsPrefix + name,
+		Help:   help,
+		Type:   t,
+		Labels: make(map[string]string),
+		Value:  value,
+	}
+	for i := 0; i+1 < len(labels); i += 2 {
+		m.Labels[labels[i]] = labels[i+1]
+	}
+	return m
+}
+
+// Modules implementing this interface will have their
+// metrics exposed together with the session ones.
+type MetricsProvider interface {
+	Metrics() []Metric
+}
+
+func (s *Session) Metrics() []Metric {
+	metrics := make([]Metric, 0)
+
+	for tag, count := range s.Events.Counters() {
+		metrics = append(metrics, NewMetric("events_total", "Number of events by type.", MetricCounter, float64(count), "type", tag))
+	}
+	metrics = append(metrics, NewMetric("log_dropped_total", "Debug lines dropped because the log queue was full.", MetricCounter, float64(s.Events.LogsDropped())))
+	metrics = append(metrics, NewMetric("events_dropped_total", "Events which could not be delivered to their subscribers.", MetricCounter, float64(s.Events.Dropped())))
+
+	if s.Queue != nil {
+		metrics = append(metrics,
+			NewMetric("packets_sent_bytes_total", "Bytes sent by the packet queue.", MetricCounter, float64(s.Queue.Sent)),
+			NewMetric("packets_received_bytes_total", "Bytes received by the packet queue.", MetricCounter, float64(s.Queue.Received)),
+			NewMetric("packets_received_total", "Packets received by the packet queue.", MetricCounter, float64(s.Queue.PktReceived)),
+			NewMetric("packets_errors_total", "Errors of the packet queue.", MetricCounter, float64(s.Queue.Errors)))
+	}
+
+	if s.RateLimit != nil {
+		metrics = append(metrics, s.RateLimit.Metrics()...)
+	}
+
+	gets, allocs := core.BufferPoolStats()
+	metrics = append(metrics,
+		NewMetric("buffer_pool_gets_total", "Buffers taken from the pool for packets and proxy bodies.", MetricCounter, float64(gets)),
+		NewMetric("buffer_pool_allocs_total", "Buffers the pool had to allocate.", MetricCounter, float64(allocs)))
+
+	if s.Targets != nil {
+		metrics = append(metrics, NewMetric("targets", "Number of endpoints on the network.", MetricGauge, float64(s.Targets.Len())))
+	}
+
+	for _, m := range s.Modules {
+		running := 0.0
+		if m.Running() == true {
+			running = 1.0
+		}
+		metrics = append(metrics, NewMetric("module_running", "1 if the module is running, 0 otherwise.", MetricGauge, running, "module", m.Name()))
+
+		if provider, ok := m.(MetricsProvider); ok == true {
+			metrics = append(metrics, provider.Metrics()...)
+		}
+	}
+
+	return metrics
+}
+
+func (m Metric) labels() string {
+	if len(m.Labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(m.Labels))
+	for name := range m.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		value := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n").Replace(m.Labels[name])
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", name, value))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Writes metrics in the Prometheus text exposition format.
+func WriteMetrics(w io.Writer, metrics []Metric) {
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+
+	prev := ""
+	for _, m := range metrics {
+		if m.Name != prev {
+			fmt.Fprintf(w, "# HELP %s %s\n", m.Name, m.Help)
+			fmt.Fprintf(w, "# TYPE %s %s\n", m.Name, m.Type)
+			prev = m.Name
+		}
+		fmt.Fprintf(w, "%s%s %v\n", m.Name, m.labels(), m.Value)
+	}
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewMetric(t *testing.T) {
+	m := NewMetric("events_total", "Number of events.", MetricCounter, 3, "type", "wifi.ap.new", "dangling")
+	if m.Name != "bettercap_events_total" {
+		t.Fatalf("Unexpected name %s", m.Name)
+	} else if len(m.Labels) != 1 || m.Labels["type"] != "wifi.ap.new" {
+		t.Fatalf("Unexpected labels %v", m.Labels)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  []Metric
+		expected string
+	}{
+		{
+			"empty",
+			[]Metric{},
+			"",
+		},
+		{
+			"no labels",
+			[]Metric{NewMetric("targets", "Number of endpoints.", MetricGauge, 12)},
+			"# HELP bettercap_targets Number of endpoints.\n" +
+				"# TYPE bettercap_targets gauge\n" +
+				"bettercap_targets 12\n",
+		},
+		{
+			"sorted labels",
+			[]Metric{NewMetric("module_running", "Running.", MetricGauge, 1, "module", "arp.spoof", "iface", "eth0")},
+			"# HELP bettercap_module_running Running.\n" +
+				"# TYPE bettercap_module_running gauge\n" +
+				"bettercap_module_running{iface=\"eth0\",module=\"arp.spoof\"} 1\n",
+		},
+		{
+			"escaped labels",
+			[]Metric{NewMetric("events_total", "Events.", MetricCounter, 1, "type", "a\"b\\c\nd")},
+			"# HELP bettercap_events_total Events.\n" +
+				"# TYPE bettercap_events_total counter\n" +
+				"bettercap_events_total{type=\"a\\\"b\\\\c\\nd\"} 1\n",
+		},
+		{
+			"grouped by name",
+			[]Metric{
+				NewMetric("targets", "Number of endpoints.", MetricGauge, 2),
+				NewMetric("events_total", "Events.", MetricCounter, 1, "type", "a"),
+				NewMetric("events_total", "Events.", MetricCounter, 0.5, "type", "b"),
+			},
+			"# HELP bettercap_events_total Events.\n" +
+				"# TYPE bettercap_events_total counter\n" +
+				"bettercap_events_total{type=\"a\"} 1\n" +
+				"bettercap_events_total{type=\"b\"} 0.5\n" +
+				"# HELP bettercap_targets Number of endpoints.\n" +
+				"# TYPE bettercap_targets gauge\n" +
+				"bettercap_targets 2\n",
+		},
+		{
+			"large values",
+			[]Metric{NewMetric("packets_received_bytes_total", "Bytes.", MetricCounter, 12345678)},
+			"# HELP bettercap_packets_received_bytes_total Bytes.\n" +
+				"# TYPE bettercap_packets_received_bytes_total counter\n" +
+				"bettercap_packets_received_bytes_total 1.2345678e+07\n",
+		},
+	}
+
+	for _, test := range tests {
+		buf := bytes.Buffer{}
+		WriteMetrics(&buf, test.metrics)
+		if buf.String() != test.expected {
+			t.Fatalf("%s: expected:\n%s\ngot:\n%s", test.name, test.expected, buf.String())
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+
+	"github.com/evilsocket/bettercap-ng/net"
 )
 
 type Module interface {
@@ -104,6 +106,21 @@ func (m SessionModule) BoolParam(name string) (error, bool) {
 	}
 }
 
+// Returns the interface set by the parameter, or the session one if it's
+// empty, interfaces without an IPv4 address are accepted too as modules
+// binding other interfaces only capture from them.
+func (m SessionModule) InterfaceParam(name string) (error, *net.Endpoint) {
+	err, ifName := m.StringParam(name)
+	if err != nil {
+		return err, nil
+	} else if ifName == "" || ifName == m.Session.Interface.Name() {
+		return nil, m.Session.Interface
+	}
+
+	iface, err := net.FindCaptureInterface(ifName)
+	return err, iface
+}
+
 func (m *SessionModule) AddHandler(h ModuleHandler) {
 	m.handlers = append(m.handlers, h)
 }
@@ -122,12 +139,14 @@ func (m *SessionModule) Running() bool {
 
 func (m *SessionModule) SetRunning(running bool) {
 	m.StatusLock.Lock()
-	defer m.StatusLock.Unlock()
 	m.Started = running
+	m.StatusLock.Unlock()
 
 	if running {
 		m.Session.Events.Add("mod.started", m.Name)
 	} else {
 		m.Session.Events.Add("mod.stopped", m.Name)
 	}
+
+	m.Session.onModuleStatus(m.Name, running)
 }
@@ -0,0 +1,90 @@
+package session
+
+import (
+	"sync"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// If true, starting a module will also start the modules it depends on,
+// which are stopped again once no running module needs them anymore.
+const AutoDepsVariable = "session.auto-deps"
+
+var ModuleDependencies = map[string][]string{
+	"http.proxy":  []string{"arp.spoof"},
+	"https.proxy": []string{"arp.spoof"},
+}
+
+var (
+	autoStarted     = make(map[string]bool)
+	autoStartedLock = &sync.Mutex{}
+)
+
+func (s *Session) autoDepsEnabled() bool {
+	found, value := s.Env.Get(AutoDepsVariable)
+	return found == true && value == "true"
+}
+
+func (s *Session) isNeeded(dep string) bool {
+	for name, deps := range ModuleDependencies {
+		for _, d := range deps {
+			if d == dep {
+				if err, m := s.Module(name); err == nil && m.Running() == true {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (s *Session) startDependencies(name string) {
+	for _, dep := range ModuleDependencies[name] {
+		err, m := s.Module(dep)
+		if err != nil || m.Running() == true {
+			continue
+		}
+
+		s.Events.Log(core.INFO, "Starting %s since %s depends on it.", dep, name)
+		if err = s.StartModule(m); err != nil {
+			s.Events.Log(core.ERROR, "Error while starting %s: %s", dep, err)
+			continue
+		}
+
+		autoStartedLock.Lock()
+		autoStarted[dep] = true
+		autoStartedLock.Unlock()
+	}
+}
+
+func (s *Session) stopDependencies() {
+	unneeded := make([]string, 0)
+
+	autoStartedLock.Lock()
+	for dep := range autoStarted {
+		if s.isNeeded(dep) == false {
+			unneeded = append(unneeded, dep)
+			delete(autoStarted, dep)
+		}
+	}
+	autoStartedLock.Unlock()
+
+	for _, dep := range unneeded {
+		if err, m := s.Module(dep); err == nil && m.Running() == true {
+			s.Events.Log(core.INFO, "Stopping %s since no running module depends on it.", dep)
+			if err = m.Stop(); err != nil {
+				s.Events.Log(core.ERROR, "Error while stopping %s: %s", dep, err)
+			}
+		}
+	}
+}
+
+func (s *Session) onModuleStatus(name string, running bool) {
+	if running == true {
+		if s.autoDepsEnabled() == true {
+			s.startDependencies(name)
+		}
+	} else {
+		s.stopDependencies()
+	}
+}
@@ -11,6 +11,9 @@ import (
 
 type ParamType int
 
+// Shown instead of the value of secret parameters.
+const RedactedValue = "********"
+
 const (
 	STRING ParamType = iota
 	BOOL             = iota
@@ -22,6 +25,8 @@ type ModuleParam struct {
 	Type        ParamType
 	Value       string
 	Description string
+	// passwords and tokens, not shown to read only API accounts
+	Secret bool
 
 	Validator *regexp.Regexp
 }
@@ -46,6 +51,13 @@ func NewStringParameter(name string, def_value string, validator string, desc st
 	return NewModuleParameter(name, def_value, STRING, validator, desc)
 }
 
+// A string parameter holding a password or a token.
+func NewSecretParameter(name string, def_value string, validator string, desc string) *ModuleParam {
+	p := NewStringParameter(name, def_value, validator, desc)
+	p.Secret = true
+	return p
+}
+
 func NewBoolParameter(name string, def_value string, desc string) *ModuleParam {
 	return NewModuleParameter(name, def_value, BOOL, "^(true|false)$", desc)
 }
@@ -109,10 +121,15 @@ func (p ModuleParam) Dump(padding int) string {
 		" : %s\n", p.Name, p.Value)
 }
 
-func (p ModuleParam) Help(padding int) string {
+func (p ModuleParam) Help(s *Session, padding int) string {
+	_, value := s.Env.Get(p.Name)
+	if value != p.Value {
+		value = core.Green(value)
+	}
+
 	return fmt.Sprintf("  "+core.YELLOW+"%"+strconv.Itoa(padding)+"s"+core.RESET+
 		" : "+
-		"%s "+core.DIM+"(default=%s"+core.RESET+")\n", p.Name, p.Description, p.Value)
+		"%s "+core.DIM+"(value="+core.RESET+"%s"+core.DIM+" default=%s"+core.RESET+")\n", p.Name, p.Description, value, p.Value)
 }
 
 func (p ModuleParam) Register(s *Session) {
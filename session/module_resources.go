@@ -0,0 +1,155 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+)
+
+// Profiler label set on the goroutines started by each module, the
+// goroutines they start inherit it so they can be accounted to it.
+const ModuleLabel = "module"
+
+// Runs the callback with the goroutine labeled as belonging to the module.
+func withModuleLabel(name string, cb func()) {
+	pprof.Do(context.Background(), pprof.Labels(ModuleLabel, name), func(ctx context.Context) {
+		cb()
+	})
+}
+
+// Starts the module so that its goroutines are accounted to it, modules
+// starting other modules should use this instead of calling Start.
+func (s *Session) StartModule(m Module) (err error) {
+	withModuleLabel(m.Name(), func() {
+		err = m.Start()
+	})
+	return
+}
+
+// Returns the number of goroutines of each module, parsed from the
+// goroutine profile as it's the only place exposing the labels.
+func ModuleGoroutines() map[string]int {
+	counts := make(map[string]int)
+
+	buf := bytes.Buffer{}
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return counts
+	}
+
+	// each group of identical stacks is "N @ 0x... 0x..." followed
+	// by "# labels: {...}" if the goroutines are labeled
+	group := 0
+	scanner := bufio.NewScanner(&buf)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, " @ "); idx > 0 {
+			group, _ = strconv.Atoi(line[:idx])
+		} else if strings.HasPrefix(line, "# labels: ") {
+			labels := make(map[string]string)
+			if json.Unmarshal([]byte(line[10:]), &labels) == nil {
+				if name, found := labels[ModuleLabel]; found == true {
+					counts[name] += group
+				}
+			}
+			group = 0
+		}
+	}
+
+	return counts
+}
+
+// Prefixes of the functions of the type implementing the module, for
+// instance github.com/evilsocket/bettercap-ng/modules.(*Sniffer).
+func modulePrefixes(m Module) []string {
+	t := reflect.TypeOf(m)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return []string{
+		t.PkgPath() + ".(*" + t.Name() + ").",
+		t.PkgPath() + "." + t.Name() + ".",
+	}
+}
+
+// Same as the one of runtime/pprof, as the profile records are samples.
+func scaleHeapSample(count, size, rate int64) int64 {
+	if count == 0 || size == 0 {
+		return 0
+	} else if rate <= 1 {
+		return size
+	}
+
+	avg := float64(size) / float64(count)
+	scale := 1 / (1 - math.Exp(-avg/float64(rate)))
+	return int64(float64(size) * scale)
+}
+
+// Returns an estimate of the heap memory in use by each module as of the
+// last garbage collection, allocations are accounted to the innermost
+// method of a module type in their stack.
+func ModuleMemory(modules []Module) map[string]uint64 {
+	usage := make(map[string]uint64)
+
+	prefixes := make(map[string]string)
+	for _, m := range modules {
+		for _, prefix := range modulePrefixes(m) {
+			prefixes[prefix] = m.Name()
+		}
+	}
+
+	var records []runtime.MemProfileRecord
+	n, ok := runtime.MemProfile(nil, true)
+	for {
+		// leave room for the records added in the meantime
+		records = make([]runtime.MemProfileRecord, n+50)
+		if n, ok = runtime.MemProfile(records, true); ok == true {
+			records = records[:n]
+			break
+		}
+	}
+
+	rate := int64(runtime.MemProfileRate)
+	cache := make(map[uintptr]string)
+	for _, r := range records {
+		inuse := scaleHeapSample(r.InUseObjects(), r.InUseBytes(), rate)
+		if inuse == 0 {
+			continue
+		}
+
+		owner := ""
+		for _, pc := range r.Stack() {
+			name, found := cache[pc]
+			if found == false {
+				// the return address might belong to the next line already
+				if fn := runtime.FuncForPC(pc - 1); fn != nil {
+					for prefix, module := range prefixes {
+						if strings.HasPrefix(fn.Name(), prefix) {
+							name = module
+							break
+						}
+					}
+				}
+				cache[pc] = name
+			}
+
+			if name != "" {
+				owner = name
+				break
+			}
+		}
+
+		if owner != "" {
+			usage[owner] += uint64(inuse)
+		}
+	}
+
+	return usage
+}
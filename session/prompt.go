@@ -54,9 +54,33 @@ var PromptCallbacks = map[string]func(s *Session) string{
 	"{net.errors}": func(s *Session) string {
 		return fmt.Sprintf("%d", s.Queue.Errors)
 	},
+	"{iface.name}": func(s *Session) string {
+		return s.Interface.Name()
+	},
+	"{iface.address}": func(s *Session) string {
+		return s.Interface.IpAddress
+	},
+	"{gateway.address}": func(s *Session) string {
+		return s.Gateway.IpAddress
+	},
+	"{targets}": func(s *Session) string {
+		return fmt.Sprintf("%d", s.Targets.Len())
+	},
+	"{modules}": func(s *Session) string {
+		return fmt.Sprintf("%d", len(s.runningModules()))
+	},
+	"{modules.names}": func(s *Session) string {
+		return strings.Join(s.runningModules(), ",")
+	},
+	"{last.event}": func(s *Session) string {
+		if e, found := s.Events.Last(); found == true {
+			return e.Tag
+		}
+		return ""
+	},
 }
 
-var envRe = regexp.MustCompile("{env\\.(.+)}")
+var envRe = regexp.MustCompile("{env\\.([^}]+)}")
 
 type Prompt struct {
 }
@@ -79,17 +103,25 @@ func (p Prompt) Render(s *Session) string {
 		prompt = strings.Replace(prompt, tok, cb(s), -1)
 	}
 
-	m := envRe.FindStringSubmatch(prompt)
-	if len(m) == 2 {
-		name := m[1]
-		_, value := s.Env.Get(name)
-		prompt = strings.Replace(prompt, m[0], value, -1)
-	}
+	prompt = envRe.ReplaceAllStringFunc(prompt, func(token string) string {
+		_, value := s.Env.Get(envRe.FindStringSubmatch(token)[1])
+		return value
+	})
 
 	// make sure an user error does not screw all terminal
-	if strings.HasPrefix(prompt, core.RESET) == false {
+	if strings.HasSuffix(prompt, core.RESET) == false {
 		prompt += core.RESET
 	}
 
 	return prompt
 }
+
+func (s *Session) runningModules() []string {
+	names := make([]string, 0)
+	for _, m := range s.Modules {
+		if m.Running() == true {
+			names = append(names, m.Name())
+		}
+	}
+	return names
+}
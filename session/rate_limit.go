@@ -0,0 +1,125 @@
+package session
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Packets per second shared by the active modules, 0 means no limit.
+	RateLimitVariable = "session.rate-limit"
+	// Comma separated list of module:weight, modules which are not
+	// listed have weight 1 and a module with weight 2 gets twice the
+	// packets of a module with weight 1 while both are sending.
+	RateLimitWeightsVariable = "session.rate-limit.weights"
+)
+
+// A module which didn't send anything in this interval doesn't
+// take a share of the budget anymore.
+const rateLimitIdle = 2 * time.Second
+
+// Splits the packets per second budget among the modules which are
+// sending, according to their weights, so that the overall rate stays
+// under a threshold regardless of how many of them are running.
+type RateLimiter struct {
+	sync.Mutex
+
+	session    *Session
+	rawWeights string
+	weights    map[string]float64
+	// when each module can send its next packet
+	next    map[string]time.Time
+	delayed map[string]uint64
+}
+
+func NewRateLimiter(s *Session) *RateLimiter {
+	return &RateLimiter{
+		session: s,
+		weights: make(map[string]float64),
+		next:    make(map[string]time.Time),
+		delayed: make(map[string]uint64),
+	}
+}
+
+// Invalid values disable the limit rather than stopping the modules.
+func (l *RateLimiter) Budget() float64 {
+	if found, value := l.session.Env.Get(RateLimitVariable); found == true {
+		if pps, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && pps > 0 {
+			return pps
+		}
+	}
+	return 0
+}
+
+func (l *RateLimiter) weight(module string) float64 {
+	_, raw := l.session.Env.Get(RateLimitWeightsVariable)
+	if raw != l.rawWeights {
+		l.rawWeights = raw
+		l.weights = make(map[string]float64)
+		for _, item := range strings.Split(raw, ",") {
+			parts := strings.SplitN(item, ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			if w, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil && w > 0 {
+				l.weights[strings.TrimSpace(parts[0])] = w
+			}
+		}
+	}
+
+	if w, found := l.weights[module]; found == true {
+		return w
+	}
+	return 1
+}
+
+// Blocks until the module is allowed to send another packet.
+func (l *RateLimiter) Wait(module string) {
+	if l == nil {
+		return
+	}
+
+	pps := l.Budget()
+	if pps <= 0 {
+		return
+	}
+
+	l.Lock()
+	now := time.Now()
+	total := l.weight(module)
+	for name, at := range l.next {
+		if now.Sub(at) > rateLimitIdle {
+			delete(l.next, name)
+		} else if name != module {
+			total += l.weight(name)
+		}
+	}
+
+	at := l.next[module]
+	if at.Before(now) {
+		at = now
+	}
+	share := pps * l.weight(module) / total
+	l.next[module] = at.Add(time.Duration(float64(time.Second) / share))
+	if at.After(now) {
+		l.delayed[module]++
+	}
+	l.Unlock()
+
+	time.Sleep(at.Sub(now))
+}
+
+func (l *RateLimiter) Metrics() []Metric {
+	l.Lock()
+	defer l.Unlock()
+
+	metrics := []Metric{
+		NewMetric("rate_limit_pps", "Packets per second shared by the active modules, 0 if not limited.", MetricGauge, l.Budget()),
+	}
+	for module, count := range l.delayed {
+		metrics = append(metrics, NewMetric("rate_limit_delayed_total", "Packets delayed by the rate limiter.", MetricCounter, float64(count), "module", module))
+	}
+	return metrics
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestRateLimiter(pps string, weights string) *RateLimiter {
+	s := &Session{}
+	s.Env = NewEnvironment(s)
+	s.Events = NewEventPool(false, true, 64)
+	if pps != "" {
+		s.Env.Set(RateLimitVariable, pps)
+	}
+	if weights != "" {
+		s.Env.Set(RateLimitWeightsVariable, weights)
+	}
+	return NewRateLimiter(s)
+}
+
+func TestRateLimiterBudget(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected float64
+	}{
+		{"", 0},
+		{"100", 100},
+		{" 50 ", 50},
+		{"0.5", 0.5},
+		{"0", 0},
+		{"-10", 0},
+		{"fast", 0},
+	}
+
+	for _, test := range tests {
+		if budget := newTestRateLimiter(test.value, "").Budget(); budget != test.expected {
+			t.Fatalf("Expected %f for '%s', got %f", test.expected, test.value, budget)
+		}
+	}
+}
+
+func TestRateLimiterWeights(t *testing.T) {
+	l := newTestRateLimiter("100", "arp.spoof:2, net.probe : 0.5,broken,zero:0,negative:-1,nan:abc")
+	tests := []struct {
+		module   string
+		expected float64
+	}{
+		{"arp.spoof", 2},
+		{"net.probe", 0.5},
+		{"broken", 1},
+		{"zero", 1},
+		{"negative", 1},
+		{"nan", 1},
+		{"dns.spoof", 1},
+	}
+
+	for _, test := range tests {
+		if w := l.weight(test.module); w != test.expected {
+			t.Fatalf("Expected weight %f for %s, got %f", test.expected, test.module, w)
+		}
+	}
+
+	// changes of the variable are picked up
+	l.session.Env.Set(RateLimitWeightsVariable, "arp.spoof:4")
+	if w := l.weight("arp.spoof"); w != 4 {
+		t.Fatalf("Expected weight 4 after the change, got %f", w)
+	} else if w = l.weight("net.probe"); w != 1 {
+		t.Fatalf("Expected weight 1 after the change, got %f", w)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	var nilLimiter *RateLimiter
+	nilLimiter.Wait("arp.spoof")
+
+	l := newTestRateLimiter("", "")
+	for i := 0; i < 100; i++ {
+		l.Wait("arp.spoof")
+	}
+
+	if len(l.next) != 0 || len(l.delayed) != 0 {
+		t.Fatalf("Expected no accounting without a budget, got %v %v", l.next, l.delayed)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := newTestRateLimiter("200", "")
+
+	start := time.Now()
+	for i := 0; i < 21; i++ {
+		l.Wait("arp.spoof")
+	}
+
+	// the first packet goes out right away, the other ones every 5ms
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("Expected at least 100ms for 21 packets at 200pps, got %s", elapsed)
+	} else if l.delayed["arp.spoof"] != 20 {
+		t.Fatalf("Expected 20 delayed packets, got %d", l.delayed["arp.spoof"])
+	}
+}
+
+func TestRateLimiterShares(t *testing.T) {
+	tests := []struct {
+		weights  string
+		expected time.Duration
+	}{
+		// both modules get half of the budget
+		{"", 20 * time.Millisecond},
+		// arp.spoof gets 3/4 of the budget
+		{"arp.spoof:3", time.Second / 75},
+		// arp.spoof gets 1/5 of the budget
+		{"net.probe:4", 50 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		l := newTestRateLimiter("100", test.weights)
+		l.Wait("net.probe")
+
+		before := time.Now()
+		l.Wait("arp.spoof")
+		after := time.Now()
+
+		if interval := l.next["arp.spoof"].Sub(before); interval < test.expected || interval > test.expected+after.Sub(before) {
+			t.Fatalf("Expected an interval of %s with weights '%s', got %s", test.expected, test.weights, interval)
+		}
+	}
+
+	// idle modules don't take a share anymore
+	l := newTestRateLimiter("100", "")
+	l.next["net.probe"] = time.Now().Add(-2 * rateLimitIdle)
+
+	before := time.Now()
+	l.Wait("arp.spoof")
+	after := time.Now()
+
+	if _, found := l.next["net.probe"]; found == true {
+		t.Fatalf("Expected the idle module to be forgotten.")
+	} else if interval := l.next["arp.spoof"].Sub(before); interval < 10*time.Millisecond || interval > 10*time.Millisecond+after.Sub(before) {
+		t.Fatalf("Expected an interval of 10ms, got %s", interval)
+	}
+}
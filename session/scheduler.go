@@ -0,0 +1,388 @@
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+var scheduleWindowParser = regexp.MustCompile(`^(\d{1,2}):(\d{2})-(\d{1,2}):(\d{2})$`)
+
+// Limits when and for how long a module can run, so that unattended
+// sessions stay within the authorized testing window.
+type Schedule struct {
+	Module string `json:"module"`
+	// minutes from midnight, the window can span midnight
+	Window bool `json:"window"`
+	From   int  `json:"from"`
+	To     int  `json:"to"`
+	// total running time allowed, across restarts
+	MaxDuration time.Duration `json:"max_duration"`
+	Elapsed     time.Duration `json:"elapsed"`
+	// events of type EventPrefix after which the module is stopped
+	MaxEvents   uint64 `json:"max_events"`
+	EventPrefix string `json:"event_prefix"`
+	Events      uint64 `json:"events"`
+	// why the module can't run anymore, if it can't
+	Expired string `json:"expired"`
+
+	eventsBase uint64
+	since      time.Time
+	open       bool
+}
+
+func parseScheduleTime(hours string, minutes string) (int, error) {
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	if h > 23 || m > 59 {
+		return 0, fmt.Errorf("Invalid time %s:%s.", hours, minutes)
+	}
+	return h*60 + m, nil
+}
+
+func minutesString(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func (sc *Schedule) InWindow(t time.Time) bool {
+	if sc.Window == false {
+		return true
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	if sc.From < sc.To {
+		return now >= sc.From && now < sc.To
+	}
+	return now >= sc.From || now < sc.To
+}
+
+func (sc *Schedule) String() string {
+	parts := make([]string, 0)
+	if sc.Window == true {
+		parts = append(parts, fmt.Sprintf("%s-%s", minutesString(sc.From), minutesString(sc.To)))
+	}
+	if sc.MaxDuration > 0 {
+		parts = append(parts, fmt.Sprintf("%s of %s", sc.Elapsed.Round(time.Second), sc.MaxDuration))
+	}
+	if sc.MaxEvents > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d %s events", sc.Events, sc.MaxEvents, sc.EventPrefix))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type Scheduler struct {
+	sync.Mutex
+
+	session   *Session
+	schedules map[string]*Schedule
+	quit      chan bool
+}
+
+func NewScheduler(s *Session) *Scheduler {
+	return &Scheduler{
+		session:   s,
+		schedules: make(map[string]*Schedule),
+	}
+}
+
+func (sch *Scheduler) sumEvents(prefix string) uint64 {
+	total := uint64(0)
+	for tag, n := range sch.session.Events.Counters() {
+		if strings.HasPrefix(tag, prefix) {
+			total += n
+		}
+	}
+	return total
+}
+
+// Returns the schedule of the module, creating it if needed.
+func (sch *Scheduler) get(module string) *Schedule {
+	sc, found := sch.schedules[module]
+	if found == false {
+		sc = &Schedule{Module: module}
+		sch.schedules[module] = sc
+	}
+
+	if sch.quit == nil {
+		sch.quit = make(chan bool)
+		go sch.worker(sch.quit)
+	}
+
+	return sc
+}
+
+func (sch *Scheduler) SetWindow(module string, from int, to int) {
+	sch.Lock()
+	defer sch.Unlock()
+
+	sc := sch.get(module)
+	sc.Window = true
+	sc.From = from
+	sc.To = to
+	sc.open = false
+}
+
+func (sch *Scheduler) SetDuration(module string, max time.Duration) {
+	sch.Lock()
+	defer sch.Unlock()
+
+	sc := sch.get(module)
+	sc.MaxDuration = max
+	sc.Elapsed = 0
+	sc.Expired = ""
+	sc.since = time.Time{}
+}
+
+func (sch *Scheduler) SetEvents(module string, max uint64, prefix string) {
+	sch.Lock()
+	defer sch.Unlock()
+
+	sc := sch.get(module)
+	sc.MaxEvents = max
+	sc.EventPrefix = prefix
+	sc.Events = 0
+	sc.eventsBase = sch.sumEvents(prefix)
+	sc.Expired = ""
+}
+
+func (sch *Scheduler) Clear(module string) bool {
+	sch.Lock()
+	defer sch.Unlock()
+
+	if _, found := sch.schedules[module]; found == false {
+		return false
+	}
+
+	delete(sch.schedules, module)
+	if len(sch.schedules) == 0 && sch.quit != nil {
+		close(sch.quit)
+		sch.quit = nil
+	}
+	return true
+}
+
+func (sch *Scheduler) Stop() {
+	sch.Lock()
+	defer sch.Unlock()
+
+	if sch.quit != nil {
+		close(sch.quit)
+		sch.quit = nil
+	}
+}
+
+// A snapshot of the schedules sorted by module name.
+func (sch *Scheduler) Schedules() []Schedule {
+	sch.Lock()
+	defer sch.Unlock()
+
+	list := make([]Schedule, 0, len(sch.schedules))
+	for _, sc := range sch.schedules {
+		list = append(list, *sc)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Module < list[j].Module
+	})
+	return list
+}
+
+// Returns an error if the schedule doesn't allow the module to start now.
+func (sch *Scheduler) Check(module string) error {
+	sch.Lock()
+	defer sch.Unlock()
+
+	if sc, found := sch.schedules[module]; found == true {
+		if sc.Expired != "" {
+			return fmt.Errorf("The %s module can't be started anymore: %s.", module, sc.Expired)
+		} else if sc.InWindow(time.Now()) == false {
+			return fmt.Errorf("The %s module is scheduled to run between %s and %s.", module, minutesString(sc.From), minutesString(sc.To))
+		}
+	}
+	return nil
+}
+
+func (sch *Scheduler) worker(quit chan bool) {
+	defer sch.session.Recover()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case now := <-ticker.C:
+			sch.tick(now)
+		}
+	}
+}
+
+// Updates the schedules and returns the commands to run, the lock
+// can't be held while running them as they're checked against it.
+func (sch *Scheduler) update(now time.Time) []string {
+	sch.Lock()
+	defer sch.Unlock()
+
+	commands := make([]string, 0)
+	for name, sc := range sch.schedules {
+		err, m := sch.session.Module(name)
+		if err != nil {
+			continue
+		}
+
+		running := m.Running()
+		if running == true {
+			if sc.since.IsZero() == false {
+				sc.Elapsed += now.Sub(sc.since)
+			}
+			sc.since = now
+		} else {
+			sc.since = time.Time{}
+		}
+
+		if sc.MaxEvents > 0 {
+			sc.Events = sch.sumEvents(sc.EventPrefix) - sc.eventsBase
+		}
+
+		if sc.Expired == "" {
+			if sc.MaxDuration > 0 && sc.Elapsed >= sc.MaxDuration {
+				sc.Expired = fmt.Sprintf("it ran for %s", sc.MaxDuration)
+			} else if sc.MaxEvents > 0 && sc.Events >= sc.MaxEvents {
+				sc.Expired = fmt.Sprintf("%d %s events", sc.Events, sc.EventPrefix)
+			}
+		}
+
+		inWindow := sc.InWindow(now)
+		opened := inWindow == true && sc.open == false
+		sc.open = inWindow
+		if running == true && (sc.Expired != "" || inWindow == false) {
+			why := sc.Expired
+			if why == "" {
+				why = fmt.Sprintf("outside of %s-%s", minutesString(sc.From), minutesString(sc.To))
+			}
+			sch.session.Events.Log(core.WARNING, "Stopping %s, %s.", core.Bold(name), why)
+			commands = append(commands, name+" off")
+		} else if running == false && sc.Expired == "" && sc.Window == true && opened == true {
+			// started once when the window opens, it can still be stopped by hand
+			sch.session.Events.Log(core.INFO, "Starting %s, scheduled at %s.", core.Bold(name), minutesString(sc.From))
+			commands = append(commands, name+" on")
+		}
+	}
+
+	return commands
+}
+
+func (sch *Scheduler) tick(now time.Time) {
+	for _, cmd := range sch.update(now) {
+		if err := sch.session.Run(cmd); err != nil {
+			sch.session.Events.Log(core.ERROR, "%s", err)
+		}
+	}
+}
+
+// Completes the name of a module followed by suffix.
+func (s *Session) moduleCompleter(suffix string) func(string) []string {
+	return func(line string) []string {
+		names := make([]string, 0)
+		for _, m := range s.Modules {
+			names = append(names, m.Name()+suffix)
+		}
+		return names
+	}
+}
+
+func (s *Session) scheduleWindowHandler(args []string, sess *Session) error {
+	if err, _ := s.Module(args[0]); err != nil {
+		return err
+	}
+
+	m := scheduleWindowParser.FindStringSubmatch(args[1])
+	if m == nil {
+		return fmt.Errorf("Invalid window '%s', expected HH:MM-HH:MM.", args[1])
+	}
+
+	from, err := parseScheduleTime(m[1], m[2])
+	if err != nil {
+		return err
+	}
+	to, err := parseScheduleTime(m[3], m[4])
+	if err != nil {
+		return err
+	} else if from == to {
+		return fmt.Errorf("The window must not be empty.")
+	}
+
+	s.Scheduler.SetWindow(args[0], from, to)
+	return nil
+}
+
+func (s *Session) scheduleDurationHandler(args []string, sess *Session) error {
+	if err, _ := s.Module(args[0]); err != nil {
+		return err
+	}
+
+	max, err := time.ParseDuration(args[1])
+	if err != nil {
+		return err
+	} else if max <= 0 {
+		return fmt.Errorf("The duration must be positive.")
+	}
+
+	s.Scheduler.SetDuration(args[0], max)
+	return nil
+}
+
+func (s *Session) scheduleEventsHandler(args []string, sess *Session) error {
+	if err, _ := s.Module(args[0]); err != nil {
+		return err
+	}
+
+	max, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return err
+	} else if max == 0 {
+		return fmt.Errorf("The number of events must be positive.")
+	}
+
+	prefix := strings.TrimSpace(args[2])
+	if prefix == "" {
+		prefix = args[0]
+	}
+
+	s.Scheduler.SetEvents(args[0], max, prefix)
+	return nil
+}
+
+func (s *Session) scheduleClearHandler(args []string, sess *Session) error {
+	if s.Scheduler.Clear(args[0]) == false {
+		return fmt.Errorf("The %s module is not scheduled.", args[0])
+	}
+	return nil
+}
+
+func (s *Session) schedulesHandler(args []string, sess *Session) error {
+	schedules := s.Scheduler.Schedules()
+	if len(schedules) == 0 {
+		fmt.Println(core.Dim("No scheduled modules."))
+		return nil
+	}
+
+	fmt.Println()
+	for _, sc := range schedules {
+		status := core.Green("allowed")
+		if sc.Expired != "" {
+			status = core.Red("expired, " + sc.Expired)
+		} else if sc.InWindow(time.Now()) == false {
+			status = core.Yellow("waiting")
+		}
+		fmt.Printf("  %s : %s ( %s )\n", core.Bold(sc.Module), sc.String(), status)
+	}
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+
+	"github.com/robertkrimen/otto"
+)
+
+// A javascript file driving the session, it can use:
+//
+//	run("command")             execute a command, returns false on error
+//	onEvent("prefix", cb)      call cb(event) for every event starting with prefix
+//	sleep(seconds)             wait for the given amount of seconds
+//	log(...)                   print a message
+//	env.get("name")            value of a session variable, undefined if not set
+//	env.set("name", "value")   set a session variable
+type SessionScript struct {
+	Path string
+
+	sess     *Session
+	vm       *otto.Otto
+	lock     *sync.Mutex
+	handlers map[string][]otto.Value
+}
+
+func LoadSessionScript(path string, sess *Session) (*SessionScript, error) {
+	path, err := core.ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SessionScript{
+		Path:     path,
+		sess:     sess,
+		vm:       otto.New(),
+		lock:     &sync.Mutex{},
+		handlers: make(map[string][]otto.Value),
+	}
+
+	if err = s.defineBuiltins(); err != nil {
+		return nil, err
+	}
+
+	// compile now in order to report syntax errors right away
+	script, err := s.vm.Compile(path, string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	go s.eventsListener()
+	go func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if _, err := s.vm.Run(script); err != nil {
+			s.sess.Events.Log(core.ERROR, "%s: %s", path, err)
+		}
+	}()
+
+	return s, nil
+}
+
+func (s *SessionScript) defineBuiltins() error {
+	// the storage map is shared with the session, only access it
+	// through the environment methods holding its lock
+	env, err := s.vm.Object(`({})`)
+	if err != nil {
+		return err
+	}
+
+	if err = env.Set("get", func(call otto.FunctionCall) otto.Value {
+		if found, value := s.sess.Env.Get(call.Argument(0).String()); found == true {
+			v, _ := otto.ToValue(value)
+			return v
+		}
+		return otto.UndefinedValue()
+	}); err != nil {
+		return err
+	}
+
+	if err = env.Set("set", func(call otto.FunctionCall) otto.Value {
+		s.sess.Env.Set(call.Argument(0).String(), call.Argument(1).String())
+		return otto.Value{}
+	}); err != nil {
+		return err
+	}
+
+	if err = s.vm.Set("env", env); err != nil {
+		return err
+	}
+
+	if err := s.vm.Set("run", func(call otto.FunctionCall) otto.Value {
+		ok := true
+		for _, cmd := range ParseCommands(call.Argument(0).String()) {
+			if err := s.sess.Run(cmd); err != nil {
+				s.sess.Events.Log(core.ERROR, "%s: %s", s.Path, err)
+				ok = false
+				break
+			}
+		}
+
+		v, _ := otto.ToValue(ok)
+		return v
+	}); err != nil {
+		return err
+	}
+
+	if err := s.vm.Set("onEvent", func(call otto.FunctionCall) otto.Value {
+		prefix := call.Argument(0).String()
+		cb := call.Argument(1)
+		if cb.IsFunction() == false {
+			s.sess.Events.Log(core.ERROR, "%s: onEvent expects a callback function.", s.Path)
+			return otto.Value{}
+		}
+
+		s.handlers[prefix] = append(s.handlers[prefix], cb)
+		return otto.Value{}
+	}); err != nil {
+		return err
+	}
+
+	if err := s.vm.Set("sleep", func(call otto.FunctionCall) otto.Value {
+		secs, err := call.Argument(0).ToFloat()
+		if err != nil {
+			s.sess.Events.Log(core.ERROR, "%s: %s", s.Path, err)
+			return otto.Value{}
+		}
+
+		// let event callbacks run while we wait
+		s.lock.Unlock()
+		time.Sleep(time.Duration(secs * float64(time.Second)))
+		s.lock.Lock()
+
+		return otto.Value{}
+	}); err != nil {
+		return err
+	}
+
+	return s.vm.Set("log", func(call otto.FunctionCall) otto.Value {
+		for _, v := range call.ArgumentList {
+			fmt.Printf("%s", v.String())
+		}
+		fmt.Println()
+		s.sess.Refresh()
+
+		return otto.Value{}
+	})
+}
+
+func (s *SessionScript) onEvent(e Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var obj otto.Value
+	for prefix, callbacks := range s.handlers {
+		if strings.HasPrefix(e.Tag, prefix) == false {
+			continue
+		}
+
+		if obj.IsUndefined() == true {
+			raw, err := json.Marshal(e)
+			if err != nil {
+				s.sess.Events.Log(core.ERROR, "%s: error while encoding %s event: %s", s.Path, e.Tag, err)
+				return
+			} else if obj, err = s.vm.Call("JSON.parse", nil, string(raw)); err != nil {
+				s.sess.Events.Log(core.ERROR, "%s: error while decoding %s event: %s", s.Path, e.Tag, err)
+				return
+			}
+		}
+
+		for _, cb := range callbacks {
+			if _, err := cb.Call(otto.NullValue(), obj); err != nil {
+				s.sess.Events.Log(core.ERROR, "%s: error in %s callback: %s", s.Path, prefix, err)
+			}
+		}
+	}
+}
+
+func (s *SessionScript) eventsListener() {
+	defer s.sess.Recover()
+	listener := s.sess.Events.Listen()
+	defer s.sess.Events.Unlisten(listener)
+
+	for e := range listener {
+		// errors reported by the callbacks would trigger them again
+		if msg, ok := e.Data.(LogMessage); ok == true && e.Tag == "sys.log" && strings.HasPrefix(msg.Message, s.Path+": ") {
+			continue
+		}
+		s.onEvent(e)
+	}
+}
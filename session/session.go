@@ -1,15 +1,11 @@
 package session
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
-	"os"
-	"os/signal"
-	"os/user"
 	"sort"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 
 	"github.com/chzyer/readline"
@@ -34,7 +30,19 @@ type Session struct {
 	Firewall  firewall.FirewallManager `json:"-"`
 	Env       *Environment             `json:"env"`
 	Targets   *Targets                 `json:"targets"`
+	BLE       *net.BLE                 `json:"ble"`
+	HID       *net.HID                 `json:"hid"`
+	Wireless  *net.WirelessWatcher     `json:"-"`
+	GPS       *GPS                     `json:"gps"`
+	RateLimit *RateLimiter             `json:"-"`
+	Scheduler *Scheduler               `json:"-"`
+	Aliases   *Aliases                 `json:"-"`
 	Queue     *packets.Queue           `json:"packets"`
+	LogFile   *LogFile                 `json:"-"`
+	Scripts   []*SessionScript         `json:"-"`
+	Journal   *Journal                 `json:"-"`
+	Creds     *Credentials             `json:"-"`
+	Container *ContainerInfo           `json:"container"`
 	Input     *readline.Instance       `json:"-"`
 	Active    bool                     `json:"active"`
 	Prompt    Prompt                   `json:"-"`
@@ -43,12 +51,39 @@ type Session struct {
 	Modules      []Module         `json:"-"`
 	HelpPadding  int              `json:"-"`
 
+	closeLock   *sync.Mutex
+	closed      bool
+	recoverOnce sync.Once
+	inputPrompt string
+
+	ifaceLock   *sync.RWMutex
+	queueDryRun func(raw []byte)
+
+	triggersLock *sync.Mutex
+	triggerRuns  map[string]*triggerRun
+
 	Events *EventPool `json:"-"`
 }
 
+// Splits a buffer into commands separated by ';', semicolons
+// between double quotes are not considered as separators.
 func ParseCommands(buffer string) []string {
 	cmds := make([]string, 0)
-	for _, cmd := range strings.Split(buffer, ";") {
+	parts := make([]string, 0)
+	quoted := false
+	last := 0
+
+	for i, c := range buffer {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ';' && quoted == false {
+			parts = append(parts, buffer[last:i])
+			last = i + 1
+		}
+	}
+	parts = append(parts, buffer[last:])
+
+	for _, cmd := range parts {
 		cmd = strings.Trim(cmd, "\r\n\t ")
 		if cmd != "" || (len(cmd) > 0 && cmd[0] != '#') {
 			cmds = append(cmds, cmd)
@@ -69,6 +104,16 @@ func New() (*Session, error) {
 		CoreHandlers: make([]CommandHandler, 0),
 		Modules:      make([]Module, 0),
 		HelpPadding:  0,
+		Scripts:      make([]*SessionScript, 0),
+		Journal:      NewJournal(),
+
+		closeLock: &sync.Mutex{},
+		closed:    false,
+
+		ifaceLock: &sync.RWMutex{},
+
+		triggersLock: &sync.Mutex{},
+		triggerRuns:  make(map[string]*triggerRun),
 
 		Events: nil,
 	}
@@ -77,13 +122,41 @@ func New() (*Session, error) {
 		return nil, err
 	}
 
+	if *s.Options.NoColors == true || *s.Options.JSONOutput == true {
+		core.DisableColors()
+		for tok := range PromptEffects {
+			PromptEffects[tok] = ""
+		}
+	}
+	core.JSONOutput = *s.Options.JSONOutput
+
+	if err = firewall.SetBackend(*s.Options.Firewall); err != nil {
+		return nil, err
+	}
+	firewall.ExactRestore = *s.Options.FirewallExact
+
 	s.Env = NewEnvironment(s)
-	s.Events = NewEventPool(*s.Options.Debug, *s.Options.Silent)
+	s.Creds = NewCredentials(s)
+	s.GPS = NewGPS()
+	s.RateLimit = NewRateLimiter(s)
+	s.Scheduler = NewScheduler(s)
+	s.Events = NewEventPool(*s.Options.Debug, *s.Options.Silent, *s.Options.EventsBuffer)
+
+	if *s.Options.LogFile != "" {
+		if s.LogFile, err = NewLogFile(*s.Options.LogFile, *s.Options.LogLevel, *s.Options.LogFormat, *s.Options.LogMaxSize); err != nil {
+			return nil, err
+		}
+		s.Events.SetLogFile(s.LogFile)
+	}
 
-	if u, err := user.Current(); err != nil {
+	if s.Aliases, err = LoadAliases(AliasesFile); err != nil {
+		s.Events.Log(core.WARNING, "%s", err)
+	}
+
+	if err := checkCapabilities("This software", SessionCapabilities); err != nil {
 		return nil, err
-	} else if u.Uid != "0" {
-		return nil, fmt.Errorf("This software must run as root.")
+	} else if isRoot() == false {
+		s.Events.Log(core.WARNING, "Running without root privileges, modules changing the firewall or the kernel parameters won't be available.")
 	}
 
 	s.registerCoreHandlers()
@@ -104,9 +177,24 @@ func (s *Session) Module(name string) (err error, mod Module) {
 	return fmt.Errorf("Module %s not found", name), mod
 }
 
+// Returns true if the variable holds the value of a secret parameter.
+func (s *Session) IsSecret(name string) bool {
+	for _, m := range s.Modules {
+		if p, found := m.Parameters()[name]; found == true && p.Secret == true {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Session) setupInput() error {
 	var err error
 
+	// non interactive session, nothing to read from
+	if *s.Options.NoPrompt == true {
+		return nil
+	}
+
 	pcompleters := make([]readline.PrefixCompleterInterface, 0)
 	for _, h := range s.CoreHandlers {
 		if h.Completer == nil {
@@ -142,13 +230,20 @@ func (s *Session) setupInput() error {
 		pcompleters = append(pcompleters, item)
 	}
 
+	pcompleters = append(pcompleters, readline.PcItemDynamic(func(prefix string) []string {
+		return s.Aliases.Names()
+	}))
+
 	history := ""
 	if *s.Options.NoHistory == false {
-		history = "bettercap.history"
+		if history, err = core.ExpandPath(*s.Options.HistoryFile); err != nil {
+			return err
+		}
 	}
 
 	cfg := readline.Config{
 		HistoryFile:       history,
+		HistoryLimit:      *s.Options.HistoryLimit,
 		InterruptPrompt:   "^C",
 		EOFPrompt:         "exit",
 		HistorySearchFold: true,
@@ -171,24 +266,115 @@ func (s *Session) setupInput() error {
 	return nil
 }
 
-func (s *Session) Close() {
-	s.Events.Add("session.closing", nil)
+func (s *Session) Register(mod Module) error {
+	s.Modules = append(s.Modules, mod)
+	return nil
+}
 
-	for _, m := range s.Modules {
-		if m.Running() {
-			m.Stop()
-		}
+func (s *Session) findGateway() {
+	iface := s.CurrentInterface()
+
+	gw, err := net.FindGateway(iface)
+	if err != nil {
+		s.Events.Log(core.WARNING, "%s", err.Error())
+	}
+
+	if gw == nil || gw.IpAddress == iface.IpAddress {
+		gw = iface
+	}
+
+	s.ifaceLock.Lock()
+	s.Gateway = gw
+	if s.Targets != nil {
+		s.Targets.Gateway = gw
 	}
+	s.ifaceLock.Unlock()
 
-	s.Firewall.Restore()
-	s.Queue.Stop()
+	s.Env.Set("gateway.address", gw.IpAddress)
+	s.Env.Set("gateway.mac", gw.HwAddress)
 }
 
-func (s *Session) Register(mod Module) error {
-	s.Modules = append(s.Modules, mod)
+// Updates the session after the interface configuration changed,
+// for instance its MAC or IP address, looking for the gateway again.
+func (s *Session) Rediscover() {
+	iface := s.CurrentInterface()
+	s.Env.Set("iface.ipv4", iface.IpAddress)
+	s.Env.Set("iface.ipv6", iface.Ip6Address)
+	s.Env.Set("iface.mac", iface.HwAddress)
+	s.findGateway()
+}
+
+// Returns the interface and the gateway as they are now, both are
+// replaced as a whole when the network changes.
+func (s *Session) CurrentInterface() *net.Endpoint {
+	s.ifaceLock.RLock()
+	defer s.ifaceLock.RUnlock()
+	return s.Interface
+}
+
+func (s *Session) CurrentGateway() *net.Endpoint {
+	s.ifaceLock.RLock()
+	defer s.ifaceLock.RUnlock()
+	return s.Gateway
+}
+
+// Replaces the session interface with iface, which must not be modified
+// afterwards. If reopen is true the packet queue is opened again on the
+// new interface, as its capture handle doesn't survive the link going
+// down and it filters the activities by the interface addresses.
+func (s *Session) SwapInterface(iface *net.Endpoint, reopen bool) error {
+	var q *packets.Queue
+	var err error
+
+	if reopen == true {
+		if q, err = packets.NewQueue(iface); err != nil {
+			return err
+		} else if s.queueDryRun != nil {
+			q.SetDryRun(s.queueDryRun)
+		}
+	}
+
+	s.ifaceLock.Lock()
+	old := s.Queue
+	s.Interface = iface
+	s.Targets.Interface = iface
+	if q != nil {
+		s.Queue = q
+	}
+	s.ifaceLock.Unlock()
+
+	if q != nil {
+		go s.activitiesListener(q)
+		old.Stop()
+	}
+
+	s.Rediscover()
+
 	return nil
 }
 
+// Keeps reading network events of the queue in order to add / update
+// endpoints, until the queue is stopped.
+func (s *Session) activitiesListener(q *packets.Queue) {
+	defer s.Recover()
+
+	for event := range q.Activities {
+		if event.Source == true {
+			addr := event.IP.String()
+			mac := event.MAC.String()
+
+			existing := s.Targets.AddIfNotExist(addr, mac)
+			if existing != nil {
+				existing.LastSeen = time.Now()
+			}
+		}
+
+		if s.Active == false {
+			return
+		}
+	}
+}
+
 func (s *Session) Start() error {
 	var err error
 
@@ -197,13 +383,14 @@ func (s *Session) Start() error {
 		return s.Modules[i].Name() < s.Modules[j].Name()
 	})
 
-	net.OuiInit()
-
 	if s.Interface, err = net.FindInterface(*s.Options.InterfaceName); err != nil {
 		return err
 	}
 
 	s.Env.Set(PromptVariable, DefaultPrompt)
+	s.Env.Set(AutoDepsVariable, "false")
+	s.Env.Set(RateLimitVariable, "0")
+	s.Env.Set(RateLimitWeightsVariable, "")
 
 	s.Env.Set("iface.name", s.Interface.Name())
 	s.Env.Set("iface.ipv4", s.Interface.IpAddress)
@@ -214,20 +401,44 @@ func (s *Session) Start() error {
 		return err
 	}
 
-	if s.Gateway, err = net.FindGateway(s.Interface); err != nil {
-		s.Events.Log(core.WARNING, "%s", err.Error())
-	}
+	s.findGateway()
 
-	if s.Gateway == nil || s.Gateway.IpAddress == s.Interface.IpAddress {
-		s.Gateway = s.Interface
-	}
+	s.Targets = NewTargets(s, s.Interface, s.Gateway)
+	s.BLE = net.NewBLE(func(dev *net.BLEDevice) {
+		dev.Locate(s.GPS.Position())
+		s.Events.Add("ble.device.new", dev)
+	}, func(dev *net.BLEDevice) {
+		s.Events.Add("ble.device.lost", dev)
+	})
+	s.HID = net.NewHID(func(dev *net.HIDDevice) {
+		s.Events.Add("hid.device.new", dev)
+	}, func(dev *net.HIDDevice) {
+		s.Events.Add("hid.device.lost", dev)
+	})
+	s.Wireless = net.NewWirelessWatcher(func(name string) {
+		s.Events.Add("wifi.iface.new", name)
+	}, func(name string) {
+		s.Events.Add("wifi.iface.lost", name)
+	})
+	s.Wireless.Start(2 * time.Second)
+	s.setupContainer()
 
-	s.Env.Set("gateway.address", s.Gateway.IpAddress)
-	s.Env.Set("gateway.mac", s.Gateway.HwAddress)
+	if *s.Options.Pprof != "" {
+		if err := s.startPprof(*s.Options.Pprof); err != nil {
+			return err
+		}
+	}
 
-	s.Targets = NewTargets(s, s.Interface, s.Gateway)
+	firewall.Warn = func(format string, args ...interface{}) {
+		s.Events.Log(core.WARNING, format, args...)
+	}
 	s.Firewall = firewall.Make()
 
+	if *s.Options.DryRun == true {
+		s.setupDryRun()
+	}
+	s.Firewall = newJournaledFirewall(s.Firewall, s.Journal)
+
 	if err := s.setupInput(); err != nil {
 		return err
 	}
@@ -251,37 +462,13 @@ func (s *Session) Start() error {
 		}
 	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	signal.Notify(c, syscall.SIGTERM)
-	go func() {
-		<-c
-		fmt.Println()
-		s.Events.Log(core.WARNING, "Got SIGTERM")
-		s.Close()
-		os.Exit(0)
-	}()
+	go s.signalsHandler()
 
 	s.Active = true
 
-	// keep reading network events in order to add / update endpoints
-	go func() {
-		for event := range s.Queue.Activities {
-			if event.Source == true {
-				addr := event.IP.String()
-				mac := event.MAC.String()
+	go s.triggersListener()
 
-				existing := s.Targets.AddIfNotExist(addr, mac)
-				if existing != nil {
-					existing.LastSeen = time.Now()
-				}
-			}
-
-			if s.Active == false {
-				return
-			}
-		}
-	}()
+	go s.activitiesListener(s.Queue)
 
 	s.Events.Add("session.started", nil)
 
@@ -289,43 +476,60 @@ func (s *Session) Start() error {
 }
 
 func (s *Session) Refresh() {
-	s.Input.SetPrompt(s.Prompt.Render(s))
+	if s.Input == nil {
+		return
+	}
+
+	if s.inputPrompt != "" {
+		s.Input.SetPrompt(s.inputPrompt)
+	} else {
+		s.Input.SetPrompt(s.Prompt.Render(s))
+	}
 	s.Input.Refresh()
 }
 
-func (s *Session) ReadLine() (string, error) {
-	s.Refresh()
-	return s.Input.Readline()
-}
+// Asks the user for a value using a custom prompt, if hidden
+// is true the value is not echoed back nor saved in the history.
+func (s *Session) ReadInput(prompt string, hidden bool) (string, error) {
+	if s.Input == nil {
+		return "", fmt.Errorf("Interactive prompt is disabled.")
+	}
 
-func (s *Session) RunCaplet(filename string) error {
-	s.Events.Log(core.INFO, "Reading from caplet %s ...", filename)
+	s.inputPrompt = prompt
+	defer func() {
+		s.inputPrompt = ""
+	}()
 
-	input, err := os.Open(filename)
-	if err != nil {
-		return err
+	if hidden == true {
+		raw, err := s.Input.ReadPassword(prompt)
+		return string(raw), err
 	}
-	defer input.Close()
-
-	scanner := bufio.NewScanner(input)
-	scanner.Split(bufio.ScanLines)
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" || line[0] == '#' {
-			continue
-		}
+	s.Refresh()
+	return s.Input.Readline()
+}
 
-		if err = s.Run(line); err != nil {
-			return err
-		}
+func (s *Session) ReadLine() (string, error) {
+	if s.Input == nil {
+		return "", fmt.Errorf("Interactive prompt is disabled.")
 	}
 
-	return nil
+	s.Refresh()
+	return s.Input.Readline()
 }
 
 func (s *Session) Run(line string) error {
 	line = strings.TrimRight(line, " ")
+	// the prompt and aliases are expanded every time they're used,
+	// so their tokens must not be expanded when they're defined.
+	if strings.HasPrefix(line, "set "+PromptVariable+" ") == false && strings.HasPrefix(line, "alias ") == false {
+		line = s.Env.Expand(line)
+	}
+	return s.dispatch(line)
+}
+
+// Executes the handler matching the line, as it is.
+func (s *Session) dispatch(line string) error {
 	for _, h := range s.CoreHandlers {
 		if parsed, args := h.Parse(line); parsed == true {
 			return h.Exec(args, s)
@@ -335,10 +539,46 @@ func (s *Session) Run(line string) error {
 	for _, m := range s.Modules {
 		for _, h := range m.Handlers() {
 			if parsed, args := h.Parse(line); parsed == true {
-				return h.Exec(args)
+				if h.Name == m.Name()+" on" {
+					if err := s.checkModuleCapabilities(m.Name()); err != nil {
+						return err
+					} else if err := s.checkContainer(m.Name()); err != nil {
+						return err
+					} else if err := s.Scheduler.Check(m.Name()); err != nil {
+						return err
+					}
+				}
+				// so that the goroutines the handler starts are accounted to the module
+				var err error
+				withModuleLabel(m.Name(), func() {
+					err = h.Exec(args)
+				})
+				return err
 			}
 		}
 	}
 
+	if commands, found := s.Aliases.Get(line); found == true {
+		return s.runAlias(commands, 1)
+	}
+
 	return fmt.Errorf("Unknown command %s%s%s, type %shelp%s for the help menu.", core.BOLD, line, core.RESET, core.BOLD, core.RESET)
 }
+
+func (s *Session) runAlias(commands string, depth int) error {
+	if depth > maxAliasDepth {
+		return fmt.Errorf("Too many nested aliases, check your aliases for loops.")
+	}
+
+	for _, cmd := range ParseCommands(commands) {
+		if nested, found := s.Aliases.Get(cmd); found == true {
+			if err := s.runAlias(nested, depth+1); err != nil {
+				return err
+			}
+		} else if err := s.Run(cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
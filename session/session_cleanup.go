@@ -0,0 +1,139 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// Stops every running module, removes firewall redirections and restores
+// the original forwarding state, it's safe to call it more than once.
+func (s *Session) Close() {
+	s.closeLock.Lock()
+	if s.closed == true {
+		s.closeLock.Unlock()
+		return
+	}
+	s.closed = true
+	s.closeLock.Unlock()
+
+	if s.Events != nil {
+		s.Events.Add("session.closing", nil)
+	}
+
+	// nothing must be started again while the network is restored
+	if s.Scheduler != nil {
+		s.Scheduler.Stop()
+	}
+
+	if s.Wireless != nil {
+		s.Wireless.Stop()
+	}
+
+	for _, m := range s.Modules {
+		if m.Running() {
+			s.stopModule(m)
+		}
+	}
+
+	if s.Firewall != nil {
+		s.Firewall.Restore()
+	}
+
+	// whatever the modules and the firewall failed to restore
+	s.Rollback()
+
+	if s.Creds != nil {
+		if err := s.Creds.SaveVault(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error while saving the vault: %s\n", err)
+		}
+	}
+
+	if s.Queue != nil {
+		s.Queue.Stop()
+	}
+
+	if s.Events != nil {
+		s.Events.Flush()
+	}
+}
+
+// a module failing to stop must not prevent the others from
+// restoring the network state.
+func (s *Session) stopModule(m Module) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "Error while stopping %s: %v\n", m.Name(), r)
+		}
+	}()
+
+	if err := m.Stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error while stopping %s: %s\n", m.Name(), err)
+	}
+}
+
+// Reverts every change recorded in the journal, the most recent first.
+func (s *Session) Rollback() int {
+	return s.Journal.Rollback(func(e *JournalEntry, err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error while reverting '%s': %s\n", e.Description, err)
+		} else if s.Events != nil {
+			s.Events.Log(core.INFO, "Reverted: %s", e.Description)
+		}
+	})
+}
+
+// Time given to Close after a panic, modules may wait for the
+// goroutine that panicked while stopping.
+const recoverTimeout = 10 * time.Second
+
+// To be deferred first thing by the main goroutine and by the long lived
+// goroutines of the modules, as recover only catches the panics of its
+// own goroutine: restores the network state before the panic is
+// propagated, which makes the process exit.
+func (s *Session) Recover() {
+	if r := recover(); r != nil {
+		fmt.Fprintf(os.Stderr, "\n%v\n\n%s\n", r, debug.Stack())
+		fmt.Fprintf(os.Stderr, "Restoring network state ...\n")
+
+		// goroutines panicking meanwhile wait for the first one
+		s.recoverOnce.Do(func() {
+			done := make(chan bool)
+			go func() {
+				s.Close()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(recoverTimeout):
+				fmt.Fprintf(os.Stderr, "Timed out while restoring the network state.\n")
+			}
+		})
+
+		panic(r)
+	}
+}
+
+func (s *Session) signalsHandler() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+
+	sig := <-c
+	fmt.Println()
+	s.Events.Log(core.WARNING, "Got %s, restoring network state ( send it again to force exit ) ...", sig)
+
+	go func() {
+		<-c
+		fmt.Fprintf(os.Stderr, "Forced exit.\n")
+		os.Exit(1)
+	}()
+
+	s.Close()
+	os.Exit(0)
+}
@@ -20,12 +20,12 @@ func (s *Session) helpHandler(args []string, sess *Session) error {
 
 	if filter == "" {
 		fmt.Println()
-		fmt.Printf(core.Bold("MAIN COMMANDS\n\n"))
+		fmt.Print(core.Bold("MAIN COMMANDS\n\n"))
 		for _, h := range s.CoreHandlers {
 			fmt.Printf("  "+core.Yellow("%"+strconv.Itoa(s.HelpPadding)+"s")+" : %s\n", h.Name, h.Description)
 		}
 
-		fmt.Printf(core.Bold("\nMODULES\n"))
+		fmt.Print(core.Bold("\nMODULES\n"))
 
 		for _, m := range s.Modules {
 			status := ""
@@ -42,7 +42,7 @@ func (s *Session) helpHandler(args []string, sess *Session) error {
 	} else {
 		err, m := s.Module(filter)
 		if err != nil {
-			return err
+			return s.helpSearch(filter)
 		}
 
 		fmt.Println()
@@ -54,14 +54,14 @@ func (s *Session) helpHandler(args []string, sess *Session) error {
 		}
 		fmt.Printf("%s (%s): %s\n\n", core.Yellow(m.Name()), status, core.Dim(m.Description()))
 		for _, h := range m.Handlers() {
-			fmt.Printf(h.Help(s.HelpPadding))
+			fmt.Print(h.Help(s.HelpPadding))
 		}
 
 		params := m.Parameters()
 		if len(params) > 0 {
 			fmt.Printf("\n  Parameters\n\n")
 			for _, p := range params {
-				fmt.Printf(p.Help(s.HelpPadding))
+				fmt.Print(p.Help(s, s.HelpPadding))
 			}
 			fmt.Println()
 		}
@@ -70,26 +70,69 @@ func (s *Session) helpHandler(args []string, sess *Session) error {
 	return nil
 }
 
-func (s *Session) activeHandler(args []string, sess *Session) error {
+func helpMatch(keyword string, fields ...string) bool {
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), keyword) == true {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Session) helpSearch(keyword string) error {
+	keyword = strings.ToLower(keyword)
+	found := false
+
+	fmt.Println()
+	for _, h := range s.CoreHandlers {
+		if helpMatch(keyword, h.Name, h.Description) == true {
+			fmt.Printf("  "+core.Yellow("%"+strconv.Itoa(s.HelpPadding)+"s")+" : %s\n", h.Name, h.Description)
+			found = true
+		}
+	}
+
 	for _, m := range s.Modules {
-		if m.Running() == false {
+		modMatch := helpMatch(keyword, m.Name(), m.Description())
+		handlers := make([]ModuleHandler, 0)
+		for _, h := range m.Handlers() {
+			if modMatch == true || helpMatch(keyword, h.Name, h.Description) == true {
+				handlers = append(handlers, h)
+			}
+		}
+		params := make([]*ModuleParam, 0)
+		for _, p := range m.Parameters() {
+			if modMatch == true || helpMatch(keyword, p.Name, p.Description) == true {
+				params = append(params, p)
+			}
+		}
+
+		if modMatch == false && len(handlers) == 0 && len(params) == 0 {
 			continue
 		}
 
-		fmt.Printf("%s (%s)\n", core.Bold(m.Name()), core.Dim(m.Description()))
-		params := m.Parameters()
+		found = true
+		status := core.Red("not running")
+		if m.Running() {
+			status = core.Green("running")
+		}
+
+		fmt.Printf("\n%s (%s): %s\n\n", core.Yellow(m.Name()), status, core.Dim(m.Description()))
+		for _, h := range handlers {
+			fmt.Print(h.Help(s.HelpPadding))
+		}
 		if len(params) > 0 {
 			fmt.Println()
 			for _, p := range params {
-				_, val := s.Env.Get(p.Name)
-				fmt.Printf("  "+core.YELLOW+"%"+strconv.Itoa(s.HelpPadding)+"s"+core.RESET+
-					" : %s\n", p.Name, val)
+				fmt.Print(p.Help(s, s.HelpPadding))
 			}
 		}
+	}
 
-		fmt.Println()
+	if found == false {
+		return fmt.Errorf("No module, command or parameter matching '%s'.", keyword)
 	}
 
+	fmt.Println()
 	return nil
 }
 
@@ -99,7 +142,9 @@ func (s *Session) exitHandler(args []string, sess *Session) error {
 	}
 
 	s.Active = false
-	s.Input.Close()
+	if s.Input != nil {
+		s.Input.Close()
+	}
 	return nil
 }
 
@@ -112,13 +157,71 @@ func (s *Session) sleepHandler(args []string, sess *Session) error {
 	}
 }
 
+func (s *Session) ifHandler(args []string, sess *Session) error {
+	name, op, expected, cmds := args[0], args[1], args[2], args[3]
+
+	value := name
+	if found, v := s.Env.Get(name); found == true {
+		value = v
+	}
+
+	// "" is an empty value, "a b" a value containing spaces
+	if len(expected) >= 2 && expected[0] == '"' && expected[len(expected)-1] == '"' {
+		expected = expected[1 : len(expected)-1]
+	}
+
+	if (op == "==") != (value == expected) {
+		return nil
+	}
+
+	// "a; b" executes both commands only if the condition is true
+	if len(cmds) >= 2 && cmds[0] == '"' && cmds[len(cmds)-1] == '"' {
+		cmds = cmds[1 : len(cmds)-1]
+	}
+
+	for _, cmd := range ParseCommands(cmds) {
+		if err := s.Run(cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Session) readHandler(args []string, sess *Session) error {
+	hidden := args[0] != ""
+	name := args[1]
+	prompt := strings.TrimSpace(args[2])
+
+	if len(prompt) >= 2 && prompt[0] == '"' && prompt[len(prompt)-1] == '"' {
+		prompt = prompt[1 : len(prompt)-1]
+	} else if prompt == "" {
+		prompt = name
+	}
+
+	value, err := s.ReadInput(prompt+" ", hidden)
+	if err != nil {
+		return err
+	}
+
+	s.Env.Set(name, strings.TrimSpace(value))
+	return nil
+}
+
 func (s *Session) getHandler(args []string, sess *Session) error {
 	key := args[0]
-	if key == "*" {
+	if strings.HasSuffix(key, "*") == true {
+		prefix := strings.TrimSuffix(key, "*")
 		prev_ns := ""
+		found := false
 
 		fmt.Println()
 		for _, k := range s.Env.Sorted() {
+			if strings.HasPrefix(k, prefix) == false {
+				continue
+			}
+
+			found = true
 			ns := ""
 			toks := strings.Split(k, ".")
 			if len(toks) > 0 {
@@ -132,6 +235,10 @@ func (s *Session) getHandler(args []string, sess *Session) error {
 
 			fmt.Printf("  %"+strconv.Itoa(s.Env.Padding)+"s: '%s'\n", k, s.Env.Storage[k])
 		}
+
+		if found == false {
+			return fmt.Errorf("No variables matching %s.", key)
+		}
 		fmt.Println()
 	} else if found, value := s.Env.Get(key); found == true {
 		fmt.Println()
@@ -148,14 +255,35 @@ func (s *Session) setHandler(args []string, sess *Session) error {
 	key := args[0]
 	value := args[1]
 
-	if value == "\"\"" {
-		value = ""
+	// "" is an empty value, "a; b" a value containing separators
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
 	}
 
 	s.Env.Set(key, value)
 	return nil
 }
 
+func (s *Session) resetHandler(args []string, sess *Session) error {
+	prefix := strings.TrimSuffix(args[0], "*")
+	found := false
+
+	for _, m := range s.Modules {
+		for _, p := range m.Parameters() {
+			if strings.HasPrefix(p.Name, prefix) == true {
+				s.Env.Set(p.Name, p.Value)
+				found = true
+			}
+		}
+	}
+
+	if found == false {
+		return fmt.Errorf("No module parameters matching %s.", args[0])
+	}
+
+	return nil
+}
+
 func (s *Session) clsHandler(args []string, sess *Session) error {
 	// fixes a weird bug which causes the screen not to be fully
 	// cleared if a "clear; net.show" commands chain is executed
@@ -163,12 +291,26 @@ func (s *Session) clsHandler(args []string, sess *Session) error {
 	for i := 0; i < 80; i++ {
 		fmt.Println()
 	}
-	readline.ClearScreen(s.Input.Stdout())
+	if s.Input != nil {
+		readline.ClearScreen(s.Input.Stdout())
+	}
 	return nil
 }
 
 func (s *Session) includeHandler(args []string, sess *Session) error {
-	return s.RunCaplet(args[0])
+	if args = capletArgs(args[0]); len(args) == 0 {
+		return fmt.Errorf("No caplet specified.")
+	}
+	return s.RunCaplet(args[0], args[1:]...)
+}
+
+func (s *Session) scriptHandler(args []string, sess *Session) error {
+	script, err := LoadSessionScript(args[0], s)
+	if err != nil {
+		return err
+	}
+	s.Scripts = append(s.Scripts, script)
+	return nil
 }
 
 func (s *Session) shHandler(args []string, sess *Session) error {
@@ -179,15 +321,101 @@ func (s *Session) shHandler(args []string, sess *Session) error {
 	return err
 }
 
+func (s *Session) aliasHandler(args []string, sess *Session) error {
+	name := args[0]
+	commands := strings.Trim(args[1], "\" ")
+
+	if commands == "" {
+		return fmt.Errorf("Empty commands for alias %s.", name)
+	}
+
+	for _, h := range s.CoreHandlers {
+		if parsed, _ := h.Parse(name); parsed == true {
+			return fmt.Errorf("Can't use %s as an alias name, it's a builtin command.", name)
+		}
+	}
+
+	return s.Aliases.Set(name, commands)
+}
+
+func (s *Session) unaliasHandler(args []string, sess *Session) error {
+	return s.Aliases.Del(args[0])
+}
+
+func (s *Session) aliasesHandler(args []string, sess *Session) error {
+	names := s.Aliases.Names()
+	if len(names) == 0 {
+		fmt.Println(core.Dim("No aliases defined."))
+		return nil
+	}
+
+	pad := 0
+	for _, name := range names {
+		if len(name) > pad {
+			pad = len(name)
+		}
+	}
+
+	fmt.Println()
+	for _, name := range names {
+		commands, _ := s.Aliases.Get(name)
+		fmt.Printf("  %"+strconv.Itoa(pad)+"s: '%s'\n", name, commands)
+	}
+	fmt.Println()
+
+	return nil
+}
+
+func (s *Session) sessionSaveHandler(args []string, sess *Session) error {
+	return s.SaveState(args[0])
+}
+
+func (s *Session) sessionRestoreHandler(args []string, sess *Session) error {
+	return s.RestoreState(args[0])
+}
+
+func (s *Session) sessionDiffHandler(args []string, sess *Session) error {
+	return s.DiffStateFile(args[0])
+}
+
+func (s *Session) sessionJournalHandler(args []string, sess *Session) error {
+	entries := s.Journal.Entries()
+	if len(entries) == 0 {
+		fmt.Println(core.Dim("No changes to the network."))
+		return nil
+	}
+
+	fmt.Println()
+	for _, e := range entries {
+		fmt.Printf("  %s\n", e)
+	}
+	fmt.Println()
+
+	return nil
+}
+
+func (s *Session) sessionRollbackHandler(args []string, sess *Session) error {
+	for _, m := range s.Modules {
+		if m.Running() == true {
+			s.stopModule(m)
+		}
+	}
+
+	if failed := s.Rollback(); failed > 0 {
+		return fmt.Errorf("%d changes could not be reverted.", failed)
+	}
+	return nil
+}
+
 func (s *Session) addHandler(h CommandHandler, c *readline.PrefixCompleter) {
 	h.Completer = c
 	s.CoreHandlers = append(s.CoreHandlers, h)
 }
 
 func (s *Session) registerCoreHandlers() {
-	s.addHandler(NewCommandHandler("help MODULE",
+	s.addHandler(NewCommandHandler("help MODULE|KEYWORD",
 		"^(help|\\?)(.*)$",
-		"List available commands or show module specific help if no module name is provided.",
+		"List available commands, show module specific help or search commands and parameters matching KEYWORD.",
 		s.helpHandler),
 		readline.PcItem("help", readline.PcItemDynamic(func(prefix string) []string {
 			prefix = strings.Trim(prefix[4:], "\t\r\n ")
@@ -201,11 +429,23 @@ func (s *Session) registerCoreHandlers() {
 		})))
 
 	s.addHandler(NewCommandHandler("active",
-		"^active$",
-		"Show information about active modules.",
-		s.activeHandler),
+		"^(active)$",
+		"Same as modules.show, listing only the running modules.",
+		s.modulesShowHandler),
 		readline.PcItem("active"))
 
+	s.addHandler(NewCommandHandler("modules.show",
+		"^(modules\\.show)$",
+		"Show every module with its status, goroutines, memory and changed parameters, and the resources used by the whole process.",
+		s.modulesShowHandler),
+		readline.PcItem("modules.show"))
+
+	s.addHandler(NewCommandHandler("session.diag",
+		"^session\\.diag$",
+		"Show runtime diagnostics: goroutines, memory, garbage collection, open descriptors and the counters of each module.",
+		s.diagHandler),
+		readline.PcItem("session.diag"))
+
 	s.addHandler(NewCommandHandler("quit",
 		"^(q|quit|e|exit)$",
 		"Close the session and exit.",
@@ -218,9 +458,21 @@ func (s *Session) registerCoreHandlers() {
 		s.sleepHandler),
 		readline.PcItem("sleep"))
 
+	s.addHandler(NewCommandHandler("if NAME ==|!= VALUE then COMMANDS",
+		"^if\\s+([^\\s]+)\\s+(==|!=)\\s+(\"[^\"]*\"|[^\\s]+)\\s+then\\s+(.+)$",
+		"Execute COMMANDS only if the variable NAME is ( or is not ) equal to VALUE, put COMMANDS between double quotes to make the condition apply to all of them.",
+		s.ifHandler),
+		readline.PcItem("if"))
+
+	s.addHandler(NewCommandHandler("read [-s] NAME [PROMPT]",
+		"^read\\s+(-s\\s+)?([^\\s]+)(\\s+.+)?$",
+		"Ask the user for the value of the variable NAME showing PROMPT, with -s the value will not be shown nor saved in the history.",
+		s.readHandler),
+		readline.PcItem("read"))
+
 	s.addHandler(NewCommandHandler("get NAME",
 		"^get\\s+(.+)",
-		"Get the value of variable NAME, use * for all.",
+		"Get the value of variable NAME, use * for all or a trailing * to list variables by prefix ( e.g. get http.proxy.* ).",
 		s.getHandler),
 		readline.PcItem("get", readline.PcItemDynamic(func(prefix string) []string {
 			prefix = strings.Trim(prefix[3:], "\t\r\n ")
@@ -248,15 +500,32 @@ func (s *Session) registerCoreHandlers() {
 			return varNames
 		})))
 
+	s.addHandler(NewCommandHandler("reset PREFIX",
+		"^reset\\s+([^\\s]+)$",
+		"Restore the default value of every module parameter starting with PREFIX ( e.g. reset http.proxy. ).",
+		s.resetHandler),
+		readline.PcItem("reset", readline.PcItemDynamic(func(prefix string) []string {
+			prefix = strings.Trim(prefix[5:], "\t\r\n ")
+			names := []string{""}
+			for _, m := range s.Modules {
+				for name := range m.Parameters() {
+					if prefix == "" || strings.HasPrefix(name, prefix) == true {
+						names = append(names, name)
+					}
+				}
+			}
+			return names
+		})))
+
 	s.addHandler(NewCommandHandler("clear",
 		"^(clear|cls)$",
 		"Clear the screen.",
 		s.clsHandler),
 		readline.PcItem("clear"))
 
-	s.addHandler(NewCommandHandler("include CAPLET",
+	s.addHandler(NewCommandHandler("include CAPLET [ARGS]",
 		"^include\\s+(.+)",
-		"Load and run this caplet in the current session.",
+		"Load and run this caplet in the current session, $1, $2, etc. in the caplet will be replaced with the given ARGS.",
 		s.includeHandler),
 		readline.PcItem("include", readline.PcItemDynamic(func(prefix string) []string {
 			prefix = strings.Trim(prefix[8:], "\t\r\n ")
@@ -269,6 +538,101 @@ func (s *Session) registerCoreHandlers() {
 			return files
 		})))
 
+	s.addHandler(NewCommandHandler("script FILE",
+		"^script\\s+(.+)",
+		"Load and execute in background this javascript session script.",
+		s.scriptHandler),
+		readline.PcItem("script", readline.PcItemDynamic(func(prefix string) []string {
+			prefix = strings.Trim(prefix[7:], "\t\r\n ")
+			if prefix == "" {
+				prefix = "."
+			}
+
+			files := []string{}
+			files, _ = filepath.Glob(prefix + "*")
+			return files
+		})))
+
+	s.addHandler(NewCommandHandler("alias NAME COMMANDS",
+		"^alias\\s+([^\\s]+)\\s+(.+)$",
+		"Define NAME as a shortcut for the given ; separated COMMANDS, aliases are saved to "+AliasesFile+".",
+		s.aliasHandler),
+		readline.PcItem("alias"))
+
+	s.addHandler(NewCommandHandler("unalias NAME",
+		"^unalias\\s+([^\\s]+)$",
+		"Remove the alias NAME.",
+		s.unaliasHandler),
+		readline.PcItem("unalias", readline.PcItemDynamic(func(prefix string) []string {
+			return s.Aliases.Names()
+		})))
+
+	s.addHandler(NewCommandHandler("aliases",
+		"^aliases$",
+		"Show defined aliases.",
+		s.aliasesHandler),
+		readline.PcItem("aliases"))
+
+	s.addHandler(NewCommandHandler("session.save FILE",
+		"^session\\.save\\s+(.+)$",
+		"Save variables, aliases, targets and running modules of the current session to FILE.",
+		s.sessionSaveHandler),
+		readline.PcItem("session.save"))
+
+	s.addHandler(NewCommandHandler("session.restore FILE",
+		"^session\\.restore\\s+(.+)$",
+		"Restore a session previously saved to FILE.",
+		s.sessionRestoreHandler),
+		readline.PcItem("session.restore"))
+
+	s.addHandler(NewCommandHandler("session.diff FILE",
+		"^session\\.diff\\s+(.+)$",
+		"Compare the hosts of a session previously saved to FILE with the ones discovered so far, restoring it does the same if hosts were already discovered.",
+		s.sessionDiffHandler),
+		readline.PcItem("session.diff"))
+
+	s.addHandler(NewCommandHandler("session.journal",
+		"^session\\.journal$",
+		"Show the changes applied to the network which have not been reverted yet.",
+		s.sessionJournalHandler),
+		readline.PcItem("session.journal"))
+
+	s.addHandler(NewCommandHandler("session.rollback",
+		"^session\\.rollback$",
+		"Stop all modules and revert the changes applied to the network, the most recent first.",
+		s.sessionRollbackHandler),
+		readline.PcItem("session.rollback"))
+
+	s.addHandler(NewCommandHandler("MODULE.schedule HH:MM-HH:MM",
+		"^([^\\s]+)\\.schedule\\s+([^\\s]+)$",
+		"Start MODULE every day at the first time and stop it at the second one, it can't be started outside of this window.",
+		s.scheduleWindowHandler),
+		readline.PcItemDynamic(s.moduleCompleter(".schedule")))
+
+	s.addHandler(NewCommandHandler("MODULE.schedule.duration DURATION",
+		"^([^\\s]+)\\.schedule\\.duration\\s+([^\\s]+)$",
+		"Stop MODULE for good once it ran for DURATION in total ( e.g. 2h30m ).",
+		s.scheduleDurationHandler),
+		readline.PcItemDynamic(s.moduleCompleter(".schedule.duration")))
+
+	s.addHandler(NewCommandHandler("MODULE.schedule.events N [TYPE]",
+		"^([^\\s]+)\\.schedule\\.events\\s+(\\d+)(\\s+[^\\s]+)?$",
+		"Stop MODULE for good after N events whose type starts with TYPE ( the module name by default, e.g. creds.new for captured credentials ).",
+		s.scheduleEventsHandler),
+		readline.PcItemDynamic(s.moduleCompleter(".schedule.events")))
+
+	s.addHandler(NewCommandHandler("MODULE.schedule.clear",
+		"^([^\\s]+)\\.schedule\\.clear$",
+		"Remove the schedule of MODULE.",
+		s.scheduleClearHandler),
+		readline.PcItemDynamic(s.moduleCompleter(".schedule.clear")))
+
+	s.addHandler(NewCommandHandler("schedules",
+		"^schedules$",
+		"Show the scheduled modules.",
+		s.schedulesHandler),
+		readline.PcItem("schedules"))
+
 	s.addHandler(NewCommandHandler("! COMMAND",
 		"^!\\s*(.+)$",
 		"Execute a shell command and print its output.",
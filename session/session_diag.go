@@ -0,0 +1,110 @@
+package session
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/evilsocket/bettercap-ng/core"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Serves the profiler on its own mux, so it's never exposed by modules
+// serving the default one.
+func (s *Session) startPprof(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("/debug/pprof/", pprof.Index)
+	router.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	router.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	router.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	router.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	s.Events.Log(core.INFO, "Profiler available on http://%s/debug/pprof/", listener.Addr())
+
+	go http.Serve(listener, router)
+
+	return nil
+}
+
+func (s *Session) diagHandler(args []string, sess *Session) error {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	lastGC := "never"
+	if mem.NumGC > 0 {
+		lastGC = fmt.Sprintf("%s, paused %s",
+			humanize.Time(time.Unix(0, int64(mem.LastGC))),
+			time.Duration(mem.PauseNs[(mem.NumGC+255)%256]))
+	}
+
+	rows := [][]string{
+		{"Go", runtime.Version()},
+		{"Goroutines", fmt.Sprintf("%d", runtime.NumGoroutine())},
+		{"CPUs", fmt.Sprintf("%d (GOMAXPROCS %d)", runtime.NumCPU(), runtime.GOMAXPROCS(0))},
+		{"Heap allocated", humanize.Bytes(mem.HeapAlloc)},
+		{"Heap in use", humanize.Bytes(mem.HeapInuse)},
+		{"Heap objects", fmt.Sprintf("%d", mem.HeapObjects)},
+		{"Obtained from the OS", humanize.Bytes(mem.Sys)},
+		{"GC cycles", fmt.Sprintf("%d", mem.NumGC)},
+		{"Last GC", lastGC},
+	}
+
+	if fds, sockets, err := openDescriptors(); err == nil {
+		rows = append(rows, []string{"Open files", fmt.Sprintf("%d", fds)})
+		rows = append(rows, []string{"Open sockets", fmt.Sprintf("%d", sockets)})
+	}
+
+	rows = append(rows, []string{"Events dropped", fmt.Sprintf("%d", s.Events.Dropped())})
+	rows = append(rows, []string{"Log lines dropped", fmt.Sprintf("%d", s.Events.LogsDropped())})
+
+	if *s.Options.Pprof != "" {
+		rows = append(rows, []string{"Profiler", fmt.Sprintf("http://%s/debug/pprof/", *s.Options.Pprof)})
+	}
+
+	fmt.Println()
+	core.AsTable(os.Stdout, []string{"Runtime", "Value"}, rows)
+
+	counters := make([][]string, 0)
+	for _, m := range s.Modules {
+		provider, ok := m.(MetricsProvider)
+		if ok == false {
+			continue
+		}
+
+		for _, metric := range provider.Metrics() {
+			labels := make([]string, 0)
+			for name, value := range metric.Labels {
+				labels = append(labels, fmt.Sprintf("%s=%s", name, value))
+			}
+			sort.Strings(labels)
+
+			counters = append(counters, []string{
+				core.Bold(m.Name()),
+				metric.Name,
+				strings.Join(labels, " "),
+				fmt.Sprintf("%.0f", metric.Value),
+			})
+		}
+	}
+
+	if len(counters) > 0 {
+		fmt.Println()
+		core.AsTable(os.Stdout, []string{"Module", "Counter", "Labels", "Value"}, counters)
+	}
+
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// A property of a host which differs from the saved session.
+type TargetChange struct {
+	IpAddress string `json:"ipv4"`
+	HwAddress string `json:"mac"`
+	Field     string `json:"field"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+}
+
+// Differences between the hosts of a saved session and the ones
+// known to the current one, hosts are matched by hardware address.
+type SessionDiff struct {
+	New     []SessionTarget `json:"new"`
+	Lost    []SessionTarget `json:"lost"`
+	Changed []TargetChange  `json:"changed"`
+}
+
+func (d SessionDiff) Empty() bool {
+	return len(d.New) == 0 && len(d.Lost) == 0 && len(d.Changed) == 0
+}
+
+func diffTargets(saved []SessionTarget, current []SessionTarget) SessionDiff {
+	diff := SessionDiff{
+		New:     make([]SessionTarget, 0),
+		Lost:    make([]SessionTarget, 0),
+		Changed: make([]TargetChange, 0),
+	}
+
+	before := make(map[string]SessionTarget)
+	for _, t := range saved {
+		before[t.HwAddress] = t
+	}
+
+	after := make(map[string]SessionTarget)
+	for _, t := range current {
+		after[t.HwAddress] = t
+
+		prev, found := before[t.HwAddress]
+		if found == false {
+			diff.New = append(diff.New, t)
+			continue
+		}
+
+		if prev.IpAddress != t.IpAddress {
+			diff.Changed = append(diff.Changed, TargetChange{t.IpAddress, t.HwAddress, "ipv4", prev.IpAddress, t.IpAddress})
+		}
+		// a name not resolved yet is not a change
+		if prev.Hostname != t.Hostname && t.Hostname != "" {
+			diff.Changed = append(diff.Changed, TargetChange{t.IpAddress, t.HwAddress, "hostname", prev.Hostname, t.Hostname})
+		}
+	}
+
+	for _, t := range saved {
+		if _, found := after[t.HwAddress]; found == false {
+			diff.Lost = append(diff.Lost, t)
+		}
+	}
+
+	sort.Slice(diff.New, func(i, j int) bool { return diff.New[i].IpAddress < diff.New[j].IpAddress })
+	sort.Slice(diff.Lost, func(i, j int) bool { return diff.Lost[i].IpAddress < diff.Lost[j].IpAddress })
+
+	return diff
+}
+
+func loadState(filename string) (SessionState, error) {
+	var state SessionState
+
+	filename, err := core.ExpandPath(filename)
+	if err != nil {
+		return state, err
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return state, err
+	}
+
+	if err = json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("Error while parsing %s: %s", filename, err)
+	}
+
+	return state, nil
+}
+
+// Compares the hosts of a saved session with the ones discovered so
+// far, emits an event for each difference and prints them.
+func (s *Session) DiffState(saved SessionState) SessionDiff {
+	diff := diffTargets(saved.Targets, s.State().Targets)
+
+	for _, t := range diff.New {
+		s.Events.Add("session.diff.new", t)
+	}
+	for _, t := range diff.Lost {
+		s.Events.Add("session.diff.lost", t)
+	}
+	for _, c := range diff.Changed {
+		s.Events.Add("session.diff.changed", c)
+	}
+
+	if diff.Empty() == true {
+		fmt.Println(core.Dim("No changes since the saved session."))
+		return diff
+	}
+
+	fmt.Println()
+	for _, t := range diff.New {
+		fmt.Printf("  %s %-15s %s %s\n", core.Green("+"), t.IpAddress, t.HwAddress, core.Dim(t.Hostname))
+	}
+	for _, t := range diff.Lost {
+		fmt.Printf("  %s %-15s %s %s\n", core.Red("-"), t.IpAddress, t.HwAddress, core.Dim(t.Hostname))
+	}
+	for _, c := range diff.Changed {
+		fmt.Printf("  %s %-15s %s %s '%s' > '%s'\n", core.Yellow("~"), c.IpAddress, c.HwAddress, c.Field, c.Old, c.New)
+	}
+	fmt.Println()
+
+	s.Events.Log(core.INFO, "%d new, %d lost and %d changed hosts since the saved session.", len(diff.New), len(diff.Lost), len(diff.Changed))
+
+	return diff
+}
+
+func (s *Session) DiffStateFile(filename string) error {
+	state, err := loadState(filename)
+	if err != nil {
+		return err
+	}
+
+	s.DiffState(state)
+	return nil
+}
@@ -0,0 +1,32 @@
+package session
+
+import (
+	"github.com/evilsocket/bettercap-ng/core"
+	"github.com/evilsocket/bettercap-ng/firewall"
+	"github.com/evilsocket/bettercap-ng/net"
+	"github.com/evilsocket/bettercap-ng/packets"
+)
+
+// Replaces the firewall, the packet injection and the interfaces
+// changes with versions that only report what they would do.
+func (s *Session) setupDryRun() {
+	report := func(format string, args ...interface{}) {
+		s.Events.Log(core.WARNING, format, args...)
+	}
+
+	s.Firewall = firewall.MakeDryRun(s.Firewall.IsForwardingEnabled(), report)
+	net.SetDryRun(report)
+
+	// spoofers keep sending the same packets, only report new ones
+	seen := make(map[string]bool)
+	s.queueDryRun = func(raw []byte) {
+		desc := packets.Describe(raw)
+		if _, found := seen[desc]; found == false {
+			seen[desc] = true
+			report("[dry-run] Would send %s.", desc)
+		}
+	}
+	s.Queue.SetDryRun(s.queueDryRun)
+
+	report("Running in dry-run mode, no changes will be applied to the network.")
+}
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+
+	"github.com/dustin/go-humanize"
+)
+
+// Returns the number of open file descriptors and sockets of the
+// process, or an error if /proc is not available.
+func openDescriptors() (fds int, sockets int, err error) {
+	entries, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, entry := range entries {
+		fds++
+		if target, err := os.Readlink(filepath.Join("/proc/self/fd", entry.Name())); err == nil && strings.HasPrefix(target, "socket:") {
+			sockets++
+		}
+	}
+
+	return fds, sockets, nil
+}
+
+// Parameters of the module whose value is not the default one.
+func (s *Session) changedParams(m Module) []string {
+	changed := make([]string, 0)
+	for name, p := range m.Parameters() {
+		if _, value := s.Env.Get(name); value != p.Value {
+			changed = append(changed, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Also registered as "active", in which case only the running modules are listed.
+func (s *Session) modulesShowHandler(args []string, sess *Session) error {
+	onlyRunning := args[0] == "active"
+	goroutines := ModuleGoroutines()
+	memory := ModuleMemory(s.Modules)
+
+	rows := make([][]string, 0)
+	for _, m := range s.Modules {
+		status := core.Red("not running")
+		if m.Running() == true {
+			status = core.Green("running")
+		} else if onlyRunning == true {
+			continue
+		}
+
+		rows = append(rows, []string{
+			core.Bold(m.Name()),
+			status,
+			fmt.Sprintf("%d", goroutines[m.Name()]),
+			humanize.Bytes(memory[m.Name()]),
+			strings.Join(s.changedParams(m), "\n"),
+		})
+	}
+
+	fmt.Println()
+	core.AsTable(os.Stdout, []string{"Module", "Status", "Goroutines", "Memory", "Changed Parameters"}, rows)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	// descriptors can't be accounted to the module that opened them
+	resources := [][]string{
+		{"Goroutines", fmt.Sprintf("%d", runtime.NumGoroutine())},
+		{"Memory", humanize.Bytes(mem.Alloc)},
+	}
+
+	if fds, sockets, err := openDescriptors(); err == nil {
+		resources = append(resources, []string{"Open files", fmt.Sprintf("%d", fds)})
+		resources = append(resources, []string{"Open sockets", fmt.Sprintf("%d", sockets)})
+	}
+
+	fmt.Println()
+	core.AsTable(os.Stdout, []string{"Process Resource", "Usage"}, resources)
+	fmt.Println()
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package session
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/evilsocket/bettercap-ng/core"
+)
+
+// Variables that depend on the current network and that
+// should never be carried to another session.
+var volatileVariables = []string{
+	"iface.",
+	"gateway.",
+}
+
+type SessionTarget struct {
+	IpAddress string `json:"ipv4"`
+	HwAddress string `json:"mac"`
+	Hostname  string `json:"hostname"`
+}
+
+type SessionState struct {
+	Env     map[string]string `json:"env"`
+	Aliases map[string]string `json:"aliases"`
+	Targets []SessionTarget   `json:"targets"`
+	Modules []string          `json:"modules"`
+}
+
+func isVolatileVariable(name string) bool {
+	for _, prefix := range volatileVariables {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Session) State() SessionState {
+	state := SessionState{
+		Env:     make(map[string]string),
+		Aliases: make(map[string]string),
+		Targets: make([]SessionTarget, 0),
+		Modules: make([]string, 0),
+	}
+
+	for _, name := range s.Env.Sorted() {
+		if isVolatileVariable(name) == false {
+			_, state.Env[name] = s.Env.Get(name)
+		}
+	}
+
+	for _, name := range s.Aliases.Names() {
+		state.Aliases[name], _ = s.Aliases.Get(name)
+	}
+
+	for _, t := range s.Targets.List() {
+		state.Targets = append(state.Targets, SessionTarget{
+			IpAddress: t.IpAddress,
+			HwAddress: t.HwAddress,
+			Hostname:  t.Hostname,
+		})
+	}
+
+	for _, m := range s.Modules {
+		if m.Running() == true {
+			state.Modules = append(state.Modules, m.Name())
+		}
+	}
+
+	return state
+}
+
+func (s *Session) SaveState(filename string) error {
+	filename, err := core.ExpandPath(filename)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s.State(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err = ioutil.WriteFile(filename, data, 0600); err != nil {
+		return err
+	}
+
+	s.Events.Log(core.INFO, "Session saved to %s.", filename)
+	return nil
+}
+
+func (s *Session) RestoreState(filename string) error {
+	filename, err := core.ExpandPath(filename)
+	if err != nil {
+		return err
+	}
+
+	state, err := loadState(filename)
+	if err != nil {
+		return err
+	}
+
+	// before the saved targets are merged, and only if there's
+	// something to compare them with or they'd all be lost
+	if s.Targets.Len() > 0 {
+		s.DiffState(state)
+	}
+
+	for name, value := range state.Env {
+		if isVolatileVariable(name) == false {
+			s.Env.Set(name, value)
+		}
+	}
+
+	// the aliases file of the user is left as it is
+	for name, commands := range state.Aliases {
+		s.Aliases.SetVolatile(name, commands)
+	}
+
+	for _, t := range state.Targets {
+		s.Targets.AddIfNotExist(t.IpAddress, t.HwAddress)
+
+		if e, found := s.Targets.ByMAC(t.HwAddress); found == true && e.Hostname == "" {
+			e.Hostname = t.Hostname
+		}
+	}
+
+	for _, name := range state.Modules {
+		err, m := s.Module(name)
+		if err != nil {
+			s.Events.Log(core.WARNING, "%s", err)
+		} else if m.Running() == false {
+			if err = s.StartModule(m); err != nil {
+				s.Events.Log(core.ERROR, "Error while starting %s: %s", name, err)
+			}
+		}
+	}
+
+	s.Events.Log(core.INFO, "Session restored from %s.", filename)
+	return nil
+}
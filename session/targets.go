@@ -1,44 +1,89 @@
 package session
 
 import (
+	"encoding/json"
+	"hash/fnv"
 	"sync"
 
 	"github.com/evilsocket/bettercap-ng/net"
 )
 
-type Targets struct {
-	sync.Mutex
+// Endpoints are spread across shards, each with its own lock, so that
+// recon updates and lookups from the proxies and the sniffer on large
+// networks don't all contend for the same mutex.
+const targetsShards = 32
+
+type targetsShard struct {
+	sync.RWMutex
+	endpoints map[string]*net.Endpoint
+	ttl       map[string]uint
+}
 
+type Targets struct {
 	Session   *Session `json:"-"`
 	Interface *net.Endpoint
 	Gateway   *net.Endpoint
-	Targets   map[string]*net.Endpoint
-	TTL       map[string]uint
+
+	// indexed by MAC address, the primary key
+	byMAC [targetsShards]*targetsShard
+	// indexed by IP address
+	byIP [targetsShards]*targetsShard
 }
 
 func NewTargets(s *Session, iface, gateway *net.Endpoint) *Targets {
-	return &Targets{
+	tp := &Targets{
 		Session:   s,
 		Interface: iface,
 		Gateway:   gateway,
-		Targets:   make(map[string]*net.Endpoint),
-		TTL:       make(map[string]uint),
 	}
+
+	for i := 0; i < targetsShards; i++ {
+		tp.byMAC[i] = &targetsShard{
+			endpoints: make(map[string]*net.Endpoint),
+			ttl:       make(map[string]uint),
+		}
+		tp.byIP[i] = &targetsShard{
+			endpoints: make(map[string]*net.Endpoint),
+		}
+	}
+
+	return tp
+}
+
+func shardOf(shards [targetsShards]*targetsShard, key string) *targetsShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return shards[h.Sum32()%targetsShards]
 }
 
 func (tp *Targets) Remove(ip, mac string) {
-	tp.Lock()
-	defer tp.Unlock()
-
-	if e, found := tp.Targets[mac]; found {
-		tp.TTL[mac]--
-		if tp.TTL[mac] == 0 {
-			tp.Session.Events.Add("target.lost", e)
-			delete(tp.Targets, mac)
-			delete(tp.TTL, mac)
-		}
+	shard := shardOf(tp.byMAC, mac)
+	shard.Lock()
+
+	e, found := shard.endpoints[mac]
+	if found == false {
+		shard.Unlock()
 		return
 	}
+
+	shard.ttl[mac]--
+	if shard.ttl[mac] > 0 {
+		shard.Unlock()
+		return
+	}
+
+	delete(shard.endpoints, mac)
+	delete(shard.ttl, mac)
+	shard.Unlock()
+
+	ipShard := shardOf(tp.byIP, e.IpAddress)
+	ipShard.Lock()
+	if ipShard.endpoints[e.IpAddress] == e {
+		delete(ipShard.endpoints, e.IpAddress)
+	}
+	ipShard.Unlock()
+
+	tp.Session.Events.Add("target.lost", e)
 }
 
 func (tp *Targets) shouldIgnore(ip string) bool {
@@ -46,27 +91,59 @@ func (tp *Targets) shouldIgnore(ip string) bool {
 }
 
 func (tp *Targets) Has(ip string) bool {
-	tp.Lock()
-	defer tp.Unlock()
+	_, found := tp.ByIP(ip)
+	return found
+}
+
+func (tp *Targets) ByIP(ip string) (*net.Endpoint, bool) {
+	shard := shardOf(tp.byIP, ip)
+	shard.RLock()
+	defer shard.RUnlock()
+	e, found := shard.endpoints[ip]
+	return e, found
+}
 
-	for _, e := range tp.Targets {
-		if e.IpAddress == ip {
-			return true
+func (tp *Targets) ByMAC(mac string) (*net.Endpoint, bool) {
+	shard := shardOf(tp.byMAC, mac)
+	shard.RLock()
+	defer shard.RUnlock()
+	e, found := shard.endpoints[mac]
+	return e, found
+}
+
+// A snapshot of the known endpoints, in no particular order.
+func (tp *Targets) List() []*net.Endpoint {
+	list := make([]*net.Endpoint, 0)
+	for _, shard := range tp.byMAC {
+		shard.RLock()
+		for _, e := range shard.endpoints {
+			list = append(list, e)
 		}
+		shard.RUnlock()
 	}
+	return list
+}
 
-	return false
+func (tp *Targets) Len() int {
+	n := 0
+	for _, shard := range tp.byMAC {
+		shard.RLock()
+		n += len(shard.endpoints)
+		shard.RUnlock()
+	}
+	return n
 }
 
 func (tp *Targets) AddIfNotExist(ip, mac string) *net.Endpoint {
-	tp.Lock()
-	defer tp.Unlock()
-
 	if tp.shouldIgnore(ip) {
 		return nil
 	}
 
-	if t, found := tp.Targets[mac]; found {
+	shard := shardOf(tp.byMAC, mac)
+	shard.Lock()
+
+	if t, found := shard.endpoints[mac]; found {
+		shard.Unlock()
 		return t
 	}
 
@@ -75,10 +152,43 @@ func (tp *Targets) AddIfNotExist(ip, mac string) *net.Endpoint {
 		tp.Session.Events.Add("target.resolved", e)
 	}
 
-	tp.Targets[mac] = e
-	tp.TTL[mac] = 2
+	shard.endpoints[mac] = e
+	shard.ttl[mac] = 2
+	shard.Unlock()
+
+	ipShard := shardOf(tp.byIP, ip)
+	ipShard.Lock()
+	ipShard.endpoints[ip] = e
+	ipShard.Unlock()
 
 	tp.Session.Events.Add("target.new", e)
 
 	return nil
 }
+
+// Keeps the same layout the endpoints table had as a plain map.
+func (tp *Targets) MarshalJSON() ([]byte, error) {
+	targets := make(map[string]*net.Endpoint)
+	ttl := make(map[string]uint)
+
+	for _, shard := range tp.byMAC {
+		shard.RLock()
+		for mac, e := range shard.endpoints {
+			targets[mac] = e
+			ttl[mac] = shard.ttl[mac]
+		}
+		shard.RUnlock()
+	}
+
+	return json.Marshal(struct {
+		Interface *net.Endpoint
+		Gateway   *net.Endpoint
+		Targets   map[string]*net.Endpoint
+		TTL       map[string]uint
+	}{
+		Interface: tp.Interface,
+		Gateway:   tp.Gateway,
+		Targets:   targets,
+		TTL:       ttl,
+	})
+}